@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+
+	"file-storage-go/pkg/adapters/repository"
+	"file-storage-go/pkg/auth"
+	"file-storage-go/pkg/config"
+	"file-storage-go/pkg/database"
+	"file-storage-go/pkg/domain"
+	"file-storage-go/pkg/policy"
+)
+
+// Answers "can user U do action A on file F" for a policy file without running
+// the server. The user is described by the claims of an access token, given
+// either as the token itself or as its decoded JSON payload. The file is
+// either loaded from the database with -file-id or described by its file type
+// and linked resource, e.g. for uploads. Exits with 0 if the action is allowed
+// and 1 if it is denied.
+func main() {
+	policyPath := flag.String("policy", envOrDefault("POLICY_FILE", "policy.yaml"), "path to the policy file")
+	token := flag.String("token", "", "access token whose claims describe the user")
+	claimsPath := flag.String("claims", "", "JSON file with the token claims, - for stdin")
+	clientID := flag.String("client-id", envOrDefault("KEYCLOAK_CLIENT_ID", "file-storage"), "client whose client roles are included")
+	action := flag.String("action", "", "upload, read or delete")
+	fileID := flag.String("file-id", "", "file whose file info is loaded from the database")
+	fileType := flag.String("file-type", "", "file type")
+	linkedResourceType := flag.String("linked-resource-type", "", "linked resource type")
+	linkedResourceID := flag.String("linked-resource-id", "", "linked resource ID")
+	flag.Parse()
+
+	describesFile := *fileType != "" || *linkedResourceType != "" || *linkedResourceID != ""
+	if *action == "" || (*token == "") == (*claimsPath == "") || (*fileID != "" && describesFile) {
+		fmt.Fprintln(os.Stderr, "usage: policy-test -action ACTION (-token TOKEN | -claims FILE) (-file-id ID | [-file-type T] [-linked-resource-type T] [-linked-resource-id ID])")
+		os.Exit(2)
+	}
+
+	p, err := policy.Load(*policyPath)
+	if err != nil {
+		log.Fatalf("Failed to load policy: %v", err)
+	}
+
+	payload, err := readClaims(*token, *claimsPath)
+	if err != nil {
+		log.Fatalf("Failed to read claims: %v", err)
+	}
+
+	var claims auth.Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		log.Fatalf("Failed to decode claims: %v", err)
+	}
+
+	file := &domain.FileInfo{
+		FileType:           *fileType,
+		LinkedResourceType: *linkedResourceType,
+		LinkedResourceID:   *linkedResourceID,
+	}
+	if *fileID != "" {
+		file, err = loadFileInfo(*fileID)
+		if err != nil {
+			log.Fatalf("Failed to load file %s: %v", *fileID, err)
+		}
+	}
+
+	principal := claims.Principal(*clientID)
+	decision := p.Evaluate(policy.Input{
+		Principal:          principal,
+		Action:             domain.Permission(*action),
+		FileType:           file.FileType,
+		LinkedResourceType: file.LinkedResourceType,
+		LinkedResourceID:   file.LinkedResourceID,
+	})
+
+	target := fmt.Sprintf("%s of %s %s", file.FileType, file.LinkedResourceType, file.LinkedResourceID)
+	if *fileID != "" {
+		target = fmt.Sprintf("file %s (%s)", *fileID, target)
+	}
+	if !decision.Allowed {
+		fmt.Printf("DENY: user %q (roles %v) may not %s %s\n", principal.UserID, principal.Roles, *action, target)
+		os.Exit(1)
+	}
+	fmt.Printf("ALLOW: user %q may %s %s, granted by rule %q\n", principal.UserID, *action, target, decision.Rule)
+}
+
+// loadFileInfo reads the file info from the database configured like the
+// server's.
+func loadFileInfo(fileID string) (*domain.FileInfo, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	ctx := context.Background()
+	db, err := database.Open(ctx, cfg.GetDBConnString(), "", database.PoolConfig{MaxConns: 1}, slog.Default())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to postgres: %w", err)
+	}
+	defer db.Close()
+
+	fileInfo, err := repository.NewPostgresFileInfoRepo(db).Get(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if fileInfo == nil {
+		return nil, domain.ErrFileNotFound
+	}
+	return fileInfo, nil
+}
+
+// readClaims returns the JSON claims of the token, which is not verified, or
+// the contents of the claims file.
+func readClaims(token, claimsPath string) ([]byte, error) {
+	if token != "" {
+		parts := strings.Split(token, ".")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("token is not a JWT")
+		}
+		return base64.RawURLEncoding.DecodeString(parts[1])
+	}
+
+	if claimsPath == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(claimsPath)
+}
+
+func envOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
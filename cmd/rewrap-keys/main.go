@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"log"
+	"os"
+	"strings"
+
+	"file-storage-go/pkg/adapters/metrics"
+	"file-storage-go/pkg/adapters/storage"
+	"file-storage-go/pkg/adapters/vault"
+	"file-storage-go/pkg/config"
+	"file-storage-go/pkg/services/secrets"
+)
+
+// Re-wraps the data keys of the given file IDs with the latest version of the
+// Vault transit key. File IDs are taken from the arguments or, if none are
+// given, read line by line from stdin.
+func main() {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	accountName := cfg.BlobAccountName
+	if accountName == "" {
+		accountName = cfg.BlobStorageURL
+	}
+
+	blobStorage, err := storage.NewAzureBlobStorage(accountName, cfg.BlobStorageURL, cfg.StorageKey, cfg.ContainerName, metrics.NewPrometheusMetrics())
+	if err != nil {
+		log.Fatalf("Failed to initialize AzureBlobStorage client: %v", err)
+	}
+
+	vaultClient, err := secrets.NewAppRoleVaultClient(cfg.VaultAddress, cfg.VaultRoleID, cfg.VaultSecretID)
+	if err != nil {
+		log.Fatalf("Failed to create vault client: %v", err)
+	}
+
+	encryptingStorage := storage.NewEncryptingStorage(blobStorage, vault.NewTransitKeyProvider(vaultClient, cfg.VaultTransitMount, cfg.VaultTransitKey), cfg.AllowLegacyPlaintext)
+
+	fileIDs := os.Args[1:]
+	if len(fileIDs) == 0 {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if id := strings.TrimSpace(scanner.Text()); id != "" {
+				fileIDs = append(fileIDs, id)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			log.Fatalf("Failed to read file IDs: %v", err)
+		}
+	}
+
+	failed := 0
+	for _, fileID := range fileIDs {
+		if err := encryptingStorage.RewrapKey(context.Background(), fileID); err != nil {
+			log.Printf("Failed to rewrap key for file %s: %v", fileID, err)
+			failed++
+		}
+	}
+
+	log.Printf("Rewrapped %d of %d data keys", len(fileIDs)-failed, len(fileIDs))
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -1,35 +1,138 @@
 package server
 
 import (
+	"context"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
+	"time"
 
 	handlers "file-storage-go/pkg/adapters/http"
+	"file-storage-go/pkg/adapters/metrics"
+	"file-storage-go/pkg/adapters/webhook"
 	"file-storage-go/pkg/auth"
+	"file-storage-go/pkg/contenttype"
 	"file-storage-go/pkg/domain"
+	"file-storage-go/pkg/health"
 	"file-storage-go/pkg/middleware"
 
 	"github.com/gin-gonic/gin"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// RouteScopes lists the OAuth scopes a token needs for each group of routes.
+// An empty list disables the check for that group.
+type RouteScopes struct {
+	Read  []string
+	Write []string
+	Admin []string
+	Audit []string
+}
+
+// RouteRateLimits limits the requests of each client per group of routes, and
+// how many uploads and downloads each client runs at once. Zero values
+// disable a limit.
+type RouteRateLimits struct {
+	Read                domain.RateLimit
+	Write               domain.RateLimit
+	Upload              domain.RateLimit
+	ConcurrentUploads   int
+	ConcurrentDownloads int
+}
+
 type ServerConfig struct {
 	FileStorage          domain.FileStorage
 	JobRepo              domain.UploadJobRepository
+	Scanner              domain.ScannerControl
 	FileInfoRepo         domain.FileInfoRepository
+	UnitOfWork           domain.UnitOfWork
 	FileAuthorization    domain.FileAuthorization
+	FileGrants           domain.FileGrantRepository
+	APIKeys              domain.APIKeyRepository
+	AuditLog             domain.AuditLog
+	Webhooks             domain.WebhookRepository
+	WebhookDispatcher    *webhook.Dispatcher
+	WebhookAllowedHosts  []string
+	JobWatcher           domain.JobWatcher
+	IdempotencyKeys      domain.IdempotencyStore
+	IdempotencyKeyTTL    time.Duration
+	RateLimiter          domain.RateLimiter
+	RateLimits           RouteRateLimits
+	Thumbnails           domain.ThumbnailStore
+	SearchIndex          domain.FileSearchIndex
+	Readiness            *health.Checker
+	ServiceName          string
+	Metrics              *metrics.PrometheusMetrics
 	KeycloakURL          string
 	KeycloakClientID     string
+	KeycloakIssuer       string
+	KeycloakAudience     string
+	JWTClockSkew         time.Duration
 	UseMockAuthorization bool
-	Logger           *slog.Logger
+	AdminRole            string
+	AdminUserIDs         []string
+	AuditorRole          string
+	AuditorUserIDs       []string
+	TrustedProxies       []string
+	Scopes               RouteScopes
+	ContentTypePolicy    contenttype.MismatchPolicy
+	Logger               *slog.Logger
+	LogLevel             *slog.LevelVar
+}
+
+// Validate returns an error naming the required dependencies that are not set.
+func (config ServerConfig) Validate() error {
+	required := []struct {
+		name string
+		set  bool
+	}{
+		{"FileStorage", config.FileStorage != nil},
+		{"JobRepo", config.JobRepo != nil},
+		{"FileInfoRepo", config.FileInfoRepo != nil},
+		{"UnitOfWork", config.UnitOfWork != nil},
+		{"FileAuthorization", config.FileAuthorization != nil},
+		{"IdempotencyKeys", config.IdempotencyKeys != nil},
+		{"RateLimiter", config.RateLimiter != nil},
+		{"SearchIndex", config.SearchIndex != nil},
+		{"Readiness", config.Readiness != nil},
+		{"Metrics", config.Metrics != nil},
+		{"Logger", config.Logger != nil},
+	}
+
+	var missing []string
+	for _, dependency := range required {
+		if !dependency.set {
+			missing = append(missing, dependency.name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing dependencies: %s", strings.Join(missing, ", "))
+	}
+
+	if err := gin.New().SetTrustedProxies(config.TrustedProxies); err != nil {
+		return fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	return nil
 }
 
 func SetupRouter(config ServerConfig) *gin.Engine {
-	h := handlers.NewHandlers(config.FileStorage, config.JobRepo, config.FileInfoRepo, config.FileAuthorization)
+	h := handlers.NewHandlers(config.FileStorage, config.JobRepo, config.FileInfoRepo, config.UnitOfWork, config.FileAuthorization, config.ContentTypePolicy, config.Thumbnails, config.Webhooks, config.WebhookAllowedHosts)
+	sh := handlers.NewSearchHandlers(config.SearchIndex, config.FileInfoRepo, config.FileAuthorization)
 
 	// Create a new Gin engine without any default middleware
 	r := gin.New()
 
+	// The client IP of logs, audit events and rate limits is only taken from
+	// X-Forwarded-For when the request comes from a trusted proxy. Validate
+	// has checked the addresses.
+	if err := r.SetTrustedProxies(config.TrustedProxies); err != nil {
+		config.Logger.Error("Invalid trusted proxies", "error", err)
+	}
+
+	r.Use(middleware.RequestID())
+	r.Use(middleware.Tracing(config.ServiceName))
+	r.Use(middleware.Metrics(config.Metrics))
+
 	// Use our custom ECS logger middleware
 	r.Use(middleware.GinLoggerMiddleware(config.Logger))
 
@@ -39,36 +142,142 @@ func SetupRouter(config ServerConfig) *gin.Engine {
 	// Disable Gin's debug output
 	gin.SetMode(gin.ReleaseMode)
 
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "up"})
+	// Liveness only says that the process serves requests; restarting it does
+	// not fix an unreachable dependency.
+	live := func(c *gin.Context) {
+		c.JSON(http.StatusOK, health.Report{Status: health.StatusUp, Components: map[string]health.ComponentStatus{}})
+	}
+	r.GET("/health", live)
+	r.GET("/livez", live)
+	r.GET("/readyz", func(c *gin.Context) {
+		report := config.Readiness.Check(c.Request.Context())
+		status := http.StatusOK
+		if report.Status != health.StatusUp {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, report)
 	})
 
-	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	r.GET("/metrics", gin.WrapH(config.Metrics.Handler()))
 
 	var jwtVerifier auth.JWTVerifierInterface
 	if config.UseMockAuthorization {
 		config.Logger.Info("Using MockJWTVerifier because UseMockAuthorization is set to true.")
 		jwtVerifier = auth.NewMockJWTVerifier()
 	} else {
-		jwtVerifier = auth.NewJWTVerifier(auth.KeycloakConfig{
-			RealmURL: config.KeycloakURL,
-			ClientID: config.KeycloakClientID,
+		keycloakVerifier := auth.NewJWTVerifier(auth.KeycloakConfig{
+			RealmURL:  config.KeycloakURL,
+			ClientID:  config.KeycloakClientID,
+			Issuer:    config.KeycloakIssuer,
+			Audience:  config.KeycloakAudience,
+			ClockSkew: config.JWTClockSkew,
+			Metrics:   config.Metrics,
+			Logger:    config.Logger,
 		})
+		keycloakVerifier.Start(context.Background())
+		config.Readiness.Register("keycloak", keycloakVerifier.Check)
+		jwtVerifier = keycloakVerifier
+	}
+
+	var apiKeyAuthenticator auth.APIKeyAuthenticator
+	if config.APIKeys != nil {
+		apiKeyAuthenticator = auth.NewAPIKeyVerifier(config.APIKeys, config.Logger)
 	}
 
+	// Denials by the authentication, scope, role and rate limit checks below
+	// are recorded here, since they end requests before a route's audit.
+	r.Use(middleware.AuditDenials(config.AuditLog, config.Logger))
+
 	// Apply auth middleware to all routes except health and metrics
 	r.Use(middleware.NewAuthMiddleware(middleware.AuthMiddlewareConfig{
-		JWTVerifier: jwtVerifier,
+		JWTVerifier:         jwtVerifier,
+		APIKeyAuthenticator: apiKeyAuthenticator,
+		ClientID:            config.KeycloakClientID,
+		Logger:              config.Logger,
 	}))
 
-	r.Use(middleware.RequireUserId())
+	r.Use(middleware.RequireUserId(config.Logger))
+
+	rateLimit := func(group string, limit domain.RateLimit) gin.HandlerFunc {
+		return middleware.RateLimit(config.RateLimiter, group, limit, config.Logger)
+	}
 
-	r.POST("/upload-jobs", h.CreateUploadJob)
-	r.GET("/upload-jobs/:jobId", h.GetUploadJobStatus)
-	r.POST("/upload-jobs/:jobId", h.UploadFile)
-	r.GET("/files/:fileId", h.GetFileInfo)
-	r.GET("/files/:fileId/download", h.DownloadFile)
-	r.DELETE("/files/:fileId", h.DeleteFile)
+	read := r.Group("", middleware.RequireScopes(config.Logger, config.Scopes.Read...), rateLimit("read", config.RateLimits.Read))
+	write := r.Group("", middleware.RequireScopes(config.Logger, config.Scopes.Write...), rateLimit("write", config.RateLimits.Write))
+	uploads := middleware.ConcurrencyLimit("upload", config.RateLimits.ConcurrentUploads, config.Logger)
+	downloads := middleware.ConcurrencyLimit("download", config.RateLimits.ConcurrentDownloads, config.Logger)
+	// Job status and events are polled right after a write, so only the file
+	// reads, which tolerate replication lag, may use the replica.
+	replica := middleware.ReplicaReads()
+
+	audit := func(action domain.AuditAction) gin.HandlerFunc {
+		return middleware.Audit(config.AuditLog, action, config.Logger)
+	}
+
+	idempotent := middleware.Idempotency(config.IdempotencyKeys, config.IdempotencyKeyTTL, config.Logger)
+
+	write.POST("/upload-jobs", idempotent, audit(domain.AuditJobCreated), h.CreateUploadJob)
+	if config.JobWatcher != nil {
+		jeh := handlers.NewJobEventHandlers(config.JobRepo, config.JobWatcher)
+		read.GET("/upload-jobs/:jobId", jeh.WaitForJobStatus, h.GetUploadJobStatus)
+		read.GET("/upload-jobs/:jobId/events", jeh.StreamJobEvents)
+	} else {
+		read.GET("/upload-jobs/:jobId", h.GetUploadJobStatus)
+	}
+	write.POST("/upload-jobs/:jobId", rateLimit("upload", config.RateLimits.Upload), uploads, idempotent, audit(domain.AuditFileUploaded), h.UploadFile)
+	read.GET("/files/search", replica, sh.SearchFiles)
+	read.GET("/files/:fileId", replica, audit(domain.AuditFileMetadataRead), h.GetFileInfo)
+	read.GET("/files/:fileId/download", replica, downloads, audit(domain.AuditFileDownloaded), h.DownloadFile)
+	read.GET("/files/:fileId/thumbnail", replica, audit(domain.AuditFileDownloaded), h.GetThumbnail)
+	write.DELETE("/files/:fileId", audit(domain.AuditFileDeleted), h.DeleteFile)
+
+	admin := r.Group("/admin", middleware.RequireScopes(config.Logger, config.Scopes.Admin...), middleware.RequireAdmin(config.AdminRole, config.AdminUserIDs, config.Logger))
+	if config.Scanner != nil {
+		jh := handlers.NewJobAdminHandlers(config.JobRepo, config.UnitOfWork, config.Scanner)
+		admin.GET("/jobs", jh.ListJobs)
+		admin.POST("/jobs/purge", jh.PurgeJobs)
+		admin.POST("/jobs/:jobId/rescan", jh.RescanJob)
+		admin.POST("/jobs/:jobId/requeue", jh.RequeueJob)
+		admin.POST("/jobs/:jobId/fail", jh.FailJob)
+		admin.GET("/scanner", jh.GetScannerState)
+		admin.POST("/scanner/pause", jh.PauseScanner)
+		admin.POST("/scanner/resume", jh.ResumeScanner)
+	}
+	if config.FileGrants != nil {
+		ah := handlers.NewAdminHandlers(config.FileGrants)
+		admin.GET("/grants", ah.ListGrants)
+		admin.POST("/grants", audit(domain.AuditAuthorizationChanged), ah.CreateGrant)
+		admin.DELETE("/grants/:grantId", audit(domain.AuditAuthorizationChanged), ah.DeleteGrant)
+		admin.GET("/groups/:groupId/members", ah.ListGroupMembers)
+		admin.PUT("/groups/:groupId/members/:userId", audit(domain.AuditAuthorizationChanged), ah.AddGroupMember)
+		admin.DELETE("/groups/:groupId/members/:userId", audit(domain.AuditAuthorizationChanged), ah.RemoveGroupMember)
+	}
+	if config.APIKeys != nil {
+		kh := handlers.NewAPIKeyHandlers(config.APIKeys)
+		admin.GET("/api-keys", kh.ListAPIKeys)
+		admin.POST("/api-keys", audit(domain.AuditAuthorizationChanged), kh.CreateAPIKey)
+		admin.DELETE("/api-keys/:keyId", audit(domain.AuditAuthorizationChanged), kh.RevokeAPIKey)
+	}
+	if config.Webhooks != nil && config.WebhookDispatcher != nil {
+		wh := handlers.NewWebhookHandlers(config.Webhooks, config.WebhookDispatcher, config.WebhookAllowedHosts)
+		admin.GET("/webhooks/subscriptions", wh.ListSubscriptions)
+		admin.POST("/webhooks/subscriptions", wh.CreateSubscription)
+		admin.DELETE("/webhooks/subscriptions/:subscriptionId", wh.DeleteSubscription)
+		admin.GET("/webhooks/deliveries", wh.ListDeliveries)
+		admin.POST("/webhooks/deliveries/:deliveryId/redeliver", wh.Redeliver)
+	}
+	if config.LogLevel != nil {
+		lh := handlers.NewLogLevelHandlers(config.LogLevel, config.Logger)
+		admin.GET("/log-level", lh.GetLogLevel)
+		admin.PUT("/log-level", lh.SetLogLevel)
+	}
+
+	if config.AuditLog != nil {
+		auh := handlers.NewAuditHandlers(config.AuditLog, config.Logger)
+		auditors := r.Group("/audit", middleware.RequireScopes(config.Logger, config.Scopes.Audit...), middleware.RequireAuditor(config.AuditorRole, config.AuditorUserIDs, config.Logger))
+		auditors.GET("/events", auh.QueryEvents)
+		auditors.GET("/events/export", auh.ExportEvents)
+	}
 
 	return r
 }
@@ -3,55 +3,96 @@ package main
 import (
 	"context"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"file-storage-go/cmd/server"
+	"file-storage-go/pkg/adapters/authorization"
 	"file-storage-go/pkg/adapters/jobrunner"
 	"file-storage-go/pkg/adapters/metrics"
+	"file-storage-go/pkg/adapters/outbox"
 	"file-storage-go/pkg/adapters/repository"
 	"file-storage-go/pkg/adapters/storage"
+	"file-storage-go/pkg/adapters/textextract"
+	"file-storage-go/pkg/adapters/thumbnail"
+	"file-storage-go/pkg/adapters/vault"
 	"file-storage-go/pkg/adapters/viruschecker"
+	"file-storage-go/pkg/adapters/webhook"
 	"file-storage-go/pkg/config"
+	"file-storage-go/pkg/contenttype"
+	"file-storage-go/pkg/database"
 	"file-storage-go/pkg/domain"
+	"file-storage-go/pkg/health"
 	"file-storage-go/pkg/loginit"
+	"file-storage-go/pkg/policy"
+	"file-storage-go/pkg/services/secrets"
+	"file-storage-go/pkg/tracing"
 )
 
 func main() {
 	// Initialize the ECS logger
-	logger := loginit.InitLogger()
+	logger, logLevel, err := loginit.InitLogger()
+	if err != nil {
+		logger.Error("Invalid LOG_LEVEL", "error", err)
+		os.Exit(1)
+	}
 
+	// LoadConfig fills in the defaults and only loads the storage credentials
+	// without USE_MOCK_STORAGE, so any error is fatal.
 	cfg, err := config.LoadConfig()
 	if err != nil {
-		if os.Getenv("USE_MOCK_STORAGE") != "true" {
-			logger.Error("Failed to load config", "error", err)
-			os.Exit(1)
-		}
-		if cfg == nil {
-			logger.Warn("Config loading failed, but USE_MOCK_STORAGE is true. Proceeding with potentially incomplete config for mocks.")
-			cfg = &config.Config{ServerPort: "8080"}
-		}
+		logger.Error("Failed to load config", "error", err)
+		os.Exit(1)
+	}
+
+	traceSampleRatio, err := strconv.ParseFloat(cfg.TraceSampleRatio, 64)
+	if err != nil {
+		logger.Error("Invalid OTEL_TRACES_SAMPLER_ARG format", "error", err)
+		os.Exit(1)
+	}
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    cfg.TracesExporter,
+		ServiceName: cfg.ServiceName,
+		SampleRatio: traceSampleRatio,
+	})
+	if err != nil {
+		logger.Error("Invalid OTEL_TRACES_EXPORTER", "error", err)
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
+
+	healthCheckTimeout, err := time.ParseDuration(cfg.HealthCheckTimeout)
+	if err != nil {
+		logger.Error("Invalid HEALTH_CHECK_TIMEOUT format", "error", err)
+		os.Exit(1)
+	}
+	healthCheckCacheTTL, err := time.ParseDuration(cfg.HealthCheckCacheTTL)
+	if err != nil {
+		logger.Error("Invalid HEALTH_CHECK_CACHE_TTL format", "error", err)
+		os.Exit(1)
 	}
+	readiness := health.NewChecker(healthCheckTimeout, healthCheckCacheTTL)
 
 	metricsCollector := metrics.NewPrometheusMetrics()
-	var fileStorage domain.FileStorage
+	var blobStorage domain.MetadataFileStorage
 
 	if os.Getenv("USE_MOCK_STORAGE") == "true" {
 		logger.Info("Using MockStorage because USE_MOCK_STORAGE is set to true.")
-		fileStorage = storage.NewMockStorage()
+		blobStorage = storage.NewMockStorage()
 	} else {
 		if cfg.BlobStorageURL == "" {
 			logger.Error("BLOB_STORAGE_URL is required when not using mock storage.")
 			os.Exit(1)
 		}
 
-		var azureStorageErr error
 		accountNameForCreds := cfg.BlobAccountName
 		if accountNameForCreds == "" {
 			logger.Warn("BlobAccountName is not set. This is fine for Azurite if BlobStorageURL is the Azurite URL. For real Azure, ensure BlobAccountName is configured.")
 			accountNameForCreds = cfg.BlobStorageURL
 		}
 
-		fileStorage, azureStorageErr = storage.NewAzureBlobStorage(
+		azureStorage, azureStorageErr := storage.NewAzureBlobStorage(
 			accountNameForCreds,
 			cfg.BlobStorageURL,
 			cfg.StorageKey,
@@ -62,27 +103,289 @@ func main() {
 			logger.Error("Failed to initialize AzureBlobStorage client", "error", azureStorageErr)
 			os.Exit(1)
 		}
+		readiness.Register("blobStorage", azureStorage.Check)
+		blobStorage = azureStorage
+	}
+
+	if cfg.EncryptionEnabled {
+		vaultClient, err := secrets.NewAppRoleVaultClient(cfg.VaultAddress, cfg.VaultRoleID, cfg.VaultSecretID)
+		if err != nil {
+			logger.Error("Failed to create vault client for encryption", "error", err)
+			os.Exit(1)
+		}
+		logger.Info("Encrypting files at rest with Vault transit key", "mount", cfg.VaultTransitMount, "key", cfg.VaultTransitKey)
+		if cfg.AllowLegacyPlaintext {
+			logger.Warn("Serving files without a key envelope as plaintext")
+		}
+		keyProvider := vault.NewTransitKeyProvider(vaultClient, cfg.VaultTransitMount, cfg.VaultTransitKey)
+		blobStorage = storage.NewEncryptingStorage(blobStorage, keyProvider, cfg.AllowLegacyPlaintext)
+	}
+
+	var fileStorage domain.FileStorage = blobStorage
+	if cfg.CompressionEnabled {
+		logger.Info("Compressing files at rest", "contentTypes", cfg.CompressionTypes)
+		fileStorage = storage.NewCompressingStorage(blobStorage, strings.Split(cfg.CompressionTypes, ","))
 	}
 
 	var jobRepo domain.UploadJobRepository
 	var fileInfoRepo domain.FileInfoRepository
+	var unitOfWork domain.UnitOfWork
+	var searchIndex domain.FileSearchIndex
+	var apiKeys domain.APIKeyRepository
+	var auditLog domain.AuditLog
+	var webhooks domain.WebhookRepository
+	var eventOutbox domain.Outbox
+	var jobWatcher domain.JobWatcher
+	var idempotencyKeys domain.IdempotencyStore
+	var db *database.DB
+	var inMemoryJobRepo *repository.InMemoryJobRepo
+	var inMemoryFileInfoRepo *repository.InMemoryFileInfoRepo
 	if cfg.UseInMemoryRepo {
-		logger.Info("Using InMemoryJobRepo because USE_IN_MEMORY_REPO is set to true.")
-		jobRepo = repository.NewInMemoryJobRepo()
+		inMemoryOutbox := repository.NewInMemoryOutbox()
+		eventOutbox = inMemoryOutbox
 		logger.Info("Using InMemoryFileInfoRepo because USE_IN_MEMORY_REPO is set to true.")
-		fileInfoRepo = repository.NewInMemoryFileInfoRepo()
+		inMemoryFileInfoRepo = repository.NewInMemoryFileInfoRepoWithOutbox(inMemoryOutbox)
+		fileInfoRepo = inMemoryFileInfoRepo
+		logger.Info("Using InMemoryJobRepo because USE_IN_MEMORY_REPO is set to true.")
+		inMemoryJobRepo = repository.NewInMemoryJobRepoWithOutbox(inMemoryOutbox, inMemoryFileInfoRepo)
+		jobRepo, jobWatcher = inMemoryJobRepo, inMemoryJobRepo
+		searchIndex = repository.NewInMemorySearchIndex()
+		apiKeys = repository.NewInMemoryAPIKeyRepo()
+		auditLog = repository.NewInMemoryAuditLog()
+		webhooks = repository.NewInMemoryWebhookRepo()
+		idempotencyKeys = repository.NewInMemoryIdempotencyStore()
 	} else {
-		jobRepo, err = repository.NewPostgresJobRepo(cfg.GetDBConnString())
+		dbConnMaxLifetime, err := time.ParseDuration(cfg.DBConnMaxLifetime)
+		if err != nil {
+			logger.Error("Invalid DB_CONN_MAX_LIFETIME format", "error", err)
+			os.Exit(1)
+		}
+		dbStatementTimeout, err := time.ParseDuration(cfg.DBStatementTimeout)
 		if err != nil {
-			logger.Error("Failed to create postgres job repository", "error", err)
+			logger.Error("Invalid DB_STATEMENT_TIMEOUT format", "error", err)
+			os.Exit(1)
 		}
-		fileInfoRepo, err = repository.NewPostgresFileInfoRepo(cfg.GetDBConnString())
+		db, err = database.Open(context.Background(), cfg.GetDBConnString(), cfg.DBReplicaDSN, database.PoolConfig{
+			MaxConns:         int32(cfg.DBMaxConns),
+			MinConns:         int32(cfg.DBMinConns),
+			MaxConnLifetime:  dbConnMaxLifetime,
+			StatementTimeout: dbStatementTimeout,
+		}, logger)
 		if err != nil {
-			logger.Error("Failed to create postgres file info repository", "error", err)
+			logger.Error("Failed to connect to postgres", "error", err)
+			os.Exit(1)
 		}
+		if cfg.DBReplicaDSN != "" {
+			logger.Info("Routing read-only queries to the read replica")
+			go db.MonitorReplica(context.Background(), 10*time.Second)
+		}
+
+		readiness.Register("postgres", db.Primary().Ping)
+
+		jobRepo = repository.NewPostgresJobRepo(db)
+		postgresJobWatcher := repository.NewPostgresJobWatcher(cfg.GetDBConnString(), logger)
+		go postgresJobWatcher.Start(context.Background())
+		jobWatcher = postgresJobWatcher
+		fileInfoRepo = repository.NewPostgresFileInfoRepo(db)
+		searchIndex = repository.NewPostgresSearchIndex(db, cfg.SearchLanguage)
+		apiKeys = repository.NewPostgresAPIKeyRepo(db)
+		auditLog = repository.NewPostgresAuditLog(db)
+		webhooks = repository.NewPostgresWebhookRepo(db)
+		eventOutbox = repository.NewPostgresOutbox(db)
+		idempotencyKeys = repository.NewPostgresIdempotencyStore(db)
 	}
 
-	fileAuthorization := repository.NewMockFileAuthorization()
+	idempotencyKeyTTL, err := time.ParseDuration(cfg.IdempotencyKeyTTL)
+	if err != nil {
+		logger.Error("Invalid IDEMPOTENCY_KEY_TTL format", "error", err)
+		os.Exit(1)
+	}
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			deleted, err := idempotencyKeys.DeleteExpired(context.Background(), time.Now())
+			if err != nil {
+				logger.Error("Failed to delete expired idempotency keys", "error", err)
+				continue
+			}
+			if deleted > 0 {
+				logger.Info("Deleted expired idempotency keys", "count", deleted)
+			}
+		}
+	}()
+
+	var rateLimiter domain.RateLimiter
+	switch cfg.RateLimitStore {
+	case "memory":
+		rateLimiter = repository.NewInMemoryRateLimiter()
+	case "postgres":
+		if db == nil {
+			logger.Error("RATE_LIMIT_STORE postgres requires USE_IN_MEMORY_REPO to be false")
+			os.Exit(1)
+		}
+		logger.Info("Sharing rate limits between replicas in Postgres")
+		rateLimiter = repository.NewPostgresRateLimiter(db)
+	default:
+		logger.Error("Invalid RATE_LIMIT_STORE, expected memory or postgres", "value", cfg.RateLimitStore)
+		os.Exit(1)
+	}
+	rateLimitRead, err := domain.ParseRateLimit(cfg.RateLimitRead)
+	if err != nil {
+		logger.Error("Invalid RATE_LIMIT_READ format", "error", err)
+		os.Exit(1)
+	}
+	rateLimitWrite, err := domain.ParseRateLimit(cfg.RateLimitWrite)
+	if err != nil {
+		logger.Error("Invalid RATE_LIMIT_WRITE format", "error", err)
+		os.Exit(1)
+	}
+	rateLimitUpload, err := domain.ParseRateLimit(cfg.RateLimitUpload)
+	if err != nil {
+		logger.Error("Invalid RATE_LIMIT_UPLOAD format", "error", err)
+		os.Exit(1)
+	}
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := rateLimiter.DeleteExpired(context.Background(), time.Now()); err != nil {
+				logger.Error("Failed to delete expired rate limit buckets", "error", err)
+			}
+		}
+	}()
+
+	webhookTimeout, err := time.ParseDuration(cfg.WebhookTimeout)
+	if err != nil {
+		logger.Error("Invalid WEBHOOK_TIMEOUT format", "error", err)
+		os.Exit(1)
+	}
+	// Deliveries must be signed and may only reach allowlisted hosts.
+	var webhookDispatcher *webhook.Dispatcher
+	if cfg.WebhooksEnabled {
+		if cfg.WebhookSecret == "" || len(cfg.GetWebhookAllowedHosts()) == 0 {
+			logger.Error("WEBHOOK_SECRET and WEBHOOK_ALLOWED_HOSTS are required when WEBHOOKS_ENABLED is set")
+			os.Exit(1)
+		}
+		webhookDispatcher = webhook.NewDispatcher(webhooks, webhook.Config{
+			Secret:      cfg.WebhookSecret,
+			MaxAttempts: cfg.WebhookMaxAttempts,
+			Timeout:     webhookTimeout,
+			Logger:      logger,
+		})
+		go webhookDispatcher.Start(context.Background())
+	} else {
+		logger.Info("Webhooks are disabled because WEBHOOKS_ENABLED is not set.")
+	}
+
+	var eventPublisher domain.EventPublisher
+	switch cfg.EventPublisher {
+	case "log":
+		eventPublisher = outbox.NewLogPublisher(os.Stdout)
+	case "http":
+		if cfg.EventPublisherURL == "" {
+			logger.Error("EVENT_PUBLISHER_URL is required when EVENT_PUBLISHER is http")
+			os.Exit(1)
+		}
+		eventPublisherTimeout, err := time.ParseDuration(cfg.EventTimeout)
+		if err != nil {
+			logger.Error("Invalid EVENT_PUBLISHER_TIMEOUT format", "error", err)
+			os.Exit(1)
+		}
+		eventPublisher = outbox.NewHTTPPublisher(cfg.EventPublisherURL, eventPublisherTimeout)
+	case "none":
+		logger.Warn("Not publishing file events because EVENT_PUBLISHER is set to none. The outbox is only used to clean up deleted files.")
+	default:
+		logger.Error("Invalid EVENT_PUBLISHER, expected log, http or none", "value", cfg.EventPublisher)
+		os.Exit(1)
+	}
+	if eventPublisher != nil {
+		logger.Info("Publishing file events from the outbox", "publisher", cfg.EventPublisher)
+	}
+
+	outboxRetention, err := time.ParseDuration(cfg.OutboxRetention)
+	if err != nil {
+		logger.Error("Invalid OUTBOX_RETENTION format", "error", err)
+		os.Exit(1)
+	}
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			deleted, err := eventOutbox.DeletePublished(context.Background(), time.Now().Add(-outboxRetention))
+			if err != nil {
+				logger.Error("Failed to delete published outbox events", "error", err)
+				continue
+			}
+			if deleted > 0 {
+				logger.Info("Deleted published outbox events", "count", deleted)
+			}
+		}
+	}()
+
+	var fileAuthorization domain.FileAuthorization
+	var fileGrants domain.FileGrantRepository
+	switch cfg.FileAuthorization {
+	case "mock":
+		logger.Warn("Using MockFileAuthorization because FILE_AUTHORIZATION is set to mock. Every request is allowed.")
+		fileAuthorization = repository.NewMockFileAuthorization()
+	case "acl":
+		if cfg.UseInMemoryRepo {
+			aclAuthorization := repository.NewInMemoryFileAuthorization()
+			fileAuthorization, fileGrants = aclAuthorization, aclAuthorization
+		} else {
+			aclAuthorization := repository.NewPostgresFileAuthorization(db)
+			fileAuthorization, fileGrants = aclAuthorization, aclAuthorization
+		}
+	case "policy":
+		authorizationPolicy, err := policy.Load(cfg.PolicyFile)
+		if err != nil {
+			logger.Error("Failed to load authorization policy", "path", cfg.PolicyFile, "error", err)
+			os.Exit(1)
+		}
+		logger.Info("Authorizing file access with policy", "path", cfg.PolicyFile, "rules", len(authorizationPolicy.Rules))
+		fileAuthorization = authorization.NewPolicyFileAuthorization(authorizationPolicy, fileInfoRepo)
+	case "callout":
+		if cfg.AuthzCalloutURL == "" {
+			logger.Error("AUTHZ_CALLOUT_URL is required when FILE_AUTHORIZATION is callout")
+			os.Exit(1)
+		}
+		calloutTimeout, err := time.ParseDuration(cfg.AuthzCalloutTimeout)
+		if err != nil {
+			logger.Error("Invalid AUTHZ_CALLOUT_TIMEOUT format", "error", err)
+			os.Exit(1)
+		}
+		cacheTTL, err := time.ParseDuration(cfg.AuthzCacheTTL)
+		if err != nil {
+			logger.Error("Invalid AUTHZ_CACHE_TTL format", "error", err)
+			os.Exit(1)
+		}
+		logger.Info("Authorizing file access with external authorization service", "url", cfg.AuthzCalloutURL, "timeout", calloutTimeout, "cacheTTL", cacheTTL)
+		authorizationService := authorization.NewHTTPAuthorizationService(cfg.AuthzCalloutURL, calloutTimeout, cacheTTL)
+		fileAuthorization = authorization.NewServiceFileAuthorization(authorizationService, fileInfoRepo)
+	default:
+		logger.Error("Invalid FILE_AUTHORIZATION, expected mock, acl, policy or callout", "value", cfg.FileAuthorization)
+		os.Exit(1)
+	}
+
+	// Files are registered with the authorization in the same unit of work
+	// as the upload job and file info changes.
+	if cfg.UseInMemoryRepo {
+		unitOfWork = repository.NewInMemoryUnitOfWork(inMemoryJobRepo, inMemoryFileInfoRepo, fileAuthorization, webhooks)
+	} else {
+		unitOfWork = repository.NewPostgresUnitOfWork(db, fileAuthorization)
+	}
+	// Webhook deliveries are recorded in the same unit of work as the job
+	// updates they announce, including updates made outside of one.
+	if webhookDispatcher != nil {
+		notifyingUnitOfWork := webhook.NewNotifyingUnitOfWork(unitOfWork, webhookDispatcher)
+		unitOfWork = notifyingUnitOfWork
+		jobRepo = webhook.NewNotifyingJobRepo(jobRepo, notifyingUnitOfWork)
+	} else {
+		webhooks = nil
+	}
+
+	fileAuthorization = authorization.NewInstrumentedFileAuthorization(fileAuthorization, metricsCollector)
 
 	var virusChecker domain.VirusChecker
 	if cfg.UseMockVirusChecker {
@@ -93,7 +396,9 @@ func main() {
 			logger.Error("VIRUS_CHECKER_URL is required when not using mock virus checker")
 			os.Exit(1)
 		}
-		virusChecker = viruschecker.NewHTTPVirusChecker(cfg.VirusCheckerURL)
+		httpVirusChecker := viruschecker.NewHTTPVirusChecker(cfg.VirusCheckerURL)
+		readiness.Register("virusChecker", httpVirusChecker.Check)
+		virusChecker = httpVirusChecker
 	}
 
 	virusCheckTimeout, err := time.ParseDuration(cfg.VirusCheckTimeout)
@@ -102,27 +407,108 @@ func main() {
 		os.Exit(1)
 	}
 
+	jwtClockSkew, err := time.ParseDuration(cfg.JWTClockSkew)
+	if err != nil && !cfg.UseMockAuthorization {
+		logger.Error("Invalid JWT_CLOCK_SKEW format", "error", err)
+		os.Exit(1)
+	}
+
+	contentTypePolicy, err := contenttype.ParseMismatchPolicy(cfg.ContentTypePolicy)
+	if err != nil {
+		logger.Error("Invalid CONTENT_TYPE_MISMATCH_POLICY", "error", err)
+		os.Exit(1)
+	}
+
+	thumbnailSizes, err := cfg.GetThumbnailSizes()
+	if err != nil {
+		logger.Error("Invalid THUMBNAIL_SIZES", "error", err)
+		os.Exit(1)
+	}
+
+	postScanStages := []domain.PostScanStage{textextract.NewExtractor(fileStorage, searchIndex)}
+	var thumbnails domain.ThumbnailStore
+	if len(thumbnailSizes) > 0 {
+		logger.Info("Generating thumbnails for scanned images", "sizes", thumbnailSizes)
+		thumbnailGenerator := thumbnail.NewGenerator(fileStorage, thumbnailSizes)
+		thumbnails = thumbnailGenerator
+		postScanStages = append(postScanStages, thumbnailGenerator)
+	}
+
+	// The relay always runs, because the blobs of deleted files are deleted
+	// from their file.deleted events.
+	go outbox.NewRelay(eventOutbox, outbox.NewCleanupPublisher(fileStorage, thumbnails, eventPublisher), 0, logger).Start(context.Background())
+
 	virusScanner := jobrunner.NewVirusScannerJobRunner(
 		jobRepo,
 		fileInfoRepo,
-		fileAuthorization,
+		unitOfWork,
 		fileStorage,
 		virusChecker,
 		virusCheckTimeout,
 		metricsCollector,
+		auditLog,
+		logger,
+		postScanStages...,
 	)
 
 	go virusScanner.Start(context.Background())
+	metricsCollector.RegisterScanner(virusScanner)
+	metricsCollector.RegisterJobCounts(jobRepo, logger)
 
 	serverConfig := server.ServerConfig{
 		FileStorage:          fileStorage,
 		JobRepo:              jobRepo,
+		Scanner:              virusScanner,
 		FileInfoRepo:         fileInfoRepo,
+		UnitOfWork:           unitOfWork,
 		FileAuthorization:    fileAuthorization,
+		FileGrants:           fileGrants,
+		APIKeys:              apiKeys,
+		AuditLog:             auditLog,
+		Webhooks:             webhooks,
+		WebhookDispatcher:    webhookDispatcher,
+		WebhookAllowedHosts:  cfg.GetWebhookAllowedHosts(),
+		JobWatcher:           jobWatcher,
+		IdempotencyKeys:      idempotencyKeys,
+		IdempotencyKeyTTL:    idempotencyKeyTTL,
+		RateLimiter:          rateLimiter,
+		Thumbnails:           thumbnails,
+		SearchIndex:          searchIndex,
+		Readiness:            readiness,
+		ServiceName:          cfg.ServiceName,
+		Metrics:              metricsCollector,
 		KeycloakURL:          cfg.KeycloakURL,
 		KeycloakClientID:     cfg.KeycloakClientID,
+		KeycloakIssuer:       cfg.KeycloakIssuer,
+		KeycloakAudience:     cfg.KeycloakAudience,
+		JWTClockSkew:         jwtClockSkew,
 		Logger:               logger,
+		LogLevel:             logLevel,
 		UseMockAuthorization: cfg.UseMockAuthorization,
+		AdminRole:            cfg.AdminRole,
+		AdminUserIDs:         cfg.GetAdminUserIDs(),
+		AuditorRole:          cfg.AuditorRole,
+		AuditorUserIDs:       cfg.GetAuditorUserIDs(),
+		TrustedProxies:       cfg.GetTrustedProxies(),
+		Scopes: server.RouteScopes{
+			Read:  cfg.GetScopesRead(),
+			Write: cfg.GetScopesWrite(),
+			Admin: cfg.GetScopesAdmin(),
+			Audit: cfg.GetScopesAudit(),
+		},
+		RateLimits: server.RouteRateLimits{
+			Read:                rateLimitRead,
+			Write:               rateLimitWrite,
+			Upload:              rateLimitUpload,
+			ConcurrentUploads:   cfg.ConcurrentUploads,
+			ConcurrentDownloads: cfg.ConcurrentDownloads,
+		},
+		ContentTypePolicy: contentTypePolicy,
+	}
+
+	if err := serverConfig.Validate(); err != nil {
+		logger.Error("Invalid server configuration", "error", err)
+		os.Exit(1)
 	}
 
 	r := server.SetupRouter(serverConfig)
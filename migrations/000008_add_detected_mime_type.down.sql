@@ -0,0 +1,2 @@
+ALTER TABLE file_info DROP COLUMN content_type_mismatch;
+ALTER TABLE file_info DROP COLUMN detected_mime_type;
@@ -0,0 +1,2 @@
+ALTER TABLE file_info ADD COLUMN detected_mime_type VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE file_info ADD COLUMN content_type_mismatch BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,8 @@
+CREATE TABLE file_search_index (
+    file_id VARCHAR(255) PRIMARY KEY REFERENCES file_info(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    content_tsv TSVECTOR NOT NULL,
+    indexed_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_file_search_index_content_tsv ON file_search_index USING GIN (content_tsv);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS acl_group_members;
+DROP TABLE IF EXISTS file_grants;
+DROP TABLE IF EXISTS file_acl_resources;
@@ -0,0 +1,30 @@
+CREATE TABLE file_acl_resources (
+    file_id VARCHAR(255) PRIMARY KEY,
+    file_type VARCHAR(100) NOT NULL,
+    linked_resource_type VARCHAR(100) NOT NULL,
+    linked_resource_id VARCHAR(255) NOT NULL
+);
+
+CREATE TABLE file_grants (
+    id VARCHAR(255) PRIMARY KEY,
+    principal_type VARCHAR(10) NOT NULL CHECK (principal_type IN ('user', 'group')),
+    principal_id VARCHAR(255) NOT NULL,
+    resource_type VARCHAR(100) NOT NULL,
+    resource_id VARCHAR(255) NOT NULL,
+    file_type VARCHAR(100) NOT NULL DEFAULT '',
+    permission VARCHAR(10) NOT NULL CHECK (permission IN ('read', 'delete', 'upload')),
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- Serves the authorization lookups, which always know the resource and permission.
+CREATE UNIQUE INDEX idx_file_grants_resource ON file_grants (resource_type, resource_id, permission, principal_type, principal_id, file_type);
+CREATE INDEX idx_file_grants_principal ON file_grants (principal_type, principal_id);
+
+CREATE TABLE acl_group_members (
+    group_id VARCHAR(255) NOT NULL,
+    user_id VARCHAR(255) NOT NULL,
+    PRIMARY KEY (group_id, user_id)
+);
+
+CREATE INDEX idx_acl_group_members_user_id ON acl_group_members (user_id);
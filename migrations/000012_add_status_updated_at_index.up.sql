@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_upload_jobs_status_updated_at ON upload_jobs (status, updated_at);
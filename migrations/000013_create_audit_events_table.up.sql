@@ -0,0 +1,35 @@
+CREATE TABLE audit_events (
+    seq BIGSERIAL PRIMARY KEY,
+    id VARCHAR(36) NOT NULL UNIQUE,
+    occurred_at TIMESTAMP NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    outcome VARCHAR(10) NOT NULL CHECK (outcome IN ('success', 'denied', 'failure')),
+    user_id VARCHAR(255) NOT NULL DEFAULT '',
+    client_ip VARCHAR(45) NOT NULL DEFAULT '',
+    request_id VARCHAR(255) NOT NULL DEFAULT '',
+    file_id VARCHAR(36) NOT NULL DEFAULT '',
+    linked_resource_type VARCHAR(100) NOT NULL DEFAULT '',
+    linked_resource_id VARCHAR(255) NOT NULL DEFAULT '',
+    details JSONB NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX idx_audit_events_occurred_at ON audit_events (occurred_at);
+CREATE INDEX idx_audit_events_user_id ON audit_events (user_id, occurred_at);
+CREATE INDEX idx_audit_events_file_id ON audit_events (file_id, occurred_at);
+CREATE INDEX idx_audit_events_linked_resource ON audit_events (linked_resource_type, linked_resource_id, occurred_at);
+
+-- Audit events are append-only. The application role must not be able to
+-- rewrite history, so updates, deletes and truncates are rejected.
+CREATE FUNCTION reject_audit_event_changes() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_no_update_or_delete
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION reject_audit_event_changes();
+
+CREATE TRIGGER audit_events_no_truncate
+    BEFORE TRUNCATE ON audit_events
+    FOR EACH STATEMENT EXECUTE FUNCTION reject_audit_event_changes();
@@ -0,0 +1,33 @@
+CREATE TABLE webhook_subscriptions (
+    id VARCHAR(36) PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL DEFAULT '',
+    job_id VARCHAR(36) NOT NULL DEFAULT '',
+    linked_resource_type VARCHAR(100) NOT NULL DEFAULT '',
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    CHECK ((job_id = '') <> (linked_resource_type = ''))
+);
+
+CREATE INDEX idx_webhook_subscriptions_job_id ON webhook_subscriptions (job_id) WHERE job_id <> '';
+CREATE INDEX idx_webhook_subscriptions_linked_resource_type ON webhook_subscriptions (linked_resource_type) WHERE linked_resource_type <> '';
+
+CREATE TABLE webhook_deliveries (
+    id VARCHAR(36) PRIMARY KEY,
+    subscription_id VARCHAR(36) NOT NULL,
+    job_id VARCHAR(36) NOT NULL,
+    url TEXT NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(10) NOT NULL CHECK (status IN ('pending', 'succeeded', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    last_attempt_at TIMESTAMP,
+    last_status_code INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL
+);
+
+-- Serves the delivery worker, which only looks at pending deliveries.
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_webhook_deliveries_job_id ON webhook_deliveries (job_id);
@@ -0,0 +1,15 @@
+CREATE TABLE outbox_events (
+    seq BIGSERIAL PRIMARY KEY,
+    id VARCHAR(36) NOT NULL UNIQUE,
+    type VARCHAR(50) NOT NULL,
+    file_id VARCHAR(36) NOT NULL,
+    occurred_at TIMESTAMP NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    last_error TEXT NOT NULL DEFAULT '',
+    published_at TIMESTAMP
+);
+
+-- Serves the relay, which publishes the oldest unpublished event of each file.
+CREATE INDEX idx_outbox_events_unpublished ON outbox_events (file_id, seq) WHERE published_at IS NULL;
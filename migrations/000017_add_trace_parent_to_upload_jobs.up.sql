@@ -0,0 +1 @@
+ALTER TABLE upload_jobs ADD COLUMN trace_parent VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE upload_jobs ADD COLUMN request_id VARCHAR(128) NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE idempotency_keys ADD COLUMN location VARCHAR(2048) NOT NULL DEFAULT '';
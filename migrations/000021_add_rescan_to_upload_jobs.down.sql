@@ -0,0 +1 @@
+ALTER TABLE upload_jobs DROP COLUMN rescan;
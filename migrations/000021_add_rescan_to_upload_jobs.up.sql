@@ -0,0 +1 @@
+ALTER TABLE upload_jobs ADD COLUMN rescan BOOLEAN NOT NULL DEFAULT FALSE;
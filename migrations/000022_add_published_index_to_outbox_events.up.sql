@@ -0,0 +1,2 @@
+-- Serves the deletion of published events after the retention period.
+CREATE INDEX idx_outbox_events_published_at ON outbox_events (published_at) WHERE published_at IS NOT NULL;
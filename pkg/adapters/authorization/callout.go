@@ -0,0 +1,220 @@
+package authorization
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"file-storage-go/pkg/domain"
+	"file-storage-go/pkg/requestid"
+)
+
+const maxCachedDecisions = 10000
+
+// perTokenClaims change with every token of the same user and session. They
+// are not sent to the authorization service, so that decisions stay cached
+// across token refreshes.
+var perTokenClaims = map[string]bool{
+	"exp":           true,
+	"iat":           true,
+	"nbf":           true,
+	"jti":           true,
+	"auth_time":     true,
+	"sid":           true,
+	"session_state": true,
+	"at_hash":       true,
+	"c_hash":        true,
+	"nonce":         true,
+}
+
+type calloutRequest struct {
+	Input calloutInput `json:"input"`
+}
+
+type calloutInput struct {
+	User     calloutUser     `json:"user"`
+	Resource calloutResource `json:"resource"`
+	Action   string          `json:"action"`
+}
+
+type calloutUser struct {
+	ID     string         `json:"id"`
+	Roles  []string       `json:"roles,omitempty"`
+	Claims map[string]any `json:"claims,omitempty"`
+}
+
+type calloutResource struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// calloutResponse accepts both OPA result shapes, {"result": true} and
+// {"result": {"allow": true}}. A missing result is an undefined decision.
+type calloutResponse struct {
+	Result json.RawMessage `json:"result"`
+}
+
+type cachedDecision struct {
+	allowed   bool
+	expiresAt time.Time
+}
+
+// HTTPAuthorizationService asks an external policy decision point, e.g. OPA or
+// the service owning the linked resource, whether a user may perform an action.
+// Decisions are cached for cacheTTL per user, roles, claims, resource and
+// action; errors are never cached.
+type HTTPAuthorizationService struct {
+	client   *http.Client
+	url      string
+	timeout  time.Duration
+	cacheTTL time.Duration
+
+	cache map[string]cachedDecision
+	mu    sync.Mutex
+}
+
+func NewHTTPAuthorizationService(url string, timeout, cacheTTL time.Duration) *HTTPAuthorizationService {
+	return &HTTPAuthorizationService{
+		client:   &http.Client{},
+		url:      url,
+		timeout:  timeout,
+		cacheTTL: cacheTTL,
+		cache:    make(map[string]cachedDecision),
+	}
+}
+
+func (s *HTTPAuthorizationService) Authorize(ctx context.Context, userID string, resourceType string, resourceID string, action string) (bool, error) {
+	input := calloutInput{
+		User:     calloutUser{ID: userID},
+		Resource: calloutResource{Type: resourceType, ID: resourceID},
+		Action:   action,
+	}
+	if principal := domain.PrincipalFromContext(ctx); principal != nil && principal.UserID == userID {
+		input.User.Roles = principal.Roles
+		input.User.Claims = userClaims(principal.Claims)
+	}
+
+	body, err := json.Marshal(calloutRequest{Input: input})
+	if err != nil {
+		return false, fmt.Errorf("failed to encode authorization request: %w", err)
+	}
+
+	// Decisions are cached per request body, so that a change of the user's
+	// roles or claims is sent to the authorization service right away.
+	sum := sha256.Sum256(body)
+	key := hex.EncodeToString(sum[:])
+	if allowed, ok := s.cached(key); ok {
+		return allowed, nil
+	}
+
+	allowed, err := s.callout(ctx, body)
+	if err != nil {
+		return false, err
+	}
+
+	s.store(key, allowed)
+	return allowed, nil
+}
+
+// userClaims returns the claims without the per-token ones.
+func userClaims(claims map[string]any) map[string]any {
+	filtered := make(map[string]any, len(claims))
+	for name, value := range claims {
+		if !perTokenClaims[name] {
+			filtered[name] = value
+		}
+	}
+	return filtered
+}
+
+func (s *HTTPAuthorizationService) callout(ctx context.Context, body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create authorization request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	requestid.SetHeader(req)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to send authorization request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unexpected status code from authorization service: %d", resp.StatusCode)
+	}
+
+	var result calloutResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("failed to decode authorization response: %w", err)
+	}
+
+	return parseDecision(result.Result)
+}
+
+func parseDecision(result json.RawMessage) (bool, error) {
+	if len(result) == 0 || string(result) == "null" {
+		return false, nil
+	}
+
+	var allowed bool
+	if err := json.Unmarshal(result, &allowed); err == nil {
+		return allowed, nil
+	}
+
+	var object struct {
+		Allow bool `json:"allow"`
+	}
+	if err := json.Unmarshal(result, &object); err != nil {
+		return false, fmt.Errorf("unexpected authorization result: %s", result)
+	}
+	return object.Allow, nil
+}
+
+func (s *HTTPAuthorizationService) cached(key string) (bool, bool) {
+	if s.cacheTTL <= 0 {
+		return false, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	decision, ok := s.cache[key]
+	if !ok || time.Now().After(decision.expiresAt) {
+		return false, false
+	}
+	return decision.allowed, true
+}
+
+func (s *HTTPAuthorizationService) store(key string, allowed bool) {
+	if s.cacheTTL <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if len(s.cache) >= maxCachedDecisions {
+		for k, decision := range s.cache {
+			if now.After(decision.expiresAt) {
+				delete(s.cache, k)
+			}
+		}
+		if len(s.cache) >= maxCachedDecisions {
+			s.cache = make(map[string]cachedDecision)
+		}
+	}
+
+	s.cache[key] = cachedDecision{allowed: allowed, expiresAt: now.Add(s.cacheTTL)}
+}
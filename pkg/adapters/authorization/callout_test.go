@@ -0,0 +1,213 @@
+package authorization
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"file-storage-go/pkg/adapters/repository"
+	"file-storage-go/pkg/domain"
+	"file-storage-go/pkg/requestid"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDecisionServer(t *testing.T, decide func(input calloutInput) string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		var req calloutRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(decide(req.Input)))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestHTTPAuthorizationService_Decisions(t *testing.T) {
+	server, _ := newDecisionServer(t, func(input calloutInput) string {
+		switch input.Resource.ID {
+		case "bool":
+			return `{"result": true}`
+		case "object":
+			return `{"result": {"allow": true, "reason": "member"}}`
+		case "undefined":
+			return `{}`
+		default:
+			return `{"result": false}`
+		}
+	})
+
+	service := NewHTTPAuthorizationService(server.URL, time.Second, 0)
+	ctx := context.Background()
+
+	for resourceID, expected := range map[string]bool{"bool": true, "object": true, "undefined": false, "other": false} {
+		allowed, err := service.Authorize(ctx, "alice", "company", resourceID, "read")
+		require.NoError(t, err)
+		assert.Equal(t, expected, allowed, resourceID)
+	}
+}
+
+func TestHTTPAuthorizationService_SendsPrincipal(t *testing.T) {
+	var received calloutInput
+	server, _ := newDecisionServer(t, func(input calloutInput) string {
+		received = input
+		return `{"result": true}`
+	})
+
+	service := NewHTTPAuthorizationService(server.URL, time.Second, 0)
+	ctx := domain.ContextWithPrincipal(context.Background(), &domain.Principal{
+		UserID: "alice",
+		Roles:  []string{"accountant"},
+		Claims: map[string]any{"companies": []any{"3"}, "exp": 1700000300, "jti": "token-1"},
+	})
+
+	_, err := service.Authorize(ctx, "alice", "company", "3", "upload:invoice")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", received.User.ID)
+	assert.Equal(t, []string{"accountant"}, received.User.Roles)
+	assert.Equal(t, map[string]any{"companies": []any{"3"}}, received.User.Claims, "per-token claims are not sent")
+	assert.Equal(t, calloutResource{Type: "company", ID: "3"}, received.Resource)
+	assert.Equal(t, "upload:invoice", received.Action)
+}
+
+func TestHTTPAuthorizationService_CachesDecisions(t *testing.T) {
+	server, calls := newDecisionServer(t, func(input calloutInput) string {
+		return `{"result": true}`
+	})
+
+	service := NewHTTPAuthorizationService(server.URL, time.Second, time.Minute)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		allowed, err := service.Authorize(ctx, "alice", "company", "3", "read")
+		require.NoError(t, err)
+		assert.True(t, allowed)
+	}
+	assert.Equal(t, int32(1), calls.Load())
+
+	_, err := service.Authorize(ctx, "bob", "company", "3", "read")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestHTTPAuthorizationService_CachesAcrossTokens(t *testing.T) {
+	server, calls := newDecisionServer(t, func(input calloutInput) string {
+		return `{"result": true}`
+	})
+
+	service := NewHTTPAuthorizationService(server.URL, time.Second, time.Minute)
+	for i := 0; i < 3; i++ {
+		ctx := domain.ContextWithPrincipal(context.Background(), &domain.Principal{
+			UserID: "alice",
+			Claims: map[string]any{"companies": []any{"3"}, "iat": 1700000000 + i, "exp": 1700000300 + i, "jti": fmt.Sprintf("token-%d", i), "auth_time": 1700000000},
+		})
+		_, err := service.Authorize(ctx, "alice", "company", "3", "read")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), calls.Load(), "a refreshed token does not bypass the cache")
+}
+
+func TestHTTPAuthorizationService_RoleChangeBypassesCache(t *testing.T) {
+	server, calls := newDecisionServer(t, func(input calloutInput) string {
+		if len(input.User.Roles) > 0 && input.User.Roles[0] == "accountant" {
+			return `{"result": true}`
+		}
+		return `{"result": false}`
+	})
+
+	service := NewHTTPAuthorizationService(server.URL, time.Second, time.Minute)
+	accountant := domain.ContextWithPrincipal(context.Background(), &domain.Principal{UserID: "alice", Roles: []string{"accountant"}})
+	revoked := domain.ContextWithPrincipal(context.Background(), &domain.Principal{UserID: "alice"})
+
+	allowed, err := service.Authorize(accountant, "alice", "company", "3", "read")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	allowed, err = service.Authorize(revoked, "alice", "company", "3", "read")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestHTTPAuthorizationService_Errors(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(200 * time.Millisecond):
+		}
+	}))
+	defer slow.Close()
+
+	service := NewHTTPAuthorizationService(slow.URL, 20*time.Millisecond, time.Minute)
+	_, err := service.Authorize(context.Background(), "alice", "company", "3", "read")
+	assert.Error(t, err, "timeouts fail closed")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	service = NewHTTPAuthorizationService(slow.URL, time.Second, time.Minute)
+	_, err = service.Authorize(ctx, "alice", "company", "3", "read")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	service = NewHTTPAuthorizationService(failing.URL, time.Second, time.Minute)
+	_, err = service.Authorize(context.Background(), "alice", "company", "3", "read")
+	assert.Error(t, err)
+}
+
+func TestServiceFileAuthorization(t *testing.T) {
+	server, _ := newDecisionServer(t, func(input calloutInput) string {
+		if input.Resource.Type == "company" && input.Resource.ID == "3" && input.Action != "delete:invoice" {
+			return `{"result": true}`
+		}
+		return `{"result": false}`
+	})
+
+	fileInfoRepo := repository.NewInMemoryFileInfoRepo()
+	ctx := context.Background()
+	require.NoError(t, fileInfoRepo.Create(ctx, &domain.FileInfo{ID: "file", FileType: "invoice", LinkedResourceType: "company", LinkedResourceID: "3"}))
+
+	authz := NewServiceFileAuthorization(NewHTTPAuthorizationService(server.URL, time.Second, 0), fileInfoRepo)
+
+	allowed, err := authz.CanUploadFile(ctx, "alice", "invoice", "company", "3")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	allowed, err = authz.CanReadFile(ctx, "alice", "file")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	allowed, err = authz.CanDeleteFile(ctx, "alice", "file")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+
+	allowed, err = authz.CanReadFile(ctx, "alice", "missing")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+}
+
+func TestHTTPAuthorizationService_ForwardsRequestID(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(requestid.Header)
+		w.Write([]byte(`{"result": true}`))
+	}))
+	t.Cleanup(server.Close)
+
+	service := NewHTTPAuthorizationService(server.URL, time.Second, 0)
+	_, err := service.Authorize(requestid.NewContext(context.Background(), "req-1"), "alice", "company", "3", "read")
+	require.NoError(t, err)
+	assert.Equal(t, "req-1", received)
+}
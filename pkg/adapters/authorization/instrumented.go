@@ -0,0 +1,74 @@
+package authorization
+
+import (
+	"context"
+	"time"
+
+	"file-storage-go/pkg/domain"
+)
+
+// InstrumentedFileAuthorization records the duration and result of the
+// access checks of another domain.FileAuthorization.
+type InstrumentedFileAuthorization struct {
+	domain.FileAuthorization
+	metrics domain.MetricsCollector
+}
+
+func NewInstrumentedFileAuthorization(inner domain.FileAuthorization, metrics domain.MetricsCollector) *InstrumentedFileAuthorization {
+	return &InstrumentedFileAuthorization{
+		FileAuthorization: inner,
+		metrics:           metrics,
+	}
+}
+
+func (a *InstrumentedFileAuthorization) CanUploadFile(ctx context.Context, userID, fileType, linkedResourceType, linkedResourceID string) (bool, error) {
+	start := time.Now()
+	allowed, err := a.FileAuthorization.CanUploadFile(ctx, userID, fileType, linkedResourceType, linkedResourceID)
+	a.record(domain.PermissionUpload, allowed, err, start)
+	return allowed, err
+}
+
+func (a *InstrumentedFileAuthorization) CanReadFile(ctx context.Context, userID, fileID string) (bool, error) {
+	start := time.Now()
+	allowed, err := a.FileAuthorization.CanReadFile(ctx, userID, fileID)
+	a.record(domain.PermissionRead, allowed, err, start)
+	return allowed, err
+}
+
+func (a *InstrumentedFileAuthorization) CanDeleteFile(ctx context.Context, userID, fileID string) (bool, error) {
+	start := time.Now()
+	allowed, err := a.FileAuthorization.CanDeleteFile(ctx, userID, fileID)
+	a.record(domain.PermissionDelete, allowed, err, start)
+	return allowed, err
+}
+
+// FilterReadableFiles records a read decision per file, each with an equal
+// share of the duration of the batch.
+func (a *InstrumentedFileAuthorization) FilterReadableFiles(ctx context.Context, userID string, files []*domain.FileInfo) ([]*domain.FileInfo, error) {
+	start := time.Now()
+	readable, err := a.FileAuthorization.FilterReadableFiles(ctx, userID, files)
+	if err != nil || len(files) == 0 {
+		a.record(domain.PermissionRead, false, err, start)
+		return readable, err
+	}
+
+	share := time.Since(start) / time.Duration(len(files))
+	for range readable {
+		a.metrics.RecordAuthorizationCheck(string(domain.PermissionRead), "allowed", share)
+	}
+	for range len(files) - len(readable) {
+		a.metrics.RecordAuthorizationCheck(string(domain.PermissionRead), "denied", share)
+	}
+	return readable, nil
+}
+
+func (a *InstrumentedFileAuthorization) record(action domain.Permission, allowed bool, err error, start time.Time) {
+	result := "denied"
+	switch {
+	case err != nil:
+		result = "error"
+	case allowed:
+		result = "allowed"
+	}
+	a.metrics.RecordAuthorizationCheck(string(action), result, time.Since(start))
+}
@@ -0,0 +1,92 @@
+package authorization
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"file-storage-go/pkg/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordedCheck struct {
+	action string
+	result string
+}
+
+type recordingMetrics struct {
+	domain.MetricsCollector
+	checks []recordedCheck
+}
+
+func (m *recordingMetrics) RecordAuthorizationCheck(action, result string, duration time.Duration) {
+	m.checks = append(m.checks, recordedCheck{action: action, result: result})
+}
+
+type stubFileAuthorization struct {
+	domain.FileAuthorization
+	allowed bool
+	err     error
+}
+
+func (a *stubFileAuthorization) CanUploadFile(ctx context.Context, userID, fileType, linkedResourceType, linkedResourceID string) (bool, error) {
+	return a.allowed, a.err
+}
+
+func (a *stubFileAuthorization) CanReadFile(ctx context.Context, userID, fileID string) (bool, error) {
+	return a.allowed, a.err
+}
+
+func (a *stubFileAuthorization) CanDeleteFile(ctx context.Context, userID, fileID string) (bool, error) {
+	return a.allowed, a.err
+}
+
+func (a *stubFileAuthorization) FilterReadableFiles(ctx context.Context, userID string, files []*domain.FileInfo) ([]*domain.FileInfo, error) {
+	if a.err != nil || !a.allowed {
+		return nil, a.err
+	}
+	return files[:1], nil
+}
+
+func TestInstrumentedFileAuthorization(t *testing.T) {
+	ctx := context.Background()
+	metrics := &recordingMetrics{}
+	inner := &stubFileAuthorization{allowed: true}
+	authz := NewInstrumentedFileAuthorization(inner, metrics)
+
+	allowed, err := authz.CanUploadFile(ctx, "alice", "invoice", "company", "3")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	inner.allowed = false
+	allowed, err = authz.CanReadFile(ctx, "alice", "file-1")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+
+	inner.err = errors.New("service unavailable")
+	_, err = authz.CanDeleteFile(ctx, "alice", "file-1")
+	assert.Error(t, err)
+
+	assert.Equal(t, []recordedCheck{
+		{action: "upload", result: "allowed"},
+		{action: "read", result: "denied"},
+		{action: "delete", result: "error"},
+	}, metrics.checks)
+}
+
+func TestInstrumentedFileAuthorization_FilterReadableFiles(t *testing.T) {
+	metrics := &recordingMetrics{}
+	authz := NewInstrumentedFileAuthorization(&stubFileAuthorization{allowed: true}, metrics)
+
+	readable, err := authz.FilterReadableFiles(context.Background(), "alice", []*domain.FileInfo{{ID: "file-1"}, {ID: "file-2"}})
+	require.NoError(t, err)
+	assert.Len(t, readable, 1)
+
+	assert.Equal(t, []recordedCheck{
+		{action: "read", result: "allowed"},
+		{action: "read", result: "denied"},
+	}, metrics.checks)
+}
@@ -0,0 +1,99 @@
+package authorization
+
+import (
+	"context"
+	"fmt"
+
+	"file-storage-go/pkg/domain"
+	"file-storage-go/pkg/policy"
+)
+
+// PolicyFileAuthorization decides every request from a declarative policy
+// evaluated against the principal of the request context. Nothing is stored
+// per file, so CreateFileAuthorization and RemoveFileAuthorization are no-ops.
+type PolicyFileAuthorization struct {
+	policy       *policy.Policy
+	fileInfoRepo domain.FileInfoRepository
+}
+
+func NewPolicyFileAuthorization(p *policy.Policy, fileInfoRepo domain.FileInfoRepository) *PolicyFileAuthorization {
+	return &PolicyFileAuthorization{
+		policy:       p,
+		fileInfoRepo: fileInfoRepo,
+	}
+}
+
+func (a *PolicyFileAuthorization) CanUploadFile(ctx context.Context, userID, fileType, linkedResourceType, linkedResourceID string) (bool, error) {
+	decision := a.policy.Evaluate(policy.Input{
+		Principal:          principalFor(ctx, userID),
+		Action:             domain.PermissionUpload,
+		FileType:           fileType,
+		LinkedResourceType: linkedResourceType,
+		LinkedResourceID:   linkedResourceID,
+	})
+	return decision.Allowed, nil
+}
+
+func (a *PolicyFileAuthorization) CanReadFile(ctx context.Context, userID, fileID string) (bool, error) {
+	return a.canAccessFile(ctx, userID, fileID, domain.PermissionRead)
+}
+
+func (a *PolicyFileAuthorization) CanDeleteFile(ctx context.Context, userID, fileID string) (bool, error) {
+	return a.canAccessFile(ctx, userID, fileID, domain.PermissionDelete)
+}
+
+// FilterReadableFiles evaluates the policy in process, so the files are
+// checked without further lookups.
+func (a *PolicyFileAuthorization) FilterReadableFiles(ctx context.Context, userID string, files []*domain.FileInfo) ([]*domain.FileInfo, error) {
+	principal := principalFor(ctx, userID)
+	readable := make([]*domain.FileInfo, 0, len(files))
+	for _, fileInfo := range files {
+		decision := a.policy.Evaluate(policy.Input{
+			Principal:          principal,
+			Action:             domain.PermissionRead,
+			FileType:           fileInfo.FileType,
+			LinkedResourceType: fileInfo.LinkedResourceType,
+			LinkedResourceID:   fileInfo.LinkedResourceID,
+		})
+		if decision.Allowed {
+			readable = append(readable, fileInfo)
+		}
+	}
+	return readable, nil
+}
+
+func (a *PolicyFileAuthorization) canAccessFile(ctx context.Context, userID, fileID string, action domain.Permission) (bool, error) {
+	fileInfo, err := a.fileInfoRepo.Get(ctx, fileID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get file info: %w", err)
+	}
+	if fileInfo == nil {
+		return false, nil
+	}
+
+	decision := a.policy.Evaluate(policy.Input{
+		Principal:          principalFor(ctx, userID),
+		Action:             action,
+		FileType:           fileInfo.FileType,
+		LinkedResourceType: fileInfo.LinkedResourceType,
+		LinkedResourceID:   fileInfo.LinkedResourceID,
+	})
+	return decision.Allowed, nil
+}
+
+func (a *PolicyFileAuthorization) CreateFileAuthorization(ctx context.Context, fileID, fileType, linkedResourceID, linkedResourceType string) error {
+	return nil
+}
+
+func (a *PolicyFileAuthorization) RemoveFileAuthorization(ctx context.Context, fileID, fileType, linkedResourceID, linkedResourceType string) error {
+	return nil
+}
+
+// principalFor returns the request principal if it belongs to userID. Other
+// callers are evaluated without roles or claims.
+func principalFor(ctx context.Context, userID string) *domain.Principal {
+	if principal := domain.PrincipalFromContext(ctx); principal != nil && principal.UserID == userID {
+		return principal
+	}
+	return &domain.Principal{UserID: userID}
+}
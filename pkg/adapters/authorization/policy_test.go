@@ -0,0 +1,67 @@
+package authorization
+
+import (
+	"context"
+	"testing"
+
+	"file-storage-go/pkg/adapters/repository"
+	"file-storage-go/pkg/domain"
+	"file-storage-go/pkg/policy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyFileAuthorization(t *testing.T) {
+	p, err := policy.Parse([]byte(`
+rules:
+  - name: company-members
+    roles: [member]
+    actions: [upload, read]
+    linkedResourceTypes: [company]
+    linkedResourceIdClaim: companies
+`))
+	require.NoError(t, err)
+
+	fileInfoRepo := repository.NewInMemoryFileInfoRepo()
+	ctx := context.Background()
+	require.NoError(t, fileInfoRepo.Create(ctx, &domain.FileInfo{ID: "file-3", FileType: "invoice", LinkedResourceType: "company", LinkedResourceID: "3"}))
+	require.NoError(t, fileInfoRepo.Create(ctx, &domain.FileInfo{ID: "file-7", FileType: "invoice", LinkedResourceType: "company", LinkedResourceID: "7"}))
+
+	authz := NewPolicyFileAuthorization(p, fileInfoRepo)
+	requestCtx := domain.ContextWithPrincipal(ctx, &domain.Principal{
+		UserID: "alice",
+		Roles:  []string{"member"},
+		Claims: map[string]any{"companies": []any{"3"}},
+	})
+
+	allowed, err := authz.CanUploadFile(requestCtx, "alice", "invoice", "company", "3")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	allowed, err = authz.CanReadFile(requestCtx, "alice", "file-3")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	allowed, err = authz.CanReadFile(requestCtx, "alice", "file-7")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+
+	allowed, err = authz.CanDeleteFile(requestCtx, "alice", "file-3")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+
+	allowed, err = authz.CanReadFile(requestCtx, "alice", "missing")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+
+	allowed, err = authz.CanReadFile(requestCtx, "bob", "file-3")
+	require.NoError(t, err)
+	assert.False(t, allowed, "the principal of another user must not be used")
+
+	files, err := fileInfoRepo.GetMany(ctx, []string{"file-7", "file-3"})
+	require.NoError(t, err)
+	readable, err := authz.FilterReadableFiles(requestCtx, "alice", files)
+	require.NoError(t, err)
+	assert.Equal(t, []*domain.FileInfo{files[1]}, readable)
+}
@@ -0,0 +1,140 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"file-storage-go/pkg/domain"
+	"file-storage-go/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type CreateGrantRequest struct {
+	PrincipalType domain.PrincipalType `json:"principalType" binding:"required,oneof=user group"`
+	PrincipalID   string               `json:"principalId" binding:"required"`
+	ResourceType  string               `json:"resourceType" binding:"required"`
+	ResourceID    string               `json:"resourceId" binding:"required"`
+	FileType      string               `json:"fileType"`
+	Permission    domain.Permission    `json:"permission" binding:"required,oneof=read delete upload"`
+}
+
+type AdminHandlers struct {
+	grants domain.FileGrantRepository
+}
+
+func NewAdminHandlers(grants domain.FileGrantRepository) *AdminHandlers {
+	return &AdminHandlers{
+		grants: grants,
+	}
+}
+
+func (h *AdminHandlers) ListGrants(c *gin.Context) {
+	grants, err := h.grants.ListGrants(c.Request.Context(), domain.FileGrantFilter{
+		PrincipalType: domain.PrincipalType(c.Query("principalType")),
+		PrincipalID:   c.Query("principalId"),
+		ResourceType:  c.Query("resourceType"),
+		ResourceID:    c.Query("resourceId"),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list grants"})
+		return
+	}
+
+	if grants == nil {
+		grants = []*domain.FileGrant{}
+	}
+	c.JSON(http.StatusOK, grants)
+}
+
+func (h *AdminHandlers) CreateGrant(c *gin.Context) {
+	var req CreateGrantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if req.ResourceType == domain.GrantResourceFile && req.Permission == domain.PermissionUpload {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload can only be granted on linked resources"})
+		return
+	}
+	if req.FileType != "" && req.Permission != domain.PermissionUpload {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "fileType only applies to upload grants"})
+		return
+	}
+
+	grant := &domain.FileGrant{
+		ID:            uuid.New().String(),
+		PrincipalType: req.PrincipalType,
+		PrincipalID:   req.PrincipalID,
+		ResourceType:  req.ResourceType,
+		ResourceID:    req.ResourceID,
+		FileType:      req.FileType,
+		Permission:    req.Permission,
+		CreatedBy:     c.GetString("userId"),
+		CreatedAt:     time.Now(),
+	}
+
+	middleware.SetAuditDetail(c, "grantId", grant.ID)
+	middleware.SetAuditDetail(c, "principal", string(grant.PrincipalType)+":"+grant.PrincipalID)
+	middleware.SetAuditDetail(c, "permission", string(grant.Permission))
+	if grant.ResourceType == domain.GrantResourceFile {
+		middleware.SetAuditResource(c, grant.ResourceID, "", "")
+	} else {
+		middleware.SetAuditResource(c, "", grant.ResourceType, grant.ResourceID)
+	}
+
+	if err := h.grants.CreateGrant(c.Request.Context(), grant); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create grant"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, grant)
+}
+
+func (h *AdminHandlers) DeleteGrant(c *gin.Context) {
+	err := h.grants.DeleteGrant(c.Request.Context(), c.Param("grantId"))
+	if errors.Is(err, domain.ErrGrantNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Grant not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete grant"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *AdminHandlers) ListGroupMembers(c *gin.Context) {
+	members, err := h.grants.ListGroupMembers(c.Request.Context(), c.Param("groupId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list group members"})
+		return
+	}
+
+	if members == nil {
+		members = []string{}
+	}
+	c.JSON(http.StatusOK, gin.H{"groupId": c.Param("groupId"), "members": members})
+}
+
+func (h *AdminHandlers) AddGroupMember(c *gin.Context) {
+	if err := h.grants.AddGroupMember(c.Request.Context(), c.Param("groupId"), c.Param("userId")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add group member"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *AdminHandlers) RemoveGroupMember(c *gin.Context) {
+	if err := h.grants.RemoveGroupMember(c.Request.Context(), c.Param("groupId"), c.Param("userId")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove group member"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
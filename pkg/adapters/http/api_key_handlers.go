@@ -0,0 +1,112 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"file-storage-go/pkg/auth"
+	"file-storage-go/pkg/domain"
+	"file-storage-go/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	OwnerID   string     `json:"ownerId" binding:"required"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// CreateAPIKeyResponse is the only response that contains the key itself.
+type CreateAPIKeyResponse struct {
+	*domain.APIKey
+	Key string `json:"key"`
+}
+
+type APIKeyHandlers struct {
+	keys domain.APIKeyRepository
+}
+
+func NewAPIKeyHandlers(keys domain.APIKeyRepository) *APIKeyHandlers {
+	return &APIKeyHandlers{
+		keys: keys,
+	}
+}
+
+func (h *APIKeyHandlers) CreateAPIKey(c *gin.Context) {
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	now := time.Now()
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expiresAt must be in the future"})
+		return
+	}
+
+	key, keyHash, prefix, err := auth.GenerateAPIKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate API key"})
+		return
+	}
+
+	scopes := req.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	apiKey := &domain.APIKey{
+		ID:        uuid.New().String(),
+		Name:      req.Name,
+		OwnerID:   req.OwnerID,
+		Scopes:    scopes,
+		Prefix:    prefix,
+		ExpiresAt: req.ExpiresAt,
+		CreatedBy: c.GetString("userId"),
+		CreatedAt: now,
+	}
+
+	middleware.SetAuditDetail(c, "apiKeyId", apiKey.ID)
+	middleware.SetAuditDetail(c, "ownerId", apiKey.OwnerID)
+	middleware.SetAuditDetail(c, "scopes", strings.Join(apiKey.Scopes, " "))
+
+	if err := h.keys.Create(c.Request.Context(), apiKey, keyHash); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, CreateAPIKeyResponse{APIKey: apiKey, Key: key})
+}
+
+func (h *APIKeyHandlers) ListAPIKeys(c *gin.Context) {
+	keys, err := h.keys.List(c.Request.Context(), c.Query("ownerId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list API keys"})
+		return
+	}
+
+	if keys == nil {
+		keys = []*domain.APIKey{}
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+func (h *APIKeyHandlers) RevokeAPIKey(c *gin.Context) {
+	err := h.keys.Revoke(c.Request.Context(), c.Param("keyId"), time.Now())
+	if errors.Is(err, domain.ErrAPIKeyNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,114 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"file-storage-go/pkg/domain"
+	"file-storage-go/pkg/ecsslog"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+type AuditHandlers struct {
+	auditLog domain.AuditLog
+	logger   *slog.Logger
+}
+
+func NewAuditHandlers(auditLog domain.AuditLog, logger *slog.Logger) *AuditHandlers {
+	return &AuditHandlers{
+		auditLog: auditLog,
+		logger:   logger,
+	}
+}
+
+func (h *AuditHandlers) QueryEvents(c *gin.Context) {
+	filter, err := auditEventFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter.Limit, err = queryInt(c, "limit", defaultAuditLimit)
+	if err != nil || filter.Limit < 1 || filter.Limit > maxAuditLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+	filter.Offset, err = queryInt(c, "offset", 0)
+	if err != nil || filter.Offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset"})
+		return
+	}
+
+	events, err := h.auditLog.Query(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query audit events"})
+		return
+	}
+
+	if events == nil {
+		events = []*domain.AuditEvent{}
+	}
+	c.JSON(http.StatusOK, gin.H{"events": events, "limit": filter.Limit, "offset": filter.Offset})
+}
+
+// ExportEvents streams every matching event as newline delimited JSON.
+func (h *AuditHandlers) ExportEvents(c *gin.Context) {
+	filter, err := auditEventFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", "attachment; filename=audit-events.ndjson")
+	c.Status(http.StatusOK)
+
+	encoder := json.NewEncoder(c.Writer)
+	err = h.auditLog.Export(c.Request.Context(), filter, func(event *domain.AuditEvent) error {
+		return encoder.Encode(event)
+	})
+	if err != nil {
+		// The status has been sent already; a truncated export is only
+		// visible in the log and as a missing trailing newline.
+		h.logger.LogAttrs(c.Request.Context(), slog.LevelError, "Failed to export audit events",
+			ecsslog.Action("audit.export"), ecsslog.UserID(c.GetString("userId")), ecsslog.Err(err))
+		c.Error(err)
+	}
+}
+
+func auditEventFilter(c *gin.Context) (domain.AuditEventFilter, error) {
+	filter := domain.AuditEventFilter{
+		Action:             domain.AuditAction(c.Query("action")),
+		Outcome:            domain.AuditOutcome(c.Query("outcome")),
+		UserID:             c.Query("userId"),
+		FileID:             c.Query("fileId"),
+		LinkedResourceType: c.Query("linkedResourceType"),
+		LinkedResourceID:   c.Query("linkedResourceId"),
+	}
+
+	var err error
+	if filter.From, err = queryTime(c, "from"); err != nil {
+		return filter, errors.New("Invalid from, expected RFC 3339")
+	}
+	if filter.To, err = queryTime(c, "to"); err != nil {
+		return filter, errors.New("Invalid to, expected RFC 3339")
+	}
+	return filter, nil
+}
+
+func queryTime(c *gin.Context, name string) (time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -1,11 +1,21 @@
 package http
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"file-storage-go/pkg/adapters/webhook"
+	"file-storage-go/pkg/contenttype"
 	"file-storage-go/pkg/domain"
+	"file-storage-go/pkg/middleware"
+	"file-storage-go/pkg/requestid"
+	"file-storage-go/pkg/tracing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -16,25 +26,37 @@ type CreateUploadJobRequest struct {
 	FileType           string `json:"fileType" binding:"required"`
 	LinkedResourceType string `json:"linkedResourceType" binding:"required"`
 	LinkedResourceID   string `json:"linkedResourceID" binding:"required"`
+	CallbackURL        string `json:"callbackUrl"`
 }
 
 type Handlers struct {
 	fileStorage       domain.FileStorage
 	jobRepo           domain.UploadJobRepository
 	fileInfoRepo      domain.FileInfoRepository
+	unitOfWork        domain.UnitOfWork
 	fileAuthorization domain.FileAuthorization
+	contentTypePolicy contenttype.MismatchPolicy
+	thumbnails        domain.ThumbnailStore
+	webhooks          domain.WebhookRepository
+	webhookHosts      []string
 }
 
-func NewHandlers(fileStorage domain.FileStorage, jobRepo domain.UploadJobRepository, fileInfoRepo domain.FileInfoRepository, fileAuthorization domain.FileAuthorization) *Handlers {
+func NewHandlers(fileStorage domain.FileStorage, jobRepo domain.UploadJobRepository, fileInfoRepo domain.FileInfoRepository, unitOfWork domain.UnitOfWork, fileAuthorization domain.FileAuthorization, contentTypePolicy contenttype.MismatchPolicy, thumbnails domain.ThumbnailStore, webhooks domain.WebhookRepository, webhookHosts []string) *Handlers {
 	return &Handlers{
 		fileStorage:       fileStorage,
 		jobRepo:           jobRepo,
 		fileInfoRepo:      fileInfoRepo,
+		unitOfWork:        unitOfWork,
 		fileAuthorization: fileAuthorization,
+		contentTypePolicy: contentTypePolicy,
+		thumbnails:        thumbnails,
+		webhooks:          webhooks,
+		webhookHosts:      webhookHosts,
 	}
 }
 
 func (h *Handlers) CreateUploadJob(c *gin.Context) {
+	ctx := c.Request.Context()
 	userID := c.GetString("userId")
 
 	var req CreateUploadJobRequest
@@ -43,7 +65,19 @@ func (h *Handlers) CreateUploadJob(c *gin.Context) {
 		return
 	}
 
-	authorized, err := h.fileAuthorization.CanUploadFile(userID, req.FileType, req.LinkedResourceType, req.LinkedResourceID)
+	if req.CallbackURL != "" {
+		if h.webhooks == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Webhooks are not enabled"})
+			return
+		}
+		if err := webhook.CheckURL(req.CallbackURL, h.webhookHosts); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	middleware.SetAuditResource(c, "", req.LinkedResourceType, req.LinkedResourceID)
+	authorized, err := h.fileAuthorization.CanUploadFile(ctx, userID, req.FileType, req.LinkedResourceType, req.LinkedResourceID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Authorization check failed"})
 		return
@@ -56,6 +90,8 @@ func (h *Handlers) CreateUploadJob(c *gin.Context) {
 	jobID := uuid.New().String()
 	fileID := uuid.New().String()
 	now := time.Now()
+	middleware.SetAuditResource(c, fileID, "", "")
+	middleware.SetAuditDetail(c, "jobId", jobID)
 
 	fileInfo := &domain.FileInfo{
 		ID:                 fileID,
@@ -67,11 +103,6 @@ func (h *Handlers) CreateUploadJob(c *gin.Context) {
 		UpdatedAt:          now,
 	}
 
-	if err := h.fileInfoRepo.Create(c.Request.Context(), fileInfo); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create file record: " + err.Error()})
-		return
-	}
-
 	job := &domain.UploadJob{
 		ID:              jobID,
 		CreatedByUserId: userID,
@@ -79,12 +110,47 @@ func (h *Handlers) CreateUploadJob(c *gin.Context) {
 		Status:          domain.JobStatusUploading,
 		CreatedAt:       now,
 		UpdatedAt:       now,
+		RequestID:       requestid.FromContext(ctx),
 	}
 
-	if err := h.jobRepo.Create(c.Request.Context(), job); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// The file info, job and callback subscription are created together, so
+	// a failure leaves none of them behind.
+	failure := ""
+	err = h.unitOfWork.Do(ctx, func(repos domain.Repositories) error {
+		if err := repos.FileInfos.Create(ctx, fileInfo); err != nil {
+			failure = "Failed to create file record: " + err.Error()
+			return err
+		}
+		if err := repos.Jobs.Create(ctx, job); err != nil {
+			failure = err.Error()
+			return err
+		}
+		if req.CallbackURL == "" {
+			return nil
+		}
+		subscription := &domain.WebhookSubscription{
+			ID:        uuid.New().String(),
+			URL:       req.CallbackURL,
+			JobID:     jobID,
+			CreatedBy: userID,
+			CreatedAt: now,
+		}
+		if err := repos.Webhooks.CreateSubscription(ctx, subscription); err != nil {
+			failure = "Failed to register callback URL"
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		if failure == "" {
+			failure = err.Error()
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": failure})
 		return
 	}
+	if req.CallbackURL != "" {
+		middleware.SetAuditDetail(c, "callbackUrl", req.CallbackURL)
+	}
 
 	c.JSON(http.StatusCreated, ToAPIJob(job))
 }
@@ -159,11 +225,54 @@ func (h *Handlers) UploadFile(c *gin.Context) {
 	}
 	defer src.Close()
 
+	fileInfo, err := h.fileInfoRepo.Get(ctx, job.FileID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file info"})
+		return
+	}
+	if fileInfo == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	middleware.SetAuditResource(c, fileInfo.ID, fileInfo.LinkedResourceType, fileInfo.LinkedResourceID)
+
+	detectedType, content, err := contenttype.Sniff(src)
+	if err != nil {
+		job.Status = domain.JobStatusFailed
+		job.Error = "Failed to read file"
+		job.UpdatedAt = time.Now()
+		h.jobRepo.Update(ctx, job)
+		c.JSON(http.StatusBadRequest, ToAPIJob(job))
+		return
+	}
+
+	declaredType := fileInfo.FileType
+	if contenttype.Normalize(declaredType) == "" {
+		declaredType = fileHeader.Header.Get("Content-Type")
+	}
+
+	fileInfo.DetectedMimeType = detectedType
+	fileInfo.ContentTypeMismatch = !contenttype.Matches(declaredType, detectedType) || !contenttype.MatchesFilename(fileInfo.Filename, detectedType)
+	fileInfo.UpdatedAt = time.Now()
+	if err := h.fileInfoRepo.Update(ctx, fileInfo); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update file info"})
+		return
+	}
+
+	if fileInfo.ContentTypeMismatch && h.contentTypePolicy == contenttype.MismatchPolicyReject {
+		job.Status = domain.JobStatusFailed
+		job.Error = fmt.Sprintf("Content type mismatch: declared %q as %q, detected %s", fileInfo.Filename, declaredType, detectedType)
+		job.UpdatedAt = time.Now()
+		h.jobRepo.Update(ctx, job)
+		c.JSON(http.StatusUnprocessableEntity, ToAPIJob(job))
+		return
+	}
+
 	job.Status = domain.JobStatusUploading
 	job.UpdatedAt = time.Now()
 	h.jobRepo.Update(ctx, job)
 
-	err = h.fileStorage.Upload(ctx, job.FileID, src)
+	err = h.fileStorage.Upload(ctx, job.FileID, content)
 	if err != nil {
 		job.Status = domain.JobStatusFailed
 		job.Error = err.Error()
@@ -175,6 +284,8 @@ func (h *Handlers) UploadFile(c *gin.Context) {
 
 	job.Status = domain.JobStatusVirusCheckPending
 	job.UpdatedAt = time.Now()
+	job.TraceParent = tracing.TraceParent(ctx)
+	job.RequestID = requestid.FromContext(ctx)
 	h.jobRepo.Update(ctx, job)
 
 	c.JSON(http.StatusCreated, ToAPIJob(job))
@@ -185,7 +296,7 @@ func (h *Handlers) GetFileInfo(c *gin.Context) {
 	fileID := c.Param("fileId")
 	userID := c.GetString("userId")
 
-	authorized, err := h.fileAuthorization.CanReadFile(userID, fileID)
+	authorized, err := h.fileAuthorization.CanReadFile(ctx, userID, fileID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Authorization check failed"})
 		return
@@ -204,6 +315,7 @@ func (h *Handlers) GetFileInfo(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
 		return
 	}
+	middleware.SetAuditResource(c, fileInfo.ID, fileInfo.LinkedResourceType, fileInfo.LinkedResourceID)
 
 	c.JSON(http.StatusOK, fileInfo)
 }
@@ -213,7 +325,7 @@ func (h *Handlers) DownloadFile(c *gin.Context) {
 	fileID := c.Param("fileId")
 	userID := c.GetString("userId")
 
-	authorized, err := h.fileAuthorization.CanReadFile(userID, fileID)
+	authorized, err := h.fileAuthorization.CanReadFile(ctx, userID, fileID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Authorization check failed"})
 		return
@@ -232,24 +344,50 @@ func (h *Handlers) DownloadFile(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
 		return
 	}
+	middleware.SetAuditResource(c, fileInfo.ID, fileInfo.LinkedResourceType, fileInfo.LinkedResourceID)
 
-	reader, err := h.fileStorage.Download(ctx, fileID)
+	reader, contentEncoding, err := h.downloadForClient(c, fileID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to download file"})
 		return
 	}
 	defer reader.Close()
 
+	contentType := fileInfo.DetectedMimeType
+	if contentType == "" {
+		contentType = fileInfo.FileType
+	}
+
 	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileInfo.Filename))
-	c.DataFromReader(http.StatusOK, -1, fileInfo.FileType, reader, nil)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Vary", "Accept-Encoding")
+	if contentEncoding != "" {
+		c.Header("Content-Encoding", contentEncoding)
+	}
+	c.DataFromReader(http.StatusOK, -1, contentType, reader, nil)
 }
 
-func (h *Handlers) DeleteFile(c *gin.Context) {
+func (h *Handlers) GetThumbnail(c *gin.Context) {
 	ctx := c.Request.Context()
 	fileID := c.Param("fileId")
 	userID := c.GetString("userId")
 
-	authorized, err := h.fileAuthorization.CanDeleteFile(userID, fileID)
+	if h.thumbnails == nil || len(h.thumbnails.Sizes()) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thumbnails are not enabled"})
+		return
+	}
+
+	size := h.thumbnails.Sizes()[0]
+	if sizeParam := c.Query("size"); sizeParam != "" {
+		parsed, err := strconv.Atoi(sizeParam)
+		if err != nil || !slices.Contains(h.thumbnails.Sizes(), parsed) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid thumbnail size", "sizes": h.thumbnails.Sizes()})
+			return
+		}
+		size = parsed
+	}
+
+	authorized, err := h.fileAuthorization.CanReadFile(ctx, userID, fileID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Authorization check failed"})
 		return
@@ -259,49 +397,152 @@ func (h *Handlers) DeleteFile(c *gin.Context) {
 		return
 	}
 
-	job, err := h.jobRepo.GetByFileID(ctx, fileID)
+	fileInfo, err := h.fileInfoRepo.Get(ctx, fileID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file info"})
+		return
+	}
+	if fileInfo == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	middleware.SetAuditResource(c, fileInfo.ID, fileInfo.LinkedResourceType, fileInfo.LinkedResourceID)
+
+	reader, err := h.thumbnails.OpenThumbnail(ctx, fileID, size)
+	if errors.Is(err, domain.ErrFileNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thumbnail not found"})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get job details"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get thumbnail"})
 		return
 	}
+	defer reader.Close()
 
-	if job != nil {
-		job.Status = domain.JobStatusDeleted
-		job.UpdatedAt = time.Now()
-		if err := h.jobRepo.Update(ctx, job); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update job status"})
-			return
-		}
+	contentType, content, err := contenttype.Sniff(reader)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read thumbnail"})
+		return
 	}
 
-	fileInfo, err := h.fileInfoRepo.Get(ctx, fileID)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Cache-Control", "private, max-age=3600")
+	c.DataFromReader(http.StatusOK, -1, contentType, content, nil)
+}
+
+func (h *Handlers) DeleteFile(c *gin.Context) {
+	ctx := c.Request.Context()
+	fileID := c.Param("fileId")
+	userID := c.GetString("userId")
+
+	authorized, err := h.fileAuthorization.CanDeleteFile(ctx, userID, fileID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch file info for authorization removal"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Authorization check failed"})
+		return
+	}
+	if !authorized {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 		return
 	}
 
-	if fileInfo != nil {
-		if err := h.fileAuthorization.RemoveFileAuthorization(fileInfo.ID, fileInfo.FileType, fileInfo.LinkedResourceID, fileInfo.LinkedResourceType); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove file authorization"})
-			return
+	// The job is marked DELETED, the file info deleted and the file
+	// unregistered from authorization together, so a failure leaves none of
+	// them changed. The blob and its thumbnails are deleted by the outbox
+	// relay once the file.deleted event is committed.
+	var fileInfo *domain.FileInfo
+	failure := ""
+	err = h.unitOfWork.Do(ctx, func(repos domain.Repositories) error {
+		job, err := repos.Jobs.GetByFileID(ctx, fileID)
+		if err != nil {
+			failure = "Failed to get job details"
+			return err
 		}
-
-		if err := h.fileInfoRepo.Delete(ctx, fileID); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete file info"})
-			return
+		if job != nil {
+			job.Status = domain.JobStatusDeleted
+			job.UpdatedAt = time.Now()
+			if err := repos.Jobs.Update(ctx, job); err != nil {
+				failure = "Failed to update job status"
+				return err
+			}
 		}
-	}
 
-	err = h.fileStorage.Delete(ctx, fileID)
+		fileInfo, err = repos.FileInfos.Get(ctx, fileID)
+		if err != nil {
+			failure = "Failed to fetch file info for authorization removal"
+			return err
+		}
+		if fileInfo == nil {
+			return nil
+		}
+		if err := repos.FileInfos.Delete(ctx, fileID); err != nil {
+			failure = "Failed to delete file info"
+			return err
+		}
+		if err := repos.FileAuthorizations.RemoveFileAuthorization(ctx, fileInfo.ID, fileInfo.FileType, fileInfo.LinkedResourceID, fileInfo.LinkedResourceType); err != nil {
+			failure = "Failed to remove file authorization"
+			return err
+		}
+		return nil
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete file"})
+		if failure == "" {
+			failure = "Failed to delete file info"
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": failure})
+		return
+	}
+	if fileInfo == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
 		return
 	}
 
+	middleware.SetAuditResource(c, fileInfo.ID, fileInfo.LinkedResourceType, fileInfo.LinkedResourceID)
 	c.Status(http.StatusNoContent)
 }
 
+func (h *Handlers) downloadForClient(c *gin.Context, fileID string) (io.ReadCloser, string, error) {
+	ctx := c.Request.Context()
+
+	if encodedStorage, ok := h.fileStorage.(domain.EncodedFileStorage); ok {
+		reader, encoding, err := encodedStorage.DownloadEncoded(ctx, fileID)
+		if err != nil {
+			return nil, "", err
+		}
+		if encoding == domain.ContentEncodingIdentity {
+			return reader, "", nil
+		}
+		if acceptsEncoding(c.GetHeader("Accept-Encoding"), encoding) {
+			return reader, encoding, nil
+		}
+		reader.Close()
+	}
+
+	reader, err := h.fileStorage.Download(ctx, fileID)
+	return reader, "", err
+}
+
+func acceptsEncoding(header, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.TrimSpace(name)
+		if !strings.EqualFold(name, encoding) && name != "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q="); ok {
+			if weight, err := strconv.ParseFloat(q, 64); err == nil && weight == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
 func (h *Handlers) validateUserAccess(c *gin.Context, job *domain.UploadJob) error {
+	return validateJobOwner(c, job)
+}
+
+func validateJobOwner(c *gin.Context, job *domain.UploadJob) error {
 	userID := c.GetString("userId")
 
 	if job.CreatedByUserId != userID {
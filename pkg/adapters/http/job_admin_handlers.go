@@ -0,0 +1,265 @@
+package http
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"file-storage-go/pkg/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultAdminJobLimit = 100
+	maxAdminJobLimit     = 1000
+)
+
+var jobStatuses = []domain.JobStatus{
+	domain.JobStatusPending,
+	domain.JobStatusUploading,
+	domain.JobStatusVirusCheckPending,
+	domain.JobStatusVirusChecking,
+	domain.JobStatusCompleted,
+	domain.JobStatusFailed,
+	domain.JobStatusDeleted,
+}
+
+// defaultPurgeStatuses are the statuses of jobs that will not make progress
+// on their own.
+var defaultPurgeStatuses = []domain.JobStatus{
+	domain.JobStatusPending,
+	domain.JobStatusUploading,
+	domain.JobStatusFailed,
+}
+
+type FailJobRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+type PurgeJobsRequest struct {
+	// OlderThan is a duration such as "72h"; only jobs that were not updated
+	// for that long are purged.
+	OlderThan string             `json:"olderThan" binding:"required"`
+	Statuses  []domain.JobStatus `json:"statuses"`
+}
+
+// JobAdminHandlers lets operators inspect and repair upload jobs and control
+// the virus scanner.
+type JobAdminHandlers struct {
+	jobRepo    domain.UploadJobRepository
+	unitOfWork domain.UnitOfWork
+	scanner    domain.ScannerControl
+}
+
+func NewJobAdminHandlers(jobRepo domain.UploadJobRepository, unitOfWork domain.UnitOfWork, scanner domain.ScannerControl) *JobAdminHandlers {
+	return &JobAdminHandlers{
+		jobRepo:    jobRepo,
+		unitOfWork: unitOfWork,
+		scanner:    scanner,
+	}
+}
+
+func (h *JobAdminHandlers) ListJobs(c *gin.Context) {
+	filter := domain.UploadJobFilter{
+		Status: domain.JobStatus(c.Query("status")),
+		Limit:  defaultAdminJobLimit,
+	}
+	if filter.Status != "" && !slices.Contains(jobStatuses, filter.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status", "statuses": jobStatuses})
+		return
+	}
+	if olderThan := c.Query("olderThan"); olderThan != "" {
+		age, err := time.ParseDuration(olderThan)
+		if err != nil || age < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid olderThan duration"})
+			return
+		}
+		filter.UpdatedBefore = time.Now().Add(-age)
+	}
+	if limit := c.Query("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 1 || parsed > maxAdminJobLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		filter.Limit = parsed
+	}
+
+	jobs, err := h.jobRepo.List(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list jobs"})
+		return
+	}
+
+	if jobs == nil {
+		jobs = []*domain.UploadJob{}
+	}
+	c.JSON(http.StatusOK, jobs)
+}
+
+// RescanJob scans the file of a completed or failed job again, e.g. after the
+// virus signatures were updated. A completed file keeps its authorization
+// until the rescan finds it infected.
+func (h *JobAdminHandlers) RescanJob(c *gin.Context) {
+	h.transition(c, []domain.JobStatus{domain.JobStatusCompleted, domain.JobStatusFailed}, func(job *domain.UploadJob) bool {
+		if job.FileID == "" {
+			return false
+		}
+		if job.Status == domain.JobStatusCompleted {
+			job.Rescan = true
+		}
+		job.Status = domain.JobStatusVirusCheckPending
+		job.Error = ""
+		return true
+	})
+}
+
+// RequeueJob puts a failed job, or one that is stuck in the scanner, back into
+// the scan queue. A job that is still being scanned within the stuck job
+// timeout cannot be requeued, so it is never scanned twice at once.
+func (h *JobAdminHandlers) RequeueJob(c *gin.Context) {
+	h.transition(c, []domain.JobStatus{domain.JobStatusVirusChecking, domain.JobStatusFailed}, func(job *domain.UploadJob) bool {
+		if job.FileID == "" {
+			return false
+		}
+		if job.Status == domain.JobStatusVirusChecking && !h.scanner.IsStuck(job, time.Now()) {
+			return false
+		}
+		job.Status = domain.JobStatusVirusCheckPending
+		job.Error = ""
+		return true
+	})
+}
+
+func (h *JobAdminHandlers) FailJob(c *gin.Context) {
+	var req FailJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	from := []domain.JobStatus{
+		domain.JobStatusPending,
+		domain.JobStatusUploading,
+		domain.JobStatusVirusCheckPending,
+		domain.JobStatusVirusChecking,
+	}
+	h.transition(c, from, func(job *domain.UploadJob) bool {
+		job.Status = domain.JobStatusFailed
+		job.Error = "failed by " + c.GetString("userId") + ": " + req.Reason
+		return true
+	})
+}
+
+// transition changes the job's status only if it was not changed since it was
+// read, e.g. by the scanner.
+func (h *JobAdminHandlers) transition(c *gin.Context, from []domain.JobStatus, apply func(job *domain.UploadJob) bool) {
+	ctx := c.Request.Context()
+	job, err := h.jobRepo.Get(ctx, c.Param("jobId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get job"})
+		return
+	}
+	if job == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+
+	previous := job.Status
+	if !slices.Contains(from, previous) || !apply(job) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Job cannot be changed in its current status", "status": previous})
+		return
+	}
+
+	job.UpdatedAt = time.Now()
+	updated, err := h.jobRepo.UpdateIfStatus(ctx, job, previous)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update job"})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusConflict, gin.H{"error": "Job was changed concurrently, try again"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+func (h *JobAdminHandlers) PurgeJobs(c *gin.Context) {
+	var req PurgeJobsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	age, err := time.ParseDuration(req.OlderThan)
+	if err != nil || age <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid olderThan duration"})
+		return
+	}
+
+	statuses := req.Statuses
+	if len(statuses) == 0 {
+		statuses = defaultPurgeStatuses
+	}
+	for _, status := range statuses {
+		if !slices.Contains(defaultPurgeStatuses, status) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only stale jobs can be purged", "statuses": defaultPurgeStatuses})
+			return
+		}
+	}
+
+	// The files of purged jobs are deleted as by DeleteFile, together with
+	// the jobs, so that no file info or authorization is left behind. Their
+	// blobs are deleted from the file.deleted events.
+	ctx := c.Request.Context()
+	var purged []*domain.UploadJob
+	err = h.unitOfWork.Do(ctx, func(repos domain.Repositories) error {
+		var err error
+		purged, err = repos.Jobs.Purge(ctx, statuses, time.Now().Add(-age))
+		if err != nil {
+			return err
+		}
+		for _, job := range purged {
+			if job.FileID == "" {
+				continue
+			}
+			fileInfo, err := repos.FileInfos.Get(ctx, job.FileID)
+			if err != nil {
+				return err
+			}
+			if fileInfo == nil {
+				continue
+			}
+			if err := repos.FileInfos.Delete(ctx, fileInfo.ID); err != nil {
+				return err
+			}
+			if err := repos.FileAuthorizations.RemoveFileAuthorization(ctx, fileInfo.ID, fileInfo.FileType, fileInfo.LinkedResourceID, fileInfo.LinkedResourceType); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge jobs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"purged": len(purged)})
+}
+
+func (h *JobAdminHandlers) GetScannerState(c *gin.Context) {
+	c.JSON(http.StatusOK, h.scanner.State())
+}
+
+func (h *JobAdminHandlers) PauseScanner(c *gin.Context) {
+	h.scanner.Pause()
+	c.JSON(http.StatusOK, h.scanner.State())
+}
+
+func (h *JobAdminHandlers) ResumeScanner(c *gin.Context) {
+	h.scanner.Resume()
+	c.JSON(http.StatusOK, h.scanner.State())
+}
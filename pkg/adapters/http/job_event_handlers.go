@@ -0,0 +1,166 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"file-storage-go/pkg/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxWaitTimeout = 60 * time.Second
+
+	// jobRecheckInterval bounds how long a missed change notification can
+	// delay an event.
+	jobRecheckInterval = 5 * time.Second
+	keepAliveInterval  = 15 * time.Second
+)
+
+type JobEventHandlers struct {
+	jobRepo domain.UploadJobRepository
+	watcher domain.JobWatcher
+}
+
+func NewJobEventHandlers(jobRepo domain.UploadJobRepository, watcher domain.JobWatcher) *JobEventHandlers {
+	return &JobEventHandlers{
+		jobRepo: jobRepo,
+		watcher: watcher,
+	}
+}
+
+func isTerminal(status domain.JobStatus) bool {
+	return status == domain.JobStatusCompleted || status == domain.JobStatusFailed || status == domain.JobStatusDeleted
+}
+
+// getOwnJob responds with an error and returns nil unless the job exists and
+// belongs to the user.
+func (h *JobEventHandlers) getOwnJob(ctx context.Context, c *gin.Context) *domain.UploadJob {
+	job, err := h.jobRepo.Get(ctx, c.Param("jobId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil
+	}
+	if job == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload job not found"})
+		return nil
+	}
+	if err := validateJobOwner(c, job); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return nil
+	}
+	return job
+}
+
+// StreamJobEvents sends the job as a "status" Server-Sent Event now and after
+// every status change, until the job reaches a terminal status or the client
+// disconnects.
+func (h *JobEventHandlers) StreamJobEvents(c *gin.Context) {
+	ctx := c.Request.Context()
+	changed, stop := h.watcher.Watch(c.Param("jobId"))
+	defer stop()
+
+	job := h.getOwnJob(ctx, c)
+	if job == nil {
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	recheck := time.NewTicker(jobRecheckInterval)
+	defer recheck.Stop()
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	lastStatus := job.Status
+	c.SSEvent("status", ToAPIJob(job))
+	c.Writer.Flush()
+
+	for !isTerminal(lastStatus) {
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepAlive.C:
+			c.Writer.WriteString(": keep-alive\n\n")
+			c.Writer.Flush()
+			continue
+		case <-changed:
+		case <-recheck.C:
+		}
+
+		current, err := h.jobRepo.Get(ctx, job.ID)
+		if err != nil {
+			continue
+		}
+		if current == nil {
+			return
+		}
+		if current.Status != lastStatus {
+			lastStatus = current.Status
+			c.SSEvent("status", ToAPIJob(current))
+			c.Writer.Flush()
+		}
+	}
+}
+
+// WaitForJobStatus holds a GET /upload-jobs/:jobId request with a waitFor
+// status until the job reaches that or a terminal status, or until timeout
+// (default 30s, at most 60s), and then passes it on, so the status handler
+// responds with the current job.
+func (h *JobEventHandlers) WaitForJobStatus(c *gin.Context) {
+	waitFor := JobStatus(c.Query("waitFor"))
+	if waitFor == "" {
+		return
+	}
+	switch waitFor {
+	case JobStatusPending, JobStatusUploading, JobStatusChecking, JobStatusCompleted, JobStatusFailed:
+	default:
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid waitFor status"})
+		return
+	}
+
+	timeout := 30 * time.Second
+	if raw := c.Query("timeout"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 || parsed > maxWaitTimeout {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "timeout must be a duration of at most 60s"})
+			return
+		}
+		timeout = parsed
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+	changed, stop := h.watcher.Watch(c.Param("jobId"))
+	defer stop()
+
+	job := h.getOwnJob(ctx, c)
+	if job == nil {
+		c.Abort()
+		return
+	}
+
+	recheck := time.NewTicker(jobRecheckInterval)
+	defer recheck.Stop()
+
+	for toAPIJobStatus(job.Status) != waitFor && !isTerminal(job.Status) {
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+		case <-recheck.C:
+		}
+
+		current, err := h.jobRepo.Get(ctx, job.ID)
+		if err != nil || current == nil {
+			return
+		}
+		job = current
+	}
+}
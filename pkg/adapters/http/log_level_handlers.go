@@ -0,0 +1,57 @@
+package http
+
+import (
+	"log/slog"
+	"net/http"
+
+	"file-storage-go/pkg/ecsslog"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SetLogLevelRequest struct {
+	Level string `json:"level" binding:"required"`
+}
+
+// LogLevelHandlers change the level of the service's logger at runtime. The
+// change is not persisted; a restart goes back to LOG_LEVEL.
+type LogLevelHandlers struct {
+	level  *slog.LevelVar
+	logger *slog.Logger
+}
+
+func NewLogLevelHandlers(level *slog.LevelVar, logger *slog.Logger) *LogLevelHandlers {
+	return &LogLevelHandlers{
+		level:  level,
+		logger: logger,
+	}
+}
+
+func (h *LogLevelHandlers) GetLogLevel(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"level": h.level.Level().String()})
+}
+
+func (h *LogLevelHandlers) SetLogLevel(c *gin.Context) {
+	var req SetLogLevelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(req.Level)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid level, expected debug, info, warn or error"})
+		return
+	}
+
+	previous := h.level.Level()
+	h.level.Set(level)
+
+	// Logged as a warning so that the change shows unless only errors are
+	// logged.
+	h.logger.LogAttrs(c.Request.Context(), slog.LevelWarn, "Log level changed",
+		ecsslog.Action("log.level_change"), ecsslog.UserID(c.GetString("userId")),
+		slog.String("log_level.previous", previous.String()), slog.String("log_level.current", level.String()))
+
+	c.JSON(http.StatusOK, gin.H{"level": level.String()})
+}
@@ -58,3 +58,15 @@ func ToAPIJob(job *domain.UploadJob) *UploadJob {
 		Error:           job.Error,
 	}
 }
+
+type SearchResult struct {
+	File      *domain.FileInfo `json:"file"`
+	Rank      float64          `json:"rank"`
+	Highlight string           `json:"highlight"`
+}
+
+type SearchResponse struct {
+	Results []*SearchResult `json:"results"`
+	Limit   int             `json:"limit"`
+	Offset  int             `json:"offset"`
+}
@@ -0,0 +1,127 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"file-storage-go/pkg/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	maxSearchScanned   = 1000
+)
+
+type SearchHandlers struct {
+	searchIndex       domain.FileSearchIndex
+	fileInfoRepo      domain.FileInfoRepository
+	fileAuthorization domain.FileAuthorization
+}
+
+func NewSearchHandlers(searchIndex domain.FileSearchIndex, fileInfoRepo domain.FileInfoRepository, fileAuthorization domain.FileAuthorization) *SearchHandlers {
+	return &SearchHandlers{
+		searchIndex:       searchIndex,
+		fileInfoRepo:      fileInfoRepo,
+		fileAuthorization: fileAuthorization,
+	}
+}
+
+// SearchFiles pages through the ranked index hits and keeps those the user may
+// read, so limit and offset apply to the authorized results only. Each page of
+// hits is authorized with one batched check.
+func (h *SearchHandlers) SearchFiles(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := c.GetString("userId")
+
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter q is required"})
+		return
+	}
+
+	limit, err := queryInt(c, "limit", defaultSearchLimit)
+	if err != nil || limit < 1 || limit > maxSearchLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+	offset, err := queryInt(c, "offset", 0)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset"})
+		return
+	}
+
+	results := make([]*SearchResult, 0, limit)
+	skipped := 0
+	batchSize := limit * 2
+
+	for scanned := 0; scanned < maxSearchScanned && len(results) < limit; {
+		batch, err := h.searchIndex.Search(ctx, query, batchSize, scanned)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Search failed"})
+			return
+		}
+
+		fileIDs := make([]string, len(batch))
+		for i, hit := range batch {
+			fileIDs[i] = hit.FileID
+		}
+		fileInfos, err := h.fileInfoRepo.GetMany(ctx, fileIDs)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file info"})
+			return
+		}
+		readable, err := h.fileAuthorization.FilterReadableFiles(ctx, userID, fileInfos)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Authorization check failed"})
+			return
+		}
+		readableByID := make(map[string]*domain.FileInfo, len(readable))
+		for _, fileInfo := range readable {
+			readableByID[fileInfo.ID] = fileInfo
+		}
+
+		for _, hit := range batch {
+			fileInfo, ok := readableByID[hit.FileID]
+			if !ok {
+				continue
+			}
+
+			if skipped < offset {
+				skipped++
+				continue
+			}
+
+			results = append(results, &SearchResult{
+				File:      fileInfo,
+				Rank:      hit.Rank,
+				Highlight: hit.Highlight,
+			})
+			if len(results) == limit {
+				break
+			}
+		}
+
+		if len(batch) < batchSize {
+			break
+		}
+		scanned += len(batch)
+	}
+
+	c.JSON(http.StatusOK, SearchResponse{
+		Results: results,
+		Limit:   limit,
+		Offset:  offset,
+	})
+}
+
+func queryInt(c *gin.Context, name string, defaultValue int) (int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(value)
+}
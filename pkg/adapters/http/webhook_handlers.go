@@ -0,0 +1,141 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"file-storage-go/pkg/adapters/webhook"
+	"file-storage-go/pkg/domain"
+	"file-storage-go/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const maxWebhookDeliveriesLimit = 1000
+
+type CreateWebhookSubscriptionRequest struct {
+	URL                string `json:"url" binding:"required"`
+	LinkedResourceType string `json:"linkedResourceType" binding:"required"`
+	Secret             string `json:"secret"`
+}
+
+type WebhookHandlers struct {
+	webhooks     domain.WebhookRepository
+	dispatcher   *webhook.Dispatcher
+	allowedHosts []string
+}
+
+func NewWebhookHandlers(webhooks domain.WebhookRepository, dispatcher *webhook.Dispatcher, allowedHosts []string) *WebhookHandlers {
+	return &WebhookHandlers{
+		webhooks:     webhooks,
+		dispatcher:   dispatcher,
+		allowedHosts: allowedHosts,
+	}
+}
+
+func (h *WebhookHandlers) ListSubscriptions(c *gin.Context) {
+	subscriptions, err := h.webhooks.ListSubscriptions(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list webhook subscriptions"})
+		return
+	}
+
+	if subscriptions == nil {
+		subscriptions = []*domain.WebhookSubscription{}
+	}
+	c.JSON(http.StatusOK, subscriptions)
+}
+
+func (h *WebhookHandlers) CreateSubscription(c *gin.Context) {
+	var req CreateWebhookSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	if err := webhook.CheckURL(req.URL, h.allowedHosts); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	subscription := &domain.WebhookSubscription{
+		ID:                 uuid.New().String(),
+		URL:                req.URL,
+		Secret:             req.Secret,
+		LinkedResourceType: req.LinkedResourceType,
+		CreatedBy:          c.GetString("userId"),
+		CreatedAt:          time.Now(),
+	}
+	middleware.SetAuditDetail(c, "subscriptionId", subscription.ID)
+	middleware.SetAuditDetail(c, "url", subscription.URL)
+
+	if err := h.webhooks.CreateSubscription(c.Request.Context(), subscription); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook subscription"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, subscription)
+}
+
+func (h *WebhookHandlers) DeleteSubscription(c *gin.Context) {
+	err := h.webhooks.DeleteSubscription(c.Request.Context(), c.Param("subscriptionId"))
+	if errors.Is(err, domain.ErrWebhookNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook subscription not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook subscription"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *WebhookHandlers) ListDeliveries(c *gin.Context) {
+	filter := domain.WebhookDeliveryFilter{
+		Status: domain.WebhookDeliveryStatus(c.Query("status")),
+		JobID:  c.Query("jobId"),
+		Limit:  100,
+	}
+	switch filter.Status {
+	case "", domain.WebhookDeliveryPending, domain.WebhookDeliverySucceeded, domain.WebhookDeliveryFailed:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be pending, succeeded or failed"})
+		return
+	}
+	if limit := c.Query("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 1 || parsed > maxWebhookDeliveriesLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+			return
+		}
+		filter.Limit = parsed
+	}
+
+	deliveries, err := h.webhooks.ListDeliveries(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list webhook deliveries"})
+		return
+	}
+
+	if deliveries == nil {
+		deliveries = []*domain.WebhookDelivery{}
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+func (h *WebhookHandlers) Redeliver(c *gin.Context) {
+	delivery, err := h.dispatcher.Redeliver(c.Request.Context(), c.Param("deliveryId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to redeliver webhook"})
+		return
+	}
+	if delivery == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook delivery not found"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
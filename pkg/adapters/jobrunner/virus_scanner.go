@@ -2,12 +2,22 @@ package jobrunner
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"file-storage-go/pkg/domain"
+	"file-storage-go/pkg/ecsslog"
+	"file-storage-go/pkg/requestid"
+	"file-storage-go/pkg/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -15,35 +25,57 @@ const (
 	defaultChannelSize = 100
 )
 
+var (
+	errFileInfoNotFound = errors.New("file info not found")
+	// errJobChanged is returned when the job's status was changed elsewhere,
+	// e.g. by an admin or another worker, while it was being scanned.
+	errJobChanged = errors.New("job status was changed concurrently")
+)
+
 type VirusScannerJobRunner struct {
-	jobRepo           domain.UploadJobRepository
-	fileInfoRepo      domain.FileInfoRepository
-	fileAuthorization domain.FileAuthorization
-	fileStorage       domain.FileStorage
-	virusChecker      domain.VirusChecker
-	workerCount       int
-	stuckJobTimeout   time.Duration
-	metrics           domain.MetricsCollector
+	jobRepo         domain.UploadJobRepository
+	fileInfoRepo    domain.FileInfoRepository
+	unitOfWork      domain.UnitOfWork
+	fileStorage     domain.FileStorage
+	virusChecker    domain.VirusChecker
+	workerCount     int
+	stuckJobTimeout time.Duration
+	metrics         domain.MetricsCollector
+	auditLog        domain.AuditLog
+	postScanStages  []domain.PostScanStage
+	logger          *slog.Logger
+
+	jobsChan      chan *domain.UploadJob
+	paused        atomic.Bool
+	activeWorkers atomic.Int32
+	lastPoll      atomic.Int64
 }
 
 func NewVirusScannerJobRunner(
 	jobRepo domain.UploadJobRepository,
 	fileInfoRepo domain.FileInfoRepository,
-	fileAuthorization domain.FileAuthorization,
+	unitOfWork domain.UnitOfWork,
 	fileStorage domain.FileStorage,
 	virusChecker domain.VirusChecker,
 	stuckJobTimeout time.Duration,
 	metrics domain.MetricsCollector,
+	auditLog domain.AuditLog,
+	logger *slog.Logger,
+	postScanStages ...domain.PostScanStage,
 ) *VirusScannerJobRunner {
 	return &VirusScannerJobRunner{
-		jobRepo:           jobRepo,
-		fileInfoRepo:      fileInfoRepo,
-		fileAuthorization: fileAuthorization,
-		fileStorage:       fileStorage,
-		virusChecker:      virusChecker,
-		workerCount:       defaultWorkerCount,
-		stuckJobTimeout:   stuckJobTimeout,
-		metrics:           metrics,
+		jobRepo:         jobRepo,
+		fileInfoRepo:    fileInfoRepo,
+		unitOfWork:      unitOfWork,
+		fileStorage:     fileStorage,
+		virusChecker:    virusChecker,
+		workerCount:     defaultWorkerCount,
+		stuckJobTimeout: stuckJobTimeout,
+		metrics:         metrics,
+		auditLog:        auditLog,
+		postScanStages:  postScanStages,
+		logger:          logger,
+		jobsChan:        make(chan *domain.UploadJob, defaultChannelSize),
 	}
 }
 
@@ -52,22 +84,26 @@ func (r *VirusScannerJobRunner) Start(ctx context.Context) {
 	defer ticker.Stop()
 
 	var wg sync.WaitGroup
-	jobsChan := make(chan *domain.UploadJob, defaultChannelSize)
 
 	for range make([]struct{}, r.workerCount) {
 		wg.Add(1)
-		go r.worker(ctx, &wg, jobsChan)
+		go r.worker(ctx, &wg, r.jobsChan)
 	}
 
 	for {
 		select {
 		case <-ctx.Done():
-			close(jobsChan)
+			close(r.jobsChan)
 			wg.Wait()
 			return
 		case <-ticker.C:
-			if err := r.queuePendingAndStuckJobs(ctx, jobsChan); err != nil {
-				log.Printf("Error queueing pending and stuck jobs: %v", err)
+			if r.paused.Load() {
+				continue
+			}
+			r.lastPoll.Store(time.Now().UnixNano())
+			if err := r.queuePendingAndStuckJobs(ctx, r.jobsChan); err != nil {
+				r.logger.LogAttrs(ctx, slog.LevelError, "Error queueing pending and stuck jobs",
+					ecsslog.Action("scan.queue"), ecsslog.Err(err))
 			}
 		}
 	}
@@ -77,10 +113,47 @@ func (r *VirusScannerJobRunner) worker(ctx context.Context, wg *sync.WaitGroup,
 	defer wg.Done()
 
 	for job := range jobsChan {
+		// Jobs queued before a pause are dropped; they are still pending in
+		// the repository and are queued again after Resume.
+		if r.paused.Load() {
+			continue
+		}
+
+		r.activeWorkers.Add(1)
 		if err := r.processJob(ctx, job); err != nil {
-			log.Printf("Error processing job %s: %v", job.ID, err)
+			r.logger.LogAttrs(requestid.NewContext(ctx, job.RequestID), slog.LevelError, "Error processing job",
+				ecsslog.Action("file.scan"), ecsslog.JobID(job.ID), ecsslog.FileID(job.FileID), ecsslog.UserID(job.CreatedByUserId), ecsslog.Err(err))
 		}
+		r.activeWorkers.Add(-1)
+	}
+}
+
+// Pause stops polling for jobs. Jobs that are being scanned are finished.
+func (r *VirusScannerJobRunner) Pause() {
+	r.paused.Store(true)
+}
+
+func (r *VirusScannerJobRunner) Resume() {
+	r.paused.Store(false)
+}
+
+func (r *VirusScannerJobRunner) State() domain.ScannerState {
+	state := domain.ScannerState{
+		Paused:        r.paused.Load(),
+		Workers:       r.workerCount,
+		ActiveWorkers: int(r.activeWorkers.Load()),
+		QueueLength:   len(r.jobsChan),
+		QueueCapacity: cap(r.jobsChan),
 	}
+	if lastPoll := r.lastPoll.Load(); lastPoll != 0 {
+		lastPollAt := time.Unix(0, lastPoll)
+		state.LastPollAt = &lastPollAt
+	}
+	return state
+}
+
+func (r *VirusScannerJobRunner) IsStuck(job *domain.UploadJob, now time.Time) bool {
+	return job.Status == domain.JobStatusVirusChecking && now.Sub(job.UpdatedAt) > r.stuckJobTimeout
 }
 
 func (r *VirusScannerJobRunner) queuePendingAndStuckJobs(ctx context.Context, jobsChan chan<- *domain.UploadJob) error {
@@ -104,7 +177,7 @@ func (r *VirusScannerJobRunner) queuePendingAndStuckJobs(ctx context.Context, jo
 
 	now := time.Now()
 	for _, job := range stuckJobs {
-		if now.Sub(job.UpdatedAt) > r.stuckJobTimeout {
+		if r.IsStuck(job, now) {
 			select {
 			case <-ctx.Done():
 				return ctx.Err()
@@ -116,18 +189,41 @@ func (r *VirusScannerJobRunner) queuePendingAndStuckJobs(ctx context.Context, jo
 	return nil
 }
 
-func (r *VirusScannerJobRunner) processJob(ctx context.Context, job *domain.UploadJob) error {
+// processJob runs in a trace of its own, linked to the upload request that
+// queued the job.
+func (r *VirusScannerJobRunner) processJob(ctx context.Context, job *domain.UploadJob) (err error) {
+	options := []trace.SpanStartOption{
+		trace.WithNewRoot(),
+		trace.WithAttributes(attribute.String("job.id", job.ID), attribute.String("file.id", job.FileID)),
+	}
+	if link, ok := tracing.LinkFromTraceParent(job.TraceParent); ok {
+		options = append(options, trace.WithLinks(link))
+	}
+	ctx, span := otel.Tracer("file-storage-go/pkg/adapters/jobrunner").Start(ctx, "VirusScannerJobRunner.processJob", options...)
+	defer func() { tracing.End(span, err) }()
+	if job.RequestID != "" {
+		ctx = requestid.NewContext(ctx, job.RequestID)
+	}
+
 	startTime := time.Now()
 
+	// Only one worker claims a job; a job queued twice or changed since it
+	// was queued is skipped.
+	queuedStatus := job.Status
 	job.Status = domain.JobStatusVirusChecking
 	job.UpdatedAt = time.Now()
-	if err := r.jobRepo.Update(ctx, job); err != nil {
+	claimed, err := r.jobRepo.UpdateIfStatus(ctx, job, queuedStatus)
+	if err != nil {
 		return fmt.Errorf("failed to update job status: %w", err)
 	}
+	if !claimed {
+		return nil
+	}
 
 	reader, err := r.fileStorage.Download(ctx, job.FileID)
 	if err != nil {
 		r.metrics.RecordVirusCheckDuration("error", time.Since(startTime))
+		r.recordScanVerdict(ctx, job, "error")
 		return r.updateJobWithError(ctx, job, fmt.Errorf("failed to download file: %w", err))
 	}
 	defer reader.Close()
@@ -135,34 +231,161 @@ func (r *VirusScannerJobRunner) processJob(ctx context.Context, job *domain.Uplo
 	isClean, err := r.virusChecker.CheckFile(ctx, reader)
 	if err != nil {
 		r.metrics.RecordVirusCheckDuration("error", time.Since(startTime))
+		r.recordScanVerdict(ctx, job, "error")
 		return r.updateJobWithError(ctx, job, fmt.Errorf("virus check failed: %w", err))
 	}
 
 	if !isClean {
 		r.metrics.RecordVirusCheckDuration("virus_detected", time.Since(startTime))
+		r.recordScanVerdict(ctx, job, "infected")
+		if job.Rescan {
+			return r.failRescannedJob(ctx, job, fmt.Errorf("file contains malware"))
+		}
 		return r.updateJobWithError(ctx, job, fmt.Errorf("file contains malware"))
 	}
 
+	r.recordScanVerdict(ctx, job, "clean")
+
 	fileInfo, err := r.fileInfoRepo.Get(ctx, job.FileID)
 	if err != nil {
 		r.metrics.RecordVirusCheckDuration("error", time.Since(startTime))
 		return r.updateJobWithError(ctx, job, fmt.Errorf("failed to get file info: %w", err))
 	}
+	if fileInfo == nil {
+		r.metrics.RecordVirusCheckDuration("error", time.Since(startTime))
+		return r.updateJobWithError(ctx, job, errFileInfoNotFound)
+	}
 
-	if err := r.fileAuthorization.CreateFileAuthorization(job.FileID, fileInfo.FileType, fileInfo.LinkedResourceID, fileInfo.LinkedResourceType); err != nil {
+	// The job only completes if its file info still exists, e.g. was not
+	// deleted during the scan, and the file.created event carries the file
+	// info stored with the completion. The file is registered for
+	// authorization together with the completion.
+	err = r.unitOfWork.Do(ctx, func(repos domain.Repositories) error {
+		current, err := repos.FileInfos.Get(ctx, job.FileID)
+		if err != nil {
+			return fmt.Errorf("failed to get file info: %w", err)
+		}
+		if current == nil {
+			return errFileInfoNotFound
+		}
+		fileInfo = current
+
+		if err := repos.FileAuthorizations.CreateFileAuthorization(ctx, job.FileID, fileInfo.FileType, fileInfo.LinkedResourceID, fileInfo.LinkedResourceType); err != nil {
+			return fmt.Errorf("failed to create file authorization: %w", err)
+		}
+
+		job.Status = domain.JobStatusCompleted
+		job.UpdatedAt = time.Now()
+		updated, err := repos.Jobs.UpdateIfStatus(ctx, job, domain.JobStatusVirusChecking)
+		if err != nil {
+			return fmt.Errorf("failed to update job: %w", err)
+		}
+		if !updated {
+			return errJobChanged
+		}
+		return nil
+	})
+	if errors.Is(err, errJobChanged) {
+		r.metrics.RecordVirusCheckDuration("error", time.Since(startTime))
+		return err
+	}
+	if errors.Is(err, errFileInfoNotFound) {
 		r.metrics.RecordVirusCheckDuration("error", time.Since(startTime))
-		return r.updateJobWithError(ctx, job, fmt.Errorf("failed to create file authorization: %w", err))
+		return r.updateJobWithError(ctx, job, err)
 	}
+	if err != nil {
+		// Nothing was stored; the job is picked up again once it is stuck.
+		job.Status = domain.JobStatusVirusChecking
+		return err
+	}
+	r.metrics.RecordVirusCheckDuration("success", time.Since(startTime))
+
+	r.runPostScanStages(ctx, fileInfo)
+
+	return nil
+}
+
+// recordScanVerdict records the verdict, "clean", "infected" or "error", in
+// the audit log. Infected files are recorded as denied.
+func (r *VirusScannerJobRunner) recordScanVerdict(ctx context.Context, job *domain.UploadJob, verdict string) {
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("scan.verdict", verdict))
+	r.logger.LogAttrs(ctx, slog.LevelDebug, "Scanned file",
+		ecsslog.Action("file.scan"), ecsslog.JobID(job.ID), ecsslog.FileID(job.FileID), slog.String("scan.verdict", verdict))
+
+	if r.auditLog == nil {
+		return
+	}
+
+	outcome := domain.AuditOutcomeSuccess
+	switch verdict {
+	case "infected":
+		outcome = domain.AuditOutcomeDenied
+	case "error":
+		outcome = domain.AuditOutcomeFailure
+	}
+
+	event := &domain.AuditEvent{
+		ID:         uuid.New().String(),
+		OccurredAt: time.Now(),
+		Action:     domain.AuditFileScanned,
+		Outcome:    outcome,
+		UserID:     job.CreatedByUserId,
+		FileID:     job.FileID,
+		Details:    map[string]string{"jobId": job.ID, "verdict": verdict},
+	}
+	if fileInfo, err := r.fileInfoRepo.Get(ctx, job.FileID); err == nil && fileInfo != nil {
+		event.LinkedResourceType = fileInfo.LinkedResourceType
+		event.LinkedResourceID = fileInfo.LinkedResourceID
+	}
+
+	if err := r.auditLog.Record(ctx, event); err != nil {
+		r.logger.LogAttrs(ctx, slog.LevelError, "Failed to record scan verdict",
+			ecsslog.Action("file.scan"), ecsslog.JobID(job.ID), ecsslog.FileID(job.FileID), ecsslog.Err(err))
+	}
+}
 
-	job.Status = domain.JobStatusCompleted
+func (r *VirusScannerJobRunner) runPostScanStages(ctx context.Context, fileInfo *domain.FileInfo) {
+	for _, stage := range r.postScanStages {
+		if err := stage.Process(ctx, fileInfo); err != nil {
+			r.logger.LogAttrs(ctx, slog.LevelError, "Post-scan stage failed",
+				ecsslog.Action("file.post_scan"), slog.String("post_scan.stage", stage.Name()), ecsslog.FileID(fileInfo.ID), ecsslog.Err(err))
+		}
+	}
+}
+
+// failRescannedJob fails a rescan that found the file infected and, in the
+// same unit of work, removes the authorization the file got when it first
+// completed, so that it can no longer be downloaded.
+func (r *VirusScannerJobRunner) failRescannedJob(ctx context.Context, job *domain.UploadJob, err error) error {
+	job.Status = domain.JobStatusFailed
+	job.Error = err.Error()
 	job.UpdatedAt = time.Now()
-	r.metrics.RecordVirusCheckDuration("success", time.Since(startTime))
 
-	if err := r.jobRepo.Update(ctx, job); err != nil {
-		return fmt.Errorf("failed to update job: %w", err)
+	updateErr := r.unitOfWork.Do(ctx, func(repos domain.Repositories) error {
+		fileInfo, err := repos.FileInfos.Get(ctx, job.FileID)
+		if err != nil {
+			return fmt.Errorf("failed to get file info: %w", err)
+		}
+		if fileInfo != nil {
+			if err := repos.FileAuthorizations.RemoveFileAuthorization(ctx, fileInfo.ID, fileInfo.FileType, fileInfo.LinkedResourceID, fileInfo.LinkedResourceType); err != nil {
+				return fmt.Errorf("failed to remove file authorization: %w", err)
+			}
+		}
+
+		updated, err := repos.Jobs.UpdateIfStatus(ctx, job, domain.JobStatusVirusChecking)
+		if err != nil {
+			return err
+		}
+		if !updated {
+			return errJobChanged
+		}
+		return nil
+	})
+	if updateErr != nil {
+		return fmt.Errorf("failed to update job with error: %w (original error: %v)", updateErr, err)
 	}
 
-	return nil
+	return err
 }
 
 func (r *VirusScannerJobRunner) updateJobWithError(ctx context.Context, job *domain.UploadJob, err error) error {
@@ -170,9 +393,13 @@ func (r *VirusScannerJobRunner) updateJobWithError(ctx context.Context, job *dom
 	job.Error = err.Error()
 	job.UpdatedAt = time.Now()
 
-	if updateErr := r.jobRepo.Update(ctx, job); updateErr != nil {
+	updated, updateErr := r.jobRepo.UpdateIfStatus(ctx, job, domain.JobStatusVirusChecking)
+	if updateErr != nil {
 		return fmt.Errorf("failed to update job with error: %w (original error: %v)", updateErr, err)
 	}
+	if !updated {
+		return fmt.Errorf("%w (original error: %v)", errJobChanged, err)
+	}
 
 	return err
 }
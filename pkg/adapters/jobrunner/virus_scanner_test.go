@@ -1,18 +1,28 @@
 package jobrunner
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"testing"
 	"time"
 
+	"file-storage-go/pkg/adapters/repository"
 	"file-storage-go/pkg/domain"
+	"file-storage-go/pkg/tracing"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
+var testLogger = slog.New(slog.DiscardHandler)
+
 type mockFileStorage struct {
 	downloadFunc func(ctx context.Context, fileID string) (io.ReadCloser, error)
 }
@@ -63,6 +73,16 @@ func (m *mockFileInfoRepository) Get(ctx context.Context, fileID string) (*domai
 	return nil, fmt.Errorf("file info not found")
 }
 
+func (m *mockFileInfoRepository) GetMany(ctx context.Context, fileIDs []string) ([]*domain.FileInfo, error) {
+	var fileInfos []*domain.FileInfo
+	for _, fileID := range fileIDs {
+		if fileInfo, exists := m.fileInfos[fileID]; exists {
+			fileInfos = append(fileInfos, fileInfo)
+		}
+	}
+	return fileInfos, nil
+}
+
 func (m *mockFileInfoRepository) Update(ctx context.Context, fileInfo *domain.FileInfo) error {
 	m.fileInfos[fileInfo.ID] = fileInfo
 	return nil
@@ -75,23 +95,11 @@ func (m *mockFileInfoRepository) Delete(ctx context.Context, fileID string) erro
 
 type mockFileAuthorization struct{}
 
-func (m *mockFileAuthorization) CanUploadFile(userID, fileType, linkedResourceType, linkedResourceID string) (bool, error) {
-	return true, nil
-}
-
-func (m *mockFileAuthorization) CanReadFile(userID, fileID string) (bool, error) {
-	return true, nil
-}
-
-func (m *mockFileAuthorization) CanDeleteFile(userID, fileID string) (bool, error) {
-	return true, nil
-}
-
-func (m *mockFileAuthorization) CreateFileAuthorization(fileID, fileType, linkedResourceID, linkedResourceType string) error {
+func (m *mockFileAuthorization) CreateFileAuthorization(ctx context.Context, fileID, fileType, linkedResourceID, linkedResourceType string) error {
 	return nil
 }
 
-func (m *mockFileAuthorization) RemoveFileAuthorization(fileID, fileType, linkedResourceID, linkedResourceType string) error {
+func (m *mockFileAuthorization) RemoveFileAuthorization(ctx context.Context, fileID, fileType, linkedResourceID, linkedResourceType string) error {
 	return nil
 }
 
@@ -115,6 +123,11 @@ func (m *mockJobRepository) Update(ctx context.Context, job *domain.UploadJob) e
 	return nil
 }
 
+func (m *mockJobRepository) UpdateIfStatus(ctx context.Context, job *domain.UploadJob, expected domain.JobStatus) (bool, error) {
+	m.jobs[job.ID] = job
+	return true, nil
+}
+
 func (m *mockJobRepository) GetByFileID(ctx context.Context, fileID string) (*domain.UploadJob, error) {
 	for _, job := range m.jobs {
 		if job.FileID == fileID {
@@ -134,21 +147,55 @@ func (m *mockJobRepository) GetByStatus(ctx context.Context, status domain.JobSt
 	return jobs, nil
 }
 
+func (m *mockJobRepository) List(ctx context.Context, filter domain.UploadJobFilter) ([]*domain.UploadJob, error) {
+	return m.GetByStatus(ctx, filter.Status)
+}
+
+func (m *mockJobRepository) CountByStatus(ctx context.Context) (map[domain.JobStatus]int64, error) {
+	counts := make(map[domain.JobStatus]int64)
+	for _, job := range m.jobs {
+		counts[job.Status]++
+	}
+	return counts, nil
+}
+
+func (m *mockJobRepository) Purge(ctx context.Context, statuses []domain.JobStatus, updatedBefore time.Time) ([]*domain.UploadJob, error) {
+	return nil, nil
+}
+
+// mockUnitOfWork runs units of work directly against the repositories.
+type mockUnitOfWork struct {
+	jobs      domain.UploadJobRepository
+	fileInfos domain.FileInfoRepository
+}
+
+func (m *mockUnitOfWork) Do(ctx context.Context, fn func(repos domain.Repositories) error) error {
+	return fn(domain.Repositories{Jobs: m.jobs, FileInfos: m.fileInfos, FileAuthorizations: &mockFileAuthorization{}})
+}
+
 type mockMetrics struct{}
 
 func (m *mockMetrics) RecordUploadDuration(status string, duration time.Duration)     {}
 func (m *mockMetrics) RecordUploadSize(size int64)                                    {}
 func (m *mockMetrics) RecordVirusCheckDuration(status string, duration time.Duration) {}
+func (m *mockMetrics) RecordDownloadDuration(status string, duration time.Duration)   {}
+func (m *mockMetrics) RecordDownloadSize(size int64)                                  {}
+func (m *mockMetrics) RecordDeleteDuration(status string, duration time.Duration)     {}
+func (m *mockMetrics) RecordHTTPRequest(method, route string, status int, duration time.Duration, requestSize, responseSize int64) {
+}
+func (m *mockMetrics) RecordJWKSFetch(status string, duration time.Duration)                  {}
+func (m *mockMetrics) RecordAuthorizationCheck(action, result string, duration time.Duration) {}
 
 func TestVirusScannerJobRunner_ProcessJob(t *testing.T) {
 	tests := []struct {
-		name           string
-		job            *domain.UploadJob
-		downloadErr    error
-		checkResult    bool
-		checkErr       error
-		expectedStatus domain.JobStatus
-		expectedError  string
+		name            string
+		job             *domain.UploadJob
+		downloadErr     error
+		checkResult     bool
+		checkErr        error
+		expectedStatus  domain.JobStatus
+		expectedError   string
+		expectedVerdict string
 	}{
 		{
 			name: "successful virus check",
@@ -160,8 +207,9 @@ func TestVirusScannerJobRunner_ProcessJob(t *testing.T) {
 				CreatedAt:       time.Now(),
 				UpdatedAt:       time.Now(),
 			},
-			checkResult:    true,
-			expectedStatus: domain.JobStatusCompleted,
+			checkResult:     true,
+			expectedStatus:  domain.JobStatusCompleted,
+			expectedVerdict: "clean",
 		},
 		{
 			name: "virus check failed - malware detected",
@@ -173,9 +221,10 @@ func TestVirusScannerJobRunner_ProcessJob(t *testing.T) {
 				CreatedAt:       time.Now(),
 				UpdatedAt:       time.Now(),
 			},
-			checkResult:    false,
-			expectedStatus: domain.JobStatusFailed,
-			expectedError:  "file contains malware",
+			checkResult:     false,
+			expectedStatus:  domain.JobStatusFailed,
+			expectedVerdict: "infected",
+			expectedError:   "file contains malware",
 		},
 		{
 			name: "download error",
@@ -187,9 +236,10 @@ func TestVirusScannerJobRunner_ProcessJob(t *testing.T) {
 				CreatedAt:       time.Now(),
 				UpdatedAt:       time.Now(),
 			},
-			downloadErr:    errors.New("download failed"),
-			expectedStatus: domain.JobStatusFailed,
-			expectedError:  "failed to download file: download failed",
+			downloadErr:     errors.New("download failed"),
+			expectedStatus:  domain.JobStatusFailed,
+			expectedVerdict: "error",
+			expectedError:   "failed to download file: download failed",
 		},
 		{
 			name: "virus check error",
@@ -201,9 +251,10 @@ func TestVirusScannerJobRunner_ProcessJob(t *testing.T) {
 				CreatedAt:       time.Now(),
 				UpdatedAt:       time.Now(),
 			},
-			checkErr:       errors.New("check failed"),
-			expectedStatus: domain.JobStatusFailed,
-			expectedError:  "virus check failed: check failed",
+			checkErr:        errors.New("check failed"),
+			expectedStatus:  domain.JobStatusFailed,
+			expectedVerdict: "error",
+			expectedError:   "virus check failed: check failed",
 		},
 	}
 
@@ -244,16 +295,18 @@ func TestVirusScannerJobRunner_ProcessJob(t *testing.T) {
 
 			metrics := &mockMetrics{}
 
-			fileAuthorization := &mockFileAuthorization{}
+			auditLog := repository.NewInMemoryAuditLog()
 
 			runner := NewVirusScannerJobRunner(
 				repo,
 				fileInfoRepo,
-				fileAuthorization,
+				&mockUnitOfWork{jobs: repo, fileInfos: fileInfoRepo},
 				fileStorage,
 				virusChecker,
 				5*time.Second,
 				metrics,
+				auditLog,
+				testLogger,
 			)
 
 			err := runner.processJob(context.Background(), tt.job)
@@ -270,6 +323,13 @@ func TestVirusScannerJobRunner_ProcessJob(t *testing.T) {
 			if tt.expectedError != "" {
 				assert.Contains(t, updatedJob.Error, tt.expectedError)
 			}
+
+			events, err := auditLog.Query(context.Background(), domain.AuditEventFilter{Action: domain.AuditFileScanned})
+			require.NoError(t, err)
+			require.Len(t, events, 1)
+			assert.Equal(t, tt.expectedVerdict, events[0].Details["verdict"])
+			assert.Equal(t, "test-user", events[0].UserID)
+			assert.Equal(t, "test-id", events[0].LinkedResourceID)
 		})
 	}
 }
@@ -322,16 +382,17 @@ func TestVirusScannerJobRunner_ProcessStuckJobs(t *testing.T) {
 
 	metrics := &mockMetrics{}
 	fileInfoRepo := newMockFileInfoRepository()
-	fileAuthorization := &mockFileAuthorization{}
 
 	runner := NewVirusScannerJobRunner(
 		repo,
 		fileInfoRepo,
-		fileAuthorization,
+		&mockUnitOfWork{jobs: repo, fileInfos: fileInfoRepo},
 		fileStorage,
 		virusChecker,
 		5*time.Second,
 		metrics,
+		nil,
+		testLogger,
 	)
 
 	jobsChan := make(chan *domain.UploadJob, 10)
@@ -358,3 +419,256 @@ func TestVirusScannerJobRunner_ProcessStuckJobs(t *testing.T) {
 	assert.True(t, jobIDs["pending-job"])
 	assert.False(t, jobIDs["completed-job"], "completed job should not be processed")
 }
+
+type recordingStage struct {
+	processed []string
+	err       error
+}
+
+func (s *recordingStage) Name() string {
+	return "recording"
+}
+
+func (s *recordingStage) Process(ctx context.Context, fileInfo *domain.FileInfo) error {
+	s.processed = append(s.processed, fileInfo.ID)
+	return s.err
+}
+
+func TestVirusScannerJobRunner_RunsPostScanStages(t *testing.T) {
+	tests := []struct {
+		name              string
+		checkResult       bool
+		stageErr          error
+		expectedStatus    domain.JobStatus
+		expectedProcessed int
+	}{
+		{name: "clean file", checkResult: true, expectedStatus: domain.JobStatusCompleted, expectedProcessed: 1},
+		{name: "stage failure keeps job completed", checkResult: true, stageErr: errors.New("boom"), expectedStatus: domain.JobStatusCompleted, expectedProcessed: 1},
+		{name: "infected file", checkResult: false, expectedStatus: domain.JobStatusFailed, expectedProcessed: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := &domain.UploadJob{
+				ID:              "test-job",
+				CreatedByUserId: "test-user",
+				FileID:          "test-file",
+				Status:          domain.JobStatusVirusCheckPending,
+			}
+			repo := newMockJobRepository()
+			require.NoError(t, repo.Create(context.Background(), job))
+
+			fileInfoRepo := newMockFileInfoRepository()
+			require.NoError(t, fileInfoRepo.Create(context.Background(), &domain.FileInfo{ID: "test-file"}))
+
+			stage := &recordingStage{err: tt.stageErr}
+			var logs bytes.Buffer
+			runner := NewVirusScannerJobRunner(
+				repo,
+				fileInfoRepo,
+				&mockUnitOfWork{jobs: repo, fileInfos: fileInfoRepo},
+				&mockFileStorage{downloadFunc: func(ctx context.Context, fileID string) (io.ReadCloser, error) {
+					return io.NopCloser(io.Reader(nil)), nil
+				}},
+				&mockVirusChecker{checkFunc: func(ctx context.Context, reader io.Reader) (bool, error) {
+					return tt.checkResult, nil
+				}},
+				5*time.Second,
+				&mockMetrics{},
+				nil,
+				slog.New(slog.NewJSONHandler(&logs, nil)),
+				stage,
+			)
+
+			runner.processJob(context.Background(), job)
+
+			assert.Equal(t, tt.expectedStatus, job.Status)
+			assert.Len(t, stage.processed, tt.expectedProcessed)
+			if tt.stageErr != nil {
+				assert.Contains(t, logs.String(), `"event.action":"file.post_scan"`)
+				assert.Contains(t, logs.String(), `"file.id":"test-file"`)
+				assert.Contains(t, logs.String(), `"error.message":"boom"`)
+			}
+		})
+	}
+}
+
+func TestVirusScannerJobRunner_PauseAndResume(t *testing.T) {
+	repo := repository.NewInMemoryJobRepo()
+	job := &domain.UploadJob{
+		ID:              "pending-job",
+		CreatedByUserId: "test-user",
+		FileID:          "pending-file",
+		Status:          domain.JobStatusVirusCheckPending,
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
+	}
+	require.NoError(t, repo.Create(context.Background(), job))
+
+	fileInfoRepo := newMockFileInfoRepository()
+	require.NoError(t, fileInfoRepo.Create(context.Background(), &domain.FileInfo{ID: "pending-file"}))
+
+	runner := NewVirusScannerJobRunner(
+		repo,
+		fileInfoRepo,
+		&mockUnitOfWork{jobs: repo, fileInfos: fileInfoRepo},
+		&mockFileStorage{
+			downloadFunc: func(ctx context.Context, fileID string) (io.ReadCloser, error) {
+				return io.NopCloser(io.Reader(nil)), nil
+			},
+		},
+		&mockVirusChecker{
+			checkFunc: func(ctx context.Context, reader io.Reader) (bool, error) {
+				return true, nil
+			},
+		},
+		5*time.Second,
+		&mockMetrics{},
+		nil,
+		testLogger,
+	)
+
+	runner.Pause()
+	state := runner.State()
+	assert.True(t, state.Paused)
+	assert.Equal(t, defaultWorkerCount, state.Workers)
+	assert.Equal(t, defaultChannelSize, state.QueueCapacity)
+	assert.Nil(t, state.LastPollAt)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go runner.Start(ctx)
+
+	time.Sleep(500 * time.Millisecond)
+	assert.Nil(t, runner.State().LastPollAt, "a paused scanner does not poll")
+	stored, err := repo.Get(context.Background(), "pending-job")
+	require.NoError(t, err)
+	assert.Equal(t, domain.JobStatusVirusCheckPending, stored.Status)
+
+	runner.Resume()
+	assert.Eventually(t, func() bool {
+		stored, err := repo.Get(context.Background(), "pending-job")
+		return err == nil && stored.Status == domain.JobStatusCompleted
+	}, 2*time.Second, 50*time.Millisecond)
+
+	state = runner.State()
+	assert.False(t, state.Paused)
+	assert.NotNil(t, state.LastPollAt)
+}
+
+func TestVirusScannerJobRunner_ProcessJobLinksToUploadTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	uploadCtx, upload := otel.Tracer("test").Start(context.Background(), "upload")
+	upload.End()
+
+	now := time.Now()
+	job := &domain.UploadJob{
+		ID:              "traced-job",
+		CreatedByUserId: "test-user",
+		FileID:          "traced-file",
+		Status:          domain.JobStatusVirusCheckPending,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+		TraceParent:     tracing.TraceParent(uploadCtx),
+	}
+	repo := newMockJobRepository()
+	require.NoError(t, repo.Create(context.Background(), job))
+	fileInfoRepo := newMockFileInfoRepository()
+	require.NoError(t, fileInfoRepo.Create(context.Background(), &domain.FileInfo{ID: "traced-file"}))
+
+	runner := NewVirusScannerJobRunner(
+		repo,
+		fileInfoRepo,
+		&mockUnitOfWork{jobs: repo, fileInfos: fileInfoRepo},
+		&mockFileStorage{downloadFunc: func(ctx context.Context, fileID string) (io.ReadCloser, error) {
+			return io.NopCloser(io.Reader(nil)), nil
+		}},
+		&mockVirusChecker{checkFunc: func(ctx context.Context, reader io.Reader) (bool, error) {
+			return true, nil
+		}},
+		5*time.Second,
+		&mockMetrics{},
+		nil,
+		testLogger,
+	)
+	require.NoError(t, runner.processJob(context.Background(), job))
+
+	var scan sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == "VirusScannerJobRunner.processJob" {
+			scan = span
+		}
+	}
+	require.NotNil(t, scan)
+	assert.NotEqual(t, upload.SpanContext().TraceID(), scan.SpanContext().TraceID(), "the scan runs in a trace of its own")
+	require.Len(t, scan.Links(), 1)
+	assert.Equal(t, upload.SpanContext().SpanID(), scan.Links()[0].SpanContext.SpanID())
+	assert.Contains(t, scan.Attributes(), attribute.String("scan.verdict", "clean"))
+}
+
+func TestVirusScannerJobRunner_Rescan(t *testing.T) {
+	tests := []struct {
+		name           string
+		checkResult    bool
+		expectedStatus domain.JobStatus
+		expectReadable bool
+	}{
+		{name: "clean rescan keeps the file", checkResult: true, expectedStatus: domain.JobStatusCompleted, expectReadable: true},
+		{name: "infected rescan removes the authorization", checkResult: false, expectedStatus: domain.JobStatusFailed, expectReadable: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			outbox := repository.NewInMemoryOutbox()
+			fileInfoRepo := repository.NewInMemoryFileInfoRepo()
+			jobRepo := repository.NewInMemoryJobRepoWithOutbox(outbox, fileInfoRepo)
+			fileAuthorization := repository.NewInMemoryFileAuthorization()
+			unitOfWork := repository.NewInMemoryUnitOfWork(jobRepo, fileInfoRepo, fileAuthorization, nil)
+
+			fileInfo := &domain.FileInfo{ID: "test-file", FileType: "invoice", LinkedResourceType: "order", LinkedResourceID: "order-1"}
+			require.NoError(t, fileInfoRepo.Create(ctx, fileInfo))
+			require.NoError(t, fileAuthorization.CreateFileAuthorization(ctx, fileInfo.ID, fileInfo.FileType, fileInfo.LinkedResourceID, fileInfo.LinkedResourceType))
+			require.NoError(t, fileAuthorization.CreateGrant(ctx, &domain.FileGrant{
+				ID: "grant-1", PrincipalType: domain.PrincipalUser, PrincipalID: "reader",
+				ResourceType: "order", ResourceID: "order-1", Permission: domain.PermissionRead,
+			}))
+			job := &domain.UploadJob{ID: "test-job", FileID: fileInfo.ID, Status: domain.JobStatusVirusCheckPending, Rescan: true}
+			require.NoError(t, jobRepo.Create(ctx, job))
+
+			runner := NewVirusScannerJobRunner(
+				jobRepo,
+				fileInfoRepo,
+				unitOfWork,
+				&mockFileStorage{downloadFunc: func(ctx context.Context, fileID string) (io.ReadCloser, error) {
+					return io.NopCloser(bytes.NewReader(nil)), nil
+				}},
+				&mockVirusChecker{checkFunc: func(ctx context.Context, reader io.Reader) (bool, error) {
+					return tt.checkResult, nil
+				}},
+				5*time.Second,
+				&mockMetrics{},
+				nil,
+				testLogger,
+			)
+
+			_ = runner.processJob(ctx, job)
+
+			stored, err := jobRepo.Get(ctx, job.ID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, stored.Status)
+
+			readable, err := fileAuthorization.CanReadFile(ctx, "reader", fileInfo.ID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectReadable, readable)
+
+			for _, event := range outbox.Events() {
+				assert.NotEqual(t, domain.EventFileCreated, event.Type, "a rescan does not announce the file again")
+			}
+		})
+	}
+}
@@ -1,19 +1,61 @@
 package metrics
 
 import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
 	"time"
 
+	"file-storage-go/pkg/domain"
+	"file-storage-go/pkg/ecsslog"
+
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// jobCountTimeout bounds the query behind the upload_jobs gauge, so a slow
+// database does not stall scrapes.
+const jobCountTimeout = 5 * time.Second
+
+var jobStatuses = []domain.JobStatus{
+	domain.JobStatusPending,
+	domain.JobStatusUploading,
+	domain.JobStatusVirusCheckPending,
+	domain.JobStatusVirusChecking,
+	domain.JobStatusCompleted,
+	domain.JobStatusFailed,
+	domain.JobStatusDeleted,
+}
+
+// PrometheusMetrics registers its metrics on a registry of its own, so that
+// it can be created more than once, e.g. in tests. Handler serves them.
 type PrometheusMetrics struct {
+	registry *prometheus.Registry
+
 	uploadDuration     *prometheus.HistogramVec
 	uploadSize         prometheus.Histogram
+	uploadedBytes      prometheus.Counter
+	downloadDuration   *prometheus.HistogramVec
+	downloadSize       prometheus.Histogram
+	downloadedBytes    prometheus.Counter
+	deleteDuration     *prometheus.HistogramVec
 	virusCheckDuration *prometheus.HistogramVec
+	httpRequests       *prometheus.CounterVec
+	httpDuration       *prometheus.HistogramVec
+	httpRequestSize    *prometheus.HistogramVec
+	httpResponseSize   *prometheus.HistogramVec
+	jwksFetchDuration  *prometheus.HistogramVec
+	authzDuration      *prometheus.HistogramVec
 }
 
 func NewPrometheusMetrics() *PrometheusMetrics {
+	sizeBuckets := prometheus.ExponentialBuckets(1024, 2, 10)
+	httpSizeBuckets := prometheus.ExponentialBuckets(64, 4, 10)
+
 	metrics := &PrometheusMetrics{
+		registry: prometheus.NewRegistry(),
 		uploadDuration: prometheus.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Name:    "file_upload_duration_seconds",
@@ -26,9 +68,44 @@ func NewPrometheusMetrics() *PrometheusMetrics {
 			prometheus.HistogramOpts{
 				Name:    "file_upload_size_bytes",
 				Help:    "Size of uploaded files in bytes",
-				Buckets: prometheus.ExponentialBuckets(1024, 2, 10),
+				Buckets: sizeBuckets,
+			},
+		),
+		uploadedBytes: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "file_uploaded_bytes_total",
+				Help: "Bytes of files uploaded to storage",
+			},
+		),
+		downloadDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "file_download_duration_seconds",
+				Help:    "Time until storage starts returning a downloaded file, in seconds",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"status"},
+		),
+		downloadSize: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "file_download_size_bytes",
+				Help:    "Bytes read from storage per file download",
+				Buckets: sizeBuckets,
 			},
 		),
+		downloadedBytes: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "file_downloaded_bytes_total",
+				Help: "Bytes of files read from storage",
+			},
+		),
+		deleteDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "file_delete_duration_seconds",
+				Help:    "Duration of file deletions in seconds",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"status"},
+		),
 		virusCheckDuration: prometheus.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Name:    "virus_check_duration_seconds",
@@ -37,23 +114,215 @@ func NewPrometheusMetrics() *PrometheusMetrics {
 			},
 			[]string{"status"},
 		),
+		httpRequests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "http_requests_total",
+				Help: "HTTP requests served",
+			},
+			[]string{"method", "route", "status"},
+		),
+		httpDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "http_request_duration_seconds",
+				Help:    "Duration of HTTP requests in seconds",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"method", "route", "status"},
+		),
+		httpRequestSize: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "http_request_size_bytes",
+				Help:    "Size of HTTP request bodies in bytes",
+				Buckets: httpSizeBuckets,
+			},
+			[]string{"method", "route", "status"},
+		),
+		httpResponseSize: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "http_response_size_bytes",
+				Help:    "Size of HTTP response bodies in bytes",
+				Buckets: httpSizeBuckets,
+			},
+			[]string{"method", "route", "status"},
+		),
+		jwksFetchDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "jwks_fetch_duration_seconds",
+				Help:    "Duration of JWKS fetches from the identity provider in seconds",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"status"},
+		),
+		authzDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "authorization_check_duration_seconds",
+				Help:    "Duration of file authorization checks in seconds",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"action", "result"},
+		),
 	}
 
-	prometheus.MustRegister(metrics.uploadDuration)
-	prometheus.MustRegister(metrics.uploadSize)
-	prometheus.MustRegister(metrics.virusCheckDuration)
+	metrics.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		metrics.uploadDuration,
+		metrics.uploadSize,
+		metrics.uploadedBytes,
+		metrics.downloadDuration,
+		metrics.downloadSize,
+		metrics.downloadedBytes,
+		metrics.deleteDuration,
+		metrics.virusCheckDuration,
+		metrics.httpRequests,
+		metrics.httpDuration,
+		metrics.httpRequestSize,
+		metrics.httpResponseSize,
+		metrics.jwksFetchDuration,
+		metrics.authzDuration,
+	)
 
 	return metrics
 }
 
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *PrometheusMetrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// RegisterScanner exports the queue and worker state of the virus scanner.
+func (m *PrometheusMetrics) RegisterScanner(scanner domain.ScannerControl) {
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: "virus_scanner_queue_length",
+				Help: "Jobs queued for the virus scanner workers",
+			},
+			func() float64 { return float64(scanner.State().QueueLength) },
+		),
+		prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: "virus_scanner_queue_capacity",
+				Help: "Capacity of the virus scanner queue",
+			},
+			func() float64 { return float64(scanner.State().QueueCapacity) },
+		),
+		prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: "virus_scanner_workers",
+				Help: "Virus scanner workers",
+			},
+			func() float64 { return float64(scanner.State().Workers) },
+		),
+		prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: "virus_scanner_active_workers",
+				Help: "Virus scanner workers that are scanning a file",
+			},
+			func() float64 { return float64(scanner.State().ActiveWorkers) },
+		),
+		prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: "virus_scanner_worker_utilization_ratio",
+				Help: "Share of the virus scanner workers that are scanning a file",
+			},
+			func() float64 {
+				state := scanner.State()
+				if state.Workers == 0 {
+					return 0
+				}
+				return float64(state.ActiveWorkers) / float64(state.Workers)
+			},
+		),
+		prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: "virus_scanner_paused",
+				Help: "1 if the virus scanner is paused",
+			},
+			func() float64 {
+				if scanner.State().Paused {
+					return 1
+				}
+				return 0
+			},
+		),
+	)
+}
+
+// RegisterJobCounts exports the number of upload jobs per status. The jobs
+// are counted on every scrape.
+func (m *PrometheusMetrics) RegisterJobCounts(jobs domain.UploadJobRepository, logger *slog.Logger) {
+	m.registry.MustRegister(&jobCountCollector{
+		jobs:   jobs,
+		logger: logger,
+		desc:   prometheus.NewDesc("upload_jobs", "Upload jobs per status", []string{"status"}, nil),
+	})
+}
+
+type jobCountCollector struct {
+	jobs   domain.UploadJobRepository
+	logger *slog.Logger
+	desc   *prometheus.Desc
+}
+
+func (c *jobCountCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *jobCountCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), jobCountTimeout)
+	defer cancel()
+
+	counts, err := c.jobs.CountByStatus(ctx)
+	if err != nil {
+		c.logger.LogAttrs(ctx, slog.LevelError, "Error counting jobs by status",
+			ecsslog.Action("job.count"), ecsslog.Err(err))
+		return
+	}
+
+	for _, status := range jobStatuses {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(counts[status]), string(status))
+	}
+}
+
 func (m *PrometheusMetrics) RecordUploadDuration(status string, duration time.Duration) {
 	m.uploadDuration.WithLabelValues(status).Observe(duration.Seconds())
 }
 
 func (m *PrometheusMetrics) RecordUploadSize(size int64) {
 	m.uploadSize.Observe(float64(size))
+	m.uploadedBytes.Add(float64(size))
+}
+
+func (m *PrometheusMetrics) RecordDownloadDuration(status string, duration time.Duration) {
+	m.downloadDuration.WithLabelValues(status).Observe(duration.Seconds())
+}
+
+func (m *PrometheusMetrics) RecordDownloadSize(size int64) {
+	m.downloadSize.Observe(float64(size))
+	m.downloadedBytes.Add(float64(size))
+}
+
+func (m *PrometheusMetrics) RecordDeleteDuration(status string, duration time.Duration) {
+	m.deleteDuration.WithLabelValues(status).Observe(duration.Seconds())
 }
 
 func (m *PrometheusMetrics) RecordVirusCheckDuration(status string, duration time.Duration) {
 	m.virusCheckDuration.WithLabelValues(status).Observe(duration.Seconds())
 }
+
+func (m *PrometheusMetrics) RecordHTTPRequest(method, route string, status int, duration time.Duration, requestSize, responseSize int64) {
+	statusLabel := strconv.Itoa(status)
+	m.httpRequests.WithLabelValues(method, route, statusLabel).Inc()
+	m.httpDuration.WithLabelValues(method, route, statusLabel).Observe(duration.Seconds())
+	m.httpRequestSize.WithLabelValues(method, route, statusLabel).Observe(float64(requestSize))
+	m.httpResponseSize.WithLabelValues(method, route, statusLabel).Observe(float64(responseSize))
+}
+
+func (m *PrometheusMetrics) RecordJWKSFetch(status string, duration time.Duration) {
+	m.jwksFetchDuration.WithLabelValues(status).Observe(duration.Seconds())
+}
+
+func (m *PrometheusMetrics) RecordAuthorizationCheck(action, result string, duration time.Duration) {
+	m.authzDuration.WithLabelValues(action, result).Observe(duration.Seconds())
+}
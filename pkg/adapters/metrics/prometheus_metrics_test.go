@@ -1,11 +1,22 @@
 package metrics
 
 import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"file-storage-go/pkg/adapters/repository"
+	"file-storage-go/pkg/domain"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestPrometheusMetrics_RecordUploadDuration(t *testing.T) {
@@ -30,23 +41,16 @@ func TestPrometheusMetrics_RecordUploadDuration(t *testing.T) {
 }
 
 func TestPrometheusMetrics_RecordUploadSize(t *testing.T) {
-	registry := prometheus.NewRegistry()
-	metrics := &PrometheusMetrics{
-		uploadSize: prometheus.NewHistogram(
-			prometheus.HistogramOpts{
-				Name:    "file_upload_size_bytes",
-				Help:    "Size of uploaded files in bytes",
-				Buckets: prometheus.ExponentialBuckets(1024, 2, 10),
-			},
-		),
-	}
-	registry.MustRegister(metrics.uploadSize)
+	metrics := NewPrometheusMetrics()
 
 	metrics.RecordUploadSize(2048)
 
 	if count := testutil.CollectAndCount(metrics.uploadSize); count != 1 {
 		t.Errorf("Expected 1 observation, got %d", count)
 	}
+	if total := testutil.ToFloat64(metrics.uploadedBytes); total != 2048 {
+		t.Errorf("Expected 2048 bytes uploaded, got %v", total)
+	}
 }
 
 func TestPrometheusMetrics_RecordVirusCheckDuration(t *testing.T) {
@@ -73,6 +77,7 @@ func TestPrometheusMetrics_RecordVirusCheckDuration(t *testing.T) {
 }
 
 func TestNewPrometheusMetrics(t *testing.T) {
+	NewPrometheusMetrics()
 	metrics := NewPrometheusMetrics()
 	if metrics == nil {
 		t.Error("Expected non-nil metrics instance")
@@ -87,3 +92,74 @@ func TestNewPrometheusMetrics(t *testing.T) {
 		t.Error("Expected non-nil virusCheckDuration metric")
 	}
 }
+
+func TestPrometheusMetrics_Handler(t *testing.T) {
+	metrics := NewPrometheusMetrics()
+	metrics.RecordUploadSize(2048)
+	metrics.RecordDownloadSize(1024)
+	metrics.RecordHTTPRequest(http.MethodGet, "/files/:fileId", http.StatusOK, 10*time.Millisecond, 0, 512)
+
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	body, err := io.ReadAll(w.Body)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(body), "file_uploaded_bytes_total 2048")
+	assert.Contains(t, string(body), "file_downloaded_bytes_total 1024")
+	assert.Contains(t, string(body), `http_requests_total{method="GET",route="/files/:fileId",status="200"} 1`)
+	assert.Contains(t, string(body), "go_goroutines")
+}
+
+type stubScanner struct {
+	state domain.ScannerState
+}
+
+func (s *stubScanner) Pause()                                            {}
+func (s *stubScanner) Resume()                                           {}
+func (s *stubScanner) State() domain.ScannerState                        { return s.state }
+func (s *stubScanner) IsStuck(job *domain.UploadJob, now time.Time) bool { return false }
+
+func TestPrometheusMetrics_RegisterScanner(t *testing.T) {
+	metrics := NewPrometheusMetrics()
+	metrics.RegisterScanner(&stubScanner{state: domain.ScannerState{Workers: 4, ActiveWorkers: 1, QueueLength: 7, QueueCapacity: 100}})
+
+	expected := `
+# HELP virus_scanner_queue_length Jobs queued for the virus scanner workers
+# TYPE virus_scanner_queue_length gauge
+virus_scanner_queue_length 7
+# HELP virus_scanner_worker_utilization_ratio Share of the virus scanner workers that are scanning a file
+# TYPE virus_scanner_worker_utilization_ratio gauge
+virus_scanner_worker_utilization_ratio 0.25
+`
+	assert.NoError(t, testutil.GatherAndCompare(metrics.registry, strings.NewReader(expected),
+		"virus_scanner_queue_length", "virus_scanner_worker_utilization_ratio"))
+}
+
+func TestPrometheusMetrics_RegisterJobCounts(t *testing.T) {
+	jobs := repository.NewInMemoryJobRepo()
+	now := time.Now()
+	require.NoError(t, jobs.Create(context.Background(), &domain.UploadJob{ID: "job-1", Status: domain.JobStatusCompleted, CreatedAt: now, UpdatedAt: now}))
+	require.NoError(t, jobs.Create(context.Background(), &domain.UploadJob{ID: "job-2", Status: domain.JobStatusCompleted, CreatedAt: now, UpdatedAt: now}))
+	require.NoError(t, jobs.Create(context.Background(), &domain.UploadJob{ID: "job-3", Status: domain.JobStatusFailed, CreatedAt: now, UpdatedAt: now}))
+
+	metrics := NewPrometheusMetrics()
+	metrics.RegisterJobCounts(jobs, slog.New(slog.DiscardHandler))
+
+	count, err := testutil.GatherAndCount(metrics.registry, "upload_jobs")
+	require.NoError(t, err)
+	assert.Equal(t, 7, count, "every status is exported, including empty ones")
+
+	expected := `
+# HELP upload_jobs Upload jobs per status
+# TYPE upload_jobs gauge
+upload_jobs{status="COMPLETED"} 2
+upload_jobs{status="DELETED"} 0
+upload_jobs{status="FAILED"} 1
+upload_jobs{status="PENDING"} 0
+upload_jobs{status="UPLOADING"} 0
+upload_jobs{status="VIRUS_CHECK_IN_PROGRESS"} 0
+upload_jobs{status="VIRUS_CHECK_PENDING"} 0
+`
+	assert.NoError(t, testutil.GatherAndCompare(metrics.registry, strings.NewReader(expected), "upload_jobs"))
+}
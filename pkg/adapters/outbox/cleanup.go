@@ -0,0 +1,45 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"file-storage-go/pkg/domain"
+)
+
+// CleanupPublisher deletes the blob and thumbnails of every deleted file and
+// then passes the event on to next, if set. Storage is thus only cleaned up
+// once the deletion is committed, and a failed delete is retried with the
+// event. Blobs that are already gone count as deleted.
+type CleanupPublisher struct {
+	fileStorage domain.FileStorage
+	thumbnails  domain.ThumbnailStore
+	next        domain.EventPublisher
+}
+
+func NewCleanupPublisher(fileStorage domain.FileStorage, thumbnails domain.ThumbnailStore, next domain.EventPublisher) *CleanupPublisher {
+	return &CleanupPublisher{
+		fileStorage: fileStorage,
+		thumbnails:  thumbnails,
+		next:        next,
+	}
+}
+
+func (p *CleanupPublisher) Publish(ctx context.Context, event *domain.OutboxEvent) error {
+	if event.Type == domain.EventFileDeleted {
+		if err := p.fileStorage.Delete(ctx, event.FileID); err != nil && !errors.Is(err, domain.ErrFileNotFound) {
+			return fmt.Errorf("failed to delete file: %w", err)
+		}
+		if p.thumbnails != nil {
+			if err := p.thumbnails.DeleteThumbnails(ctx, event.FileID); err != nil {
+				return fmt.Errorf("failed to delete thumbnails: %w", err)
+			}
+		}
+	}
+
+	if p.next == nil {
+		return nil
+	}
+	return p.next.Publish(ctx, event)
+}
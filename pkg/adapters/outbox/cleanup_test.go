@@ -0,0 +1,44 @@
+package outbox
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"file-storage-go/pkg/adapters/storage"
+	"file-storage-go/pkg/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanupPublisher_DeletesBlobOfDeletedFiles(t *testing.T) {
+	ctx := context.Background()
+	fileStorage := storage.NewMockStorage()
+	require.NoError(t, fileStorage.Upload(ctx, "file-1", strings.NewReader("contents")))
+	require.NoError(t, fileStorage.Upload(ctx, "file-2", strings.NewReader("contents")))
+
+	next := &recordingPublisher{}
+	publisher := NewCleanupPublisher(fileStorage, nil, next)
+
+	require.NoError(t, publisher.Publish(ctx, &domain.OutboxEvent{Type: domain.EventFileCreated, FileID: "file-1"}))
+	reader, err := fileStorage.Download(ctx, "file-1")
+	require.NoError(t, err, "only deleted files are cleaned up")
+	_, _ = io.Copy(io.Discard, reader)
+	reader.Close()
+
+	deleted := &domain.OutboxEvent{Type: domain.EventFileDeleted, FileID: "file-2"}
+	require.NoError(t, publisher.Publish(ctx, deleted))
+	_, err = fileStorage.Download(ctx, "file-2")
+	assert.ErrorIs(t, err, domain.ErrFileNotFound)
+
+	require.NoError(t, publisher.Publish(ctx, deleted), "a retried delete succeeds")
+	assert.Equal(t, []string{domain.EventFileDeleted, domain.EventFileDeleted}, next.types("file-2"))
+	assert.Equal(t, []string{domain.EventFileCreated}, next.types("file-1"))
+}
+
+func TestCleanupPublisher_WithoutNextPublisher(t *testing.T) {
+	publisher := NewCleanupPublisher(storage.NewMockStorage(), nil, nil)
+	assert.NoError(t, publisher.Publish(context.Background(), &domain.OutboxEvent{Type: domain.EventFileDeleted, FileID: "missing"}))
+}
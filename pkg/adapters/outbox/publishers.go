@@ -0,0 +1,82 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"file-storage-go/pkg/domain"
+)
+
+const maxErrorBodyBytes = 512
+
+// LogPublisher writes every event as a line of JSON, e.g. to stdout for a log
+// shipper to pick up.
+type LogPublisher struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+func NewLogPublisher(w io.Writer) *LogPublisher {
+	return &LogPublisher{
+		w: w,
+	}
+}
+
+func (p *LogPublisher) Publish(ctx context.Context, event *domain.OutboxEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err = p.w.Write(append(line, '\n'))
+	return err
+}
+
+// HTTPPublisher posts every event as JSON to a URL. Any response other than
+// 2xx is a failure and the event is published again later.
+type HTTPPublisher struct {
+	client *http.Client
+	url    string
+}
+
+func NewHTTPPublisher(url string, timeout time.Duration) *HTTPPublisher {
+	return &HTTPPublisher{
+		client: &http.Client{Timeout: timeout},
+		url:    url,
+	}
+}
+
+func (p *HTTPPublisher) Publish(ctx context.Context, event *domain.OutboxEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-Id", event.ID)
+	req.Header.Set("X-Event-Type", event.Type)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"file-storage-go/pkg/domain"
+	"file-storage-go/pkg/ecsslog"
+)
+
+const (
+	defaultPollInterval = time.Second
+	defaultBatchSize    = 100
+)
+
+// Relay publishes the events written to the outbox. An event is only marked
+// published after the publisher accepted it, so events are delivered at
+// least once; consumers deduplicate by event ID.
+type Relay struct {
+	outbox       domain.Outbox
+	publisher    domain.EventPublisher
+	pollInterval time.Duration
+	batchSize    int
+	logger       *slog.Logger
+}
+
+func NewRelay(outbox domain.Outbox, publisher domain.EventPublisher, pollInterval time.Duration, logger *slog.Logger) *Relay {
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+
+	return &Relay{
+		outbox:       outbox,
+		publisher:    publisher,
+		pollInterval: pollInterval,
+		batchSize:    defaultBatchSize,
+		logger:       logger,
+	}
+}
+
+func (r *Relay) Start(ctx context.Context) {
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Keep relaying while there is a backlog; each round publishes
+			// at most one event per file.
+			for {
+				published, err := r.RelayOnce(ctx)
+				if err != nil {
+					r.logger.LogAttrs(ctx, slog.LevelError, "Error relaying outbox events",
+						ecsslog.Action("outbox.relay"), ecsslog.Err(err))
+				}
+				if err != nil || published == 0 || ctx.Err() != nil {
+					break
+				}
+			}
+		}
+	}
+}
+
+// RelayOnce publishes the due events once and returns how many were
+// published.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	return r.outbox.Relay(ctx, r.batchSize, func(event *domain.OutboxEvent) error {
+		if err := r.publisher.Publish(ctx, event); err != nil {
+			r.logger.LogAttrs(ctx, slog.LevelWarn, "Failed to publish event",
+				ecsslog.Action("outbox.publish"), slog.String("outbox.event.id", event.ID), slog.String("outbox.event.type", event.Type),
+				ecsslog.FileID(event.FileID), slog.Int("outbox.event.attempt", event.Attempts+1), ecsslog.Err(err))
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,197 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"file-storage-go/pkg/adapters/repository"
+	"file-storage-go/pkg/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingPublisher fails every event of the files in failing.
+type recordingPublisher struct {
+	mu        sync.Mutex
+	failing   map[string]bool
+	published []*domain.OutboxEvent
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event *domain.OutboxEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failing[event.FileID] {
+		return errors.New("broker unavailable")
+	}
+	p.published = append(p.published, event)
+	return nil
+}
+
+func (p *recordingPublisher) types(fileID string) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var types []string
+	for _, event := range p.published {
+		if event.FileID == fileID {
+			types = append(types, event.Type)
+		}
+	}
+	return types
+}
+
+type outboxFixture struct {
+	outbox       *repository.InMemoryOutbox
+	jobRepo      *repository.InMemoryJobRepo
+	fileInfoRepo *repository.InMemoryFileInfoRepo
+}
+
+func newOutboxFixture() *outboxFixture {
+	outbox := repository.NewInMemoryOutbox()
+	fileInfoRepo := repository.NewInMemoryFileInfoRepoWithOutbox(outbox)
+	return &outboxFixture{
+		outbox:       outbox,
+		jobRepo:      repository.NewInMemoryJobRepoWithOutbox(outbox, fileInfoRepo),
+		fileInfoRepo: fileInfoRepo,
+	}
+}
+
+// runJob creates a file and its job and moves the job through statuses.
+func (f *outboxFixture) runJob(t *testing.T, fileID string, statuses ...domain.JobStatus) {
+	t.Helper()
+	ctx := context.Background()
+	now := time.Now()
+	require.NoError(t, f.fileInfoRepo.Create(ctx, &domain.FileInfo{ID: fileID, LinkedResourceType: "invoice", LinkedResourceID: "inv-1", CreatedAt: now, UpdatedAt: now}))
+
+	job := &domain.UploadJob{ID: "job-" + fileID, FileID: fileID, Status: domain.JobStatusUploading, CreatedAt: now, UpdatedAt: now}
+	require.NoError(t, f.jobRepo.Create(ctx, job))
+	for _, status := range statuses {
+		job.Status = status
+		job.UpdatedAt = time.Now()
+		if status == domain.JobStatusFailed {
+			job.Error = "file contains malware"
+		}
+		require.NoError(t, f.jobRepo.Update(ctx, job))
+	}
+}
+
+func TestOutbox_WritesLifecycleEvents(t *testing.T) {
+	f := newOutboxFixture()
+	f.runJob(t, "clean", domain.JobStatusVirusCheckPending, domain.JobStatusVirusChecking, domain.JobStatusCompleted, domain.JobStatusCompleted)
+	f.runJob(t, "infected", domain.JobStatusVirusCheckPending, domain.JobStatusVirusChecking, domain.JobStatusFailed)
+	f.runJob(t, "no-file", domain.JobStatusFailed)
+	require.NoError(t, f.fileInfoRepo.Delete(context.Background(), "clean"))
+
+	var types []string
+	for _, event := range f.outbox.Events() {
+		types = append(types, event.FileID+" "+event.Type)
+	}
+	assert.Equal(t, []string{"clean file.created", "infected scan.failed", "clean file.deleted"}, types)
+
+	var payload domain.FileEventPayload
+	require.NoError(t, json.Unmarshal(f.outbox.Events()[1].Payload, &payload))
+	assert.Equal(t, "job-infected", payload.JobID)
+	assert.Equal(t, "file contains malware", payload.Error)
+	require.NotNil(t, payload.File)
+	assert.Equal(t, "invoice", payload.File.LinkedResourceType)
+}
+
+func TestRelay_PublishesInOrderPerFile(t *testing.T) {
+	ctx := context.Background()
+	f := newOutboxFixture()
+	f.runJob(t, "a", domain.JobStatusVirusChecking, domain.JobStatusCompleted)
+	f.runJob(t, "b", domain.JobStatusVirusChecking, domain.JobStatusCompleted)
+	require.NoError(t, f.fileInfoRepo.Delete(ctx, "a"))
+	require.NoError(t, f.fileInfoRepo.Delete(ctx, "b"))
+
+	publisher := &recordingPublisher{failing: map[string]bool{"a": true}}
+	relay := NewRelay(f.outbox, publisher, time.Millisecond, slog.New(slog.DiscardHandler))
+
+	published, err := relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, published, "one event per file and round")
+	published, err = relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, published, "the failed file does not block other files")
+	assert.Equal(t, []string{domain.EventFileCreated, domain.EventFileDeleted}, publisher.types("b"))
+	assert.Empty(t, publisher.types("a"))
+
+	published, err = relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, published, "the failed event waits for its retry")
+
+	publisher.mu.Lock()
+	publisher.failing = nil
+	publisher.mu.Unlock()
+	require.Eventually(t, func() bool {
+		_, err := relay.RelayOnce(ctx)
+		require.NoError(t, err)
+		return len(publisher.types("a")) == 2
+	}, 5*time.Second, 50*time.Millisecond)
+	assert.Equal(t, []string{domain.EventFileCreated, domain.EventFileDeleted}, publisher.types("a"), "a file's events stay in order after a retry")
+}
+
+func TestOutbox_DeletesPublishedEvents(t *testing.T) {
+	ctx := context.Background()
+	f := newOutboxFixture()
+	f.runJob(t, "a", domain.JobStatusVirusChecking, domain.JobStatusCompleted)
+	f.runJob(t, "b", domain.JobStatusVirusChecking, domain.JobStatusCompleted)
+
+	publisher := &recordingPublisher{failing: map[string]bool{"b": true}}
+	published, err := NewRelay(f.outbox, publisher, time.Millisecond, slog.New(slog.DiscardHandler)).RelayOnce(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, published)
+
+	deleted, err := f.outbox.DeletePublished(ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, deleted, "events are kept for the retention period")
+
+	deleted, err = f.outbox.DeletePublished(ctx, time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	events := f.outbox.Events()
+	require.Len(t, events, 1, "unpublished events are kept")
+	assert.Equal(t, "b", events[0].FileID)
+}
+
+func TestLogPublisher(t *testing.T) {
+	var buf bytes.Buffer
+	publisher := NewLogPublisher(&buf)
+	event := &domain.OutboxEvent{ID: "e1", Seq: 1, Type: domain.EventFileDeleted, FileID: "f1", Payload: json.RawMessage(`{"file":{"id":"f1"}}`)}
+
+	require.NoError(t, publisher.Publish(context.Background(), event))
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, "file.deleted", decoded["type"])
+	assert.Equal(t, "f1", decoded["fileId"])
+}
+
+func TestHTTPPublisher(t *testing.T) {
+	var received []byte
+	status := http.StatusAccepted
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "e1", r.Header.Get("X-Event-Id"))
+		assert.Equal(t, domain.EventScanFailed, r.Header.Get("X-Event-Type"))
+		received, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	publisher := NewHTTPPublisher(server.URL, time.Second)
+	event := &domain.OutboxEvent{ID: "e1", Type: domain.EventScanFailed, FileID: "f1", Payload: json.RawMessage(`{}`)}
+
+	require.NoError(t, publisher.Publish(context.Background(), event))
+	assert.Contains(t, string(received), `"type":"scan.failed"`)
+
+	status = http.StatusInternalServerError
+	assert.Error(t, publisher.Publish(context.Background(), event))
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"file-storage-go/pkg/domain"
+)
+
+type InMemoryAPIKeyRepo struct {
+	keys   map[string]*domain.APIKey
+	hashes map[string]string
+	mu     sync.RWMutex
+}
+
+func NewInMemoryAPIKeyRepo() *InMemoryAPIKeyRepo {
+	return &InMemoryAPIKeyRepo{
+		keys:   make(map[string]*domain.APIKey),
+		hashes: make(map[string]string),
+	}
+}
+
+func (r *InMemoryAPIKeyRepo) Create(ctx context.Context, key *domain.APIKey, keyHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *key
+	r.keys[key.ID] = &stored
+	r.hashes[keyHash] = key.ID
+	return nil
+}
+
+func (r *InMemoryAPIKeyRepo) GetByHash(ctx context.Context, keyHash string) (*domain.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keyID, exists := r.hashes[keyHash]
+	if !exists {
+		return nil, nil
+	}
+
+	key := *r.keys[keyID]
+	return &key, nil
+}
+
+func (r *InMemoryAPIKeyRepo) List(ctx context.Context, ownerID string) ([]*domain.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var keys []*domain.APIKey
+	for _, key := range r.keys {
+		if ownerID != "" && key.OwnerID != ownerID {
+			continue
+		}
+		copied := *key
+		keys = append(keys, &copied)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+	return keys, nil
+}
+
+func (r *InMemoryAPIKeyRepo) Revoke(ctx context.Context, keyID string, revokedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, exists := r.keys[keyID]
+	if !exists {
+		return domain.ErrAPIKeyNotFound
+	}
+	if key.RevokedAt == nil {
+		key.RevokedAt = &revokedAt
+	}
+	return nil
+}
+
+func (r *InMemoryAPIKeyRepo) UpdateLastUsed(ctx context.Context, keyID string, usedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if key, exists := r.keys[keyID]; exists {
+		key.LastUsedAt = &usedAt
+	}
+	return nil
+}
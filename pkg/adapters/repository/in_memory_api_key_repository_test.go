@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"file-storage-go/pkg/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryAPIKeyRepo(t *testing.T) {
+	repo := NewInMemoryAPIKeyRepo()
+	ctx := context.Background()
+	now := time.Now()
+
+	require.NoError(t, repo.Create(ctx, &domain.APIKey{ID: "k1", OwnerID: "svc-a", Scopes: []string{"files:read"}, CreatedAt: now}, "hash-1"))
+	require.NoError(t, repo.Create(ctx, &domain.APIKey{ID: "k2", OwnerID: "svc-b", CreatedAt: now.Add(time.Second)}, "hash-2"))
+
+	key, err := repo.GetByHash(ctx, "hash-1")
+	require.NoError(t, err)
+	require.NotNil(t, key)
+	assert.Equal(t, "k1", key.ID)
+
+	key, err = repo.GetByHash(ctx, "unknown")
+	require.NoError(t, err)
+	assert.Nil(t, key)
+
+	keys, err := repo.List(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, "k1", keys[0].ID)
+
+	keys, err = repo.List(ctx, "svc-b")
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, "k2", keys[0].ID)
+
+	require.NoError(t, repo.UpdateLastUsed(ctx, "k1", now))
+	require.NoError(t, repo.Revoke(ctx, "k1", now))
+	key, err = repo.GetByHash(ctx, "hash-1")
+	require.NoError(t, err)
+	assert.NotNil(t, key.LastUsedAt)
+	assert.NotNil(t, key.RevokedAt)
+
+	assert.ErrorIs(t, repo.Revoke(ctx, "unknown", now), domain.ErrAPIKeyNotFound)
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"maps"
+	"sync"
+
+	"file-storage-go/pkg/domain"
+)
+
+type InMemoryAuditLog struct {
+	events []*domain.AuditEvent
+	mu     sync.RWMutex
+}
+
+func NewInMemoryAuditLog() *InMemoryAuditLog {
+	return &InMemoryAuditLog{}
+}
+
+func (l *InMemoryAuditLog) Record(ctx context.Context, event *domain.AuditEvent) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stored := *event
+	stored.Details = maps.Clone(event.Details)
+	l.events = append(l.events, &stored)
+	return nil
+}
+
+func (l *InMemoryAuditLog) Query(ctx context.Context, filter domain.AuditEventFilter) ([]*domain.AuditEvent, error) {
+	events := l.matching(filter)
+
+	if filter.Offset > 0 {
+		if filter.Offset >= len(events) {
+			return nil, nil
+		}
+		events = events[filter.Offset:]
+	}
+	if filter.Limit > 0 && len(events) > filter.Limit {
+		events = events[:filter.Limit]
+	}
+	return events, nil
+}
+
+func (l *InMemoryAuditLog) Export(ctx context.Context, filter domain.AuditEventFilter, fn func(*domain.AuditEvent) error) error {
+	for _, event := range l.matching(filter) {
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matching returns copies of the matching events. Events are recorded in the
+// order they occur, so no sorting is needed.
+func (l *InMemoryAuditLog) matching(filter domain.AuditEventFilter) []*domain.AuditEvent {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var events []*domain.AuditEvent
+	for _, event := range l.events {
+		if !auditEventMatches(event, filter) {
+			continue
+		}
+		copied := *event
+		copied.Details = maps.Clone(event.Details)
+		events = append(events, &copied)
+	}
+	return events
+}
+
+func auditEventMatches(event *domain.AuditEvent, filter domain.AuditEventFilter) bool {
+	switch {
+	case filter.Action != "" && event.Action != filter.Action,
+		filter.Outcome != "" && event.Outcome != filter.Outcome,
+		filter.UserID != "" && event.UserID != filter.UserID,
+		filter.FileID != "" && event.FileID != filter.FileID,
+		filter.LinkedResourceType != "" && event.LinkedResourceType != filter.LinkedResourceType,
+		filter.LinkedResourceID != "" && event.LinkedResourceID != filter.LinkedResourceID,
+		!filter.From.IsZero() && event.OccurredAt.Before(filter.From),
+		!filter.To.IsZero() && !event.OccurredAt.Before(filter.To):
+		return false
+	}
+	return true
+}
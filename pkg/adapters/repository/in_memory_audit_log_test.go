@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"file-storage-go/pkg/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryAuditLog(t *testing.T) {
+	auditLog := NewInMemoryAuditLog()
+	ctx := context.Background()
+	start := time.Now()
+
+	events := []*domain.AuditEvent{
+		{ID: "e1", OccurredAt: start, Action: domain.AuditJobCreated, Outcome: domain.AuditOutcomeSuccess, UserID: "alice", FileID: "f1", LinkedResourceType: "company", LinkedResourceID: "3"},
+		{ID: "e2", OccurredAt: start.Add(time.Second), Action: domain.AuditFileDownloaded, Outcome: domain.AuditOutcomeDenied, UserID: "bob", FileID: "f1"},
+		{ID: "e3", OccurredAt: start.Add(2 * time.Second), Action: domain.AuditFileDownloaded, Outcome: domain.AuditOutcomeSuccess, UserID: "alice", FileID: "f1", Details: map[string]string{"route": "GET /files/:fileId/download"}},
+	}
+	for _, event := range events {
+		require.NoError(t, auditLog.Record(ctx, event))
+	}
+
+	// Recorded events cannot be changed through the caller's pointer.
+	events[2].Details["route"] = "changed"
+
+	all, err := auditLog.Query(ctx, domain.AuditEventFilter{})
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, "GET /files/:fileId/download", all[2].Details["route"])
+
+	downloads, err := auditLog.Query(ctx, domain.AuditEventFilter{Action: domain.AuditFileDownloaded, UserID: "alice"})
+	require.NoError(t, err)
+	require.Len(t, downloads, 1)
+	assert.Equal(t, "e3", downloads[0].ID)
+
+	denied, err := auditLog.Query(ctx, domain.AuditEventFilter{Outcome: domain.AuditOutcomeDenied})
+	require.NoError(t, err)
+	require.Len(t, denied, 1)
+	assert.Equal(t, "bob", denied[0].UserID)
+
+	page, err := auditLog.Query(ctx, domain.AuditEventFilter{FileID: "f1", Limit: 1, Offset: 1})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, "e2", page[0].ID)
+
+	window, err := auditLog.Query(ctx, domain.AuditEventFilter{From: start.Add(time.Second), To: start.Add(2 * time.Second)})
+	require.NoError(t, err)
+	require.Len(t, window, 1)
+	assert.Equal(t, "e2", window[0].ID)
+
+	var exported []string
+	err = auditLog.Export(ctx, domain.AuditEventFilter{LinkedResourceType: "company", LinkedResourceID: "3", Limit: 1}, func(event *domain.AuditEvent) error {
+		exported = append(exported, event.ID)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"e1"}, exported)
+
+	stop := errors.New("stop")
+	err = auditLog.Export(ctx, domain.AuditEventFilter{}, func(event *domain.AuditEvent) error {
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+}
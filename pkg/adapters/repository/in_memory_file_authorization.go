@@ -0,0 +1,223 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"file-storage-go/pkg/domain"
+)
+
+type fileACLResource struct {
+	fileType           string
+	linkedResourceType string
+	linkedResourceID   string
+}
+
+// InMemoryFileAuthorization evaluates grants the same way as
+// PostgresFileAuthorization, without persistence.
+type InMemoryFileAuthorization struct {
+	resources map[string]fileACLResource
+	grants    map[string]*domain.FileGrant
+	groups    map[string]map[string]bool
+	mu        sync.RWMutex
+}
+
+func NewInMemoryFileAuthorization() *InMemoryFileAuthorization {
+	return &InMemoryFileAuthorization{
+		resources: make(map[string]fileACLResource),
+		grants:    make(map[string]*domain.FileGrant),
+		groups:    make(map[string]map[string]bool),
+	}
+}
+
+func (r *InMemoryFileAuthorization) CanUploadFile(ctx context.Context, userID, fileType, linkedResourceType, linkedResourceID string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, grant := range r.grants {
+		if grant.Permission == domain.PermissionUpload &&
+			grant.ResourceType == linkedResourceType &&
+			grant.ResourceID == linkedResourceID &&
+			(grant.FileType == "" || grant.FileType == fileType) &&
+			r.matchesPrincipal(grant, userID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *InMemoryFileAuthorization) CanReadFile(ctx context.Context, userID, fileID string) (bool, error) {
+	return r.hasFilePermission(userID, fileID, domain.PermissionRead), nil
+}
+
+func (r *InMemoryFileAuthorization) CanDeleteFile(ctx context.Context, userID, fileID string) (bool, error) {
+	return r.hasFilePermission(userID, fileID, domain.PermissionDelete), nil
+}
+
+func (r *InMemoryFileAuthorization) FilterReadableFiles(ctx context.Context, userID string, files []*domain.FileInfo) ([]*domain.FileInfo, error) {
+	return filterFiles(files, func(file *domain.FileInfo) bool {
+		return r.hasFilePermission(userID, file.ID, domain.PermissionRead)
+	}), nil
+}
+
+// filterFiles returns the files for which keep returns true, in order.
+func filterFiles(files []*domain.FileInfo, keep func(*domain.FileInfo) bool) []*domain.FileInfo {
+	kept := make([]*domain.FileInfo, 0, len(files))
+	for _, file := range files {
+		if keep(file) {
+			kept = append(kept, file)
+		}
+	}
+	return kept
+}
+
+func (r *InMemoryFileAuthorization) hasFilePermission(userID, fileID string, permission domain.Permission) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	resource, exists := r.resources[fileID]
+	if !exists {
+		return false
+	}
+
+	for _, grant := range r.grants {
+		if grant.Permission != permission || !r.matchesPrincipal(grant, userID) {
+			continue
+		}
+		if grant.ResourceType == domain.GrantResourceFile && grant.ResourceID == fileID {
+			return true
+		}
+		if grant.ResourceType == resource.linkedResourceType && grant.ResourceID == resource.linkedResourceID {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *InMemoryFileAuthorization) matchesPrincipal(grant *domain.FileGrant, userID string) bool {
+	switch grant.PrincipalType {
+	case domain.PrincipalUser:
+		return grant.PrincipalID == userID
+	case domain.PrincipalGroup:
+		return r.groups[grant.PrincipalID][userID]
+	default:
+		return false
+	}
+}
+
+func (r *InMemoryFileAuthorization) CreateFileAuthorization(ctx context.Context, fileID, fileType, linkedResourceID, linkedResourceType string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.resources[fileID] = fileACLResource{
+		fileType:           fileType,
+		linkedResourceType: linkedResourceType,
+		linkedResourceID:   linkedResourceID,
+	}
+	return nil
+}
+
+func (r *InMemoryFileAuthorization) RemoveFileAuthorization(ctx context.Context, fileID, fileType, linkedResourceID, linkedResourceType string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.resources, fileID)
+	for id, grant := range r.grants {
+		if grant.ResourceType == domain.GrantResourceFile && grant.ResourceID == fileID {
+			delete(r.grants, id)
+		}
+	}
+	return nil
+}
+
+func (r *InMemoryFileAuthorization) CreateGrant(ctx context.Context, grant *domain.FileGrant) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.grants {
+		if existing.PrincipalType == grant.PrincipalType &&
+			existing.PrincipalID == grant.PrincipalID &&
+			existing.ResourceType == grant.ResourceType &&
+			existing.ResourceID == grant.ResourceID &&
+			existing.FileType == grant.FileType &&
+			existing.Permission == grant.Permission {
+			*grant = *existing
+			return nil
+		}
+	}
+
+	stored := *grant
+	r.grants[grant.ID] = &stored
+	return nil
+}
+
+func (r *InMemoryFileAuthorization) DeleteGrant(ctx context.Context, grantID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.grants[grantID]; !exists {
+		return domain.ErrGrantNotFound
+	}
+	delete(r.grants, grantID)
+	return nil
+}
+
+func (r *InMemoryFileAuthorization) ListGrants(ctx context.Context, filter domain.FileGrantFilter) ([]*domain.FileGrant, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var grants []*domain.FileGrant
+	for _, grant := range r.grants {
+		if (filter.PrincipalType != "" && grant.PrincipalType != filter.PrincipalType) ||
+			(filter.PrincipalID != "" && grant.PrincipalID != filter.PrincipalID) ||
+			(filter.ResourceType != "" && grant.ResourceType != filter.ResourceType) ||
+			(filter.ResourceID != "" && grant.ResourceID != filter.ResourceID) {
+			continue
+		}
+		copied := *grant
+		grants = append(grants, &copied)
+	}
+
+	sort.Slice(grants, func(i, j int) bool {
+		if !grants[i].CreatedAt.Equal(grants[j].CreatedAt) {
+			return grants[i].CreatedAt.Before(grants[j].CreatedAt)
+		}
+		return grants[i].ID < grants[j].ID
+	})
+	return grants, nil
+}
+
+func (r *InMemoryFileAuthorization) AddGroupMember(ctx context.Context, groupID, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.groups[groupID] == nil {
+		r.groups[groupID] = make(map[string]bool)
+	}
+	r.groups[groupID][userID] = true
+	return nil
+}
+
+func (r *InMemoryFileAuthorization) RemoveGroupMember(ctx context.Context, groupID, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.groups[groupID], userID)
+	if len(r.groups[groupID]) == 0 {
+		delete(r.groups, groupID)
+	}
+	return nil
+}
+
+func (r *InMemoryFileAuthorization) ListGroupMembers(ctx context.Context, groupID string) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var members []string
+	for userID := range r.groups[groupID] {
+		members = append(members, userID)
+	}
+	sort.Strings(members)
+	return members, nil
+}
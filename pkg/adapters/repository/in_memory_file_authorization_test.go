@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"file-storage-go/pkg/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newGrant(id string, principalType domain.PrincipalType, principalID, resourceType, resourceID string, permission domain.Permission) *domain.FileGrant {
+	return &domain.FileGrant{
+		ID:            id,
+		PrincipalType: principalType,
+		PrincipalID:   principalID,
+		ResourceType:  resourceType,
+		ResourceID:    resourceID,
+		Permission:    permission,
+		CreatedBy:     "admin",
+		CreatedAt:     time.Now(),
+	}
+}
+
+func TestInMemoryFileAuthorization_Upload(t *testing.T) {
+	authz := NewInMemoryFileAuthorization()
+	ctx := context.Background()
+
+	grant := newGrant("g1", domain.PrincipalUser, "alice", "company", "3", domain.PermissionUpload)
+	grant.FileType = "invoice"
+	require.NoError(t, authz.CreateGrant(ctx, grant))
+
+	allowed, err := authz.CanUploadFile(ctx, "alice", "invoice", "company", "3")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	allowed, err = authz.CanUploadFile(ctx, "alice", "contract", "company", "3")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+
+	allowed, err = authz.CanUploadFile(ctx, "alice", "invoice", "company", "7")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+
+	allowed, err = authz.CanUploadFile(ctx, "bob", "invoice", "company", "3")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+}
+
+func TestInMemoryFileAuthorization_ReadViaLinkedResourceAndGroup(t *testing.T) {
+	authz := NewInMemoryFileAuthorization()
+	ctx := context.Background()
+
+	require.NoError(t, authz.CreateGrant(ctx, newGrant("g1", domain.PrincipalGroup, "accounting", "company", "3", domain.PermissionRead)))
+	require.NoError(t, authz.AddGroupMember(ctx, "accounting", "alice"))
+
+	allowed, err := authz.CanReadFile(ctx, "alice", "file-1")
+	require.NoError(t, err)
+	assert.False(t, allowed, "files are not readable before they are registered")
+
+	require.NoError(t, authz.CreateFileAuthorization(ctx, "file-1", "invoice", "3", "company"))
+
+	allowed, err = authz.CanReadFile(ctx, "alice", "file-1")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	allowed, err = authz.CanDeleteFile(ctx, "alice", "file-1")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+
+	require.NoError(t, authz.RemoveGroupMember(ctx, "accounting", "alice"))
+	allowed, err = authz.CanReadFile(ctx, "alice", "file-1")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+}
+
+func TestInMemoryFileAuthorization_FilterReadableFiles(t *testing.T) {
+	authz := NewInMemoryFileAuthorization()
+	ctx := context.Background()
+
+	require.NoError(t, authz.CreateFileAuthorization(ctx, "file-1", "invoice", "3", "company"))
+	require.NoError(t, authz.CreateFileAuthorization(ctx, "file-2", "invoice", "7", "company"))
+	require.NoError(t, authz.CreateFileAuthorization(ctx, "file-3", "invoice", "7", "company"))
+	require.NoError(t, authz.CreateGrant(ctx, newGrant("g1", domain.PrincipalUser, "alice", "company", "3", domain.PermissionRead)))
+	require.NoError(t, authz.CreateGrant(ctx, newGrant("g2", domain.PrincipalUser, "alice", domain.GrantResourceFile, "file-3", domain.PermissionRead)))
+
+	files := []*domain.FileInfo{{ID: "file-3"}, {ID: "file-2"}, {ID: "file-1"}}
+	readable, err := authz.FilterReadableFiles(ctx, "alice", files)
+	require.NoError(t, err)
+	assert.Equal(t, []*domain.FileInfo{files[0], files[2]}, readable)
+
+	readable, err = authz.FilterReadableFiles(ctx, "bob", files)
+	require.NoError(t, err)
+	assert.Empty(t, readable)
+}
+
+func TestInMemoryFileAuthorization_FileGrantsAreRemovedWithFile(t *testing.T) {
+	authz := NewInMemoryFileAuthorization()
+	ctx := context.Background()
+
+	require.NoError(t, authz.CreateFileAuthorization(ctx, "file-1", "invoice", "3", "company"))
+	require.NoError(t, authz.CreateGrant(ctx, newGrant("g1", domain.PrincipalUser, "bob", domain.GrantResourceFile, "file-1", domain.PermissionDelete)))
+
+	allowed, err := authz.CanDeleteFile(ctx, "bob", "file-1")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	require.NoError(t, authz.RemoveFileAuthorization(ctx, "file-1", "invoice", "3", "company"))
+
+	allowed, err = authz.CanDeleteFile(ctx, "bob", "file-1")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+
+	grants, err := authz.ListGrants(ctx, domain.FileGrantFilter{PrincipalID: "bob"})
+	require.NoError(t, err)
+	assert.Empty(t, grants)
+}
+
+func TestInMemoryFileAuthorization_GrantManagement(t *testing.T) {
+	authz := NewInMemoryFileAuthorization()
+	ctx := context.Background()
+
+	require.NoError(t, authz.CreateGrant(ctx, newGrant("g1", domain.PrincipalUser, "alice", "company", "3", domain.PermissionRead)))
+
+	duplicate := newGrant("g2", domain.PrincipalUser, "alice", "company", "3", domain.PermissionRead)
+	require.NoError(t, authz.CreateGrant(ctx, duplicate))
+	assert.Equal(t, "g1", duplicate.ID, "granting twice returns the existing grant")
+
+	require.NoError(t, authz.CreateGrant(ctx, newGrant("g3", domain.PrincipalUser, "bob", "company", "3", domain.PermissionRead)))
+
+	grants, err := authz.ListGrants(ctx, domain.FileGrantFilter{ResourceType: "company", ResourceID: "3"})
+	require.NoError(t, err)
+	assert.Len(t, grants, 2)
+
+	grants, err = authz.ListGrants(ctx, domain.FileGrantFilter{PrincipalType: domain.PrincipalUser, PrincipalID: "alice"})
+	require.NoError(t, err)
+	require.Len(t, grants, 1)
+	assert.Equal(t, "g1", grants[0].ID)
+
+	require.NoError(t, authz.DeleteGrant(ctx, "g1"))
+	assert.ErrorIs(t, authz.DeleteGrant(ctx, "g1"), domain.ErrGrantNotFound)
+
+	require.NoError(t, authz.AddGroupMember(ctx, "accounting", "bob"))
+	require.NoError(t, authz.AddGroupMember(ctx, "accounting", "alice"))
+	members, err := authz.ListGroupMembers(ctx, "accounting")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice", "bob"}, members)
+}
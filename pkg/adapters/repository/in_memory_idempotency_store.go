@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"file-storage-go/pkg/domain"
+)
+
+type InMemoryIdempotencyStore struct {
+	records map[string]*domain.IdempotencyRecord
+	mu      sync.Mutex
+}
+
+func NewInMemoryIdempotencyStore() *InMemoryIdempotencyStore {
+	return &InMemoryIdempotencyStore{
+		records: make(map[string]*domain.IdempotencyRecord),
+	}
+}
+
+func idempotencyRecordKey(userID, key string) string {
+	return userID + "\x00" + key
+}
+
+func (s *InMemoryIdempotencyStore) Begin(ctx context.Context, record *domain.IdempotencyRecord) (*domain.IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recordKey := idempotencyRecordKey(record.UserID, record.Key)
+	if existing, exists := s.records[recordKey]; exists && existing.ExpiresAt.After(time.Now()) {
+		copied := *existing
+		return &copied, nil
+	}
+
+	stored := *record
+	stored.Completed = false
+	s.records[recordKey] = &stored
+	return nil, nil
+}
+
+func (s *InMemoryIdempotencyStore) Complete(ctx context.Context, record *domain.IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, exists := s.records[idempotencyRecordKey(record.UserID, record.Key)]
+	if !exists {
+		return nil
+	}
+	stored.Completed = true
+	stored.StatusCode = record.StatusCode
+	stored.ContentType = record.ContentType
+	stored.Location = record.Location
+	stored.Body = append([]byte(nil), record.Body...)
+	return nil
+}
+
+func (s *InMemoryIdempotencyStore) Release(ctx context.Context, userID, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recordKey := idempotencyRecordKey(userID, key)
+	if stored, exists := s.records[recordKey]; exists && !stored.Completed {
+		delete(s.records, recordKey)
+	}
+	return nil
+}
+
+func (s *InMemoryIdempotencyStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for recordKey, record := range s.records {
+		if !record.ExpiresAt.After(now) {
+			delete(s.records, recordKey)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"file-storage-go/pkg/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryIdempotencyStore(t *testing.T) {
+	store := NewInMemoryIdempotencyStore()
+	ctx := context.Background()
+	now := time.Now()
+	record := &domain.IdempotencyRecord{UserID: "alice", Key: "k1", Fingerprint: "fp", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+
+	existing, err := store.Begin(ctx, record)
+	require.NoError(t, err)
+	assert.Nil(t, existing)
+
+	existing, err = store.Begin(ctx, record)
+	require.NoError(t, err)
+	require.NotNil(t, existing)
+	assert.False(t, existing.Completed)
+
+	existing, err = store.Begin(ctx, &domain.IdempotencyRecord{UserID: "bob", Key: "k1", ExpiresAt: now.Add(time.Hour)})
+	require.NoError(t, err)
+	assert.Nil(t, existing, "keys are per user")
+
+	record.StatusCode = http.StatusCreated
+	record.Body = []byte(`{"jobId":"j1"}`)
+	require.NoError(t, store.Complete(ctx, record))
+	require.NoError(t, store.Release(ctx, "alice", "k1"), "completed records are kept")
+
+	existing, err = store.Begin(ctx, record)
+	require.NoError(t, err)
+	require.NotNil(t, existing)
+	assert.True(t, existing.Completed)
+	assert.Equal(t, http.StatusCreated, existing.StatusCode)
+	assert.Equal(t, `{"jobId":"j1"}`, string(existing.Body))
+
+	require.NoError(t, store.Release(ctx, "bob", "k1"))
+	existing, err = store.Begin(ctx, &domain.IdempotencyRecord{UserID: "bob", Key: "k1", ExpiresAt: now.Add(time.Hour)})
+	require.NoError(t, err)
+	assert.Nil(t, existing, "released records can begin again")
+
+	deleted, err := store.DeleteExpired(ctx, now.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+}
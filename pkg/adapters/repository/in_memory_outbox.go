@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"file-storage-go/pkg/domain"
+)
+
+type inMemoryOutboxEntry struct {
+	event         domain.OutboxEvent
+	nextAttemptAt time.Time
+	publishedAt   time.Time
+}
+
+type InMemoryOutbox struct {
+	entries []*inMemoryOutboxEntry
+	nextSeq int64
+	mu      sync.Mutex
+
+	// relayMu lets a single relay run at a time without blocking writers
+	// while events are published.
+	relayMu sync.Mutex
+}
+
+func NewInMemoryOutbox() *InMemoryOutbox {
+	return &InMemoryOutbox{}
+}
+
+func (o *InMemoryOutbox) append(event *domain.OutboxEvent) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.nextSeq++
+	event.Seq = o.nextSeq
+	o.entries = append(o.entries, &inMemoryOutboxEntry{event: *event, nextAttemptAt: event.OccurredAt})
+}
+
+// Events returns every event written to the outbox, published or not, in
+// Seq order.
+func (o *InMemoryOutbox) Events() []*domain.OutboxEvent {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	events := make([]*domain.OutboxEvent, 0, len(o.entries))
+	for _, entry := range o.entries {
+		event := entry.event
+		events = append(events, &event)
+	}
+	return events
+}
+
+func (o *InMemoryOutbox) Relay(ctx context.Context, limit int, publish func(*domain.OutboxEvent) error) (int, error) {
+	o.relayMu.Lock()
+	defer o.relayMu.Unlock()
+
+	published := 0
+	for _, entry := range o.dueEntries(limit) {
+		event := entry.event
+		err := publish(&event)
+		now := time.Now()
+
+		o.mu.Lock()
+		entry.event.Attempts++
+		if err != nil {
+			entry.event.LastError = err.Error()
+			entry.nextAttemptAt = now.Add(outboxRetryDelay(entry.event.Attempts))
+		} else {
+			entry.event.LastError = ""
+			entry.publishedAt = now
+			published++
+		}
+		o.mu.Unlock()
+	}
+	return published, nil
+}
+
+// dueEntries returns the oldest unpublished entry of each file if its next
+// attempt is due, in Seq order.
+func (o *InMemoryOutbox) dueEntries(limit int) []*inMemoryOutboxEntry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := time.Now()
+	seen := make(map[string]bool)
+	var due []*inMemoryOutboxEntry
+	for _, entry := range o.entries {
+		if !entry.publishedAt.IsZero() || seen[entry.event.FileID] {
+			continue
+		}
+		seen[entry.event.FileID] = true
+		if !entry.nextAttemptAt.After(now) {
+			due = append(due, entry)
+		}
+	}
+
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+	return due
+}
+
+func (o *InMemoryOutbox) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var deleted int64
+	kept := o.entries[:0]
+	for _, entry := range o.entries {
+		if !entry.publishedAt.IsZero() && entry.publishedAt.Before(before) {
+			deleted++
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	o.entries = kept
+	return deleted, nil
+}
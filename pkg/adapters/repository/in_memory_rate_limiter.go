@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"file-storage-go/pkg/domain"
+)
+
+// InMemoryRateLimiter keeps the buckets of this process only, so each
+// replica enforces the limits on its own.
+type InMemoryRateLimiter struct {
+	buckets map[string]*domain.RateLimitBucket
+	mu      sync.Mutex
+}
+
+func NewInMemoryRateLimiter() *InMemoryRateLimiter {
+	return &InMemoryRateLimiter{
+		buckets: make(map[string]*domain.RateLimitBucket),
+	}
+}
+
+func (l *InMemoryRateLimiter) Take(ctx context.Context, key string, limit domain.RateLimit, now time.Time) (domain.RateLimitResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket, exists := l.buckets[key]
+	if !exists {
+		bucket = &domain.RateLimitBucket{}
+		l.buckets[key] = bucket
+	}
+	return limit.Take(bucket, now), nil
+}
+
+func (l *InMemoryRateLimiter) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var deleted int64
+	for key, bucket := range l.buckets {
+		if !bucket.FullAt.After(now) {
+			delete(l.buckets, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
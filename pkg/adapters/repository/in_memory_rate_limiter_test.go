@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"file-storage-go/pkg/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryRateLimiter(t *testing.T) {
+	limiter := NewInMemoryRateLimiter()
+	ctx := context.Background()
+	limit := domain.RateLimit{Requests: 1, Period: time.Minute}
+	now := time.Now()
+
+	result, err := limiter.Take(ctx, "upload:user:alice", limit, now)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	result, err = limiter.Take(ctx, "upload:user:alice", limit, now.Add(time.Second))
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 59*time.Second, result.RetryAfter)
+
+	result, err = limiter.Take(ctx, "upload:user:bob", limit, now)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed, "buckets are per key")
+
+	deleted, err := limiter.DeleteExpired(ctx, now.Add(30*time.Second))
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+
+	deleted, err = limiter.DeleteExpired(ctx, now.Add(2*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+
+	result, err = limiter.Take(ctx, "upload:user:alice", limit, now.Add(2*time.Minute))
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+}
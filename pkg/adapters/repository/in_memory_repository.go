@@ -2,7 +2,10 @@ package repository
 
 import (
 	"context"
+	"slices"
+	"sort"
 	"sync"
+	"time"
 
 	"file-storage-go/pkg/domain"
 )
@@ -10,19 +13,42 @@ import (
 type InMemoryJobRepo struct {
 	jobs map[string]*domain.UploadJob
 	mu   sync.RWMutex
+
+	// statuses holds the stored status of each job. Callers update the
+	// stored jobs in place, so the previous status of an update is only
+	// known from here.
+	statuses     map[string]domain.JobStatus
+	outbox       *InMemoryOutbox
+	fileInfoRepo *InMemoryFileInfoRepo
+	signals      *jobSignals
 }
 
 func NewInMemoryJobRepo() *InMemoryJobRepo {
+	return NewInMemoryJobRepoWithOutbox(nil, nil)
+}
+
+// NewInMemoryJobRepoWithOutbox writes the file lifecycle events of job status
+// changes to outbox, with the file info from fileInfoRepo.
+func NewInMemoryJobRepoWithOutbox(outbox *InMemoryOutbox, fileInfoRepo *InMemoryFileInfoRepo) *InMemoryJobRepo {
 	return &InMemoryJobRepo{
-		jobs: make(map[string]*domain.UploadJob),
+		jobs:         make(map[string]*domain.UploadJob),
+		statuses:     make(map[string]domain.JobStatus),
+		outbox:       outbox,
+		fileInfoRepo: fileInfoRepo,
+		signals:      newJobSignals(),
 	}
 }
 
+func (r *InMemoryJobRepo) Watch(jobID string) (<-chan struct{}, func()) {
+	return r.signals.Watch(jobID)
+}
+
 func (r *InMemoryJobRepo) Create(ctx context.Context, job *domain.UploadJob) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	r.jobs[job.ID] = job
+	r.statuses[job.ID] = job.Status
 	return nil
 }
 
@@ -45,8 +71,44 @@ func (r *InMemoryJobRepo) Update(ctx context.Context, job *domain.UploadJob) err
 	if _, exists := r.jobs[job.ID]; !exists {
 		return nil
 	}
+	return r.update(ctx, job)
+}
+
+// UpdateIfStatus compares with the stored status rather than the stored job,
+// since callers may have changed the stored job in place.
+func (r *InMemoryJobRepo) UpdateIfStatus(ctx context.Context, job *domain.UploadJob, expected domain.JobStatus) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if previous, exists := r.statuses[job.ID]; !exists || previous != expected {
+		return false, nil
+	}
+	return true, r.update(ctx, job)
+}
 
+func (r *InMemoryJobRepo) update(ctx context.Context, job *domain.UploadJob) error {
+	previous := r.statuses[job.ID]
 	r.jobs[job.ID] = job
+	r.statuses[job.ID] = job.Status
+	r.signals.notify(job.ID)
+
+	eventType := domain.JobTransitionEvent(previous, job)
+	if r.outbox == nil || eventType == "" || job.FileID == "" {
+		return nil
+	}
+
+	payload := domain.FileEventPayload{JobID: job.ID}
+	if r.fileInfoRepo != nil {
+		payload.File, _ = r.fileInfoRepo.Get(ctx, job.FileID)
+	}
+	if eventType == domain.EventScanFailed {
+		payload.Error = job.Error
+	}
+	event, err := newFileEvent(eventType, job.FileID, payload, job.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	r.outbox.append(event)
 	return nil
 }
 
@@ -75,14 +137,76 @@ func (r *InMemoryJobRepo) GetByStatus(ctx context.Context, status domain.JobStat
 	return jobs, nil
 }
 
+func (r *InMemoryJobRepo) CountByStatus(ctx context.Context) (map[domain.JobStatus]int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := make(map[domain.JobStatus]int64)
+	for _, job := range r.jobs {
+		counts[job.Status]++
+	}
+	return counts, nil
+}
+
+func (r *InMemoryJobRepo) List(ctx context.Context, filter domain.UploadJobFilter) ([]*domain.UploadJob, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var jobs []*domain.UploadJob
+	for _, job := range r.jobs {
+		if filter.Status != "" && job.Status != filter.Status {
+			continue
+		}
+		if !filter.UpdatedBefore.IsZero() && !job.UpdatedAt.Before(filter.UpdatedBefore) {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].UpdatedAt.Equal(jobs[j].UpdatedAt) {
+			return jobs[i].UpdatedAt.Before(jobs[j].UpdatedAt)
+		}
+		return jobs[i].ID < jobs[j].ID
+	})
+	if filter.Limit > 0 && len(jobs) > filter.Limit {
+		jobs = jobs[:filter.Limit]
+	}
+	return jobs, nil
+}
+
+func (r *InMemoryJobRepo) Purge(ctx context.Context, statuses []domain.JobStatus, updatedBefore time.Time) ([]*domain.UploadJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var purged []*domain.UploadJob
+	for id, job := range r.jobs {
+		if slices.Contains(statuses, job.Status) && job.UpdatedAt.Before(updatedBefore) {
+			delete(r.jobs, id)
+			delete(r.statuses, id)
+			copied := *job
+			purged = append(purged, &copied)
+		}
+	}
+	return purged, nil
+}
+
 type InMemoryFileInfoRepo struct {
 	fileInfos map[string]*domain.FileInfo
 	mu        sync.RWMutex
+	outbox    *InMemoryOutbox
 }
 
 func NewInMemoryFileInfoRepo() *InMemoryFileInfoRepo {
+	return NewInMemoryFileInfoRepoWithOutbox(nil)
+}
+
+// NewInMemoryFileInfoRepoWithOutbox writes a file.deleted event to outbox
+// when file info is deleted.
+func NewInMemoryFileInfoRepoWithOutbox(outbox *InMemoryOutbox) *InMemoryFileInfoRepo {
 	return &InMemoryFileInfoRepo{
 		fileInfos: make(map[string]*domain.FileInfo),
+		outbox:    outbox,
 	}
 }
 
@@ -106,6 +230,19 @@ func (r *InMemoryFileInfoRepo) Get(ctx context.Context, fileID string) (*domain.
 	return fileInfo, nil
 }
 
+func (r *InMemoryFileInfoRepo) GetMany(ctx context.Context, fileIDs []string) ([]*domain.FileInfo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	fileInfos := make([]*domain.FileInfo, 0, len(fileIDs))
+	for _, fileID := range fileIDs {
+		if fileInfo, exists := r.fileInfos[fileID]; exists {
+			fileInfos = append(fileInfos, fileInfo)
+		}
+	}
+	return fileInfos, nil
+}
+
 func (r *InMemoryFileInfoRepo) Update(ctx context.Context, fileInfo *domain.FileInfo) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -122,6 +259,19 @@ func (r *InMemoryFileInfoRepo) Delete(ctx context.Context, fileID string) error
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	fileInfo, exists := r.fileInfos[fileID]
+	if !exists {
+		return nil
+	}
 	delete(r.fileInfos, fileID)
+
+	if r.outbox == nil {
+		return nil
+	}
+	event, err := newFileEvent(domain.EventFileDeleted, fileID, domain.FileEventPayload{File: fileInfo}, time.Now())
+	if err != nil {
+		return err
+	}
+	r.outbox.append(event)
 	return nil
 }
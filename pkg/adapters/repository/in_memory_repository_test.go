@@ -104,6 +104,32 @@ func TestInMemoryJobRepo_Update(t *testing.T) {
 	}
 }
 
+func TestInMemoryJobRepo_UpdateIfStatus(t *testing.T) {
+	repo := NewInMemoryJobRepo()
+	ctx := context.Background()
+	if err := repo.Create(ctx, &domain.UploadJob{ID: "test-job", Status: domain.JobStatusVirusChecking}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	updated, err := repo.UpdateIfStatus(ctx, &domain.UploadJob{ID: "test-job", Status: domain.JobStatusFailed}, domain.JobStatusVirusChecking)
+	if err != nil || !updated {
+		t.Fatalf("Expected the update from the expected status to be stored, got %v, %v", updated, err)
+	}
+
+	updated, err = repo.UpdateIfStatus(ctx, &domain.UploadJob{ID: "test-job", Status: domain.JobStatusCompleted}, domain.JobStatusVirusChecking)
+	if err != nil || updated {
+		t.Fatalf("Expected the update from a stale status to be skipped, got %v, %v", updated, err)
+	}
+	if status := repo.jobs["test-job"].Status; status != domain.JobStatusFailed {
+		t.Errorf("Expected status to stay %q, got %q", domain.JobStatusFailed, status)
+	}
+
+	updated, err = repo.UpdateIfStatus(ctx, &domain.UploadJob{ID: "non-existent"}, domain.JobStatusPending)
+	if err != nil || updated {
+		t.Errorf("Expected no update of a non-existent job, got %v, %v", updated, err)
+	}
+}
+
 func TestInMemoryJobRepo_GetByFileID(t *testing.T) {
 	repo := NewInMemoryJobRepo()
 	ctx := context.Background()
@@ -171,3 +197,93 @@ func TestInMemoryJobRepo_ConcurrentOperations(t *testing.T) {
 		t.Errorf("Expected 1 job after concurrent operations, got %d", len(repo.jobs))
 	}
 }
+
+func TestInMemoryJobRepo_ListAndPurge(t *testing.T) {
+	repo := NewInMemoryJobRepo()
+	ctx := context.Background()
+	now := time.Now()
+
+	jobs := []*domain.UploadJob{
+		{ID: "old-failed", Status: domain.JobStatusFailed, UpdatedAt: now.Add(-48 * time.Hour)},
+		{ID: "old-pending", Status: domain.JobStatusPending, UpdatedAt: now.Add(-25 * time.Hour)},
+		{ID: "new-failed", Status: domain.JobStatusFailed, UpdatedAt: now},
+		{ID: "old-completed", Status: domain.JobStatusCompleted, UpdatedAt: now.Add(-72 * time.Hour)},
+	}
+	for _, job := range jobs {
+		if err := repo.Create(ctx, job); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+
+	failed, err := repo.List(ctx, domain.UploadJobFilter{Status: domain.JobStatusFailed})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(failed) != 2 || failed[0].ID != "old-failed" || failed[1].ID != "new-failed" {
+		t.Errorf("Expected failed jobs oldest first, got %v", failed)
+	}
+
+	stale, err := repo.List(ctx, domain.UploadJobFilter{UpdatedBefore: now.Add(-24 * time.Hour), Limit: 2})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(stale) != 2 || stale[0].ID != "old-completed" || stale[1].ID != "old-failed" {
+		t.Errorf("Expected the two oldest jobs, got %v", stale)
+	}
+
+	purged, err := repo.Purge(ctx, []domain.JobStatus{domain.JobStatusFailed, domain.JobStatusPending}, now.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("Purge failed: %v", err)
+	}
+	if len(purged) != 2 {
+		t.Errorf("Expected 2 purged jobs, got %d", len(purged))
+	}
+	if _, exists := repo.jobs["new-failed"]; !exists {
+		t.Errorf("Recent failed job should not be purged")
+	}
+	if _, exists := repo.jobs["old-completed"]; !exists {
+		t.Errorf("Completed job should not be purged")
+	}
+}
+
+func TestInMemoryJobRepo_Watch(t *testing.T) {
+	repo := NewInMemoryJobRepo()
+	ctx := context.Background()
+	job := &domain.UploadJob{ID: "watched", Status: domain.JobStatusVirusCheckPending}
+	if err := repo.Create(ctx, job); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	changed, stop := repo.Watch(job.ID)
+	other, stopOther := repo.Watch("other")
+	defer stopOther()
+
+	job.Status = domain.JobStatusVirusChecking
+	repo.Update(ctx, job)
+	job.Status = domain.JobStatusCompleted
+	repo.Update(ctx, job)
+
+	select {
+	case <-changed:
+	default:
+		t.Fatalf("Expected a change signal")
+	}
+	select {
+	case <-changed:
+		t.Errorf("Expected signals to be coalesced")
+	default:
+	}
+	select {
+	case <-other:
+		t.Errorf("Expected no signal for another job")
+	default:
+	}
+
+	stop()
+	repo.Update(ctx, job)
+	select {
+	case <-changed:
+		t.Errorf("Expected no signal after stop")
+	default:
+	}
+}
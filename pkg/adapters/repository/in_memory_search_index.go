@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"html"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"file-storage-go/pkg/domain"
+)
+
+const (
+	highlightContext = 60
+	highlightStart   = "__HL_START__"
+	highlightEnd     = "__HL_END__"
+)
+
+type InMemorySearchIndex struct {
+	contents map[string]string
+	mu       sync.RWMutex
+}
+
+func NewInMemorySearchIndex() *InMemorySearchIndex {
+	return &InMemorySearchIndex{
+		contents: make(map[string]string),
+	}
+}
+
+func (r *InMemorySearchIndex) Index(ctx context.Context, fileID string, content string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.contents[fileID] = content
+	return nil
+}
+
+func (r *InMemorySearchIndex) Search(ctx context.Context, query string, limit, offset int) ([]*domain.SearchResult, error) {
+	terms := tokenize(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var results []*domain.SearchResult
+	for fileID, content := range r.contents {
+		tokens := tokenize(content)
+		counts := make(map[string]int, len(tokens))
+		for _, token := range tokens {
+			counts[token]++
+		}
+
+		matches := 0
+		for _, term := range terms {
+			if counts[term] == 0 {
+				matches = -1
+				break
+			}
+			matches += counts[term]
+		}
+		if matches <= 0 {
+			continue
+		}
+
+		results = append(results, &domain.SearchResult{
+			FileID:    fileID,
+			Rank:      float64(matches) / float64(len(tokens)),
+			Highlight: highlight(content, terms),
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].FileID < results[j].FileID
+	})
+
+	if offset >= len(results) {
+		return nil, nil
+	}
+	results = results[offset:]
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+func (r *InMemorySearchIndex) Delete(ctx context.Context, fileID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.contents, fileID)
+	return nil
+}
+
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func highlight(content string, terms []string) string {
+	lower := strings.ToLower(content)
+	first := -1
+	for _, term := range terms {
+		if i := strings.Index(lower, term); i >= 0 && (first < 0 || i < first) {
+			first = i
+		}
+	}
+	if first < 0 {
+		return ""
+	}
+
+	start := max(0, first-highlightContext)
+	end := min(len(content), first+highlightContext)
+	for start > 0 && !isRuneStart(content[start]) {
+		start--
+	}
+	for end < len(content) && !isRuneStart(content[end]) {
+		end++
+	}
+
+	fragment := content[start:end]
+	lowerFragment := strings.ToLower(fragment)
+	if len(lowerFragment) != len(fragment) {
+		return html.EscapeString(fragment)
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(fragment); {
+		matched := ""
+		for _, term := range terms {
+			if strings.HasPrefix(lowerFragment[i:], term) && len(term) > len(matched) {
+				matched = term
+			}
+		}
+		if matched == "" {
+			b.WriteByte(fragment[i])
+			i++
+			continue
+		}
+		b.WriteString(highlightStart)
+		b.WriteString(fragment[i : i+len(matched)])
+		b.WriteString(highlightEnd)
+		i += len(matched)
+	}
+	return escapeHighlight(b.String())
+}
+
+// escapeHighlight HTML-escapes a highlighted fragment and turns the highlight
+// markers into <mark> tags.
+func escapeHighlight(fragment string) string {
+	escaped := html.EscapeString(fragment)
+	escaped = strings.ReplaceAll(escaped, highlightStart, "<mark>")
+	return strings.ReplaceAll(escaped, highlightEnd, "</mark>")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
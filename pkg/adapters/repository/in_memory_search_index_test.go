@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemorySearchIndex_Search(t *testing.T) {
+	index := NewInMemorySearchIndex()
+	ctx := context.Background()
+
+	require.NoError(t, index.Index(ctx, "file-1", "Invoice for consulting services"))
+	require.NoError(t, index.Index(ctx, "file-2", "Invoice invoice invoice"))
+	require.NoError(t, index.Index(ctx, "file-3", "Holiday pictures"))
+
+	results, err := index.Search(ctx, "invoice", 10, 0)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "file-2", results[0].FileID)
+	assert.Equal(t, "file-1", results[1].FileID)
+	assert.Equal(t, "<mark>Invoice</mark> for consulting services", results[1].Highlight)
+
+	results, err = index.Search(ctx, "invoice consulting", 10, 0)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "file-1", results[0].FileID)
+
+	results, err = index.Search(ctx, "invoice", 1, 1)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "file-1", results[0].FileID)
+}
+
+func TestInMemorySearchIndex_EscapesHighlight(t *testing.T) {
+	index := NewInMemorySearchIndex()
+	ctx := context.Background()
+
+	require.NoError(t, index.Index(ctx, "file", "<script>alert(1)</script> secret"))
+
+	results, err := index.Search(ctx, "secret", 10, 0)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "&lt;script&gt;alert(1)&lt;/script&gt; <mark>secret</mark>", results[0].Highlight)
+}
+
+func TestInMemorySearchIndex_Delete(t *testing.T) {
+	index := NewInMemorySearchIndex()
+	ctx := context.Background()
+
+	require.NoError(t, index.Index(ctx, "file", "content"))
+	require.NoError(t, index.Delete(ctx, "file"))
+
+	results, err := index.Search(ctx, "content", 10, 0)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
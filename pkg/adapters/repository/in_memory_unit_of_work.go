@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+	"sync"
+
+	"file-storage-go/pkg/domain"
+)
+
+// InMemoryUnitOfWork runs each unit of work against copies of the
+// repositories. Its changes are applied to the repositories, and its events
+// written to their outbox, only if it succeeds. File authorizations, webhook
+// subscriptions and webhook deliveries are stored at the same point. Units of work run
+// one at a time but are not isolated from writes made outside of them.
+type InMemoryUnitOfWork struct {
+	jobs               *InMemoryJobRepo
+	fileInfos          *InMemoryFileInfoRepo
+	fileAuthorizations domain.FileAuthorizationRegistry
+	webhooks           domain.WebhookRepository
+	mu                 sync.Mutex
+}
+
+func NewInMemoryUnitOfWork(jobs *InMemoryJobRepo, fileInfos *InMemoryFileInfoRepo, fileAuthorizations domain.FileAuthorizationRegistry, webhooks domain.WebhookRepository) *InMemoryUnitOfWork {
+	return &InMemoryUnitOfWork{
+		jobs:               jobs,
+		fileInfos:          fileInfos,
+		fileAuthorizations: fileAuthorizations,
+		webhooks:           webhooks,
+	}
+}
+
+func (u *InMemoryUnitOfWork) Do(ctx context.Context, fn func(repos domain.Repositories) error) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	outbox := u.jobs.outbox
+	if outbox == nil {
+		outbox = u.fileInfos.outbox
+	}
+	var staged *InMemoryOutbox
+	if outbox != nil {
+		staged = NewInMemoryOutbox()
+	}
+
+	fileInfosBefore := u.fileInfos.snapshot()
+	fileInfos := &InMemoryFileInfoRepo{fileInfos: make(map[string]*domain.FileInfo, len(fileInfosBefore))}
+	for id, fileInfo := range fileInfosBefore {
+		fileInfos.fileInfos[id] = &fileInfo
+	}
+	if u.fileInfos.outbox != nil {
+		fileInfos.outbox = staged
+	}
+
+	jobsBefore, statusesBefore := u.jobs.snapshot()
+	jobs := &InMemoryJobRepo{
+		jobs:     make(map[string]*domain.UploadJob, len(jobsBefore)),
+		statuses: make(map[string]domain.JobStatus, len(statusesBefore)),
+		signals:  newJobSignals(),
+	}
+	for id, job := range jobsBefore {
+		jobs.jobs[id] = &job
+		jobs.statuses[id] = statusesBefore[id]
+	}
+	if u.jobs.outbox != nil {
+		jobs.outbox = staged
+	}
+	if u.jobs.fileInfoRepo != nil {
+		jobs.fileInfoRepo = fileInfos
+	}
+
+	var deferred []func(ctx context.Context) error
+	if err := fn(domain.Repositories{
+		Jobs:               jobs,
+		FileInfos:          fileInfos,
+		FileAuthorizations: &deferredFileAuthorizations{inner: u.fileAuthorizations, deferred: &deferred},
+		Webhooks:           &deferredWebhooks{WebhookRepository: u.webhooks, deferred: &deferred},
+	}); err != nil {
+		return err
+	}
+
+	for _, apply := range deferred {
+		if err := apply(ctx); err != nil {
+			return err
+		}
+	}
+	u.fileInfos.apply(fileInfosBefore, fileInfos)
+	u.jobs.apply(jobsBefore, statusesBefore, jobs)
+	if staged != nil {
+		for _, event := range staged.Events() {
+			outbox.append(event)
+		}
+	}
+	return nil
+}
+
+// deferredFileAuthorizations registers files once the unit of work succeeds.
+type deferredFileAuthorizations struct {
+	inner    domain.FileAuthorizationRegistry
+	deferred *[]func(ctx context.Context) error
+}
+
+func (r *deferredFileAuthorizations) CreateFileAuthorization(ctx context.Context, fileID, fileType, linkedResourceID, linkedResourceType string) error {
+	*r.deferred = append(*r.deferred, func(ctx context.Context) error {
+		return r.inner.CreateFileAuthorization(ctx, fileID, fileType, linkedResourceID, linkedResourceType)
+	})
+	return nil
+}
+
+func (r *deferredFileAuthorizations) RemoveFileAuthorization(ctx context.Context, fileID, fileType, linkedResourceID, linkedResourceType string) error {
+	*r.deferred = append(*r.deferred, func(ctx context.Context) error {
+		return r.inner.RemoveFileAuthorization(ctx, fileID, fileType, linkedResourceID, linkedResourceType)
+	})
+	return nil
+}
+
+// deferredWebhooks stores the subscriptions and deliveries created in a unit of
+// work once it succeeds. Other webhook changes are made immediately.
+type deferredWebhooks struct {
+	domain.WebhookRepository
+	deferred *[]func(ctx context.Context) error
+}
+
+func (r *deferredWebhooks) CreateSubscription(ctx context.Context, subscription *domain.WebhookSubscription) error {
+	stored := *subscription
+	*r.deferred = append(*r.deferred, func(ctx context.Context) error {
+		return r.WebhookRepository.CreateSubscription(ctx, &stored)
+	})
+	return nil
+}
+
+func (r *deferredWebhooks) CreateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	stored := *delivery
+	*r.deferred = append(*r.deferred, func(ctx context.Context) error {
+		return r.WebhookRepository.CreateDelivery(ctx, &stored)
+	})
+	return nil
+}
+
+// snapshot returns copies of the stored jobs and their statuses.
+func (r *InMemoryJobRepo) snapshot() (map[string]domain.UploadJob, map[string]domain.JobStatus) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	jobs := make(map[string]domain.UploadJob, len(r.jobs))
+	statuses := make(map[string]domain.JobStatus, len(r.statuses))
+	for id, job := range r.jobs {
+		jobs[id] = *job
+		statuses[id] = r.statuses[id]
+	}
+	return jobs, statuses
+}
+
+// apply stores the jobs that unit changed compared to the snapshot it
+// started from.
+func (r *InMemoryJobRepo) apply(before map[string]domain.UploadJob, statusesBefore map[string]domain.JobStatus, unit *InMemoryJobRepo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, job := range unit.jobs {
+		previous, existed := before[id]
+		if existed && previous == *job && statusesBefore[id] == unit.statuses[id] {
+			continue
+		}
+		r.jobs[id] = job
+		r.statuses[id] = unit.statuses[id]
+		r.signals.notify(id)
+	}
+	for id := range before {
+		if _, exists := unit.jobs[id]; !exists {
+			delete(r.jobs, id)
+			delete(r.statuses, id)
+		}
+	}
+}
+
+// snapshot returns copies of the stored file info.
+func (r *InMemoryFileInfoRepo) snapshot() map[string]domain.FileInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	fileInfos := make(map[string]domain.FileInfo, len(r.fileInfos))
+	for id, fileInfo := range r.fileInfos {
+		fileInfos[id] = *fileInfo
+	}
+	return fileInfos
+}
+
+// apply stores the file info that unit changed compared to the snapshot it
+// started from.
+func (r *InMemoryFileInfoRepo) apply(before map[string]domain.FileInfo, unit *InMemoryFileInfoRepo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, fileInfo := range unit.fileInfos {
+		if previous, existed := before[id]; existed && previous == *fileInfo {
+			continue
+		}
+		r.fileInfos[id] = fileInfo
+	}
+	for id := range before {
+		if _, exists := unit.fileInfos[id]; !exists {
+			delete(r.fileInfos, id)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"file-storage-go/pkg/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryUnitOfWork(t *testing.T) {
+	ctx := context.Background()
+	outbox := NewInMemoryOutbox()
+	fileInfoRepo := NewInMemoryFileInfoRepoWithOutbox(outbox)
+	jobRepo := NewInMemoryJobRepoWithOutbox(outbox, fileInfoRepo)
+	uow := NewInMemoryUnitOfWork(jobRepo, fileInfoRepo, NewMockFileAuthorization(), NewInMemoryWebhookRepo())
+	now := time.Now()
+
+	create := func(repos domain.Repositories) error {
+		if err := repos.FileInfos.Create(ctx, &domain.FileInfo{ID: "file-1", CreatedAt: now, UpdatedAt: now}); err != nil {
+			return err
+		}
+		return repos.Jobs.Create(ctx, &domain.UploadJob{ID: "job-1", FileID: "file-1", Status: domain.JobStatusUploading, CreatedAt: now, UpdatedAt: now})
+	}
+
+	failure := errors.New("insert failed")
+	err := uow.Do(ctx, func(repos domain.Repositories) error {
+		require.NoError(t, create(repos))
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+	fileInfo, err := fileInfoRepo.Get(ctx, "file-1")
+	require.NoError(t, err)
+	assert.Nil(t, fileInfo, "a failed unit of work leaves no file info behind")
+
+	require.NoError(t, uow.Do(ctx, create))
+	fileInfo, err = fileInfoRepo.Get(ctx, "file-1")
+	require.NoError(t, err)
+	require.NotNil(t, fileInfo)
+	job, err := jobRepo.Get(ctx, "job-1")
+	require.NoError(t, err)
+	require.NotNil(t, job)
+
+	changed, stop := jobRepo.Watch("job-1")
+	defer stop()
+	err = uow.Do(ctx, func(repos domain.Repositories) error {
+		job, err := repos.Jobs.Get(ctx, "job-1")
+		if err != nil {
+			return err
+		}
+		job.Status = domain.JobStatusCompleted
+		if err := repos.Jobs.Update(ctx, job); err != nil {
+			return err
+		}
+		assert.Empty(t, outbox.Events(), "events are written when the unit of work succeeds")
+		return nil
+	})
+	require.NoError(t, err)
+	job, err = jobRepo.Get(ctx, "job-1")
+	require.NoError(t, err)
+	assert.Equal(t, domain.JobStatusCompleted, job.Status)
+	select {
+	case <-changed:
+	default:
+		t.Error("watchers are notified of the job change")
+	}
+
+	err = uow.Do(ctx, func(repos domain.Repositories) error {
+		require.NoError(t, repos.FileInfos.Delete(ctx, "file-1"))
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+	fileInfo, err = fileInfoRepo.Get(ctx, "file-1")
+	require.NoError(t, err)
+	assert.NotNil(t, fileInfo, "a failed unit of work does not delete file info")
+
+	var types []string
+	for _, event := range outbox.Events() {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []string{domain.EventFileCreated}, types)
+}
+
+func TestInMemoryUnitOfWork_RegistersAuthorizationsAndSubscriptionsOnSuccess(t *testing.T) {
+	ctx := context.Background()
+	fileInfoRepo := NewInMemoryFileInfoRepo()
+	jobRepo := NewInMemoryJobRepo()
+	authz := NewInMemoryFileAuthorization()
+	webhooks := NewInMemoryWebhookRepo()
+	uow := NewInMemoryUnitOfWork(jobRepo, fileInfoRepo, authz, webhooks)
+	require.NoError(t, authz.CreateGrant(ctx, newGrant("g1", domain.PrincipalUser, "alice", "company", "3", domain.PermissionRead)))
+
+	register := func(repos domain.Repositories) error {
+		if err := repos.FileAuthorizations.CreateFileAuthorization(ctx, "file-1", "invoice", "3", "company"); err != nil {
+			return err
+		}
+		return repos.Webhooks.CreateSubscription(ctx, &domain.WebhookSubscription{ID: "sub-1", JobID: "job-1", URL: "https://example.com/hook"})
+	}
+
+	failure := errors.New("insert failed")
+	err := uow.Do(ctx, func(repos domain.Repositories) error {
+		require.NoError(t, register(repos))
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+	allowed, err := authz.CanReadFile(ctx, "alice", "file-1")
+	require.NoError(t, err)
+	assert.False(t, allowed, "a failed unit of work registers no file authorization")
+	subscriptions, err := webhooks.MatchingSubscriptions(ctx, "job-1", "")
+	require.NoError(t, err)
+	assert.Empty(t, subscriptions, "a failed unit of work creates no subscription")
+
+	require.NoError(t, uow.Do(ctx, register))
+	allowed, err = authz.CanReadFile(ctx, "alice", "file-1")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+	subscriptions, err = webhooks.MatchingSubscriptions(ctx, "job-1", "")
+	require.NoError(t, err)
+	assert.Len(t, subscriptions, 1)
+}
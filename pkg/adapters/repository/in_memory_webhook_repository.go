@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"file-storage-go/pkg/domain"
+)
+
+type InMemoryWebhookRepo struct {
+	subscriptions map[string]*domain.WebhookSubscription
+	deliveries    map[string]*domain.WebhookDelivery
+	mu            sync.RWMutex
+}
+
+func NewInMemoryWebhookRepo() *InMemoryWebhookRepo {
+	return &InMemoryWebhookRepo{
+		subscriptions: make(map[string]*domain.WebhookSubscription),
+		deliveries:    make(map[string]*domain.WebhookDelivery),
+	}
+}
+
+func (r *InMemoryWebhookRepo) CreateSubscription(ctx context.Context, subscription *domain.WebhookSubscription) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *subscription
+	r.subscriptions[subscription.ID] = &stored
+	return nil
+}
+
+func (r *InMemoryWebhookRepo) DeleteSubscription(ctx context.Context, subscriptionID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.subscriptions[subscriptionID]; !exists {
+		return domain.ErrWebhookNotFound
+	}
+	delete(r.subscriptions, subscriptionID)
+	return nil
+}
+
+// ListSubscriptions returns the configured subscriptions, not the callbacks
+// registered for single jobs.
+func (r *InMemoryWebhookRepo) ListSubscriptions(ctx context.Context) ([]*domain.WebhookSubscription, error) {
+	return r.filterSubscriptions(func(s *domain.WebhookSubscription) bool {
+		return s.JobID == ""
+	}), nil
+}
+
+func (r *InMemoryWebhookRepo) MatchingSubscriptions(ctx context.Context, jobID, linkedResourceType string) ([]*domain.WebhookSubscription, error) {
+	return r.filterSubscriptions(func(s *domain.WebhookSubscription) bool {
+		return (s.JobID != "" && s.JobID == jobID) ||
+			(s.LinkedResourceType != "" && s.LinkedResourceType == linkedResourceType)
+	}), nil
+}
+
+func (r *InMemoryWebhookRepo) filterSubscriptions(match func(*domain.WebhookSubscription) bool) []*domain.WebhookSubscription {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var subscriptions []*domain.WebhookSubscription
+	for _, subscription := range r.subscriptions {
+		if !match(subscription) {
+			continue
+		}
+		copied := *subscription
+		subscriptions = append(subscriptions, &copied)
+	}
+
+	sort.Slice(subscriptions, func(i, j int) bool {
+		if !subscriptions[i].CreatedAt.Equal(subscriptions[j].CreatedAt) {
+			return subscriptions[i].CreatedAt.Before(subscriptions[j].CreatedAt)
+		}
+		return subscriptions[i].ID < subscriptions[j].ID
+	})
+	return subscriptions
+}
+
+func (r *InMemoryWebhookRepo) CreateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *delivery
+	stored.Secret = ""
+	r.deliveries[delivery.ID] = &stored
+	return nil
+}
+
+func (r *InMemoryWebhookRepo) GetDelivery(ctx context.Context, deliveryID string) (*domain.WebhookDelivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	delivery, exists := r.deliveries[deliveryID]
+	if !exists {
+		return nil, nil
+	}
+	copied := *delivery
+	return &copied, nil
+}
+
+func (r *InMemoryWebhookRepo) ListDeliveries(ctx context.Context, filter domain.WebhookDeliveryFilter) ([]*domain.WebhookDelivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var deliveries []*domain.WebhookDelivery
+	for _, delivery := range r.deliveries {
+		if filter.Status != "" && delivery.Status != filter.Status {
+			continue
+		}
+		if filter.JobID != "" && delivery.JobID != filter.JobID {
+			continue
+		}
+		copied := *delivery
+		deliveries = append(deliveries, &copied)
+	}
+
+	sort.Slice(deliveries, func(i, j int) bool {
+		if !deliveries[i].CreatedAt.Equal(deliveries[j].CreatedAt) {
+			return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+		}
+		return deliveries[i].ID < deliveries[j].ID
+	})
+	if filter.Limit > 0 && len(deliveries) > filter.Limit {
+		deliveries = deliveries[:filter.Limit]
+	}
+	return deliveries, nil
+}
+
+func (r *InMemoryWebhookRepo) UpdateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, exists := r.deliveries[delivery.ID]
+	if !exists {
+		return domain.ErrWebhookNotFound
+	}
+	stored.Status = delivery.Status
+	stored.Attempts = delivery.Attempts
+	stored.NextAttemptAt = delivery.NextAttemptAt
+	stored.LastAttemptAt = delivery.LastAttemptAt
+	stored.LastStatusCode = delivery.LastStatusCode
+	stored.LastError = delivery.LastError
+	return nil
+}
+
+func (r *InMemoryWebhookRepo) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*domain.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var due []*domain.WebhookDelivery
+	for _, delivery := range r.deliveries {
+		if delivery.Status == domain.WebhookDeliveryPending && !delivery.NextAttemptAt.After(now) {
+			due = append(due, delivery)
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]*domain.WebhookDelivery, 0, len(due))
+	for _, delivery := range due {
+		delivery.NextAttemptAt = now.Add(lease)
+		copied := *delivery
+		if subscription, exists := r.subscriptions[delivery.SubscriptionID]; exists {
+			copied.Secret = subscription.Secret
+		}
+		claimed = append(claimed, &copied)
+	}
+	return claimed, nil
+}
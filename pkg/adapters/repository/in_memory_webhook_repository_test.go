@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"file-storage-go/pkg/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryWebhookRepo_Subscriptions(t *testing.T) {
+	repo := NewInMemoryWebhookRepo()
+	ctx := context.Background()
+	now := time.Now()
+
+	require.NoError(t, repo.CreateSubscription(ctx, &domain.WebhookSubscription{ID: "s1", URL: "http://a", LinkedResourceType: "invoice", CreatedAt: now}))
+	require.NoError(t, repo.CreateSubscription(ctx, &domain.WebhookSubscription{ID: "s2", URL: "http://b", JobID: "job-1", CreatedAt: now.Add(time.Second)}))
+	require.NoError(t, repo.CreateSubscription(ctx, &domain.WebhookSubscription{ID: "s3", URL: "http://c", LinkedResourceType: "contract", CreatedAt: now}))
+
+	subscriptions, err := repo.ListSubscriptions(ctx)
+	require.NoError(t, err)
+	require.Len(t, subscriptions, 2, "per job callbacks are not listed")
+
+	subscriptions, err = repo.MatchingSubscriptions(ctx, "job-1", "invoice")
+	require.NoError(t, err)
+	require.Len(t, subscriptions, 2)
+	assert.Equal(t, "s1", subscriptions[0].ID)
+	assert.Equal(t, "s2", subscriptions[1].ID)
+
+	subscriptions, err = repo.MatchingSubscriptions(ctx, "job-2", "")
+	require.NoError(t, err)
+	assert.Empty(t, subscriptions)
+
+	require.NoError(t, repo.DeleteSubscription(ctx, "s1"))
+	assert.ErrorIs(t, repo.DeleteSubscription(ctx, "s1"), domain.ErrWebhookNotFound)
+}
+
+func TestInMemoryWebhookRepo_ClaimDueDeliveries(t *testing.T) {
+	repo := NewInMemoryWebhookRepo()
+	ctx := context.Background()
+	now := time.Now()
+
+	require.NoError(t, repo.CreateSubscription(ctx, &domain.WebhookSubscription{ID: "s1", URL: "http://a", Secret: "own-secret", JobID: "job-1", CreatedAt: now}))
+	require.NoError(t, repo.CreateDelivery(ctx, &domain.WebhookDelivery{ID: "d1", SubscriptionID: "s1", JobID: "job-1", Status: domain.WebhookDeliveryPending, NextAttemptAt: now.Add(-time.Second), CreatedAt: now}))
+	require.NoError(t, repo.CreateDelivery(ctx, &domain.WebhookDelivery{ID: "d2", SubscriptionID: "s1", JobID: "job-1", Status: domain.WebhookDeliveryPending, NextAttemptAt: now.Add(time.Hour), CreatedAt: now}))
+	require.NoError(t, repo.CreateDelivery(ctx, &domain.WebhookDelivery{ID: "d3", SubscriptionID: "s1", JobID: "job-1", Status: domain.WebhookDeliverySucceeded, NextAttemptAt: now, CreatedAt: now}))
+
+	claimed, err := repo.ClaimDueDeliveries(ctx, now, time.Minute, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, "d1", claimed[0].ID)
+	assert.Equal(t, "own-secret", claimed[0].Secret)
+
+	claimed, err = repo.ClaimDueDeliveries(ctx, now, time.Minute, 10)
+	require.NoError(t, err)
+	assert.Empty(t, claimed, "claimed deliveries are leased")
+
+	claimed, err = repo.ClaimDueDeliveries(ctx, now.Add(2*time.Minute), time.Minute, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1, "an expired lease is claimed again")
+
+	delivery, err := repo.GetDelivery(ctx, "d1")
+	require.NoError(t, err)
+	delivery.Status = domain.WebhookDeliveryFailed
+	delivery.Attempts = 3
+	require.NoError(t, repo.UpdateDelivery(ctx, delivery))
+
+	deliveries, err := repo.ListDeliveries(ctx, domain.WebhookDeliveryFilter{Status: domain.WebhookDeliveryFailed})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, 3, deliveries[0].Attempts)
+
+	delivery, err = repo.GetDelivery(ctx, "unknown")
+	require.NoError(t, err)
+	assert.Nil(t, delivery)
+}
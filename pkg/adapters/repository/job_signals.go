@@ -0,0 +1,65 @@
+package repository
+
+import "sync"
+
+// jobSignals fans job change signals out to the watchers of each job.
+type jobSignals struct {
+	watchers map[string]map[chan struct{}]struct{}
+	mu       sync.Mutex
+}
+
+func newJobSignals() *jobSignals {
+	return &jobSignals{
+		watchers: make(map[string]map[chan struct{}]struct{}),
+	}
+}
+
+func (s *jobSignals) Watch(jobID string) (<-chan struct{}, func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch := make(chan struct{}, 1)
+	if s.watchers[jobID] == nil {
+		s.watchers[jobID] = make(map[chan struct{}]struct{})
+	}
+	s.watchers[jobID][ch] = struct{}{}
+
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		delete(s.watchers[jobID], ch)
+		if len(s.watchers[jobID]) == 0 {
+			delete(s.watchers, jobID)
+		}
+	}
+}
+
+func (s *jobSignals) notify(jobID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for ch := range s.watchers[jobID] {
+		signal(ch)
+	}
+}
+
+// notifyAll signals every watcher, e.g. after notifications may have been
+// missed.
+func (s *jobSignals) notifyAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, watchers := range s.watchers {
+		for ch := range watchers {
+			signal(ch)
+		}
+	}
+}
+
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -1,27 +1,37 @@
 package repository
 
+import (
+	"context"
+
+	"file-storage-go/pkg/domain"
+)
+
 type MockFileAuthorization struct{}
 
 func NewMockFileAuthorization() *MockFileAuthorization {
 	return &MockFileAuthorization{}
 }
 
-func (m *MockFileAuthorization) CanUploadFile(userID, fileType, linkedResourceType, linkedResourceID string) (bool, error) {
+func (m *MockFileAuthorization) CanUploadFile(ctx context.Context, userID, fileType, linkedResourceType, linkedResourceID string) (bool, error) {
 	return true, nil
 }
 
-func (m *MockFileAuthorization) CanReadFile(userID, fileID string) (bool, error) {
+func (m *MockFileAuthorization) CanReadFile(ctx context.Context, userID, fileID string) (bool, error) {
 	return true, nil
 }
 
-func (m *MockFileAuthorization) CanDeleteFile(userID, fileID string) (bool, error) {
+func (m *MockFileAuthorization) CanDeleteFile(ctx context.Context, userID, fileID string) (bool, error) {
 	return true, nil
 }
 
-func (m *MockFileAuthorization) CreateFileAuthorization(fileID, fileType, linkedResourceID, linkedResourceType string) error {
+func (m *MockFileAuthorization) FilterReadableFiles(ctx context.Context, userID string, files []*domain.FileInfo) ([]*domain.FileInfo, error) {
+	return files, nil
+}
+
+func (m *MockFileAuthorization) CreateFileAuthorization(ctx context.Context, fileID, fileType, linkedResourceID, linkedResourceType string) error {
 	return nil
 }
 
-func (m *MockFileAuthorization) RemoveFileAuthorization(fileID, fileType, linkedResourceID, linkedResourceType string) error {
+func (m *MockFileAuthorization) RemoveFileAuthorization(ctx context.Context, fileID, fileType, linkedResourceID, linkedResourceType string) error {
 	return nil
 }
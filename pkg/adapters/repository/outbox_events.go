@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"file-storage-go/pkg/domain"
+
+	"github.com/google/uuid"
+)
+
+const maxOutboxRetryDelay = 5 * time.Minute
+
+func newFileEvent(eventType, fileID string, payload domain.FileEventPayload, occurredAt time.Time) (*domain.OutboxEvent, error) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+
+	return &domain.OutboxEvent{
+		ID:         uuid.New().String(),
+		Type:       eventType,
+		FileID:     fileID,
+		OccurredAt: occurredAt,
+		Payload:    encoded,
+	}, nil
+}
+
+// outboxRetryDelay doubles the delay before the next attempt to publish an
+// event after every failed attempt, starting at one second.
+func outboxRetryDelay(attempts int) time.Duration {
+	delay := time.Second
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxOutboxRetryDelay {
+			return maxOutboxRetryDelay
+		}
+	}
+	return delay
+}
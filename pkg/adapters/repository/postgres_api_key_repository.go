@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"file-storage-go/pkg/database"
+	"file-storage-go/pkg/domain"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	createAPIKeyQuery = `
+		INSERT INTO api_keys (id, name, owner_id, key_hash, prefix, scopes, expires_at, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	apiKeyColumns = `id, name, owner_id, prefix, scopes, expires_at, last_used_at, revoked_at, created_by, created_at`
+
+	getAPIKeyByHashQuery = `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE key_hash = $1
+	`
+
+	listAPIKeysQuery = `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE $1 = '' OR owner_id = $1
+		ORDER BY created_at, id
+	`
+
+	revokeAPIKeyQuery = `
+		UPDATE api_keys
+		SET revoked_at = COALESCE(revoked_at, $2)
+		WHERE id = $1
+	`
+
+	updateAPIKeyLastUsedQuery = `
+		UPDATE api_keys
+		SET last_used_at = $2
+		WHERE id = $1
+	`
+)
+
+type PostgresAPIKeyRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresAPIKeyRepo(db *database.DB) *PostgresAPIKeyRepo {
+	return &PostgresAPIKeyRepo{
+		pool: db.Primary(),
+	}
+}
+
+func (r *PostgresAPIKeyRepo) Create(ctx context.Context, key *domain.APIKey, keyHash string) error {
+	_, err := r.pool.Exec(ctx, createAPIKeyQuery,
+		key.ID,
+		key.Name,
+		key.OwnerID,
+		keyHash,
+		key.Prefix,
+		key.Scopes,
+		key.ExpiresAt,
+		key.CreatedBy,
+		key.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create api key: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresAPIKeyRepo) GetByHash(ctx context.Context, keyHash string) (*domain.APIKey, error) {
+	key, err := scanAPIKey(r.pool.QueryRow(ctx, getAPIKeyByHashQuery, keyHash))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+	return key, nil
+}
+
+func (r *PostgresAPIKeyRepo) List(ctx context.Context, ownerID string) ([]*domain.APIKey, error) {
+	rows, err := r.pool.Query(ctx, listAPIKeysQuery, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*domain.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating api keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+func (r *PostgresAPIKeyRepo) Revoke(ctx context.Context, keyID string, revokedAt time.Time) error {
+	tag, err := r.pool.Exec(ctx, revokeAPIKeyQuery, keyID, revokedAt)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func (r *PostgresAPIKeyRepo) UpdateLastUsed(ctx context.Context, keyID string, usedAt time.Time) error {
+	if _, err := r.pool.Exec(ctx, updateAPIKeyLastUsedQuery, keyID, usedAt); err != nil {
+		return fmt.Errorf("failed to update api key last use: %w", err)
+	}
+	return nil
+}
+
+func scanAPIKey(row pgx.Row) (*domain.APIKey, error) {
+	key := &domain.APIKey{}
+	err := row.Scan(
+		&key.ID,
+		&key.Name,
+		&key.OwnerID,
+		&key.Prefix,
+		&key.Scopes,
+		&key.ExpiresAt,
+		&key.LastUsedAt,
+		&key.RevokedAt,
+		&key.CreatedBy,
+		&key.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
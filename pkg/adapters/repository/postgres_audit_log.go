@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"file-storage-go/pkg/database"
+	"file-storage-go/pkg/domain"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	recordAuditEventQuery = `
+		INSERT INTO audit_events (id, occurred_at, action, outcome, user_id, client_ip, request_id, file_id, linked_resource_type, linked_resource_id, details)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+
+	listAuditEventsQuery = `
+		SELECT id, occurred_at, action, outcome, user_id, client_ip, request_id, file_id, linked_resource_type, linked_resource_id, details
+		FROM audit_events
+	`
+)
+
+// PostgresAuditLog stores audit events in the append-only audit_events table.
+type PostgresAuditLog struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresAuditLog(db *database.DB) *PostgresAuditLog {
+	return &PostgresAuditLog{
+		pool: db.Primary(),
+	}
+}
+
+func (l *PostgresAuditLog) Record(ctx context.Context, event *domain.AuditEvent) error {
+	details := event.Details
+	if details == nil {
+		details = map[string]string{}
+	}
+
+	_, err := l.pool.Exec(ctx, recordAuditEventQuery,
+		event.ID,
+		event.OccurredAt,
+		event.Action,
+		event.Outcome,
+		event.UserID,
+		event.ClientIP,
+		event.RequestID,
+		event.FileID,
+		event.LinkedResourceType,
+		event.LinkedResourceID,
+		details,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record audit event: %w", err)
+	}
+	return nil
+}
+
+func (l *PostgresAuditLog) Query(ctx context.Context, filter domain.AuditEventFilter) ([]*domain.AuditEvent, error) {
+	var events []*domain.AuditEvent
+	err := l.query(ctx, filter, true, func(event *domain.AuditEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	return events, err
+}
+
+func (l *PostgresAuditLog) Export(ctx context.Context, filter domain.AuditEventFilter, fn func(*domain.AuditEvent) error) error {
+	return l.query(ctx, filter, false, fn)
+}
+
+func (l *PostgresAuditLog) query(ctx context.Context, filter domain.AuditEventFilter, paginate bool, fn func(*domain.AuditEvent) error) error {
+	var conditions []string
+	var args []any
+	addCondition := func(condition string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, condition+" $"+strconv.Itoa(len(args)))
+	}
+	if filter.Action != "" {
+		addCondition("action =", filter.Action)
+	}
+	if filter.Outcome != "" {
+		addCondition("outcome =", filter.Outcome)
+	}
+	if filter.UserID != "" {
+		addCondition("user_id =", filter.UserID)
+	}
+	if filter.FileID != "" {
+		addCondition("file_id =", filter.FileID)
+	}
+	if filter.LinkedResourceType != "" {
+		addCondition("linked_resource_type =", filter.LinkedResourceType)
+	}
+	if filter.LinkedResourceID != "" {
+		addCondition("linked_resource_id =", filter.LinkedResourceID)
+	}
+	if !filter.From.IsZero() {
+		addCondition("occurred_at >=", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addCondition("occurred_at <", filter.To)
+	}
+
+	query := listAuditEventsQuery
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY occurred_at, seq"
+	if paginate && filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += " LIMIT $" + strconv.Itoa(len(args))
+	}
+	if paginate && filter.Offset > 0 {
+		args = append(args, filter.Offset)
+		query += " OFFSET $" + strconv.Itoa(len(args))
+	}
+
+	rows, err := l.pool.Query(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query audit events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating audit events: %w", err)
+	}
+	return nil
+}
+
+func scanAuditEvent(row pgx.Row) (*domain.AuditEvent, error) {
+	event := &domain.AuditEvent{}
+	if err := row.Scan(
+		&event.ID,
+		&event.OccurredAt,
+		&event.Action,
+		&event.Outcome,
+		&event.UserID,
+		&event.ClientIP,
+		&event.RequestID,
+		&event.FileID,
+		&event.LinkedResourceType,
+		&event.LinkedResourceID,
+		&event.Details,
+	); err != nil {
+		return nil, fmt.Errorf("failed to scan audit event: %w", err)
+	}
+	if len(event.Details) == 0 {
+		event.Details = nil
+	}
+	return event, nil
+}
@@ -0,0 +1,317 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"file-storage-go/pkg/database"
+	"file-storage-go/pkg/domain"
+)
+
+const (
+	principalMatchClause = `
+		((g.principal_type = 'user' AND g.principal_id = $1)
+			OR (g.principal_type = 'group' AND g.principal_id IN (SELECT group_id FROM acl_group_members WHERE user_id = $1)))
+	`
+
+	canUploadFileQuery = `
+		SELECT EXISTS (
+			SELECT 1
+			FROM file_grants g
+			WHERE g.resource_type = $2 AND g.resource_id = $3 AND g.permission = 'upload'
+				AND g.file_type IN ('', $4)
+				AND ` + principalMatchClause + `
+		)
+	`
+
+	hasFilePermissionQuery = `
+		SELECT EXISTS (
+			SELECT 1
+			FROM file_acl_resources r
+			JOIN file_grants g ON g.permission = $3
+				AND ((g.resource_type = 'file' AND g.resource_id = r.file_id)
+					OR (g.resource_type = r.linked_resource_type AND g.resource_id = r.linked_resource_id))
+			WHERE r.file_id = $2
+				AND ` + principalMatchClause + `
+		)
+	`
+
+	readableFilesQuery = `
+		SELECT DISTINCT r.file_id
+		FROM file_acl_resources r
+		JOIN file_grants g ON g.permission = 'read'
+			AND ((g.resource_type = 'file' AND g.resource_id = r.file_id)
+				OR (g.resource_type = r.linked_resource_type AND g.resource_id = r.linked_resource_id))
+		WHERE r.file_id = ANY($2)
+			AND ` + principalMatchClause + `
+	`
+
+	createFileACLResourceQuery = `
+		INSERT INTO file_acl_resources (file_id, file_type, linked_resource_type, linked_resource_id)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (file_id) DO UPDATE
+		SET file_type = EXCLUDED.file_type, linked_resource_type = EXCLUDED.linked_resource_type, linked_resource_id = EXCLUDED.linked_resource_id
+	`
+
+	deleteFileACLResourceQuery = `
+		DELETE FROM file_acl_resources
+		WHERE file_id = $1
+	`
+
+	deleteFileGrantsQuery = `
+		DELETE FROM file_grants
+		WHERE resource_type = 'file' AND resource_id = $1
+	`
+
+	createGrantQuery = `
+		INSERT INTO file_grants (id, principal_type, principal_id, resource_type, resource_id, file_type, permission, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (resource_type, resource_id, permission, principal_type, principal_id, file_type) DO UPDATE
+		SET created_by = file_grants.created_by
+		RETURNING id, created_by, created_at
+	`
+
+	deleteGrantQuery = `
+		DELETE FROM file_grants
+		WHERE id = $1
+	`
+
+	listGrantsQuery = `
+		SELECT id, principal_type, principal_id, resource_type, resource_id, file_type, permission, created_by, created_at
+		FROM file_grants
+	`
+
+	addGroupMemberQuery = `
+		INSERT INTO acl_group_members (group_id, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`
+
+	removeGroupMemberQuery = `
+		DELETE FROM acl_group_members
+		WHERE group_id = $1 AND user_id = $2
+	`
+
+	listGroupMembersQuery = `
+		SELECT user_id
+		FROM acl_group_members
+		WHERE group_id = $1
+		ORDER BY user_id
+	`
+)
+
+// PostgresFileAuthorization evaluates explicit grants stored in Postgres. A
+// file becomes readable or deletable through grants on the file itself or on
+// its linked resource once CreateFileAuthorization registered it.
+type PostgresFileAuthorization struct {
+	db dbtx
+}
+
+func NewPostgresFileAuthorization(db *database.DB) *PostgresFileAuthorization {
+	return &PostgresFileAuthorization{
+		db: db.Primary(),
+	}
+}
+
+func (r *PostgresFileAuthorization) CanUploadFile(ctx context.Context, userID, fileType, linkedResourceType, linkedResourceID string) (bool, error) {
+	var allowed bool
+	err := r.db.QueryRow(ctx, canUploadFileQuery, userID, linkedResourceType, linkedResourceID, fileType).Scan(&allowed)
+	if err != nil {
+		return false, fmt.Errorf("failed to check upload permission: %w", err)
+	}
+	return allowed, nil
+}
+
+func (r *PostgresFileAuthorization) CanReadFile(ctx context.Context, userID, fileID string) (bool, error) {
+	return r.hasFilePermission(ctx, userID, fileID, domain.PermissionRead)
+}
+
+func (r *PostgresFileAuthorization) CanDeleteFile(ctx context.Context, userID, fileID string) (bool, error) {
+	return r.hasFilePermission(ctx, userID, fileID, domain.PermissionDelete)
+}
+
+// FilterReadableFiles checks all files in one query.
+func (r *PostgresFileAuthorization) FilterReadableFiles(ctx context.Context, userID string, files []*domain.FileInfo) ([]*domain.FileInfo, error) {
+	fileIDs := make([]string, len(files))
+	for i, file := range files {
+		fileIDs[i] = file.ID
+	}
+
+	rows, err := r.db.Query(ctx, readableFilesQuery, userID, fileIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check read permissions: %w", err)
+	}
+	defer rows.Close()
+
+	readable := make(map[string]bool, len(files))
+	for rows.Next() {
+		var fileID string
+		if err := rows.Scan(&fileID); err != nil {
+			return nil, fmt.Errorf("failed to scan readable file: %w", err)
+		}
+		readable[fileID] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating readable files: %w", err)
+	}
+
+	return filterFiles(files, func(file *domain.FileInfo) bool { return readable[file.ID] }), nil
+}
+
+func (r *PostgresFileAuthorization) hasFilePermission(ctx context.Context, userID, fileID string, permission domain.Permission) (bool, error) {
+	var allowed bool
+	err := r.db.QueryRow(ctx, hasFilePermissionQuery, userID, fileID, permission).Scan(&allowed)
+	if err != nil {
+		return false, fmt.Errorf("failed to check %s permission: %w", permission, err)
+	}
+	return allowed, nil
+}
+
+func (r *PostgresFileAuthorization) CreateFileAuthorization(ctx context.Context, fileID, fileType, linkedResourceID, linkedResourceType string) error {
+	_, err := r.db.Exec(ctx, createFileACLResourceQuery, fileID, fileType, linkedResourceType, linkedResourceID)
+	if err != nil {
+		return fmt.Errorf("failed to create file authorization: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresFileAuthorization) RemoveFileAuthorization(ctx context.Context, fileID, fileType, linkedResourceID, linkedResourceType string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, deleteFileGrantsQuery, fileID); err != nil {
+		return fmt.Errorf("failed to delete file grants: %w", err)
+	}
+	if _, err := tx.Exec(ctx, deleteFileACLResourceQuery, fileID); err != nil {
+		return fmt.Errorf("failed to remove file authorization: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresFileAuthorization) CreateGrant(ctx context.Context, grant *domain.FileGrant) error {
+	err := r.db.QueryRow(ctx, createGrantQuery,
+		grant.ID,
+		grant.PrincipalType,
+		grant.PrincipalID,
+		grant.ResourceType,
+		grant.ResourceID,
+		grant.FileType,
+		grant.Permission,
+		grant.CreatedBy,
+		grant.CreatedAt,
+	).Scan(&grant.ID, &grant.CreatedBy, &grant.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create grant: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresFileAuthorization) DeleteGrant(ctx context.Context, grantID string) error {
+	tag, err := r.db.Exec(ctx, deleteGrantQuery, grantID)
+	if err != nil {
+		return fmt.Errorf("failed to delete grant: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrGrantNotFound
+	}
+	return nil
+}
+
+func (r *PostgresFileAuthorization) ListGrants(ctx context.Context, filter domain.FileGrantFilter) ([]*domain.FileGrant, error) {
+	var conditions []string
+	var args []any
+	addCondition := func(column, value string) {
+		if value == "" {
+			return
+		}
+		args = append(args, value)
+		conditions = append(conditions, column+" = $"+strconv.Itoa(len(args)))
+	}
+	addCondition("principal_type", string(filter.PrincipalType))
+	addCondition("principal_id", filter.PrincipalID)
+	addCondition("resource_type", filter.ResourceType)
+	addCondition("resource_id", filter.ResourceID)
+
+	query := listGrantsQuery
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at, id"
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list grants: %w", err)
+	}
+	defer rows.Close()
+
+	var grants []*domain.FileGrant
+	for rows.Next() {
+		grant := &domain.FileGrant{}
+		if err := rows.Scan(
+			&grant.ID,
+			&grant.PrincipalType,
+			&grant.PrincipalID,
+			&grant.ResourceType,
+			&grant.ResourceID,
+			&grant.FileType,
+			&grant.Permission,
+			&grant.CreatedBy,
+			&grant.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan grant: %w", err)
+		}
+		grants = append(grants, grant)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating grants: %w", err)
+	}
+
+	return grants, nil
+}
+
+func (r *PostgresFileAuthorization) AddGroupMember(ctx context.Context, groupID, userID string) error {
+	if _, err := r.db.Exec(ctx, addGroupMemberQuery, groupID, userID); err != nil {
+		return fmt.Errorf("failed to add group member: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresFileAuthorization) RemoveGroupMember(ctx context.Context, groupID, userID string) error {
+	if _, err := r.db.Exec(ctx, removeGroupMemberQuery, groupID, userID); err != nil {
+		return fmt.Errorf("failed to remove group member: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresFileAuthorization) ListGroupMembers(ctx context.Context, groupID string) ([]string, error) {
+	rows, err := r.db.Query(ctx, listGroupMembersQuery, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list group members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("failed to scan group member: %w", err)
+		}
+		members = append(members, userID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating group members: %w", err)
+	}
+
+	return members, nil
+}
@@ -3,67 +3,65 @@ package repository
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"file-storage-go/pkg/database"
 	"file-storage-go/pkg/domain"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 const (
 	createFileInfoQuery = `
-		INSERT INTO file_info (id, filename, file_type, linked_resource_type, linked_resource_id, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO file_info (id, filename, file_type, linked_resource_type, linked_resource_id, detected_mime_type, content_type_mismatch, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 	`
 
 	getFileInfoQuery = `
-		SELECT id, filename, file_type, linked_resource_type, linked_resource_id, created_at, updated_at
+		SELECT id, filename, file_type, linked_resource_type, linked_resource_id, detected_mime_type, content_type_mismatch, created_at, updated_at
 		FROM file_info
 		WHERE id = $1
 	`
 
+	getFileInfosQuery = `
+		SELECT id, filename, file_type, linked_resource_type, linked_resource_id, detected_mime_type, content_type_mismatch, created_at, updated_at
+		FROM file_info
+		WHERE id = ANY($1)
+	`
+
 	updateFileInfoQuery = `
 		UPDATE file_info
-		SET filename = $1, file_type = $2, linked_resource_type = $3, linked_resource_id = $4, updated_at = $5
-		WHERE id = $6
+		SET filename = $1, file_type = $2, linked_resource_type = $3, linked_resource_id = $4, detected_mime_type = $5, content_type_mismatch = $6, updated_at = $7
+		WHERE id = $8
 	`
 
 	deleteFileInfoQuery = `
 		DELETE FROM file_info
 		WHERE id = $1
+		RETURNING id, filename, file_type, linked_resource_type, linked_resource_id, detected_mime_type, content_type_mismatch, created_at, updated_at
 	`
 )
 
 type PostgresFileInfoRepo struct {
-	pool *pgxpool.Pool
+	db       dbtx
+	replicas *database.DB
 }
 
-func NewPostgresFileInfoRepo(connStr string) (*PostgresFileInfoRepo, error) {
-	config, err := pgxpool.ParseConfig(connStr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse connection string: %w", err)
-	}
-
-	pool, err := pgxpool.NewWithConfig(context.Background(), config)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create connection pool: %w", err)
-	}
-
-	if err := pool.Ping(context.Background()); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
-	}
-
+func NewPostgresFileInfoRepo(db *database.DB) *PostgresFileInfoRepo {
 	return &PostgresFileInfoRepo{
-		pool: pool,
-	}, nil
+		db:       db.Primary(),
+		replicas: db,
+	}
 }
 
 func (r *PostgresFileInfoRepo) Create(ctx context.Context, fileInfo *domain.FileInfo) error {
-	_, err := r.pool.Exec(ctx, createFileInfoQuery,
+	_, err := r.db.Exec(ctx, createFileInfoQuery,
 		fileInfo.ID,
 		fileInfo.Filename,
 		fileInfo.FileType,
 		fileInfo.LinkedResourceType,
 		fileInfo.LinkedResourceID,
+		fileInfo.DetectedMimeType,
+		fileInfo.ContentTypeMismatch,
 		fileInfo.CreatedAt,
 		fileInfo.UpdatedAt,
 	)
@@ -74,13 +72,48 @@ func (r *PostgresFileInfoRepo) Create(ctx context.Context, fileInfo *domain.File
 }
 
 func (r *PostgresFileInfoRepo) Get(ctx context.Context, fileID string) (*domain.FileInfo, error) {
+	return scanFileInfo(r.reader(ctx).QueryRow(ctx, getFileInfoQuery, fileID))
+}
+
+func (r *PostgresFileInfoRepo) GetMany(ctx context.Context, fileIDs []string) ([]*domain.FileInfo, error) {
+	rows, err := r.reader(ctx).Query(ctx, getFileInfosQuery, fileIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file infos: %w", err)
+	}
+	defer rows.Close()
+
+	byID := make(map[string]*domain.FileInfo, len(fileIDs))
+	for rows.Next() {
+		fileInfo, err := scanFileInfo(rows)
+		if err != nil {
+			return nil, err
+		}
+		byID[fileInfo.ID] = fileInfo
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating file infos: %w", err)
+	}
+
+	fileInfos := make([]*domain.FileInfo, 0, len(byID))
+	for _, fileID := range fileIDs {
+		if fileInfo, ok := byID[fileID]; ok {
+			fileInfos = append(fileInfos, fileInfo)
+		}
+	}
+	return fileInfos, nil
+}
+
+// scanFileInfo returns nil if the row does not exist.
+func scanFileInfo(row pgx.Row) (*domain.FileInfo, error) {
 	fileInfo := &domain.FileInfo{}
-	err := r.pool.QueryRow(ctx, getFileInfoQuery, fileID).Scan(
+	err := row.Scan(
 		&fileInfo.ID,
 		&fileInfo.Filename,
 		&fileInfo.FileType,
 		&fileInfo.LinkedResourceType,
 		&fileInfo.LinkedResourceID,
+		&fileInfo.DetectedMimeType,
+		&fileInfo.ContentTypeMismatch,
 		&fileInfo.CreatedAt,
 		&fileInfo.UpdatedAt,
 	)
@@ -94,11 +127,13 @@ func (r *PostgresFileInfoRepo) Get(ctx context.Context, fileID string) (*domain.
 }
 
 func (r *PostgresFileInfoRepo) Update(ctx context.Context, fileInfo *domain.FileInfo) error {
-	result, err := r.pool.Exec(ctx, updateFileInfoQuery,
+	result, err := r.db.Exec(ctx, updateFileInfoQuery,
 		fileInfo.Filename,
 		fileInfo.FileType,
 		fileInfo.LinkedResourceType,
 		fileInfo.LinkedResourceID,
+		fileInfo.DetectedMimeType,
+		fileInfo.ContentTypeMismatch,
 		fileInfo.UpdatedAt,
 		fileInfo.ID,
 	)
@@ -113,20 +148,41 @@ func (r *PostgresFileInfoRepo) Update(ctx context.Context, fileInfo *domain.File
 	return nil
 }
 
+// Delete removes the file info and, in the same transaction, writes a
+// file.deleted event to the outbox.
 func (r *PostgresFileInfoRepo) Delete(ctx context.Context, fileID string) error {
-	result, err := r.pool.Exec(ctx, deleteFileInfoQuery, fileID)
+	tx, err := r.db.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to delete file info: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback(ctx)
 
-	if result.RowsAffected() == 0 {
+	fileInfo, err := scanFileInfo(tx.QueryRow(ctx, deleteFileInfoQuery, fileID))
+	if err != nil {
+		return fmt.Errorf("failed to delete file info: %w", err)
+	}
+	if fileInfo == nil {
 		return fmt.Errorf("file info not found")
 	}
 
+	event, err := newFileEvent(domain.EventFileDeleted, fileID, domain.FileEventPayload{File: fileInfo}, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := insertOutboxEvent(ctx, tx, event); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
 	return nil
 }
 
-func (r *PostgresFileInfoRepo) Close() error {
-	r.pool.Close()
-	return nil
+// reader returns where to run reads that tolerate replication lag.
+func (r *PostgresFileInfoRepo) reader(ctx context.Context) dbtx {
+	if r.replicas == nil {
+		return r.db
+	}
+	return r.replicas.Reader(ctx)
 }
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"file-storage-go/pkg/database"
+	"file-storage-go/pkg/domain"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	deleteExpiredIdempotencyKeyQuery = `
+		DELETE FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2 AND expires_at <= $3
+	`
+
+	beginIdempotencyKeyQuery = `
+		INSERT INTO idempotency_keys (user_id, key, fingerprint, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, key) DO NOTHING
+	`
+
+	getIdempotencyKeyQuery = `
+		SELECT user_id, key, fingerprint, completed, status_code, content_type, location, body, created_at, expires_at
+		FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2
+	`
+
+	completeIdempotencyKeyQuery = `
+		UPDATE idempotency_keys
+		SET completed = TRUE, status_code = $3, content_type = $4, location = $5, body = $6
+		WHERE user_id = $1 AND key = $2
+	`
+
+	releaseIdempotencyKeyQuery = `
+		DELETE FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2 AND NOT completed
+	`
+
+	deleteExpiredIdempotencyKeysQuery = `
+		DELETE FROM idempotency_keys
+		WHERE expires_at <= $1
+	`
+)
+
+// beginAttempts bounds how often Begin retries when the record it conflicted
+// with is released before it could be read.
+const beginAttempts = 3
+
+type PostgresIdempotencyStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresIdempotencyStore(db *database.DB) *PostgresIdempotencyStore {
+	return &PostgresIdempotencyStore{
+		pool: db.Primary(),
+	}
+}
+
+func (s *PostgresIdempotencyStore) Begin(ctx context.Context, record *domain.IdempotencyRecord) (*domain.IdempotencyRecord, error) {
+	for attempt := 0; attempt < beginAttempts; attempt++ {
+		if _, err := s.pool.Exec(ctx, deleteExpiredIdempotencyKeyQuery, record.UserID, record.Key, time.Now()); err != nil {
+			return nil, fmt.Errorf("failed to delete expired idempotency key: %w", err)
+		}
+
+		tag, err := s.pool.Exec(ctx, beginIdempotencyKeyQuery,
+			record.UserID, record.Key, record.Fingerprint, record.CreatedAt, record.ExpiresAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create idempotency key: %w", err)
+		}
+		if tag.RowsAffected() == 1 {
+			return nil, nil
+		}
+
+		var existing domain.IdempotencyRecord
+		err = s.pool.QueryRow(ctx, getIdempotencyKeyQuery, record.UserID, record.Key).Scan(
+			&existing.UserID,
+			&existing.Key,
+			&existing.Fingerprint,
+			&existing.Completed,
+			&existing.StatusCode,
+			&existing.ContentType,
+			&existing.Location,
+			&existing.Body,
+			&existing.CreatedAt,
+			&existing.ExpiresAt,
+		)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+		}
+		return &existing, nil
+	}
+	return nil, fmt.Errorf("failed to create idempotency key: concurrent updates")
+}
+
+func (s *PostgresIdempotencyStore) Complete(ctx context.Context, record *domain.IdempotencyRecord) error {
+	_, err := s.pool.Exec(ctx, completeIdempotencyKeyQuery,
+		record.UserID, record.Key, record.StatusCode, record.ContentType, record.Location, record.Body)
+	if err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresIdempotencyStore) Release(ctx context.Context, userID, key string) error {
+	if _, err := s.pool.Exec(ctx, releaseIdempotencyKeyQuery, userID, key); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresIdempotencyStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	tag, err := s.pool.Exec(ctx, deleteExpiredIdempotencyKeysQuery, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
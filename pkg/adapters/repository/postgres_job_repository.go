@@ -4,71 +4,89 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
+	"file-storage-go/pkg/database"
 	"file-storage-go/pkg/domain"
 
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 const (
 	createJobQuery = `
-		INSERT INTO upload_jobs (id, created_by_user_id, status, created_at, updated_at, file_id, error)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO upload_jobs (id, created_by_user_id, status, created_at, updated_at, file_id, error, trace_parent, request_id, rescan)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 	`
 
 	getJobQuery = `
-		SELECT id, created_by_user_id, status, created_at, updated_at, file_id, error
+		SELECT id, created_by_user_id, status, created_at, updated_at, file_id, error, trace_parent, request_id, rescan
 		FROM upload_jobs
 		WHERE id = $1
 	`
 
 	updateJobQuery = `
 		UPDATE upload_jobs
-		SET created_by_user_id = $1, status = $2, updated_at = $3, file_id = $4, error = $5
-		WHERE id = $6
+		SET created_by_user_id = $1, status = $2, updated_at = $3, file_id = $4, error = $5, trace_parent = $6, request_id = $7, rescan = $8
+		WHERE id = $9
 	`
 
+	lockJobStatusQuery = `
+		SELECT status
+		FROM upload_jobs
+		WHERE id = $1
+		FOR UPDATE
+	`
+
+	// The notification is sent when the transaction commits.
+	notifyJobChangedQuery = `SELECT pg_notify('` + jobChangedChannel + `', $1)`
+
 	getJobByFileIDQuery = `
-		SELECT id, created_by_user_id, status, created_at, updated_at, file_id, error
+		SELECT id, created_by_user_id, status, created_at, updated_at, file_id, error, trace_parent, request_id, rescan
 		FROM upload_jobs
 		WHERE file_id = $1
 	`
 
 	getJobsByStatusQuery = `
-		SELECT id, created_by_user_id, status, created_at, updated_at, file_id, error
+		SELECT id, created_by_user_id, status, created_at, updated_at, file_id, error, trace_parent, request_id, rescan
 		FROM upload_jobs
 		WHERE status = $1
 	`
-)
 
-type PostgresJobRepo struct {
-	pool *pgxpool.Pool
-}
+	listJobsQuery = `
+		SELECT id, created_by_user_id, status, created_at, updated_at, file_id, error, trace_parent, request_id, rescan
+		FROM upload_jobs
+	`
 
-func NewPostgresJobRepo(connStr string) (*PostgresJobRepo, error) {
-	config, err := pgxpool.ParseConfig(connStr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse connection string: %w", err)
-	}
+	countJobsByStatusQuery = `
+		SELECT status, COUNT(*)
+		FROM upload_jobs
+		GROUP BY status
+	`
 
-	pool, err := pgxpool.NewWithConfig(context.Background(), config)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create connection pool: %w", err)
-	}
+	purgeJobsQuery = `
+		DELETE FROM upload_jobs
+		WHERE status = ANY($1) AND updated_at < $2
+		RETURNING id, created_by_user_id, status, created_at, updated_at, file_id, error, trace_parent, request_id, rescan
+	`
+)
 
-	if err := pool.Ping(context.Background()); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
-	}
+type PostgresJobRepo struct {
+	db       dbtx
+	replicas *database.DB
+}
 
+func NewPostgresJobRepo(db *database.DB) *PostgresJobRepo {
 	return &PostgresJobRepo{
-		pool: pool,
-	}, nil
+		db:       db.Primary(),
+		replicas: db,
+	}
 }
 
 func (r *PostgresJobRepo) Create(ctx context.Context, job *domain.UploadJob) error {
 	fileID := r.stringToNull(job.FileID)
-	_, err := r.pool.Exec(ctx, createJobQuery,
+	_, err := r.db.Exec(ctx, createJobQuery,
 		job.ID,
 		job.CreatedByUserId,
 		job.Status,
@@ -76,6 +94,9 @@ func (r *PostgresJobRepo) Create(ctx context.Context, job *domain.UploadJob) err
 		job.UpdatedAt,
 		fileID,
 		job.Error,
+		job.TraceParent,
+		job.RequestID,
+		job.Rescan,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create upload job: %w", err)
@@ -86,7 +107,7 @@ func (r *PostgresJobRepo) Create(ctx context.Context, job *domain.UploadJob) err
 func (r *PostgresJobRepo) Get(ctx context.Context, jobID string) (*domain.UploadJob, error) {
 	job := &domain.UploadJob{}
 	var fileID sql.NullString
-	err := r.pool.QueryRow(ctx, getJobQuery, jobID).Scan(
+	err := r.reader(ctx).QueryRow(ctx, getJobQuery, jobID).Scan(
 		&job.ID,
 		&job.CreatedByUserId,
 		&job.Status,
@@ -94,6 +115,9 @@ func (r *PostgresJobRepo) Get(ctx context.Context, jobID string) (*domain.Upload
 		&job.UpdatedAt,
 		&fileID,
 		&job.Error,
+		&job.TraceParent,
+		&job.RequestID,
+		&job.Rescan,
 	)
 	if err == pgx.ErrNoRows {
 		return nil, nil
@@ -105,31 +129,93 @@ func (r *PostgresJobRepo) Get(ctx context.Context, jobID string) (*domain.Upload
 	return job, nil
 }
 
+// Update stores the job and, in the same transaction, writes the file
+// lifecycle event of its status change to the outbox.
 func (r *PostgresJobRepo) Update(ctx context.Context, job *domain.UploadJob) error {
+	_, err := r.update(ctx, job, "")
+	return err
+}
+
+func (r *PostgresJobRepo) UpdateIfStatus(ctx context.Context, job *domain.UploadJob, expected domain.JobStatus) (bool, error) {
+	return r.update(ctx, job, expected)
+}
+
+// update stores the job while its row is locked. If expected is set, it only
+// stores the job if the locked row still has that status.
+func (r *PostgresJobRepo) update(ctx context.Context, job *domain.UploadJob, expected domain.JobStatus) (bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var previous domain.JobStatus
+	err = tx.QueryRow(ctx, lockJobStatusQuery, job.ID).Scan(&previous)
+	if err == pgx.ErrNoRows && expected != "" {
+		return false, nil
+	}
+	if err == pgx.ErrNoRows {
+		return false, fmt.Errorf("upload job not found")
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to lock upload job: %w", err)
+	}
+	if expected != "" && previous != expected {
+		return false, nil
+	}
+
 	fileID := r.stringToNull(job.FileID)
-	result, err := r.pool.Exec(ctx, updateJobQuery,
+	if _, err := tx.Exec(ctx, updateJobQuery,
 		job.CreatedByUserId,
 		job.Status,
 		job.UpdatedAt,
 		fileID,
 		job.Error,
+		job.TraceParent,
+		job.RequestID,
+		job.Rescan,
 		job.ID,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to update upload job: %w", err)
+	); err != nil {
+		return false, fmt.Errorf("failed to update upload job: %w", err)
 	}
 
-	if result.RowsAffected() == 0 {
-		return fmt.Errorf("upload job not found")
+	if _, err := tx.Exec(ctx, notifyJobChangedQuery, job.ID); err != nil {
+		return false, fmt.Errorf("failed to notify job change: %w", err)
 	}
 
-	return nil
+	if eventType := domain.JobTransitionEvent(previous, job); eventType != "" && job.FileID != "" {
+		if err := r.writeJobEvent(ctx, tx, eventType, job); err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+func (r *PostgresJobRepo) writeJobEvent(ctx context.Context, tx pgx.Tx, eventType string, job *domain.UploadJob) error {
+	fileInfo, err := scanFileInfo(tx.QueryRow(ctx, getFileInfoQuery, job.FileID))
+	if err != nil {
+		return err
+	}
+
+	payload := domain.FileEventPayload{JobID: job.ID, File: fileInfo}
+	if eventType == domain.EventScanFailed {
+		payload.Error = job.Error
+	}
+	event, err := newFileEvent(eventType, job.FileID, payload, job.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	return insertOutboxEvent(ctx, tx, event)
 }
 
 func (r *PostgresJobRepo) GetByFileID(ctx context.Context, fileID string) (*domain.UploadJob, error) {
 	job := &domain.UploadJob{}
 	var dbFileID sql.NullString
-	err := r.pool.QueryRow(ctx, getJobByFileIDQuery, fileID).Scan(
+	err := r.reader(ctx).QueryRow(ctx, getJobByFileIDQuery, fileID).Scan(
 		&job.ID,
 		&job.CreatedByUserId,
 		&job.Status,
@@ -137,6 +223,9 @@ func (r *PostgresJobRepo) GetByFileID(ctx context.Context, fileID string) (*doma
 		&job.UpdatedAt,
 		&dbFileID,
 		&job.Error,
+		&job.TraceParent,
+		&job.RequestID,
+		&job.Rescan,
 	)
 	if err == pgx.ErrNoRows {
 		return nil, nil
@@ -149,10 +238,80 @@ func (r *PostgresJobRepo) GetByFileID(ctx context.Context, fileID string) (*doma
 }
 
 func (r *PostgresJobRepo) GetByStatus(ctx context.Context, status domain.JobStatus) ([]*domain.UploadJob, error) {
-	rows, err := r.pool.Query(ctx, getJobsByStatusQuery, status)
+	rows, err := r.db.Query(ctx, getJobsByStatusQuery, status)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get jobs by status: %w", err)
 	}
+	return r.scanJobs(rows)
+}
+
+func (r *PostgresJobRepo) List(ctx context.Context, filter domain.UploadJobFilter) ([]*domain.UploadJob, error) {
+	var conditions []string
+	var args []any
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, "status = $"+strconv.Itoa(len(args)))
+	}
+	if !filter.UpdatedBefore.IsZero() {
+		args = append(args, filter.UpdatedBefore)
+		conditions = append(conditions, "updated_at < $"+strconv.Itoa(len(args)))
+	}
+
+	query := listJobsQuery
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY updated_at, id"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += " LIMIT $" + strconv.Itoa(len(args))
+	}
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	return r.scanJobs(rows)
+}
+
+func (r *PostgresJobRepo) Purge(ctx context.Context, statuses []domain.JobStatus, updatedBefore time.Time) ([]*domain.UploadJob, error) {
+	statusValues := make([]string, len(statuses))
+	for i, status := range statuses {
+		statusValues[i] = string(status)
+	}
+
+	rows, err := r.db.Query(ctx, purgeJobsQuery, statusValues, updatedBefore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to purge jobs: %w", err)
+	}
+	return r.scanJobs(rows)
+}
+
+func (r *PostgresJobRepo) CountByStatus(ctx context.Context) (map[domain.JobStatus]int64, error) {
+	rows, err := r.db.Query(ctx, countJobsByStatusQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count jobs by status: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[domain.JobStatus]int64)
+	for rows.Next() {
+		var status domain.JobStatus
+		var count int64
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan job count: %w", err)
+		}
+		counts[status] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating job counts: %w", err)
+	}
+
+	return counts, nil
+}
+
+func (r *PostgresJobRepo) scanJobs(rows pgx.Rows) ([]*domain.UploadJob, error) {
 	defer rows.Close()
 
 	var jobs []*domain.UploadJob
@@ -167,6 +326,9 @@ func (r *PostgresJobRepo) GetByStatus(ctx context.Context, status domain.JobStat
 			&job.UpdatedAt,
 			&fileID,
 			&job.Error,
+			&job.TraceParent,
+			&job.RequestID,
+			&job.Rescan,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan job: %w", err)
@@ -196,7 +358,10 @@ func (r *PostgresJobRepo) nullToString(ns sql.NullString) string {
 	return ns.String
 }
 
-func (r *PostgresJobRepo) Close() error {
-	r.pool.Close()
-	return nil
+// reader returns where to run reads that tolerate replication lag.
+func (r *PostgresJobRepo) reader(ctx context.Context) dbtx {
+	if r.replicas == nil {
+		return r.db
+	}
+	return r.replicas.Reader(ctx)
 }
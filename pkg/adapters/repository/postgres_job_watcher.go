@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"file-storage-go/pkg/ecsslog"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	jobChangedChannel = "upload_job_changed"
+
+	jobWatcherReconnectDelay = time.Second
+)
+
+// PostgresJobWatcher listens for the notifications PostgresJobRepo sends when
+// a job is updated, on any replica, and signals the local watchers.
+type PostgresJobWatcher struct {
+	connStr string
+	signals *jobSignals
+	logger  *slog.Logger
+}
+
+func NewPostgresJobWatcher(connStr string, logger *slog.Logger) *PostgresJobWatcher {
+	return &PostgresJobWatcher{
+		connStr: connStr,
+		signals: newJobSignals(),
+		logger:  logger,
+	}
+}
+
+func (w *PostgresJobWatcher) Watch(jobID string) (<-chan struct{}, func()) {
+	return w.signals.Watch(jobID)
+}
+
+// Start listens until ctx is done, reconnecting after errors. Every watcher
+// is signalled after a reconnect because notifications may have been missed.
+func (w *PostgresJobWatcher) Start(ctx context.Context) {
+	for {
+		err := w.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		w.logger.LogAttrs(ctx, slog.LevelWarn, "Job watcher lost its database connection",
+			ecsslog.Action("job.watch"), ecsslog.Err(err))
+		w.signals.notifyAll()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(jobWatcherReconnectDelay):
+		}
+	}
+}
+
+func (w *PostgresJobWatcher) listen(ctx context.Context) error {
+	conn, err := pgx.Connect(ctx, w.connStr)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+jobChangedChannel); err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		w.signals.notify(notification.Payload)
+	}
+}
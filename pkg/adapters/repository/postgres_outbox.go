@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"file-storage-go/pkg/database"
+	"file-storage-go/pkg/domain"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// outboxRelayLockKey is the advisory lock that lets a single replica relay
+// events at a time.
+const outboxRelayLockKey = 0x6f7574626f78
+
+// outboxRelayLease is how long a relay has to publish the events it claimed.
+// Events it has not marked by then are claimed again by the next relay.
+const outboxRelayLease = 5 * time.Minute
+
+const (
+	insertOutboxEventQuery = `
+		INSERT INTO outbox_events (id, type, file_id, occurred_at, payload, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5, $4)
+		RETURNING seq
+	`
+
+	tryOutboxRelayLockQuery = `SELECT pg_try_advisory_xact_lock($1)`
+
+	claimDueOutboxEventsQuery = `
+		UPDATE outbox_events e
+		SET next_attempt_at = $3
+		FROM (
+			SELECT seq
+			FROM (
+				SELECT DISTINCT ON (file_id) seq, next_attempt_at
+				FROM outbox_events
+				WHERE published_at IS NULL
+				ORDER BY file_id, seq
+			) heads
+			WHERE next_attempt_at <= $1
+			ORDER BY seq
+			LIMIT $2
+		) due
+		WHERE e.seq = due.seq
+		RETURNING e.seq, e.id, e.type, e.file_id, e.occurred_at, e.payload, e.attempts, e.last_error
+	`
+
+	markOutboxEventPublishedQuery = `
+		UPDATE outbox_events
+		SET published_at = $2, attempts = attempts + 1, last_error = ''
+		WHERE seq = $1
+	`
+
+	markOutboxEventFailedQuery = `
+		UPDATE outbox_events
+		SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3
+		WHERE seq = $1
+	`
+
+	deletePublishedOutboxEventsQuery = `
+		DELETE FROM outbox_events
+		WHERE published_at < $1
+	`
+)
+
+type PostgresOutbox struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresOutbox(db *database.DB) *PostgresOutbox {
+	return &PostgresOutbox{
+		pool: db.Primary(),
+	}
+}
+
+// insertOutboxEvent writes the event in the caller's transaction.
+func insertOutboxEvent(ctx context.Context, tx pgx.Tx, event *domain.OutboxEvent) error {
+	err := tx.QueryRow(ctx, insertOutboxEventQuery,
+		event.ID,
+		event.Type,
+		event.FileID,
+		event.OccurredAt,
+		event.Payload,
+	).Scan(&event.Seq)
+	if err != nil {
+		return fmt.Errorf("failed to write %s event to outbox: %w", event.Type, err)
+	}
+	return nil
+}
+
+// Relay claims a batch of due events by moving their next attempt past the
+// relay lease and publishes them after the claim is committed, so that no
+// transaction stays open while the publisher is called. Each event is then
+// marked published or scheduled for a retry on its own.
+func (o *PostgresOutbox) Relay(ctx context.Context, limit int, publish func(*domain.OutboxEvent) error) (int, error) {
+	leaseEnd := time.Now().Add(outboxRelayLease)
+	events, err := o.claimDueEvents(ctx, limit, leaseEnd)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for _, event := range events {
+		// Events left over once the lease ended may already be claimed by
+		// another relay.
+		if time.Now().After(leaseEnd) {
+			break
+		}
+		if err := publish(event); err != nil {
+			retryAt := time.Now().Add(outboxRetryDelay(event.Attempts + 1))
+			if _, err := o.pool.Exec(ctx, markOutboxEventFailedQuery, event.Seq, err.Error(), retryAt); err != nil {
+				return published, fmt.Errorf("failed to record outbox event failure: %w", err)
+			}
+			continue
+		}
+		if _, err := o.pool.Exec(ctx, markOutboxEventPublishedQuery, event.Seq, time.Now()); err != nil {
+			return published, fmt.Errorf("failed to mark outbox event published: %w", err)
+		}
+		published++
+	}
+	return published, nil
+}
+
+// claimDueEvents returns the oldest unpublished event of each file whose next
+// attempt is due, in Seq order, and moves their next attempt to leaseEnd. The
+// advisory lock lets a single replica claim events at a time.
+func (o *PostgresOutbox) claimDueEvents(ctx context.Context, limit int, leaseEnd time.Time) ([]*domain.OutboxEvent, error) {
+	tx, err := o.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var locked bool
+	if err := tx.QueryRow(ctx, tryOutboxRelayLockQuery, outboxRelayLockKey).Scan(&locked); err != nil {
+		return nil, fmt.Errorf("failed to lock outbox: %w", err)
+	}
+	if !locked {
+		return nil, nil
+	}
+
+	events, err := scanOutboxEvents(tx.Query(ctx, claimDueOutboxEventsQuery, time.Now(), limit, leaseEnd))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	sort.Slice(events, func(i, j int) bool { return events[i].Seq < events[j].Seq })
+	return events, nil
+}
+
+func (o *PostgresOutbox) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := o.pool.Exec(ctx, deletePublishedOutboxEventsQuery, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete published outbox events: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+func scanOutboxEvents(rows pgx.Rows, err error) ([]*domain.OutboxEvent, error) {
+	if err != nil {
+		return nil, fmt.Errorf("failed to get outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*domain.OutboxEvent
+	for rows.Next() {
+		event := &domain.OutboxEvent{}
+		if err := rows.Scan(
+			&event.Seq,
+			&event.ID,
+			&event.Type,
+			&event.FileID,
+			&event.OccurredAt,
+			&event.Payload,
+			&event.Attempts,
+			&event.LastError,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating outbox events: %w", err)
+	}
+
+	return events, nil
+}
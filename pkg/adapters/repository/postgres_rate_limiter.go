@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"file-storage-go/pkg/database"
+	"file-storage-go/pkg/domain"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// lockRateLimitBucketQuery creates a full bucket for a new key and locks
+	// the bucket until the transaction ends. The no-op update makes the
+	// statement return and lock an existing bucket as well.
+	lockRateLimitBucketQuery = `
+		INSERT INTO rate_limit_buckets (key, tokens, updated_at, full_at)
+		VALUES ($1, $2, $3, $3)
+		ON CONFLICT (key) DO UPDATE SET key = EXCLUDED.key
+		RETURNING tokens, updated_at
+	`
+
+	updateRateLimitBucketQuery = `
+		UPDATE rate_limit_buckets
+		SET tokens = $2, updated_at = $3, full_at = $4
+		WHERE key = $1
+	`
+
+	deleteExpiredRateLimitBucketsQuery = `
+		DELETE FROM rate_limit_buckets
+		WHERE full_at <= $1
+	`
+)
+
+// PostgresRateLimiter shares the buckets between replicas. Each request locks
+// its bucket for one short transaction, so the replicas' clocks must be
+// roughly in sync.
+type PostgresRateLimiter struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresRateLimiter(db *database.DB) *PostgresRateLimiter {
+	return &PostgresRateLimiter{
+		pool: db.Primary(),
+	}
+}
+
+func (l *PostgresRateLimiter) Take(ctx context.Context, key string, limit domain.RateLimit, now time.Time) (domain.RateLimitResult, error) {
+	// The columns have no time zone, so times are stored and read as UTC.
+	now = now.UTC()
+
+	tx, err := l.pool.Begin(ctx)
+	if err != nil {
+		return domain.RateLimitResult{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var bucket domain.RateLimitBucket
+	if err := tx.QueryRow(ctx, lockRateLimitBucketQuery, key, float64(limit.Requests), now).Scan(&bucket.Tokens, &bucket.UpdatedAt); err != nil {
+		return domain.RateLimitResult{}, fmt.Errorf("failed to lock rate limit bucket: %w", err)
+	}
+
+	result := limit.Take(&bucket, now)
+	if _, err := tx.Exec(ctx, updateRateLimitBucketQuery, key, bucket.Tokens, bucket.UpdatedAt, bucket.FullAt); err != nil {
+		return domain.RateLimitResult{}, fmt.Errorf("failed to update rate limit bucket: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return domain.RateLimitResult{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return result, nil
+}
+
+func (l *PostgresRateLimiter) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	tag, err := l.pool.Exec(ctx, deleteExpiredRateLimitBucketsQuery, now.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired rate limit buckets: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
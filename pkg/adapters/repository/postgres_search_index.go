@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"file-storage-go/pkg/database"
+	"file-storage-go/pkg/domain"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	upsertSearchIndexQuery = `
+		INSERT INTO file_search_index (file_id, content, content_tsv, indexed_at)
+		VALUES ($1, $2, to_tsvector($3::regconfig, $2), $4)
+		ON CONFLICT (file_id) DO UPDATE
+		SET content = EXCLUDED.content, content_tsv = EXCLUDED.content_tsv, indexed_at = EXCLUDED.indexed_at
+	`
+
+	searchIndexQuery = `
+		SELECT file_id,
+			ts_rank_cd(content_tsv, query) AS rank,
+			ts_headline($1::regconfig, content, query, 'StartSel=__HL_START__, StopSel=__HL_END__, MaxFragments=3, MaxWords=20, MinWords=5')
+		FROM file_search_index, websearch_to_tsquery($1::regconfig, $2) AS query
+		WHERE content_tsv @@ query
+		ORDER BY rank DESC, file_id
+		LIMIT $3 OFFSET $4
+	`
+
+	deleteSearchIndexQuery = `
+		DELETE FROM file_search_index
+		WHERE file_id = $1
+	`
+)
+
+type PostgresSearchIndex struct {
+	pool     *pgxpool.Pool
+	replicas *database.DB
+	language string
+}
+
+func NewPostgresSearchIndex(db *database.DB, language string) *PostgresSearchIndex {
+	return &PostgresSearchIndex{
+		pool:     db.Primary(),
+		replicas: db,
+		language: language,
+	}
+}
+
+func (r *PostgresSearchIndex) Index(ctx context.Context, fileID string, content string) error {
+	_, err := r.pool.Exec(ctx, upsertSearchIndexQuery, fileID, content, r.language, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to index file content: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresSearchIndex) Search(ctx context.Context, query string, limit, offset int) ([]*domain.SearchResult, error) {
+	rows, err := r.replicas.Reader(ctx).Query(ctx, searchIndexQuery, r.language, query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search file contents: %w", err)
+	}
+	defer rows.Close()
+
+	var results []*domain.SearchResult
+	for rows.Next() {
+		result := &domain.SearchResult{}
+		var rank float32
+		if err := rows.Scan(&result.FileID, &rank, &result.Highlight); err != nil {
+			return nil, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		result.Rank = float64(rank)
+		result.Highlight = escapeHighlight(result.Highlight)
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating search results: %w", err)
+	}
+
+	return results, nil
+}
+
+func (r *PostgresSearchIndex) Delete(ctx context.Context, fileID string) error {
+	_, err := r.pool.Exec(ctx, deleteSearchIndexQuery, fileID)
+	if err != nil {
+		return fmt.Errorf("failed to delete search index entry: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"file-storage-go/pkg/database"
+	"file-storage-go/pkg/domain"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// dbtx is implemented by *pgxpool.Pool and pgx.Tx, so that repositories can
+// run inside a unit of work. Begin on a pgx.Tx starts a savepoint, so
+// repository methods that use their own transaction keep working there.
+type dbtx interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// PostgresUnitOfWork runs each unit of work in a single transaction. File
+// authorizations are registered in that transaction if fileAuthorizations is
+// the Postgres ACL; other registries keep no state in Postgres and are used
+// as they are.
+type PostgresUnitOfWork struct {
+	pool               *pgxpool.Pool
+	fileAuthorizations domain.FileAuthorizationRegistry
+}
+
+func NewPostgresUnitOfWork(db *database.DB, fileAuthorizations domain.FileAuthorizationRegistry) *PostgresUnitOfWork {
+	return &PostgresUnitOfWork{
+		pool:               db.Primary(),
+		fileAuthorizations: fileAuthorizations,
+	}
+}
+
+func (u *PostgresUnitOfWork) Do(ctx context.Context, fn func(repos domain.Repositories) error) error {
+	tx, err := u.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	fileAuthorizations := u.fileAuthorizations
+	if _, ok := fileAuthorizations.(*PostgresFileAuthorization); ok {
+		fileAuthorizations = &PostgresFileAuthorization{db: tx}
+	}
+
+	if err := fn(domain.Repositories{
+		Jobs:               &PostgresJobRepo{db: tx},
+		FileInfos:          &PostgresFileInfoRepo{db: tx},
+		FileAuthorizations: fileAuthorizations,
+		Webhooks:           &PostgresWebhookRepo{db: tx},
+	}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
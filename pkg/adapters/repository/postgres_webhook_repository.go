@@ -0,0 +1,300 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"file-storage-go/pkg/database"
+	"file-storage-go/pkg/domain"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	createWebhookSubscriptionQuery = `
+		INSERT INTO webhook_subscriptions (id, url, secret, job_id, linked_resource_type, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	deleteWebhookSubscriptionQuery = `
+		DELETE FROM webhook_subscriptions
+		WHERE id = $1
+	`
+
+	webhookSubscriptionColumns = `id, url, secret, job_id, linked_resource_type, created_by, created_at`
+
+	listWebhookSubscriptionsQuery = `
+		SELECT ` + webhookSubscriptionColumns + `
+		FROM webhook_subscriptions
+		WHERE job_id = ''
+		ORDER BY created_at, id
+	`
+
+	matchingWebhookSubscriptionsQuery = `
+		SELECT ` + webhookSubscriptionColumns + `
+		FROM webhook_subscriptions
+		WHERE (job_id <> '' AND job_id = $1) OR (linked_resource_type <> '' AND linked_resource_type = $2)
+		ORDER BY created_at, id
+	`
+
+	createWebhookDeliveryQuery = `
+		INSERT INTO webhook_deliveries (id, subscription_id, job_id, url, event_type, payload, status, attempts, next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+
+	webhookDeliveryColumns = `d.id, d.subscription_id, d.job_id, d.url, d.event_type, d.payload, d.status, d.attempts,
+		d.next_attempt_at, d.last_attempt_at, d.last_status_code, d.last_error, d.created_at`
+
+	getWebhookDeliveryQuery = `
+		SELECT ` + webhookDeliveryColumns + `
+		FROM webhook_deliveries d
+		WHERE d.id = $1
+	`
+
+	listWebhookDeliveriesQuery = `
+		SELECT ` + webhookDeliveryColumns + `
+		FROM webhook_deliveries d
+	`
+
+	updateWebhookDeliveryQuery = `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = $3, next_attempt_at = $4, last_attempt_at = $5, last_status_code = $6, last_error = $7
+		WHERE id = $1
+	`
+
+	// Deliveries whose subscription was deleted are still sent, signed with
+	// the service wide secret.
+	claimDueWebhookDeliveriesQuery = `
+		WITH due AS (
+			SELECT id
+			FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= $1
+			ORDER BY next_attempt_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = $2
+		FROM due
+		WHERE d.id = due.id
+		RETURNING ` + webhookDeliveryColumns + `,
+			COALESCE((SELECT s.secret FROM webhook_subscriptions s WHERE s.id = d.subscription_id), '')
+	`
+)
+
+type PostgresWebhookRepo struct {
+	db dbtx
+}
+
+func NewPostgresWebhookRepo(db *database.DB) *PostgresWebhookRepo {
+	return &PostgresWebhookRepo{
+		db: db.Primary(),
+	}
+}
+
+func (r *PostgresWebhookRepo) CreateSubscription(ctx context.Context, subscription *domain.WebhookSubscription) error {
+	_, err := r.db.Exec(ctx, createWebhookSubscriptionQuery,
+		subscription.ID,
+		subscription.URL,
+		subscription.Secret,
+		subscription.JobID,
+		subscription.LinkedResourceType,
+		subscription.CreatedBy,
+		subscription.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook subscription: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresWebhookRepo) DeleteSubscription(ctx context.Context, subscriptionID string) error {
+	tag, err := r.db.Exec(ctx, deleteWebhookSubscriptionQuery, subscriptionID)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook subscription: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrWebhookNotFound
+	}
+	return nil
+}
+
+// ListSubscriptions returns the configured subscriptions, not the callbacks
+// registered for single jobs.
+func (r *PostgresWebhookRepo) ListSubscriptions(ctx context.Context) ([]*domain.WebhookSubscription, error) {
+	return r.querySubscriptions(ctx, listWebhookSubscriptionsQuery)
+}
+
+func (r *PostgresWebhookRepo) MatchingSubscriptions(ctx context.Context, jobID, linkedResourceType string) ([]*domain.WebhookSubscription, error) {
+	return r.querySubscriptions(ctx, matchingWebhookSubscriptionsQuery, jobID, linkedResourceType)
+}
+
+func (r *PostgresWebhookRepo) querySubscriptions(ctx context.Context, query string, args ...any) ([]*domain.WebhookSubscription, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var subscriptions []*domain.WebhookSubscription
+	for rows.Next() {
+		subscription := &domain.WebhookSubscription{}
+		if err := rows.Scan(
+			&subscription.ID,
+			&subscription.URL,
+			&subscription.Secret,
+			&subscription.JobID,
+			&subscription.LinkedResourceType,
+			&subscription.CreatedBy,
+			&subscription.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook subscription: %w", err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook subscriptions: %w", err)
+	}
+
+	return subscriptions, nil
+}
+
+func (r *PostgresWebhookRepo) CreateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	_, err := r.db.Exec(ctx, createWebhookDeliveryQuery,
+		delivery.ID,
+		delivery.SubscriptionID,
+		delivery.JobID,
+		delivery.URL,
+		delivery.EventType,
+		delivery.Payload,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt,
+		delivery.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresWebhookRepo) GetDelivery(ctx context.Context, deliveryID string) (*domain.WebhookDelivery, error) {
+	delivery := &domain.WebhookDelivery{}
+	err := r.db.QueryRow(ctx, getWebhookDeliveryQuery, deliveryID).Scan(webhookDeliveryFields(delivery)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook delivery: %w", err)
+	}
+	return delivery, nil
+}
+
+func (r *PostgresWebhookRepo) ListDeliveries(ctx context.Context, filter domain.WebhookDeliveryFilter) ([]*domain.WebhookDelivery, error) {
+	var conditions []string
+	var args []any
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, "d.status = $"+strconv.Itoa(len(args)))
+	}
+	if filter.JobID != "" {
+		args = append(args, filter.JobID)
+		conditions = append(conditions, "d.job_id = $"+strconv.Itoa(len(args)))
+	}
+
+	query := listWebhookDeliveriesQuery
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY d.created_at DESC, d.id"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += " LIMIT $" + strconv.Itoa(len(args))
+	}
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []*domain.WebhookDelivery
+	for rows.Next() {
+		delivery := &domain.WebhookDelivery{}
+		if err := rows.Scan(webhookDeliveryFields(delivery)...); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+func (r *PostgresWebhookRepo) UpdateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	tag, err := r.db.Exec(ctx, updateWebhookDeliveryQuery,
+		delivery.ID,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt,
+		delivery.LastAttemptAt,
+		delivery.LastStatusCode,
+		delivery.LastError,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrWebhookNotFound
+	}
+	return nil
+}
+
+func (r *PostgresWebhookRepo) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*domain.WebhookDelivery, error) {
+	rows, err := r.db.Query(ctx, claimDueWebhookDeliveriesQuery, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []*domain.WebhookDelivery
+	for rows.Next() {
+		delivery := &domain.WebhookDelivery{}
+		if err := rows.Scan(append(webhookDeliveryFields(delivery), &delivery.Secret)...); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+func webhookDeliveryFields(delivery *domain.WebhookDelivery) []any {
+	return []any{
+		&delivery.ID,
+		&delivery.SubscriptionID,
+		&delivery.JobID,
+		&delivery.URL,
+		&delivery.EventType,
+		&delivery.Payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&delivery.LastAttemptAt,
+		&delivery.LastStatusCode,
+		&delivery.LastError,
+		&delivery.CreatedAt,
+	}
+}
@@ -4,11 +4,17 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"strings"
 	"time"
 
 	"file-storage-go/pkg/domain"
+	"file-storage-go/pkg/tracing"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type AzureBlobStorage struct {
@@ -39,38 +45,143 @@ func (s *AzureBlobStorage) getBlobName(fileID string) string {
 	return fileID
 }
 
+// startSpan starts a client span for an operation on the blob of fileID.
+func (s *AzureBlobStorage) startSpan(ctx context.Context, operation, fileID string) (context.Context, trace.Span) {
+	return otel.Tracer("file-storage-go/pkg/adapters/storage").Start(ctx, "AzureBlobStorage."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("azure.storage.container", s.containerName),
+			attribute.String("file.id", fileID),
+		),
+	)
+}
+
 func (s *AzureBlobStorage) Upload(ctx context.Context, fileID string, reader io.Reader) error {
+	return s.UploadWithMetadata(ctx, fileID, reader, nil)
+}
+
+// UploadWithMetadata stores metadata as blob metadata. Keys must be valid C#
+// identifiers and are case-insensitive.
+func (s *AzureBlobStorage) UploadWithMetadata(ctx context.Context, fileID string, reader io.Reader, metadata map[string]string) (err error) {
+	ctx, span := s.startSpan(ctx, "Upload", fileID)
+	defer func() { tracing.End(span, err) }()
+
 	start := time.Now()
 	blobName := s.getBlobName(fileID)
 
-	_, err := s.client.UploadStream(ctx, s.containerName, blobName, reader, nil)
+	var options *azblob.UploadStreamOptions
+	if len(metadata) > 0 {
+		options = &azblob.UploadStreamOptions{Metadata: make(map[string]*string, len(metadata))}
+		for key, value := range metadata {
+			options.Metadata[key] = &value
+		}
+	}
+
+	counter := &countingReader{reader: reader}
+	_, err = s.client.UploadStream(ctx, s.containerName, blobName, counter, options)
 	if err != nil {
 		s.metrics.RecordUploadDuration("error", time.Since(start))
 		return fmt.Errorf("failed to upload file: %w", err)
 	}
 
 	s.metrics.RecordUploadDuration("success", time.Since(start))
+	s.metrics.RecordUploadSize(counter.count)
 	return nil
 }
 
 func (s *AzureBlobStorage) Download(ctx context.Context, fileID string) (io.ReadCloser, error) {
+	body, _, err := s.DownloadWithMetadata(ctx, fileID)
+	return body, err
+}
+
+// DownloadWithMetadata returns the blob metadata with lowercased keys.
+func (s *AzureBlobStorage) DownloadWithMetadata(ctx context.Context, fileID string) (_ io.ReadCloser, _ map[string]string, err error) {
+	ctx, span := s.startSpan(ctx, "Download", fileID)
+	defer func() { tracing.End(span, err) }()
+
+	start := time.Now()
 	blobName := s.getBlobName(fileID)
 
 	downloadResponse, err := s.client.DownloadStream(ctx, s.containerName, blobName, nil)
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		s.metrics.RecordDownloadDuration("not_found", time.Since(start))
+		return nil, nil, fmt.Errorf("failed to download file %s: %w", fileID, domain.ErrFileNotFound)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to download file: %w", err)
+		s.metrics.RecordDownloadDuration("error", time.Since(start))
+		return nil, nil, fmt.Errorf("failed to download file: %w", err)
 	}
 
-	return downloadResponse.Body, nil
+	metadata := make(map[string]string, len(downloadResponse.Metadata))
+	for key, value := range downloadResponse.Metadata {
+		if value != nil {
+			metadata[strings.ToLower(key)] = *value
+		}
+	}
+
+	s.metrics.RecordDownloadDuration("success", time.Since(start))
+	return &countingReadCloser{
+		countingReader: countingReader{reader: downloadResponse.Body},
+		closer:         downloadResponse.Body,
+		onClose:        s.metrics.RecordDownloadSize,
+	}, metadata, nil
 }
 
-func (s *AzureBlobStorage) Delete(ctx context.Context, fileID string) error {
+func (s *AzureBlobStorage) Delete(ctx context.Context, fileID string) (err error) {
+	ctx, span := s.startSpan(ctx, "Delete", fileID)
+	defer func() { tracing.End(span, err) }()
+
+	start := time.Now()
 	blobName := s.getBlobName(fileID)
 
-	_, err := s.client.DeleteBlob(ctx, s.containerName, blobName, nil)
+	_, err = s.client.DeleteBlob(ctx, s.containerName, blobName, nil)
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		s.metrics.RecordDeleteDuration("not_found", time.Since(start))
+		return fmt.Errorf("failed to delete file %s: %w", fileID, domain.ErrFileNotFound)
+	}
 	if err != nil {
+		s.metrics.RecordDeleteDuration("error", time.Since(start))
 		return fmt.Errorf("failed to delete file: %w", err)
 	}
 
+	s.metrics.RecordDeleteDuration("success", time.Since(start))
+	return nil
+}
+
+// Check reports whether the container is reachable with the configured
+// credentials.
+func (s *AzureBlobStorage) Check(ctx context.Context) error {
+	if _, err := s.client.ServiceClient().NewContainerClient(s.containerName).GetProperties(ctx, nil); err != nil {
+		return fmt.Errorf("failed to get container properties: %w", err)
+	}
 	return nil
 }
+
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+// countingReadCloser reports how many bytes were read when it is closed.
+type countingReadCloser struct {
+	countingReader
+	closer  io.Closer
+	onClose func(count int64)
+	closed  bool
+}
+
+func (r *countingReadCloser) Close() error {
+	if !r.closed {
+		r.closed = true
+		r.onClose(r.count)
+	}
+	return r.closer.Close()
+}
+
+var _ domain.MetadataFileStorage = (*AzureBlobStorage)(nil)
@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountingReadCloser(t *testing.T) {
+	var reported []int64
+	reader := &countingReadCloser{
+		countingReader: countingReader{reader: strings.NewReader("file contents")},
+		closer:         io.NopCloser(nil),
+		onClose:        func(count int64) { reported = append(reported, count) },
+	}
+
+	buf := make([]byte, 4)
+	_, err := io.ReadFull(reader, buf)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	require.NoError(t, reader.Close())
+
+	assert.Equal(t, []int64{4}, reported, "the bytes read are reported once")
+}
@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"file-storage-go/pkg/contenttype"
+	"file-storage-go/pkg/domain"
+)
+
+const EncodingGzip = "gzip"
+
+// contentEncodingMetadata is the metadata key that records the content
+// encoding of a blob. Blobs without it are stored as-is.
+const contentEncodingMetadata = "contentencoding"
+
+type CompressingStorage struct {
+	inner        domain.MetadataFileStorage
+	contentTypes []string
+}
+
+// NewCompressingStorage gzips files whose sniffed content type matches one of
+// contentTypes. Entries may end in "/*" to match a whole top-level type.
+func NewCompressingStorage(inner domain.MetadataFileStorage, contentTypes []string) *CompressingStorage {
+	normalized := make([]string, 0, len(contentTypes))
+	for _, ct := range contentTypes {
+		if ct = strings.ToLower(strings.TrimSpace(ct)); ct != "" {
+			normalized = append(normalized, ct)
+		}
+	}
+
+	return &CompressingStorage{
+		inner:        inner,
+		contentTypes: normalized,
+	}
+}
+
+func (s *CompressingStorage) shouldCompress(mediaType string) bool {
+	for _, ct := range s.contentTypes {
+		if ct == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(ct, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *CompressingStorage) Upload(ctx context.Context, fileID string, reader io.Reader) error {
+	detected, content, err := contenttype.Sniff(reader)
+	if err != nil {
+		return err
+	}
+
+	if !s.shouldCompress(detected) {
+		return s.inner.Upload(ctx, fileID, content)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeGzip(pw, content))
+	}()
+
+	err = s.inner.UploadWithMetadata(ctx, fileID, pr, map[string]string{contentEncodingMetadata: EncodingGzip})
+	pr.CloseWithError(err)
+	return err
+}
+
+func writeGzip(w io.Writer, content io.Reader) error {
+	gz := gzip.NewWriter(w)
+	if _, err := io.Copy(gz, content); err != nil {
+		return fmt.Errorf("failed to compress file: %w", err)
+	}
+	return gz.Close()
+}
+
+func (s *CompressingStorage) Download(ctx context.Context, fileID string) (io.ReadCloser, error) {
+	body, encoding, err := s.DownloadEncoded(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch encoding {
+	case domain.ContentEncodingIdentity:
+		return body, nil
+	case EncodingGzip:
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			body.Close()
+			return nil, fmt.Errorf("failed to decompress file: %w", err)
+		}
+		return &readCloser{Reader: gz, closers: []io.Closer{gz, body}}, nil
+	default:
+		body.Close()
+		return nil, fmt.Errorf("unsupported content encoding: %s", encoding)
+	}
+}
+
+// DownloadEncoded returns the stored bytes without decoding them, together
+// with their content encoding.
+func (s *CompressingStorage) DownloadEncoded(ctx context.Context, fileID string) (io.ReadCloser, string, error) {
+	body, metadata, err := s.inner.DownloadWithMetadata(ctx, fileID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	encoding := metadata[contentEncodingMetadata]
+	if encoding == "" {
+		encoding = domain.ContentEncodingIdentity
+	}
+	return body, encoding, nil
+}
+
+func (s *CompressingStorage) Delete(ctx context.Context, fileID string) error {
+	return s.inner.Delete(ctx, fileID)
+}
+
+type readCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *readCloser) Close() error {
+	var firstErr error
+	for _, c := range r.closers {
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+var (
+	_ domain.FileStorage        = (*CompressingStorage)(nil)
+	_ domain.EncodedFileStorage = (*CompressingStorage)(nil)
+)
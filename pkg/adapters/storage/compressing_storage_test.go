@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"file-storage-go/pkg/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readAll(t *testing.T, reader io.ReadCloser) []byte {
+	t.Helper()
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return data
+}
+
+func TestCompressingStorage_CompressesMatchingTypes(t *testing.T) {
+	inner := NewMockStorage()
+	s := NewCompressingStorage(inner, []string{"text/*", "application/json"})
+	ctx := context.Background()
+
+	content := strings.Repeat("id,name,amount\n1,foo,42\n", 1000)
+	require.NoError(t, s.Upload(ctx, "csv", strings.NewReader(content)))
+	assert.Less(t, len(inner.files["csv"]), len(content)/5)
+
+	reader, err := s.Download(ctx, "csv")
+	require.NoError(t, err)
+	assert.Equal(t, content, string(readAll(t, reader)))
+}
+
+func TestCompressingStorage_SkipsOtherTypes(t *testing.T) {
+	inner := NewMockStorage()
+	s := NewCompressingStorage(inner, []string{"text/*"})
+	ctx := context.Background()
+
+	content := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 100)...)
+	require.NoError(t, s.Upload(ctx, "png", bytes.NewReader(content)))
+	assert.Equal(t, content, inner.files["png"])
+
+	reader, err := s.Download(ctx, "png")
+	require.NoError(t, err)
+	assert.Equal(t, content, readAll(t, reader))
+}
+
+func TestCompressingStorage_StoresEncodingAsMetadata(t *testing.T) {
+	inner := NewMockStorage()
+	s := NewCompressingStorage(inner, []string{"text/*"})
+	ctx := context.Background()
+
+	content := strings.Repeat("plain text\n", 100)
+	require.NoError(t, s.Upload(ctx, "text", strings.NewReader(content)))
+	assert.Equal(t, map[string]string{contentEncodingMetadata: EncodingGzip}, inner.metadata["text"])
+
+	gz, err := gzip.NewReader(bytes.NewReader(inner.files["text"]))
+	require.NoError(t, err)
+	data, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(data))
+
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 100)...)
+	require.NoError(t, s.Upload(ctx, "png", bytes.NewReader(png)))
+	assert.Empty(t, inner.metadata["png"])
+}
+
+func TestCompressingStorage_LegacyUncompressed(t *testing.T) {
+	inner := NewMockStorage()
+	inner.files["legacy"] = []byte("abc")
+	s := NewCompressingStorage(inner, []string{"text/*"})
+
+	reader, encoding, err := s.DownloadEncoded(context.Background(), "legacy")
+	require.NoError(t, err)
+	assert.Equal(t, domain.ContentEncodingIdentity, encoding)
+	assert.Equal(t, "abc", string(readAll(t, reader)))
+}
+
+func TestCompressingStorage_DownloadEncoded(t *testing.T) {
+	inner := NewMockStorage()
+	s := NewCompressingStorage(inner, []string{"text/plain"})
+	ctx := context.Background()
+
+	content := strings.Repeat(`{"key":"value"}`+"\n", 100)
+	require.NoError(t, s.Upload(ctx, "json", strings.NewReader(content)))
+
+	reader, encoding, err := s.DownloadEncoded(ctx, "json")
+	require.NoError(t, err)
+	assert.Equal(t, EncodingGzip, encoding)
+
+	gz, err := gzip.NewReader(bytes.NewReader(readAll(t, reader)))
+	require.NoError(t, err)
+	data, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(data))
+}
+
+func TestCompressingStorage_WrapsEncryptingStorage(t *testing.T) {
+	inner := NewMockStorage()
+	s := NewCompressingStorage(NewEncryptingStorage(inner, newFakeKeyProvider(), false), []string{"text/*"})
+	ctx := context.Background()
+
+	content := strings.Repeat("compress then encrypt\n", 500)
+	require.NoError(t, s.Upload(ctx, "file", strings.NewReader(content)))
+
+	reader, err := s.Download(ctx, "file")
+	require.NoError(t, err)
+	assert.Equal(t, content, string(readAll(t, reader)))
+}
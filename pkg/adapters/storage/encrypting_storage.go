@@ -0,0 +1,378 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"file-storage-go/pkg/domain"
+)
+
+const (
+	encryptionAlgorithm    = "AES-256-GCM-STREAM"
+	defaultEncryptionChunk = 64 * 1024
+	keyBlobSuffix          = ".key"
+)
+
+// ErrKeyEnvelopeMissing is returned for files without a "<fileID>.key" blob,
+// unless plaintext files from before encryption was enabled are allowed.
+var ErrKeyEnvelopeMissing = errors.New("key envelope missing")
+
+type envelope struct {
+	Algorithm  string `json:"algorithm"`
+	ChunkSize  int    `json:"chunkSize"`
+	WrappedKey string `json:"wrappedKey"`
+}
+
+// EncryptingStorage encrypts file contents with a per-file AES-GCM data key
+// before handing them to the wrapped backend. The data key, wrapped by the
+// DataKeyProvider, is stored next to the file in a "<fileID>.key" blob.
+// Files without one are refused unless allowPlaintext is set while files
+// stored before encryption was enabled are being migrated.
+type EncryptingStorage struct {
+	inner          domain.FileStorage
+	keys           domain.DataKeyProvider
+	chunkSize      int
+	allowPlaintext bool
+}
+
+func NewEncryptingStorage(inner domain.FileStorage, keys domain.DataKeyProvider, allowPlaintext bool) *EncryptingStorage {
+	return &EncryptingStorage{
+		inner:          inner,
+		keys:           keys,
+		chunkSize:      defaultEncryptionChunk,
+		allowPlaintext: allowPlaintext,
+	}
+}
+
+func (s *EncryptingStorage) keyBlobName(fileID string) string {
+	return fileID + keyBlobSuffix
+}
+
+func (s *EncryptingStorage) Upload(ctx context.Context, fileID string, reader io.Reader) error {
+	return s.upload(ctx, fileID, reader, func(encrypted io.Reader) error {
+		return s.inner.Upload(ctx, fileID, encrypted)
+	})
+}
+
+// UploadWithMetadata passes metadata through to the wrapped backend
+// unencrypted.
+func (s *EncryptingStorage) UploadWithMetadata(ctx context.Context, fileID string, reader io.Reader, metadata map[string]string) error {
+	inner, err := s.metadataStorage()
+	if err != nil {
+		return err
+	}
+	return s.upload(ctx, fileID, reader, func(encrypted io.Reader) error {
+		return inner.UploadWithMetadata(ctx, fileID, encrypted, metadata)
+	})
+}
+
+func (s *EncryptingStorage) upload(ctx context.Context, fileID string, reader io.Reader, store func(io.Reader) error) error {
+	dataKey, wrapped, err := s.keys.GenerateDataKey(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to generate data key: %w", err)
+	}
+	defer clear(dataKey)
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return err
+	}
+
+	env := envelope{
+		Algorithm:  encryptionAlgorithm,
+		ChunkSize:  s.chunkSize,
+		WrappedKey: wrapped,
+	}
+	if err := s.writeEnvelope(ctx, fileID, env); err != nil {
+		return err
+	}
+
+	encrypted := &encryptReader{
+		src:       bufio.NewReaderSize(reader, s.chunkSize),
+		aead:      aead,
+		aad:       []byte(fileID),
+		chunkSize: s.chunkSize,
+		plain:     make([]byte, s.chunkSize),
+	}
+	if err := store(encrypted); err != nil {
+		s.inner.Delete(ctx, s.keyBlobName(fileID))
+		return err
+	}
+
+	return nil
+}
+
+func (s *EncryptingStorage) Download(ctx context.Context, fileID string) (io.ReadCloser, error) {
+	return s.download(ctx, fileID, func() (io.ReadCloser, error) {
+		return s.inner.Download(ctx, fileID)
+	})
+}
+
+func (s *EncryptingStorage) DownloadWithMetadata(ctx context.Context, fileID string) (io.ReadCloser, map[string]string, error) {
+	inner, err := s.metadataStorage()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var metadata map[string]string
+	body, err := s.download(ctx, fileID, func() (io.ReadCloser, error) {
+		body, md, err := inner.DownloadWithMetadata(ctx, fileID)
+		metadata = md
+		return body, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, metadata, nil
+}
+
+func (s *EncryptingStorage) metadataStorage() (domain.MetadataFileStorage, error) {
+	inner, ok := s.inner.(domain.MetadataFileStorage)
+	if !ok {
+		return nil, errors.New("wrapped storage does not support metadata")
+	}
+	return inner, nil
+}
+
+func (s *EncryptingStorage) download(ctx context.Context, fileID string, open func() (io.ReadCloser, error)) (io.ReadCloser, error) {
+	env, err := s.readEnvelope(ctx, fileID)
+	if errors.Is(err, domain.ErrFileNotFound) {
+		if s.allowPlaintext {
+			return open()
+		}
+		return nil, fmt.Errorf("%w for file %s", ErrKeyEnvelopeMissing, fileID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := s.keys.DecryptDataKey(ctx, env.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key: %w", err)
+	}
+	defer clear(dataKey)
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := open()
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptReader{
+		src:       bufio.NewReaderSize(body, env.ChunkSize+aead.Overhead()+1),
+		closer:    body,
+		aead:      aead,
+		aad:       []byte(fileID),
+		frame:     make([]byte, env.ChunkSize+aead.Overhead()),
+		chunkSize: env.ChunkSize,
+	}, nil
+}
+
+// Delete deletes the key envelope even if the file itself is already gone, so
+// that retried deletes clean up both.
+func (s *EncryptingStorage) Delete(ctx context.Context, fileID string) error {
+	err := s.inner.Delete(ctx, fileID)
+	if err != nil && !errors.Is(err, domain.ErrFileNotFound) {
+		return err
+	}
+	if keyErr := s.inner.Delete(ctx, s.keyBlobName(fileID)); keyErr != nil && !errors.Is(keyErr, domain.ErrFileNotFound) {
+		return keyErr
+	}
+	return err
+}
+
+// RewrapKey re-wraps the data key of a file with the latest version of the
+// key encryption key. The file contents are left untouched.
+func (s *EncryptingStorage) RewrapKey(ctx context.Context, fileID string) error {
+	env, err := s.readEnvelope(ctx, fileID)
+	if err != nil {
+		return err
+	}
+
+	rewrapped, err := s.keys.RewrapDataKey(ctx, env.WrappedKey)
+	if err != nil {
+		return fmt.Errorf("failed to rewrap data key: %w", err)
+	}
+	if rewrapped == env.WrappedKey {
+		return nil
+	}
+
+	env.WrappedKey = rewrapped
+	return s.writeEnvelope(ctx, fileID, env)
+}
+
+func (s *EncryptingStorage) writeEnvelope(ctx context.Context, fileID string, env envelope) error {
+	data, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("failed to marshal key envelope: %w", err)
+	}
+	if err := s.inner.Upload(ctx, s.keyBlobName(fileID), bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("failed to store key envelope: %w", err)
+	}
+	return nil
+}
+
+func (s *EncryptingStorage) readEnvelope(ctx context.Context, fileID string) (envelope, error) {
+	var env envelope
+
+	reader, err := s.inner.Download(ctx, s.keyBlobName(fileID))
+	if err != nil {
+		return env, err
+	}
+	defer reader.Close()
+
+	if err := json.NewDecoder(reader).Decode(&env); err != nil {
+		return env, fmt.Errorf("failed to decode key envelope: %w", err)
+	}
+	if env.Algorithm != encryptionAlgorithm {
+		return env, fmt.Errorf("unsupported encryption algorithm: %s", env.Algorithm)
+	}
+	if env.ChunkSize <= 0 {
+		return env, fmt.Errorf("invalid chunk size in key envelope: %d", env.ChunkSize)
+	}
+
+	return env, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gcm: %w", err)
+	}
+	return aead, nil
+}
+
+// chunkNonce derives the nonce for a chunk from its index. The last byte marks
+// the final chunk so that truncated ciphertexts fail authentication.
+func chunkNonce(size int, counter uint64, final bool) []byte {
+	nonce := make([]byte, size)
+	binary.BigEndian.PutUint64(nonce[size-9:size-1], counter)
+	if final {
+		nonce[size-1] = 1
+	}
+	return nonce
+}
+
+type encryptReader struct {
+	src       *bufio.Reader
+	aead      cipher.AEAD
+	aad       []byte
+	chunkSize int
+	plain     []byte
+	pending   []byte
+	counter   uint64
+	done      bool
+}
+
+func (r *encryptReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.sealNext(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func (r *encryptReader) sealNext() error {
+	n, err := io.ReadFull(r.src, r.plain)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+
+	final := n < r.chunkSize
+	if !final {
+		if _, peekErr := r.src.Peek(1); peekErr == io.EOF {
+			final = true
+		} else if peekErr != nil {
+			return peekErr
+		}
+	}
+
+	nonce := chunkNonce(r.aead.NonceSize(), r.counter, final)
+	r.pending = r.aead.Seal(r.pending[:0], nonce, r.plain[:n], r.aad)
+	r.counter++
+	r.done = final
+	return nil
+}
+
+type decryptReader struct {
+	src       *bufio.Reader
+	closer    io.Closer
+	aead      cipher.AEAD
+	aad       []byte
+	frame     []byte
+	chunkSize int
+	pending   []byte
+	counter   uint64
+	done      bool
+}
+
+func (r *decryptReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.openNext(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func (r *decryptReader) openNext() error {
+	n, err := io.ReadFull(r.src, r.frame)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+
+	final := n < len(r.frame)
+	if !final {
+		if _, peekErr := r.src.Peek(1); peekErr == io.EOF {
+			final = true
+		} else if peekErr != nil {
+			return peekErr
+		}
+	}
+
+	nonce := chunkNonce(r.aead.NonceSize(), r.counter, final)
+	plain, err := r.aead.Open(r.frame[:0], nonce, r.frame[:n], r.aad)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt chunk %d: %w", r.counter, err)
+	}
+
+	r.pending = plain
+	r.counter++
+	r.done = final
+	return nil
+}
+
+func (r *decryptReader) Close() error {
+	return r.closer.Close()
+}
+
+var _ domain.MetadataFileStorage = (*EncryptingStorage)(nil)
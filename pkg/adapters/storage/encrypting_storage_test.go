@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeKeyProvider struct {
+	version int
+	keys    map[string][]byte
+}
+
+func newFakeKeyProvider() *fakeKeyProvider {
+	return &fakeKeyProvider{version: 1, keys: make(map[string][]byte)}
+}
+
+func (p *fakeKeyProvider) GenerateDataKey(ctx context.Context) ([]byte, string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, "", err
+	}
+	id := fmt.Sprintf("key-%d", len(p.keys))
+	p.keys[id] = key
+	return append([]byte(nil), key...), fmt.Sprintf("vault:v%d:%s", p.version, id), nil
+}
+
+func (p *fakeKeyProvider) DecryptDataKey(ctx context.Context, wrapped string) ([]byte, error) {
+	parts := strings.SplitN(wrapped, ":", 3)
+	key, ok := p.keys[parts[2]]
+	if !ok {
+		return nil, fmt.Errorf("unknown key")
+	}
+	return append([]byte(nil), key...), nil
+}
+
+func (p *fakeKeyProvider) RewrapDataKey(ctx context.Context, wrapped string) (string, error) {
+	parts := strings.SplitN(wrapped, ":", 3)
+	return fmt.Sprintf("vault:v%d:%s", p.version, parts[2]), nil
+}
+
+func newTestEncryptingStorage(chunkSize int) (*EncryptingStorage, *MockStorage) {
+	inner := NewMockStorage()
+	s := NewEncryptingStorage(inner, newFakeKeyProvider(), false)
+	s.chunkSize = chunkSize
+	return s, inner
+}
+
+func TestEncryptingStorage_RoundTrip(t *testing.T) {
+	sizes := []int{0, 1, 15, 16, 17, 64, 1000}
+
+	for _, size := range sizes {
+		t.Run(fmt.Sprintf("size %d", size), func(t *testing.T) {
+			s, inner := newTestEncryptingStorage(16)
+			ctx := context.Background()
+
+			content := make([]byte, size)
+			_, err := rand.Read(content)
+			require.NoError(t, err)
+
+			require.NoError(t, s.Upload(ctx, "file", bytes.NewReader(content)))
+			assert.Contains(t, inner.files, "file.key")
+			// Shorter random content turns up in the ciphertext by chance.
+			if size >= 16 {
+				assert.NotContains(t, string(inner.files["file"]), string(content))
+			}
+
+			reader, err := s.Download(ctx, "file")
+			require.NoError(t, err)
+			defer reader.Close()
+
+			data, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, content, data)
+		})
+	}
+}
+
+func TestEncryptingStorage_DetectsTampering(t *testing.T) {
+	s, inner := newTestEncryptingStorage(16)
+	ctx := context.Background()
+
+	require.NoError(t, s.Upload(ctx, "file", strings.NewReader("some confidential content here")))
+	inner.files["file"][3] ^= 0xff
+
+	reader, err := s.Download(ctx, "file")
+	require.NoError(t, err)
+	_, err = io.ReadAll(reader)
+	assert.Error(t, err)
+}
+
+func TestEncryptingStorage_DetectsTruncation(t *testing.T) {
+	s, inner := newTestEncryptingStorage(16)
+	ctx := context.Background()
+
+	require.NoError(t, s.Upload(ctx, "file", strings.NewReader("some confidential content here")))
+	inner.files["file"] = inner.files["file"][:32]
+
+	reader, err := s.Download(ctx, "file")
+	require.NoError(t, err)
+	_, err = io.ReadAll(reader)
+	assert.Error(t, err)
+}
+
+func TestEncryptingStorage_BindsCiphertextToFileID(t *testing.T) {
+	s, inner := newTestEncryptingStorage(16)
+	ctx := context.Background()
+
+	require.NoError(t, s.Upload(ctx, "file-a", strings.NewReader("content a")))
+	require.NoError(t, s.Upload(ctx, "file-b", strings.NewReader("content b")))
+	inner.files["file-b.key"] = inner.files["file-a.key"]
+	inner.files["file-b"] = inner.files["file-a"]
+
+	reader, err := s.Download(ctx, "file-b")
+	require.NoError(t, err)
+	_, err = io.ReadAll(reader)
+	assert.Error(t, err)
+}
+
+func TestEncryptingStorage_RefusesFilesWithoutKey(t *testing.T) {
+	s, inner := newTestEncryptingStorage(16)
+	inner.files["legacy"] = []byte("plain content")
+
+	_, err := s.Download(context.Background(), "legacy")
+	assert.ErrorIs(t, err, ErrKeyEnvelopeMissing)
+}
+
+func TestEncryptingStorage_LegacyPlaintextFallback(t *testing.T) {
+	s, inner := newTestEncryptingStorage(16)
+	s.allowPlaintext = true
+	inner.files["legacy"] = []byte("plain content")
+
+	reader, err := s.Download(context.Background(), "legacy")
+	require.NoError(t, err)
+
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "plain content", string(data))
+}
+
+func TestEncryptingStorage_RewrapKey(t *testing.T) {
+	inner := NewMockStorage()
+	keys := newFakeKeyProvider()
+	s := NewEncryptingStorage(inner, keys, false)
+	ctx := context.Background()
+
+	require.NoError(t, s.Upload(ctx, "file", strings.NewReader("rotating content")))
+	assert.Contains(t, string(inner.files["file.key"]), "vault:v1:")
+	ciphertext := append([]byte(nil), inner.files["file"]...)
+
+	keys.version = 2
+	require.NoError(t, s.RewrapKey(ctx, "file"))
+	assert.Contains(t, string(inner.files["file.key"]), "vault:v2:")
+	assert.Equal(t, ciphertext, inner.files["file"])
+
+	reader, err := s.Download(ctx, "file")
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "rotating content", string(data))
+}
+
+func TestEncryptingStorage_Delete(t *testing.T) {
+	s, inner := newTestEncryptingStorage(16)
+	ctx := context.Background()
+
+	require.NoError(t, s.Upload(ctx, "file", strings.NewReader("content")))
+	require.NoError(t, s.Delete(ctx, "file"))
+	assert.Empty(t, inner.files)
+}
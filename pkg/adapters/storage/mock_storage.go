@@ -5,23 +5,30 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"maps"
 	"sync"
 
 	"file-storage-go/pkg/domain"
 )
 
 type MockStorage struct {
-	mu    sync.RWMutex
-	files map[string][]byte
+	mu       sync.RWMutex
+	files    map[string][]byte
+	metadata map[string]map[string]string
 }
 
 func NewMockStorage() *MockStorage {
 	return &MockStorage{
-		files: make(map[string][]byte),
+		files:    make(map[string][]byte),
+		metadata: make(map[string]map[string]string),
 	}
 }
 
 func (ms *MockStorage) Upload(ctx context.Context, fileID string, reader io.Reader) error {
+	return ms.UploadWithMetadata(ctx, fileID, reader, nil)
+}
+
+func (ms *MockStorage) UploadWithMetadata(ctx context.Context, fileID string, reader io.Reader, metadata map[string]string) error {
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
@@ -31,19 +38,25 @@ func (ms *MockStorage) Upload(ctx context.Context, fileID string, reader io.Read
 	}
 
 	ms.files[fileID] = data
+	ms.metadata[fileID] = maps.Clone(metadata)
 	return nil
 }
 
 func (ms *MockStorage) Download(ctx context.Context, fileID string) (io.ReadCloser, error) {
+	body, _, err := ms.DownloadWithMetadata(ctx, fileID)
+	return body, err
+}
+
+func (ms *MockStorage) DownloadWithMetadata(ctx context.Context, fileID string) (io.ReadCloser, map[string]string, error) {
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
 
 	data, ok := ms.files[fileID]
 	if !ok {
-		return nil, fmt.Errorf("mockstorage: file with ID '%s' not found", fileID)
+		return nil, nil, fmt.Errorf("mockstorage: file with ID '%s': %w", fileID, domain.ErrFileNotFound)
 	}
 
-	return io.NopCloser(bytes.NewReader(data)), nil
+	return io.NopCloser(bytes.NewReader(data)), maps.Clone(ms.metadata[fileID]), nil
 }
 
 func (ms *MockStorage) Delete(ctx context.Context, fileID string) error {
@@ -51,7 +64,8 @@ func (ms *MockStorage) Delete(ctx context.Context, fileID string) error {
 	defer ms.mu.Unlock()
 
 	delete(ms.files, fileID)
+	delete(ms.metadata, fileID)
 	return nil
 }
 
-var _ domain.FileStorage = (*MockStorage)(nil)
+var _ domain.MetadataFileStorage = (*MockStorage)(nil)
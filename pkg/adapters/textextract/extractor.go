@@ -0,0 +1,112 @@
+package textextract
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"file-storage-go/pkg/domain"
+)
+
+const (
+	maxInputBytes = 50 << 20
+	maxTextBytes  = 512 << 10
+)
+
+// Extractor indexes the text of scanned plain text, PDF and office documents
+// in a FileSearchIndex.
+type Extractor struct {
+	fileStorage domain.FileStorage
+	index       domain.FileSearchIndex
+}
+
+func NewExtractor(fileStorage domain.FileStorage, index domain.FileSearchIndex) *Extractor {
+	return &Extractor{
+		fileStorage: fileStorage,
+		index:       index,
+	}
+}
+
+func (e *Extractor) Name() string {
+	return "text-extraction"
+}
+
+func (e *Extractor) Process(ctx context.Context, fileInfo *domain.FileInfo) error {
+	if !supported(fileInfo.DetectedMimeType) {
+		return nil
+	}
+
+	reader, err := e.fileStorage.Download(ctx, fileInfo.ID)
+	if err != nil {
+		return fmt.Errorf("failed to download file: %w", err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, maxInputBytes))
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	text, ok := Extract(fileInfo.DetectedMimeType, data)
+	if !ok {
+		return nil
+	}
+
+	if err := e.index.Index(ctx, fileInfo.ID, text); err != nil {
+		return fmt.Errorf("failed to index file content: %w", err)
+	}
+	return nil
+}
+
+func supported(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "text/") || mimeType == "application/pdf" || mimeType == "application/zip"
+}
+
+// Extract returns the normalized text of data. The second return value is
+// false if the format is not supported.
+func Extract(mimeType string, data []byte) (string, bool) {
+	var text string
+	switch {
+	case mimeType == "text/html":
+		return "", false
+	case strings.HasPrefix(mimeType, "text/"):
+		text = string(data)
+	case mimeType == "application/pdf":
+		text = extractPDF(data)
+	case mimeType == "application/zip":
+		var ok bool
+		if text, ok = extractOffice(data); !ok {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+
+	return normalize(text), true
+}
+
+func normalize(text string) string {
+	text = strings.ToValidUTF8(text, "")
+	text = strings.ReplaceAll(text, "\x00", "")
+
+	lines := strings.Split(text, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			kept = append(kept, line)
+		}
+	}
+	text = strings.Join(kept, "\n")
+
+	if len(text) > maxTextBytes {
+		text = text[:maxTextBytes]
+		for !utf8.ValidString(text) {
+			text = text[:len(text)-1]
+		}
+	}
+	return text
+}
+
+var _ domain.PostScanStage = (*Extractor)(nil)
@@ -0,0 +1,131 @@
+package textextract
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"file-storage-go/pkg/adapters/repository"
+	"file-storage-go/pkg/adapters/storage"
+	"file-storage-go/pkg/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func buildPDF(t *testing.T, content string, compress bool) []byte {
+	t.Helper()
+	stream := []byte(content)
+	filter := ""
+	if compress {
+		var buf bytes.Buffer
+		w := zlib.NewWriter(&buf)
+		_, err := w.Write(stream)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		stream = buf.Bytes()
+		filter = " /Filter /FlateDecode"
+	}
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+	fmt.Fprintf(&pdf, "4 0 obj\n<< /Length %d%s >>\nstream\n", len(stream), filter)
+	pdf.Write(stream)
+	pdf.WriteString("\nendstream\nendobj\n%%EOF\n")
+	return pdf.Bytes()
+}
+
+func TestExtract_PlainText(t *testing.T) {
+	text, ok := Extract("text/plain", []byte("  hello   world \n\n second line "))
+	require.True(t, ok)
+	assert.Equal(t, "hello world\nsecond line", text)
+}
+
+func TestExtract_PDF(t *testing.T) {
+	content := `BT /F1 12 Tf 72 712 Td (Quarterly report) Tj 0 -14 Td [(Rev) -20 (enue \(EUR\))] TJ <48656c6c6f> Tj ET`
+
+	for _, compress := range []bool{false, true} {
+		t.Run(fmt.Sprintf("compressed=%v", compress), func(t *testing.T) {
+			text, ok := Extract("application/pdf", buildPDF(t, content, compress))
+			require.True(t, ok)
+			assert.Contains(t, text, "Quarterly report")
+			assert.Contains(t, text, "Revenue (EUR)")
+			assert.Contains(t, text, "Hello")
+		})
+	}
+}
+
+func TestExtract_Docx(t *testing.T) {
+	docx := buildZip(t, map[string]string{
+		"[Content_Types].xml": `<Types/>`,
+		"word/document.xml": `<?xml version="1.0"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+<w:body><w:p><w:r><w:t>Invoice</w:t></w:r><w:r><w:t xml:space="preserve"> number 42</w:t></w:r></w:p>
+<w:p><w:r><w:t>Due next month</w:t></w:r></w:p></w:body></w:document>`,
+	})
+
+	text, ok := Extract("application/zip", docx)
+	require.True(t, ok)
+	assert.Equal(t, "Invoice number 42\nDue next month", text)
+}
+
+func TestExtract_Xlsx(t *testing.T) {
+	xlsx := buildZip(t, map[string]string{
+		"xl/sharedStrings.xml":     `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><si><t>Customer</t></si><si><t>Amount</t></si></sst>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row><c t="inlineStr"><is><t>Inline cell</t></is></c></row></sheetData></worksheet>`,
+	})
+
+	text, ok := Extract("application/zip", xlsx)
+	require.True(t, ok)
+	assert.Contains(t, text, "Customer")
+	assert.Contains(t, text, "Amount")
+	assert.Contains(t, text, "Inline cell")
+}
+
+func TestExtract_Unsupported(t *testing.T) {
+	_, ok := Extract("application/zip", buildZip(t, map[string]string{"foo.txt": "bar"}))
+	assert.False(t, ok)
+
+	_, ok = Extract("image/png", []byte("\x89PNG"))
+	assert.False(t, ok)
+}
+
+func TestExtractor_IndexesContent(t *testing.T) {
+	fileStorage := storage.NewMockStorage()
+	index := repository.NewInMemorySearchIndex()
+	ctx := context.Background()
+	require.NoError(t, fileStorage.Upload(ctx, "file", strings.NewReader("The quick brown fox")))
+
+	extractor := NewExtractor(fileStorage, index)
+	require.NoError(t, extractor.Process(ctx, &domain.FileInfo{ID: "file", DetectedMimeType: "text/plain"}))
+
+	results, err := index.Search(ctx, "fox", 10, 0)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "file", results[0].FileID)
+}
+
+func TestExtractor_SkipsUnsupportedTypes(t *testing.T) {
+	index := repository.NewInMemorySearchIndex()
+	extractor := NewExtractor(storage.NewMockStorage(), index)
+
+	require.NoError(t, extractor.Process(context.Background(), &domain.FileInfo{ID: "img", DetectedMimeType: "image/png"}))
+}
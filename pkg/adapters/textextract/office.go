@@ -0,0 +1,127 @@
+package textextract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// textElements holds the local names of the elements that carry text in
+// OOXML parts; blockElements end a paragraph, cell or row.
+var (
+	textElements  = map[string]bool{"t": true}
+	blockElements = map[string]bool{"p": true, "tr": true, "si": true, "row": true, "h": true, "table-row": true}
+)
+
+func extractOffice(data []byte) (string, bool) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", false
+	}
+
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	var parts []*zip.File
+	allText := false
+	switch {
+	case files["word/document.xml"] != nil:
+		parts = []*zip.File{files["word/document.xml"]}
+	case files["xl/sharedStrings.xml"] != nil || hasPrefix(files, "xl/worksheets/"):
+		if f := files["xl/sharedStrings.xml"]; f != nil {
+			parts = append(parts, f)
+		}
+		parts = append(parts, matching(files, "xl/worksheets/", ".xml")...)
+	case hasPrefix(files, "ppt/slides/"):
+		parts = matching(files, "ppt/slides/", ".xml")
+	case files["content.xml"] != nil:
+		parts = []*zip.File{files["content.xml"]}
+		allText = true
+	default:
+		return "", false
+	}
+
+	var out strings.Builder
+	for _, part := range parts {
+		if err := extractXMLPart(part, allText, &out); err != nil {
+			continue
+		}
+	}
+	return out.String(), true
+}
+
+func hasPrefix(files map[string]*zip.File, prefix string) bool {
+	for name := range files {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func matching(files map[string]*zip.File, dir, ext string) []*zip.File {
+	var result []*zip.File
+	for name, f := range files {
+		if path.Dir(name)+"/" == dir && strings.HasSuffix(name, ext) {
+			result = append(result, f)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return naturalLess(result[i].Name, result[j].Name)
+	})
+	return result
+}
+
+func naturalLess(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+func extractXMLPart(f *zip.File, allText bool, out *strings.Builder) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	decoder := xml.NewDecoder(io.LimitReader(rc, maxInputBytes))
+	inText := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if textElements[t.Name.Local] {
+				inText++
+			}
+			if t.Name.Local == "tab" {
+				out.WriteString("\t")
+			}
+		case xml.EndElement:
+			if textElements[t.Name.Local] && inText > 0 {
+				inText--
+			}
+			if blockElements[t.Name.Local] {
+				out.WriteString("\n")
+			}
+		case xml.CharData:
+			if allText || inText > 0 {
+				out.Write(t)
+			}
+		}
+	}
+}
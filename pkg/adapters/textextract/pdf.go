@@ -0,0 +1,234 @@
+package textextract
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var streamPattern = regexp.MustCompile(`(?s)<<(.*?)>>\s*stream\r?\n`)
+
+// extractPDF pulls the text shown by Tj, TJ, ' and " operators out of the
+// content streams of a PDF. Fonts with custom encodings are not mapped, so
+// this only covers PDFs whose text layer uses standard single-byte encodings.
+func extractPDF(data []byte) string {
+	var out strings.Builder
+
+	for _, loc := range streamPattern.FindAllSubmatchIndex(data, -1) {
+		dict := data[loc[2]:loc[3]]
+		if k := bytes.LastIndex(dict, []byte("obj")); k >= 0 {
+			dict = dict[k:]
+		}
+		start := loc[1]
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		raw := data[start : start+end]
+
+		if bytes.Contains(dict, []byte("/Image")) || bytes.Contains(dict, []byte("/Length1")) {
+			continue
+		}
+
+		content := raw
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			decoded, err := inflate(raw)
+			if err != nil {
+				continue
+			}
+			content = decoded
+		}
+
+		if !bytes.Contains(content, []byte("BT")) {
+			continue
+		}
+		extractContentStream(content, &out)
+	}
+
+	return out.String()
+}
+
+func inflate(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	decoded, err := io.ReadAll(io.LimitReader(r, maxInputBytes))
+	if err != nil && len(decoded) == 0 {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+func extractContentStream(content []byte, out *strings.Builder) {
+	var operands []string
+	inText := false
+
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case c == '(':
+			s, next := readLiteralString(content, i)
+			operands = append(operands, s)
+			i = next
+		case c == '<' && i+1 < len(content) && content[i+1] == '<':
+			i += 2
+		case c == '<':
+			s, next := readHexString(content, i)
+			operands = append(operands, s)
+			i = next
+		case c == '%':
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+		case isDelimiter(c):
+			i++
+		default:
+			start := i
+			for i < len(content) && !isDelimiter(content[i]) && content[i] != '(' && content[i] != '<' {
+				i++
+			}
+			op := string(content[start:i])
+			switch op {
+			case "BT":
+				inText = true
+			case "ET":
+				inText = false
+				out.WriteString("\n")
+			case "Tj", "TJ":
+				if inText {
+					out.WriteString(strings.Join(operands, ""))
+				}
+			case "'", "\"":
+				if inText {
+					out.WriteString("\n")
+					if len(operands) > 0 {
+						out.WriteString(operands[len(operands)-1])
+					}
+				}
+			case "T*", "Td", "TD":
+				if inText {
+					out.WriteString("\n")
+				}
+			}
+			if isOperator(op) {
+				operands = operands[:0]
+			}
+		}
+	}
+}
+
+func isDelimiter(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\f', 0, '[', ']', '{', '}', '/', '>':
+		return true
+	}
+	return false
+}
+
+func isOperator(token string) bool {
+	if token == "" {
+		return false
+	}
+	c := token[0]
+	return (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || c == '\'' || c == '"' || c == '*'
+}
+
+func readLiteralString(content []byte, i int) (string, int) {
+	var b strings.Builder
+	depth := 0
+
+	for i < len(content) {
+		c := content[i]
+		switch c {
+		case '(':
+			if depth > 0 {
+				b.WriteByte(c)
+			}
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return b.String(), i + 1
+			}
+			b.WriteByte(c)
+		case '\\':
+			i++
+			if i >= len(content) {
+				return b.String(), i
+			}
+			switch e := content[i]; e {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'b', 'f':
+			case '\r', '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					value := 0
+					for j := 0; j < 3 && i < len(content) && content[i] >= '0' && content[i] <= '7'; j++ {
+						value = value*8 + int(content[i]-'0')
+						i++
+					}
+					i--
+					writeLatin1(&b, byte(value))
+				} else {
+					b.WriteByte(e)
+				}
+			}
+		default:
+			writeLatin1(&b, c)
+		}
+		i++
+	}
+
+	return b.String(), i
+}
+
+func readHexString(content []byte, i int) (string, int) {
+	end := bytes.IndexByte(content[i:], '>')
+	if end < 0 {
+		return "", len(content)
+	}
+
+	var digits []byte
+	for _, c := range content[i+1 : i+end] {
+		if (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+
+	var b strings.Builder
+	for j := 0; j < len(digits); j += 2 {
+		writeLatin1(&b, hexValue(digits[j])<<4|hexValue(digits[j+1]))
+	}
+	return b.String(), i + end + 1
+}
+
+func hexValue(c byte) byte {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+func writeLatin1(b *strings.Builder, c byte) {
+	if c < 0x20 && c != '\n' && c != '\t' {
+		return
+	}
+	b.WriteRune(rune(c))
+}
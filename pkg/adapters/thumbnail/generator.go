@@ -0,0 +1,166 @@
+package thumbnail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"sort"
+
+	"file-storage-go/pkg/domain"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+const (
+	jpegQuality       = 80
+	maxSourcePixels   = 50_000_000
+	thumbnailBlobPath = "%s/thumbnails/%d"
+)
+
+var supportedTypes = map[string]func(io.Reader) (image.Image, error){
+	"image/jpeg": jpeg.Decode,
+	"image/png":  png.Decode,
+	"image/gif":  gif.Decode,
+	"image/webp": webp.Decode,
+}
+
+var configDecoders = map[string]func(io.Reader) (image.Config, error){
+	"image/jpeg": jpeg.DecodeConfig,
+	"image/png":  png.DecodeConfig,
+	"image/gif":  gif.DecodeConfig,
+	"image/webp": webp.DecodeConfig,
+}
+
+// Generator renders thumbnails of scanned images into derived blobs next to
+// the original file. Thumbnails fit within a size x size box and keep the
+// aspect ratio of the original.
+type Generator struct {
+	fileStorage domain.FileStorage
+	sizes       []int
+}
+
+func NewGenerator(fileStorage domain.FileStorage, sizes []int) *Generator {
+	sorted := append([]int(nil), sizes...)
+	sort.Ints(sorted)
+
+	return &Generator{
+		fileStorage: fileStorage,
+		sizes:       sorted,
+	}
+}
+
+func (g *Generator) Name() string {
+	return "thumbnail"
+}
+
+func (g *Generator) Sizes() []int {
+	return g.sizes
+}
+
+func (g *Generator) blobID(fileID string, size int) string {
+	return fmt.Sprintf(thumbnailBlobPath, fileID, size)
+}
+
+func (g *Generator) Process(ctx context.Context, fileInfo *domain.FileInfo) error {
+	decode, ok := supportedTypes[fileInfo.DetectedMimeType]
+	if !ok {
+		return nil
+	}
+
+	src, err := g.readImage(ctx, fileInfo.ID, fileInfo.DetectedMimeType, decode)
+	if err != nil {
+		return err
+	}
+
+	for _, size := range g.sizes {
+		var buf bytes.Buffer
+		if err := encode(&buf, scale(src, size)); err != nil {
+			return fmt.Errorf("failed to encode %dpx thumbnail: %w", size, err)
+		}
+		if err := g.fileStorage.Upload(ctx, g.blobID(fileInfo.ID, size), &buf); err != nil {
+			return fmt.Errorf("failed to store %dpx thumbnail: %w", size, err)
+		}
+	}
+
+	return nil
+}
+
+func (g *Generator) readImage(ctx context.Context, fileID, mimeType string, decode func(io.Reader) (image.Image, error)) (image.Image, error) {
+	reader, err := g.fileStorage.Download(ctx, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+
+	cfg, err := configDecoders[mimeType](bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image header: %w", err)
+	}
+	if cfg.Width*cfg.Height > maxSourcePixels {
+		return nil, fmt.Errorf("image too large for thumbnail generation: %dx%d", cfg.Width, cfg.Height)
+	}
+
+	img, err := decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return img, nil
+}
+
+func (g *Generator) OpenThumbnail(ctx context.Context, fileID string, size int) (io.ReadCloser, error) {
+	return g.fileStorage.Download(ctx, g.blobID(fileID, size))
+}
+
+func (g *Generator) DeleteThumbnails(ctx context.Context, fileID string) error {
+	for _, size := range g.sizes {
+		err := g.fileStorage.Delete(ctx, g.blobID(fileID, size))
+		if err != nil && !errors.Is(err, domain.ErrFileNotFound) {
+			return fmt.Errorf("failed to delete %dpx thumbnail: %w", size, err)
+		}
+	}
+	return nil
+}
+
+func scale(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= size && height <= size {
+		return src
+	}
+
+	if width >= height {
+		height = max(1, height*size/width)
+		width = size
+	} else {
+		width = max(1, width*size/height)
+		height = size
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+	return dst
+}
+
+func encode(w io.Writer, img image.Image) error {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: jpegQuality})
+	}
+	return png.Encode(w, img)
+}
+
+var (
+	_ domain.PostScanStage  = (*Generator)(nil)
+	_ domain.ThumbnailStore = (*Generator)(nil)
+)
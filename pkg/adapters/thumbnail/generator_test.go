@@ -0,0 +1,119 @@
+package thumbnail
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"file-storage-go/pkg/adapters/storage"
+	"file-storage-go/pkg/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodePNG(t *testing.T, width, height int, alpha uint8) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 100, A: alpha})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestGenerator_Process(t *testing.T) {
+	fileStorage := storage.NewMockStorage()
+	ctx := context.Background()
+	require.NoError(t, fileStorage.Upload(ctx, "img", bytes.NewReader(encodePNG(t, 400, 200, 255))))
+
+	g := NewGenerator(fileStorage, []int{256, 64})
+	assert.Equal(t, []int{64, 256}, g.Sizes())
+
+	err := g.Process(ctx, &domain.FileInfo{ID: "img", DetectedMimeType: "image/png"})
+	require.NoError(t, err)
+
+	reader, err := g.OpenThumbnail(ctx, "img", 64)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	thumb, format, err := image.Decode(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "jpeg", format)
+	assert.Equal(t, 64, thumb.Bounds().Dx())
+	assert.Equal(t, 32, thumb.Bounds().Dy())
+}
+
+func TestGenerator_KeepsTransparencyAsPNG(t *testing.T) {
+	fileStorage := storage.NewMockStorage()
+	ctx := context.Background()
+	require.NoError(t, fileStorage.Upload(ctx, "img", bytes.NewReader(encodePNG(t, 100, 300, 128))))
+
+	g := NewGenerator(fileStorage, []int{30})
+	require.NoError(t, g.Process(ctx, &domain.FileInfo{ID: "img", DetectedMimeType: "image/png"}))
+
+	reader, err := g.OpenThumbnail(ctx, "img", 30)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	thumb, format, err := image.Decode(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "png", format)
+	assert.Equal(t, 10, thumb.Bounds().Dx())
+	assert.Equal(t, 30, thumb.Bounds().Dy())
+}
+
+func TestGenerator_DoesNotUpscale(t *testing.T) {
+	fileStorage := storage.NewMockStorage()
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 20, 10)), nil))
+	require.NoError(t, fileStorage.Upload(ctx, "small", &buf))
+
+	g := NewGenerator(fileStorage, []int{64})
+	require.NoError(t, g.Process(ctx, &domain.FileInfo{ID: "small", DetectedMimeType: "image/jpeg"}))
+
+	reader, err := g.OpenThumbnail(ctx, "small", 64)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	thumb, _, err := image.Decode(reader)
+	require.NoError(t, err)
+	assert.Equal(t, 20, thumb.Bounds().Dx())
+}
+
+func TestGenerator_SkipsNonImages(t *testing.T) {
+	fileStorage := storage.NewMockStorage()
+	ctx := context.Background()
+	require.NoError(t, fileStorage.Upload(ctx, "doc", strings.NewReader("plain text")))
+
+	g := NewGenerator(fileStorage, []int{64})
+	require.NoError(t, g.Process(ctx, &domain.FileInfo{ID: "doc", DetectedMimeType: "text/plain"}))
+
+	_, err := g.OpenThumbnail(ctx, "doc", 64)
+	assert.ErrorIs(t, err, domain.ErrFileNotFound)
+}
+
+func TestGenerator_DeleteThumbnails(t *testing.T) {
+	fileStorage := storage.NewMockStorage()
+	ctx := context.Background()
+	require.NoError(t, fileStorage.Upload(ctx, "img", bytes.NewReader(encodePNG(t, 100, 100, 255))))
+
+	g := NewGenerator(fileStorage, []int{16, 32})
+	require.NoError(t, g.Process(ctx, &domain.FileInfo{ID: "img", DetectedMimeType: "image/png"}))
+	require.NoError(t, g.DeleteThumbnails(ctx, "img"))
+
+	for _, size := range g.Sizes() {
+		_, err := g.OpenThumbnail(ctx, "img", size)
+		assert.ErrorIs(t, err, domain.ErrFileNotFound)
+	}
+}
@@ -0,0 +1,96 @@
+package vault
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+type TransitKeyProvider struct {
+	client  *VaultClient
+	mount   string
+	keyName string
+}
+
+func NewTransitKeyProvider(client *VaultClient, mount, keyName string) *TransitKeyProvider {
+	return &TransitKeyProvider{
+		client:  client,
+		mount:   strings.Trim(mount, "/"),
+		keyName: keyName,
+	}
+}
+
+func (p *TransitKeyProvider) GenerateDataKey(ctx context.Context) ([]byte, string, error) {
+	path := fmt.Sprintf("%s/datakey/plaintext/%s", p.mount, p.keyName)
+	secret, err := p.client.client.Logical().WriteWithContext(ctx, path, map[string]interface{}{
+		"bits": 256,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+	if secret == nil {
+		return nil, "", fmt.Errorf("empty response generating data key")
+	}
+
+	plaintext, err := decodeTransitPlaintext(secret.Data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	wrapped, ok := secret.Data["ciphertext"].(string)
+	if !ok {
+		return nil, "", fmt.Errorf("invalid ciphertext format from vault")
+	}
+
+	return plaintext, wrapped, nil
+}
+
+func (p *TransitKeyProvider) DecryptDataKey(ctx context.Context, wrapped string) ([]byte, error) {
+	path := fmt.Sprintf("%s/decrypt/%s", p.mount, p.keyName)
+	secret, err := p.client.client.Logical().WriteWithContext(ctx, path, map[string]interface{}{
+		"ciphertext": wrapped,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key: %w", err)
+	}
+	if secret == nil {
+		return nil, fmt.Errorf("empty response decrypting data key")
+	}
+
+	return decodeTransitPlaintext(secret.Data)
+}
+
+func (p *TransitKeyProvider) RewrapDataKey(ctx context.Context, wrapped string) (string, error) {
+	path := fmt.Sprintf("%s/rewrap/%s", p.mount, p.keyName)
+	secret, err := p.client.client.Logical().WriteWithContext(ctx, path, map[string]interface{}{
+		"ciphertext": wrapped,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to rewrap data key: %w", err)
+	}
+	if secret == nil {
+		return "", fmt.Errorf("empty response rewrapping data key")
+	}
+
+	rewrapped, ok := secret.Data["ciphertext"].(string)
+	if !ok {
+		return "", fmt.Errorf("invalid ciphertext format from vault")
+	}
+
+	return rewrapped, nil
+}
+
+func decodeTransitPlaintext(data map[string]interface{}) ([]byte, error) {
+	encoded, ok := data["plaintext"].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid plaintext format from vault")
+	}
+
+	plaintext, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode data key: %w", err)
+	}
+
+	return plaintext, nil
+}
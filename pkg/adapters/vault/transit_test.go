@@ -0,0 +1,76 @@
+package vault
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransitKeyProvider(t *testing.T) {
+	dataKey := []byte("0123456789abcdef0123456789abcdef")
+	encodedKey := base64.StdEncoding.EncodeToString(dataKey)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+
+		switch r.URL.Path {
+		case "/v1/transit/datakey/plaintext/files":
+			assert.EqualValues(t, 256, body["bits"])
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{
+					"plaintext":  encodedKey,
+					"ciphertext": "vault:v1:wrapped",
+				},
+			})
+		case "/v1/transit/decrypt/files":
+			assert.Equal(t, "vault:v1:wrapped", body["ciphertext"])
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{
+					"plaintext": encodedKey,
+				},
+			})
+		case "/v1/transit/rewrap/files":
+			assert.Equal(t, "vault:v1:wrapped", body["ciphertext"])
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{
+					"ciphertext": "vault:v2:wrapped",
+				},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewVaultClient(server.URL, "test-token")
+	require.NoError(t, err)
+
+	provider := NewTransitKeyProvider(client, "/transit/", "files")
+	ctx := context.Background()
+
+	t.Run("GenerateDataKey", func(t *testing.T) {
+		plaintext, wrapped, err := provider.GenerateDataKey(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, dataKey, plaintext)
+		assert.Equal(t, "vault:v1:wrapped", wrapped)
+	})
+
+	t.Run("DecryptDataKey", func(t *testing.T) {
+		plaintext, err := provider.DecryptDataKey(ctx, "vault:v1:wrapped")
+		require.NoError(t, err)
+		assert.Equal(t, dataKey, plaintext)
+	})
+
+	t.Run("RewrapDataKey", func(t *testing.T) {
+		rewrapped, err := provider.RewrapDataKey(ctx, "vault:v1:wrapped")
+		require.NoError(t, err)
+		assert.Equal(t, "vault:v2:wrapped", rewrapped)
+	})
+}
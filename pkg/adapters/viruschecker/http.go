@@ -8,6 +8,11 @@ import (
 	"io"
 	"net/http"
 	"time"
+
+	"file-storage-go/pkg/requestid"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type httpResponse struct {
@@ -25,6 +30,11 @@ func NewHTTPVirusChecker(baseURL string) *HTTPVirusChecker {
 	return &HTTPVirusChecker{
 		client: &http.Client{
 			Timeout: 30 * time.Second,
+			// Requests outside of a traced operation, e.g. health checks,
+			// are not traced.
+			Transport: otelhttp.NewTransport(http.DefaultTransport, otelhttp.WithFilter(func(r *http.Request) bool {
+				return trace.SpanContextFromContext(r.Context()).IsValid()
+			})),
 		},
 		baseURL: baseURL,
 	}
@@ -40,6 +50,7 @@ func (c *HTTPVirusChecker) CheckFile(ctx context.Context, reader io.Reader) (boo
 	if err != nil {
 		return false, fmt.Errorf("failed to create request: %w", err)
 	}
+	requestid.SetHeader(req)
 
 	resp, err := c.client.Do(req)
 	if err != nil {
@@ -62,3 +73,23 @@ func (c *HTTPVirusChecker) CheckFile(ctx context.Context, reader io.Reader) (boo
 
 	return result.Clean, nil
 }
+
+// Check reports whether the virus checker answers. Any response below 500
+// counts, since the endpoint only accepts file uploads.
+func (c *HTTPVirusChecker) Check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,347 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"file-storage-go/pkg/domain"
+	"file-storage-go/pkg/ecsslog"
+
+	"github.com/google/uuid"
+)
+
+const (
+	HeaderID        = "X-Webhook-Id"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+
+	defaultMaxAttempts  = 8
+	defaultBaseBackoff  = 10 * time.Second
+	defaultMaxBackoff   = time.Hour
+	defaultTimeout      = 10 * time.Second
+	defaultPollInterval = time.Second
+	defaultBatchSize    = 20
+
+	maxErrorBodyBytes = 512
+)
+
+type Config struct {
+	// Secret signs deliveries of subscriptions without their own secret.
+	Secret       string
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	Timeout      time.Duration
+	PollInterval time.Duration
+	// AllowInternalAddresses lets deliveries reach loopback, link-local and
+	// private addresses, e.g. a receiver in a test.
+	AllowInternalAddresses bool
+	// Logger defaults to slog.Default().
+	Logger *slog.Logger
+}
+
+// Event is the JSON body of a delivery.
+type Event struct {
+	Type       string            `json:"type"`
+	OccurredAt time.Time         `json:"occurredAt"`
+	Job        *domain.UploadJob `json:"job"`
+	File       *EventFile        `json:"file,omitempty"`
+}
+
+type EventFile struct {
+	ID                 string `json:"id"`
+	FileType           string `json:"fileType,omitempty"`
+	LinkedResourceType string `json:"linkedResourceType,omitempty"`
+	LinkedResourceID   string `json:"linkedResourceID,omitempty"`
+}
+
+// Dispatcher records a delivery per matching subscription when an upload job
+// finishes and sends the pending deliveries with exponential backoff until
+// they succeed or run out of attempts. Deliveries are claimed with a lease,
+// so several replicas can run a dispatcher against the same repository.
+type Dispatcher struct {
+	repo         domain.WebhookRepository
+	client       *http.Client
+	secret       string
+	maxAttempts  int
+	baseBackoff  time.Duration
+	maxBackoff   time.Duration
+	pollInterval time.Duration
+	lease        time.Duration
+	logger       *slog.Logger
+}
+
+func NewDispatcher(repo domain.WebhookRepository, config Config) *Dispatcher {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultMaxAttempts
+	}
+	if config.BaseBackoff <= 0 {
+		config.BaseBackoff = defaultBaseBackoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = defaultMaxBackoff
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultPollInterval
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+
+	return &Dispatcher{
+		repo:         repo,
+		client:       newClient(config.Timeout, config.AllowInternalAddresses),
+		secret:       config.Secret,
+		maxAttempts:  config.MaxAttempts,
+		baseBackoff:  config.BaseBackoff,
+		maxBackoff:   config.MaxBackoff,
+		pollInterval: config.PollInterval,
+		// A claimed delivery is retried by another replica if its attempt
+		// has not been recorded well after the request timed out.
+		lease:  2 * config.Timeout,
+		logger: config.Logger,
+	}
+}
+
+// newClient returns a client that neither follows redirects nor, unless
+// allowInternal is set, connects to internal addresses. It does not use a
+// proxy, which would connect on its behalf.
+func newClient(timeout time.Duration, allowInternal bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowInternal {
+		dialer.Control = checkDialAddress
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Sign returns the X-Webhook-Signature value for a delivery: the hex encoded
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the secret.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Enqueue records a delivery of the job's event for every subscription of the
+// job or of its file's linked resource type. Jobs that have not finished, and
+// rescans that completed again, are ignored. The deliveries are stored through
+// repos, so that they are recorded in the unit of work that updated the job.
+func (d *Dispatcher) Enqueue(ctx context.Context, repos domain.Repositories, job *domain.UploadJob) error {
+	var eventType string
+	switch job.Status {
+	case domain.JobStatusCompleted:
+		if job.Rescan {
+			return nil
+		}
+		eventType = domain.WebhookEventJobCompleted
+	case domain.JobStatusFailed:
+		eventType = domain.WebhookEventJobFailed
+	default:
+		return nil
+	}
+
+	event := Event{Type: eventType, OccurredAt: job.UpdatedAt, Job: job}
+	if job.FileID != "" {
+		fileInfo, err := repos.FileInfos.Get(ctx, job.FileID)
+		if err != nil {
+			return fmt.Errorf("failed to get file info: %w", err)
+		}
+		if fileInfo != nil {
+			event.File = &EventFile{
+				ID:                 fileInfo.ID,
+				FileType:           fileInfo.FileType,
+				LinkedResourceType: fileInfo.LinkedResourceType,
+				LinkedResourceID:   fileInfo.LinkedResourceID,
+			}
+		}
+	}
+
+	linkedResourceType := ""
+	if event.File != nil {
+		linkedResourceType = event.File.LinkedResourceType
+	}
+	subscriptions, err := repos.Webhooks.MatchingSubscriptions(ctx, job.ID, linkedResourceType)
+	if err != nil {
+		return fmt.Errorf("failed to get webhook subscriptions: %w", err)
+	}
+	if len(subscriptions) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook event: %w", err)
+	}
+
+	now := time.Now()
+	for _, subscription := range subscriptions {
+		delivery := &domain.WebhookDelivery{
+			ID:             uuid.New().String(),
+			SubscriptionID: subscription.ID,
+			JobID:          job.ID,
+			URL:            subscription.URL,
+			EventType:      eventType,
+			Payload:        payload,
+			Status:         domain.WebhookDeliveryPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+		}
+		if err := repos.Webhooks.CreateDelivery(ctx, delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Dispatcher) Start(ctx context.Context) {
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.DispatchDue(ctx); err != nil {
+				d.logger.LogAttrs(ctx, slog.LevelError, "Error dispatching webhooks",
+					ecsslog.Action("webhook.dispatch"), ecsslog.Err(err))
+			}
+		}
+	}
+}
+
+// DispatchDue sends the deliveries whose next attempt is due.
+func (d *Dispatcher) DispatchDue(ctx context.Context) error {
+	deliveries, err := d.repo.ClaimDueDeliveries(ctx, time.Now(), d.lease, defaultBatchSize)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.attempt(ctx, delivery)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+func (d *Dispatcher) attempt(ctx context.Context, delivery *domain.WebhookDelivery) {
+	statusCode, err := d.send(ctx, delivery)
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.LastStatusCode = statusCode
+	delivery.LastError = ""
+	switch {
+	case err == nil:
+		delivery.Status = domain.WebhookDeliverySucceeded
+	case delivery.Attempts >= d.maxAttempts:
+		delivery.Status = domain.WebhookDeliveryFailed
+		delivery.LastError = err.Error()
+	default:
+		delivery.NextAttemptAt = now.Add(d.backoff(delivery.Attempts))
+		delivery.LastError = err.Error()
+	}
+
+	if err != nil {
+		d.logger.LogAttrs(ctx, slog.LevelWarn, "Webhook delivery failed",
+			ecsslog.Action("webhook.deliver"), slog.String("webhook.delivery.id", delivery.ID), slog.String("url.full", delivery.URL),
+			slog.Int("webhook.delivery.attempt", delivery.Attempts), ecsslog.Err(err))
+	}
+	if err := d.repo.UpdateDelivery(context.WithoutCancel(ctx), delivery); err != nil {
+		d.logger.LogAttrs(ctx, slog.LevelError, "Error recording webhook delivery",
+			ecsslog.Action("webhook.deliver"), slog.String("webhook.delivery.id", delivery.ID), ecsslog.Err(err))
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery *domain.WebhookDelivery) (int, error) {
+	secret := delivery.Secret
+	if secret == "" {
+		secret = d.secret
+	}
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, delivery.ID)
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+		return resp.StatusCode, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff doubles the delay after every failed attempt, up to maxBackoff.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.baseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= d.maxBackoff {
+			return d.maxBackoff
+		}
+	}
+	return delay
+}
+
+// Redeliver resets a delivery so that it is sent again with a fresh set of
+// attempts. It returns nil if the delivery does not exist.
+func (d *Dispatcher) Redeliver(ctx context.Context, deliveryID string) (*domain.WebhookDelivery, error) {
+	delivery, err := d.repo.GetDelivery(ctx, deliveryID)
+	if err != nil || delivery == nil {
+		return nil, err
+	}
+
+	delivery.Status = domain.WebhookDeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now()
+	if err := d.repo.UpdateDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
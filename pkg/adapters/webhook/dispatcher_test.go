@@ -0,0 +1,277 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"file-storage-go/pkg/adapters/repository"
+	"file-storage-go/pkg/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type receivedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver is a local webhook endpoint that fails the first failures requests.
+type receiver struct {
+	server   *httptest.Server
+	failures int
+
+	mu       sync.Mutex
+	received []receivedWebhook
+}
+
+func newReceiver(t *testing.T, failures int) *receiver {
+	t.Helper()
+	r := &receiver{failures: failures}
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.received = append(r.received, receivedWebhook{header: req.Header.Clone(), body: body})
+		if len(r.received) <= r.failures {
+			http.Error(w, "try again later", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+func (r *receiver) requests() []receivedWebhook {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]receivedWebhook(nil), r.received...)
+}
+
+type webhookFixture struct {
+	repo       *repository.InMemoryWebhookRepo
+	jobRepo    *NotifyingJobRepo
+	unitOfWork *NotifyingUnitOfWork
+	dispatcher *Dispatcher
+	job        *domain.UploadJob
+}
+
+func newWebhookFixture(t *testing.T, config Config) *webhookFixture {
+	t.Helper()
+	ctx := context.Background()
+	now := time.Now()
+
+	fileInfoRepo := repository.NewInMemoryFileInfoRepo()
+	require.NoError(t, fileInfoRepo.Create(ctx, &domain.FileInfo{ID: "file-1", FileType: "application/pdf", LinkedResourceType: "invoice", LinkedResourceID: "inv-1", CreatedAt: now, UpdatedAt: now}))
+
+	repo := repository.NewInMemoryWebhookRepo()
+	config.AllowInternalAddresses = true
+	dispatcher := NewDispatcher(repo, config)
+	inMemoryJobRepo := repository.NewInMemoryJobRepo()
+	unitOfWork := NewNotifyingUnitOfWork(repository.NewInMemoryUnitOfWork(inMemoryJobRepo, fileInfoRepo, nil, repo), dispatcher)
+	jobRepo := NewNotifyingJobRepo(inMemoryJobRepo, unitOfWork)
+
+	job := &domain.UploadJob{ID: "job-1", CreatedByUserId: "user-1", FileID: "file-1", Status: domain.JobStatusVirusChecking, CreatedAt: now, UpdatedAt: now}
+	require.NoError(t, jobRepo.Create(ctx, job))
+
+	return &webhookFixture{repo: repo, jobRepo: jobRepo, unitOfWork: unitOfWork, dispatcher: dispatcher, job: job}
+}
+
+func TestDispatcher_DeliversSignedEvent(t *testing.T) {
+	ctx := context.Background()
+	receiver := newReceiver(t, 0)
+	f := newWebhookFixture(t, Config{Secret: "global-secret"})
+
+	require.NoError(t, f.repo.CreateSubscription(ctx, &domain.WebhookSubscription{ID: "per-job", URL: receiver.server.URL, JobID: "job-1", CreatedAt: time.Now()}))
+	require.NoError(t, f.repo.CreateSubscription(ctx, &domain.WebhookSubscription{ID: "invoices", URL: receiver.server.URL, Secret: "invoice-secret", LinkedResourceType: "invoice", CreatedAt: time.Now()}))
+	require.NoError(t, f.repo.CreateSubscription(ctx, &domain.WebhookSubscription{ID: "contracts", URL: receiver.server.URL, LinkedResourceType: "contract", CreatedAt: time.Now()}))
+
+	f.job.Status = domain.JobStatusCompleted
+	require.NoError(t, f.jobRepo.Update(ctx, f.job))
+	require.NoError(t, f.dispatcher.DispatchDue(ctx))
+
+	requests := receiver.requests()
+	require.Len(t, requests, 2)
+
+	secrets := map[string]string{}
+	for _, req := range requests {
+		assert.Equal(t, domain.WebhookEventJobCompleted, req.header.Get(HeaderEvent))
+		timestamp, err := strconv.ParseInt(req.header.Get(HeaderTimestamp), 10, 64)
+		require.NoError(t, err)
+
+		signature := req.header.Get(HeaderSignature)
+		for _, secret := range []string{"global-secret", "invoice-secret"} {
+			if signature == Sign(secret, timestamp, req.body) {
+				secrets[secret] = req.header.Get(HeaderID)
+			}
+		}
+
+		var event Event
+		require.NoError(t, json.Unmarshal(req.body, &event))
+		assert.Equal(t, domain.WebhookEventJobCompleted, event.Type)
+		assert.Equal(t, "job-1", event.Job.ID)
+		require.NotNil(t, event.File)
+		assert.Equal(t, "invoice", event.File.LinkedResourceType)
+		assert.Equal(t, "inv-1", event.File.LinkedResourceID)
+	}
+	assert.Len(t, secrets, 2, "each subscription is signed with its own secret or the global one")
+
+	deliveries, err := f.repo.ListDeliveries(ctx, domain.WebhookDeliveryFilter{Status: domain.WebhookDeliverySucceeded})
+	require.NoError(t, err)
+	assert.Len(t, deliveries, 2)
+}
+
+func TestDispatcher_RetriesWithBackoffAndRedelivers(t *testing.T) {
+	ctx := context.Background()
+	receiver := newReceiver(t, 3)
+	f := newWebhookFixture(t, Config{Secret: "secret", MaxAttempts: 3, BaseBackoff: time.Millisecond, MaxBackoff: 4 * time.Millisecond})
+
+	require.NoError(t, f.repo.CreateSubscription(ctx, &domain.WebhookSubscription{ID: "per-job", URL: receiver.server.URL, JobID: "job-1", CreatedAt: time.Now()}))
+
+	f.job.Status = domain.JobStatusFailed
+	f.job.Error = "Virus detected"
+	require.NoError(t, f.jobRepo.Update(ctx, f.job))
+
+	var delivery *domain.WebhookDelivery
+	require.Eventually(t, func() bool {
+		require.NoError(t, f.dispatcher.DispatchDue(ctx))
+		deliveries, err := f.repo.ListDeliveries(ctx, domain.WebhookDeliveryFilter{JobID: "job-1"})
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		delivery = deliveries[0]
+		return delivery.Status != domain.WebhookDeliveryPending
+	}, 2*time.Second, 5*time.Millisecond)
+
+	assert.Equal(t, domain.WebhookDeliveryFailed, delivery.Status)
+	assert.Equal(t, 3, delivery.Attempts)
+	assert.Equal(t, http.StatusServiceUnavailable, delivery.LastStatusCode)
+	assert.Contains(t, delivery.LastError, "try again later")
+	assert.Equal(t, domain.WebhookEventJobFailed, receiver.requests()[0].header.Get(HeaderEvent))
+
+	redelivered, err := f.dispatcher.Redeliver(ctx, delivery.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.WebhookDeliveryPending, redelivered.Status)
+	require.NoError(t, f.dispatcher.DispatchDue(ctx))
+
+	delivery, err = f.repo.GetDelivery(ctx, delivery.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.WebhookDeliverySucceeded, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Len(t, receiver.requests(), 4)
+
+	redelivered, err = f.dispatcher.Redeliver(ctx, "unknown")
+	require.NoError(t, err)
+	assert.Nil(t, redelivered)
+}
+
+func TestDispatcher_IgnoresUnfinishedJobs(t *testing.T) {
+	ctx := context.Background()
+	f := newWebhookFixture(t, Config{})
+	require.NoError(t, f.repo.CreateSubscription(ctx, &domain.WebhookSubscription{ID: "per-job", URL: "http://127.0.0.1:1", JobID: "job-1", CreatedAt: time.Now()}))
+
+	f.job.Status = domain.JobStatusVirusCheckPending
+	require.NoError(t, f.jobRepo.Update(ctx, f.job))
+
+	f.job.Status = domain.JobStatusCompleted
+	f.job.Rescan = true
+	require.NoError(t, f.jobRepo.Update(ctx, f.job), "a rescan that completes again is not announced")
+
+	deliveries, err := f.repo.ListDeliveries(ctx, domain.WebhookDeliveryFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, deliveries)
+}
+
+func TestDispatcher_DiscardsDeliveriesOfFailedUnitOfWork(t *testing.T) {
+	ctx := context.Background()
+	f := newWebhookFixture(t, Config{})
+	require.NoError(t, f.repo.CreateSubscription(ctx, &domain.WebhookSubscription{ID: "per-job", URL: "http://127.0.0.1:1", JobID: "job-1", CreatedAt: time.Now()}))
+
+	failure := errors.New("file info update failed")
+	err := f.unitOfWork.Do(ctx, func(repos domain.Repositories) error {
+		job := *f.job
+		job.Status = domain.JobStatusCompleted
+		require.NoError(t, repos.Jobs.Update(ctx, &job))
+		return failure
+	})
+	require.ErrorIs(t, err, failure)
+
+	deliveries, err := f.repo.ListDeliveries(ctx, domain.WebhookDeliveryFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, deliveries, "deliveries are only recorded together with the job update")
+}
+
+func TestDispatcher_Backoff(t *testing.T) {
+	d := NewDispatcher(nil, Config{BaseBackoff: time.Second, MaxBackoff: 10 * time.Second})
+	assert.Equal(t, time.Second, d.backoff(1))
+	assert.Equal(t, 2*time.Second, d.backoff(2))
+	assert.Equal(t, 8*time.Second, d.backoff(4))
+	assert.Equal(t, 10*time.Second, d.backoff(5))
+	assert.Equal(t, 10*time.Second, d.backoff(30))
+}
+
+func TestCheckURL(t *testing.T) {
+	assert.Error(t, CheckURL("https://hooks.example.com/files", nil))
+
+	allowed := []string{"hooks.example.com", "*.internal.example.com", "127.0.0.1", "10.0.0.1"}
+	assert.NoError(t, CheckURL("https://hooks.example.com/files", allowed))
+	assert.NoError(t, CheckURL("http://billing.internal.example.com:8080/files", allowed))
+	assert.Error(t, CheckURL("ftp://hooks.example.com/files", allowed))
+	assert.Error(t, CheckURL("/files", allowed))
+	assert.Error(t, CheckURL("https://internal.example.com/files", allowed))
+	assert.Error(t, CheckURL("https://evil.com/hooks.example.com", allowed))
+	assert.Error(t, CheckURL("http://127.0.0.1/files", allowed))
+	assert.Error(t, CheckURL("http://10.0.0.1/files", allowed))
+}
+
+func TestCheckDialAddress(t *testing.T) {
+	assert.NoError(t, checkDialAddress("tcp4", "93.184.216.34:443", nil))
+	assert.Error(t, checkDialAddress("tcp4", "127.0.0.1:80", nil))
+	assert.Error(t, checkDialAddress("tcp4", "169.254.169.254:80", nil))
+	assert.Error(t, checkDialAddress("tcp4", "192.168.1.10:80", nil))
+	assert.Error(t, checkDialAddress("tcp6", "[::1]:80", nil))
+	assert.Error(t, checkDialAddress("tcp6", "[fd00::1]:80", nil))
+	assert.Error(t, checkDialAddress("tcp4", "0.0.0.0:80", nil))
+}
+
+func TestDispatcher_RejectsInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("delivery reached an internal address")
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodPost, server.URL, nil)
+	require.NoError(t, err)
+	_, err = newClient(time.Second, false).Do(req)
+	assert.Error(t, err)
+}
+
+func TestDispatcher_DoesNotFollowRedirects(t *testing.T) {
+	followed := false
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		followed = true
+	}))
+	defer target.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusTemporaryRedirect)
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodPost, server.URL, nil)
+	require.NoError(t, err)
+	resp, err := newClient(time.Second, true).Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusTemporaryRedirect, resp.StatusCode)
+	assert.False(t, followed)
+}
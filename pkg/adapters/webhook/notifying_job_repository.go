@@ -0,0 +1,38 @@
+package webhook
+
+import (
+	"context"
+
+	"file-storage-go/pkg/domain"
+)
+
+// NotifyingJobRepo runs every job update in a NotifyingUnitOfWork, so that
+// the webhook deliveries of jobs updated to COMPLETED or FAILED are recorded
+// with the update, whichever component made the change.
+type NotifyingJobRepo struct {
+	domain.UploadJobRepository
+	unitOfWork *NotifyingUnitOfWork
+}
+
+func NewNotifyingJobRepo(inner domain.UploadJobRepository, unitOfWork *NotifyingUnitOfWork) *NotifyingJobRepo {
+	return &NotifyingJobRepo{
+		UploadJobRepository: inner,
+		unitOfWork:          unitOfWork,
+	}
+}
+
+func (r *NotifyingJobRepo) Update(ctx context.Context, job *domain.UploadJob) error {
+	return r.unitOfWork.Do(ctx, func(repos domain.Repositories) error {
+		return repos.Jobs.Update(ctx, job)
+	})
+}
+
+func (r *NotifyingJobRepo) UpdateIfStatus(ctx context.Context, job *domain.UploadJob, expected domain.JobStatus) (bool, error) {
+	var updated bool
+	err := r.unitOfWork.Do(ctx, func(repos domain.Repositories) error {
+		var err error
+		updated, err = repos.Jobs.UpdateIfStatus(ctx, job, expected)
+		return err
+	})
+	return updated, err
+}
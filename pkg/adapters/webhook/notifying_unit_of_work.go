@@ -0,0 +1,53 @@
+package webhook
+
+import (
+	"context"
+
+	"file-storage-go/pkg/domain"
+)
+
+// NotifyingUnitOfWork records webhook deliveries for the jobs a unit of work
+// updates to COMPLETED or FAILED in that same unit of work, so that a stored
+// update never loses its deliveries.
+type NotifyingUnitOfWork struct {
+	inner      domain.UnitOfWork
+	dispatcher *Dispatcher
+}
+
+func NewNotifyingUnitOfWork(inner domain.UnitOfWork, dispatcher *Dispatcher) *NotifyingUnitOfWork {
+	return &NotifyingUnitOfWork{
+		inner:      inner,
+		dispatcher: dispatcher,
+	}
+}
+
+func (u *NotifyingUnitOfWork) Do(ctx context.Context, fn func(repos domain.Repositories) error) error {
+	return u.inner.Do(ctx, func(repos domain.Repositories) error {
+		notifying := repos
+		notifying.Jobs = &notifyingJobs{UploadJobRepository: repos.Jobs, repos: repos, dispatcher: u.dispatcher}
+		return fn(notifying)
+	})
+}
+
+// notifyingJobs enqueues the deliveries of the jobs it updated through the
+// repositories of the unit of work.
+type notifyingJobs struct {
+	domain.UploadJobRepository
+	repos      domain.Repositories
+	dispatcher *Dispatcher
+}
+
+func (r *notifyingJobs) Update(ctx context.Context, job *domain.UploadJob) error {
+	if err := r.UploadJobRepository.Update(ctx, job); err != nil {
+		return err
+	}
+	return r.dispatcher.Enqueue(ctx, r.repos, job)
+}
+
+func (r *notifyingJobs) UpdateIfStatus(ctx context.Context, job *domain.UploadJob, expected domain.JobStatus) (bool, error) {
+	updated, err := r.UploadJobRepository.UpdateIfStatus(ctx, job, expected)
+	if err != nil || !updated {
+		return updated, err
+	}
+	return true, r.dispatcher.Enqueue(ctx, r.repos, job)
+}
@@ -0,0 +1,58 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// CheckURL validates a callback URL. It must be an absolute http or https URL
+// whose host is one of allowedHosts; entries starting with "*." match every
+// subdomain. Hosts that are internal IP addresses are rejected even if they
+// are allowed.
+func CheckURL(rawURL string, allowedHosts []string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("callback URL must be an absolute http or https URL")
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if ip := net.ParseIP(host); ip != nil && isInternalIP(ip) {
+		return errors.New("callback host is not allowed")
+	}
+	for _, allowed := range allowedHosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed {
+			return nil
+		}
+		if suffix, ok := strings.CutPrefix(allowed, "*"); ok && strings.HasPrefix(suffix, ".") && strings.HasSuffix(host, suffix) {
+			return nil
+		}
+	}
+	return errors.New("callback host is not allowed")
+}
+
+// checkDialAddress is a net.Dialer Control function that refuses connections
+// to internal IP addresses. It runs after DNS resolution, so an allowed host
+// that resolves to an internal address cannot be reached either.
+func checkDialAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || isInternalIP(ip) {
+		return fmt.Errorf("callback address %s is not allowed", host)
+	}
+	return nil
+}
+
+// isInternalIP reports whether ip is a loopback, link-local, private,
+// unspecified or multicast address.
+func isInternalIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsPrivate() ||
+		ip.IsUnspecified() || ip.IsMulticast()
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"file-storage-go/pkg/adapters/repository"
+	"file-storage-go/pkg/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createAPIKey(t *testing.T, repo domain.APIKeyRepository, id string, expiresAt *time.Time) string {
+	t.Helper()
+	key, keyHash, prefix, err := GenerateAPIKey()
+	require.NoError(t, err)
+	require.NoError(t, repo.Create(context.Background(), &domain.APIKey{
+		ID:        id,
+		Name:      "batch importer",
+		OwnerID:   "svc-importer",
+		Scopes:    []string{"files:write"},
+		Prefix:    prefix,
+		ExpiresAt: expiresAt,
+		CreatedBy: "admin",
+		CreatedAt: time.Now(),
+	}, keyHash))
+	return key
+}
+
+func TestAPIKeyVerifier(t *testing.T) {
+	repo := repository.NewInMemoryAPIKeyRepo()
+	verifier := NewAPIKeyVerifier(repo, slog.New(slog.DiscardHandler))
+	ctx := context.Background()
+
+	key := createAPIKey(t, repo, "key-1", nil)
+	assert.NotContains(t, HashAPIKey(key), key)
+
+	principal, err := verifier.Authenticate(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, "svc-importer", principal.UserID)
+	assert.Equal(t, []string{"files:write"}, principal.Scopes)
+	assert.Equal(t, "key-1", principal.Claims["api_key_id"])
+
+	keys, err := repo.List(ctx, "svc-importer")
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.NotNil(t, keys[0].LastUsedAt)
+
+	_, err = verifier.Authenticate(ctx, key+"x")
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+
+	_, err = verifier.Authenticate(ctx, "not-a-key")
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+
+	require.NoError(t, repo.Revoke(ctx, "key-1", time.Now()))
+	_, err = verifier.Authenticate(ctx, key)
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+}
+
+func TestAPIKeyVerifier_Expired(t *testing.T) {
+	repo := repository.NewInMemoryAPIKeyRepo()
+	verifier := NewAPIKeyVerifier(repo, slog.New(slog.DiscardHandler))
+
+	expired := time.Now().Add(-time.Hour)
+	key := createAPIKey(t, repo, "key-1", &expired)
+
+	_, err := verifier.Authenticate(context.Background(), key)
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+}
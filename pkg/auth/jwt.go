@@ -1,143 +1,327 @@
 package auth
 
 import (
-	"crypto/rsa"
-	"encoding/base64"
+	"context"
 	"encoding/json"
 	"fmt"
-	"math/big"
+	"log/slog"
 	"net/http"
 	"strings"
 	"sync"
 	"time"
 
+	"file-storage-go/pkg/domain"
+	"file-storage-go/pkg/ecsslog"
+
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type Claims struct {
-	UserId string `json:"user_id"`
+	UserId         string               `json:"user_id"`
+	RealmAccess    RoleClaim            `json:"realm_access"`
+	ResourceAccess map[string]RoleClaim `json:"resource_access"`
+	// Scope is the space separated list of granted OAuth scopes.
+	Scope string `json:"scope"`
 	jwt.RegisteredClaims
+
+	// Raw holds every claim of the token, including custom ones such as
+	// "companies" that policies refer to.
+	Raw map[string]any `json:"-"`
+}
+
+type RoleClaim struct {
+	Roles []string `json:"roles"`
+}
+
+func (c *Claims) UnmarshalJSON(data []byte) error {
+	type plainClaims Claims
+	if err := json.Unmarshal(data, (*plainClaims)(c)); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &c.Raw)
+}
+
+// Roles returns the Keycloak realm roles together with the client roles of
+// clientID.
+func (c *Claims) Roles(clientID string) []string {
+	roles := append([]string{}, c.RealmAccess.Roles...)
+	if client, ok := c.ResourceAccess[clientID]; ok {
+		roles = append(roles, client.Roles...)
+	}
+	return roles
+}
+
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+func (c *Claims) Principal(clientID string) *domain.Principal {
+	return &domain.Principal{
+		UserID: c.UserId,
+		Roles:  c.Roles(clientID),
+		Scopes: c.Scopes(),
+		Claims: c.Raw,
+	}
 }
 
 type KeycloakConfig struct {
 	RealmURL string
 	ClientID string
+	// Issuer is the expected "iss" claim. Defaults to RealmURL.
+	Issuer string
+	// Audience must be one of the token's "aud" values. Defaults to ClientID.
+	Audience string
+	// ClockSkew is the leeway applied to "exp", "nbf" and "iat".
+	ClockSkew time.Duration
+	// RefreshInterval is used for background JWKS refreshes when the JWKS
+	// response carries no Cache-Control max-age. Defaults to 15 minutes.
+	RefreshInterval time.Duration
+	// MinRefetchInterval limits how often an unknown kid triggers a JWKS fetch.
+	// Defaults to 30 seconds.
+	MinRefetchInterval time.Duration
+	// Metrics, if set, records the duration of JWKS fetches.
+	Metrics domain.MetricsCollector
+	// Logger defaults to slog.Default().
+	Logger *slog.Logger
 }
 
-type KeycloakPublicKey struct {
-	Kid string `json:"kid"`
-	Kty string `json:"kty"`
-	Alg string `json:"alg"`
-	Use string `json:"use"`
-	N   string `json:"n"`
-	E   string `json:"e"`
-}
+const (
+	defaultJWKSRefreshInterval    = 15 * time.Minute
+	defaultJWKSMinRefetchInterval = 30 * time.Second
+)
 
-type KeycloakKeys struct {
-	Keys []KeycloakPublicKey `json:"keys"`
-}
+var supportedSigningMethods = []string{"RS256", "PS256", "ES256"}
 
 type JWTVerifier struct {
 	config     KeycloakConfig
 	httpClient *http.Client
-	keys       map[string]KeycloakPublicKey
-	mu         sync.RWMutex
+	jwksURL    string
+
+	keys        map[string]*jsonWebKey
+	lastFetch   time.Time
+	nextRefresh time.Time
+	mu          sync.RWMutex
+	fetchMu     sync.Mutex
 }
 
 func NewJWTVerifier(config KeycloakConfig) *JWTVerifier {
+	if config.Issuer == "" {
+		config.Issuer = config.RealmURL
+	}
+	if config.Audience == "" {
+		config.Audience = config.ClientID
+	}
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = defaultJWKSRefreshInterval
+	}
+	if config.MinRefetchInterval <= 0 {
+		config.MinRefetchInterval = defaultJWKSMinRefetchInterval
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+
 	return &JWTVerifier{
 		config:     config,
 		httpClient: &http.Client{Timeout: 10 * time.Second},
-		keys:       make(map[string]KeycloakPublicKey),
+		jwksURL:    fmt.Sprintf("%s/protocol/openid-connect/certs", config.RealmURL),
+		keys:       make(map[string]*jsonWebKey),
+	}
+}
+
+// Start fetches the JWKS and keeps refreshing it in the background until ctx is
+// done. Refreshes follow the Cache-Control max-age of the JWKS response.
+func (v *JWTVerifier) Start(ctx context.Context) {
+	if err := v.fetchPublicKeys(ctx); err != nil {
+		v.config.Logger.LogAttrs(ctx, slog.LevelError, "Initial JWKS fetch failed",
+			ecsslog.Action("jwks.fetch"), slog.String("url.full", v.jwksURL), ecsslog.Err(err))
+	}
+
+	go func() {
+		for {
+			v.mu.RLock()
+			wait := time.Until(v.nextRefresh)
+			v.mu.RUnlock()
+			if wait < v.config.MinRefetchInterval {
+				wait = v.config.MinRefetchInterval
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+
+			if err := v.fetchPublicKeys(ctx); err != nil {
+				v.config.Logger.LogAttrs(ctx, slog.LevelError, "JWKS refresh failed",
+					ecsslog.Action("jwks.fetch"), slog.String("url.full", v.jwksURL), ecsslog.Err(err))
+			}
+		}
+	}()
+}
+
+// fetchPublicKeys replaces the key set with the current JWKS, so keys removed
+// by a rotation stop being accepted.
+func (v *JWTVerifier) fetchPublicKeys(ctx context.Context) error {
+	v.fetchMu.Lock()
+	defer v.fetchMu.Unlock()
+
+	return v.fetchPublicKeysLocked(ctx)
+}
+
+// refetchForUnknownKid fetches the JWKS unless it was fetched less than
+// MinRefetchInterval ago, so tokens with made-up kids cannot flood the
+// identity provider.
+func (v *JWTVerifier) refetchForUnknownKid(ctx context.Context) error {
+	v.fetchMu.Lock()
+	defer v.fetchMu.Unlock()
+
+	v.mu.RLock()
+	recentlyFetched := time.Since(v.lastFetch) < v.config.MinRefetchInterval
+	v.mu.RUnlock()
+	if recentlyFetched {
+		return nil
 	}
+
+	return v.fetchPublicKeysLocked(ctx)
 }
 
-func (v *JWTVerifier) fetchPublicKeys() error {
-	url := fmt.Sprintf("%s/protocol/openid-connect/certs", v.config.RealmURL)
-	resp, err := v.httpClient.Get(url)
+func (v *JWTVerifier) fetchPublicKeysLocked(ctx context.Context) (err error) {
+	now := time.Now()
+	if v.config.Metrics != nil {
+		defer func() {
+			status := "success"
+			if err != nil {
+				status = "error"
+			}
+			v.config.Metrics.RecordJWKSFetch(status, time.Since(now))
+		}()
+	}
+
+	v.mu.Lock()
+	v.lastFetch = now
+	v.nextRefresh = now.Add(v.config.MinRefetchInterval)
+	v.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.jwksURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+
+	resp, err := v.httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to fetch public keys: %w", err)
 	}
 	defer resp.Body.Close()
 
-	var keys KeycloakKeys
-	if err := json.NewDecoder(resp.Body).Decode(&keys); err != nil {
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code fetching public keys: %d", resp.StatusCode)
+	}
+
+	var set jsonWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
 		return fmt.Errorf("failed to decode public keys: %w", err)
 	}
 
+	keys := make(map[string]*jsonWebKey, len(set.Keys))
+	for i := range set.Keys {
+		key := &set.Keys[i]
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		if err := key.parse(); err != nil {
+			v.config.Logger.LogAttrs(ctx, slog.LevelWarn, "Skipping JWKS key",
+				ecsslog.Action("jwks.fetch"), slog.String("jwks.key.id", key.Kid), ecsslog.Err(err))
+			continue
+		}
+		keys[key.Kid] = key
+	}
+
+	refreshIn := v.config.RefreshInterval
+	if maxAge, ok := parseMaxAge(resp.Header.Get("Cache-Control")); ok {
+		refreshIn = maxAge
+	}
+
 	v.mu.Lock()
-	defer v.mu.Unlock()
+	v.keys = keys
+	v.nextRefresh = now.Add(refreshIn)
+	v.mu.Unlock()
+
+	return nil
+}
 
-	for _, key := range keys.Keys {
-		v.keys[key.Kid] = key
+// Check reports whether the identity provider serves the JWKS. It does not
+// replace the cached keys.
+func (v *JWTVerifier) Check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.jwksURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create JWKS request: %w", err)
 	}
 
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch public keys: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code fetching public keys: %d", resp.StatusCode)
+	}
 	return nil
 }
 
-func (v *JWTVerifier) getPublicKey(kid string) (KeycloakPublicKey, error) {
+func (v *JWTVerifier) getPublicKey(kid string) (*jsonWebKey, error) {
 	v.mu.RLock()
 	key, exists := v.keys[kid]
 	v.mu.RUnlock()
 
-	if !exists {
-		if err := v.fetchPublicKeys(); err != nil {
-			return KeycloakPublicKey{}, err
-		}
-
-		v.mu.RLock()
-		key, exists = v.keys[kid]
-		v.mu.RUnlock()
+	if exists {
+		return key, nil
+	}
 
-		if !exists {
-			return KeycloakPublicKey{}, fmt.Errorf("public key not found for kid: %s", kid)
-		}
+	if err := v.refetchForUnknownKid(context.Background()); err != nil {
+		return nil, err
 	}
 
+	v.mu.RLock()
+	key, exists = v.keys[kid]
+	v.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("public key not found for kid: %s", kid)
+	}
 	return key, nil
 }
 
 func (v *JWTVerifier) VerifyToken(tokenString string) (*jwt.Token, error) {
-	parser := jwt.Parser{}
-	token, _, err := parser.ParseUnverified(tokenString, &Claims{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse token header: %w", err)
+	parserOptions := []jwt.ParserOption{
+		jwt.WithValidMethods(supportedSigningMethods),
+		jwt.WithIssuer(v.config.Issuer),
+		jwt.WithLeeway(v.config.ClockSkew),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
 	}
-
-	kid, ok := token.Header["kid"].(string)
-	if !ok {
-		return nil, fmt.Errorf("kid not found in token header")
-	}
-
-	key, err := v.getPublicKey(kid)
-	if err != nil {
-		return nil, err
+	if v.config.Audience != "" {
+		parserOptions = append(parserOptions, jwt.WithAudience(v.config.Audience))
 	}
 
-	token, err = jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if token.Method.Alg() != "RS256" {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		kid, ok := token.Header["kid"].(string)
+		if !ok {
+			return nil, fmt.Errorf("kid not found in token header")
 		}
 
-		nBytes, err := base64.RawURLEncoding.DecodeString(key.N)
+		key, err := v.getPublicKey(kid)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decode modulus: %w", err)
+			return nil, err
 		}
-		eBytes, err := base64.RawURLEncoding.DecodeString(key.E)
-		if err != nil {
-			return nil, fmt.Errorf("failed to decode exponent: %w", err)
-		}
-
-		n := new(big.Int).SetBytes(nBytes)
-		e := new(big.Int).SetBytes(eBytes)
 
-		publicKey := &rsa.PublicKey{
-			N: n,
-			E: int(e.Int64()),
+		if key.Alg != "" && key.Alg != token.Method.Alg() {
+			return nil, fmt.Errorf("key %s is for %s, token is signed with %s", kid, key.Alg, token.Method.Alg())
 		}
 
-		return publicKey, nil
-	}, jwt.WithValidMethods([]string{"RS256"}))
+		return key.publicKey, nil
+	}, parserOptions...)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to verify token: %w", err)
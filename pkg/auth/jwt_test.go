@@ -0,0 +1,264 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// jwksStandIn serves a JWKS document like Keycloak's certs endpoint.
+type jwksStandIn struct {
+	server       *httptest.Server
+	fetches      atomic.Int32
+	cacheControl string
+
+	mu   sync.Mutex
+	keys []map[string]string
+}
+
+func newJWKSStandIn(t *testing.T) *jwksStandIn {
+	t.Helper()
+	s := &jwksStandIn{}
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/realms/test/protocol/openid-connect/certs", r.URL.Path)
+		s.fetches.Add(1)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.cacheControl != "" {
+			w.Header().Set("Cache-Control", s.cacheControl)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"keys": s.keys})
+	}))
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func (s *jwksStandIn) realmURL() string {
+	return s.server.URL + "/realms/test"
+}
+
+func (s *jwksStandIn) setKeys(keys ...map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+func encodeBigInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func rsaJWK(kid, alg string, key *rsa.PrivateKey) map[string]string {
+	return map[string]string{
+		"kid": kid, "kty": "RSA", "alg": alg, "use": "sig",
+		"n": encodeBigInt(key.N), "e": encodeBigInt(big.NewInt(int64(key.E))),
+	}
+}
+
+func ecJWK(kid string, key *ecdsa.PrivateKey) map[string]string {
+	return map[string]string{
+		"kid": kid, "kty": "EC", "alg": "ES256", "use": "sig", "crv": "P-256",
+		"x": encodeBigInt(key.X), "y": encodeBigInt(key.Y),
+	}
+}
+
+type tokenOptions struct {
+	issuer    string
+	audience  string
+	expiresAt time.Time
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, kid string, key crypto.PrivateKey, opts tokenOptions) string {
+	t.Helper()
+	claims := &Claims{
+		UserId: "user-1",
+		Scope:  "openid files:read",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    opts.issuer,
+			Audience:  jwt.ClaimStrings{opts.audience},
+			Subject:   "user-1",
+			ExpiresAt: jwt.NewNumericDate(opts.expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+		},
+	}
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestJWTVerifier_Algorithms(t *testing.T) {
+	jwks := newJWKSStandIn(t)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	jwks.setKeys(rsaJWK("rs", "RS256", rsaKey), rsaJWK("ps", "PS256", rsaKey), ecJWK("es", ecKey))
+
+	verifier := NewJWTVerifier(KeycloakConfig{RealmURL: jwks.realmURL(), ClientID: "file-storage"})
+	opts := tokenOptions{issuer: jwks.realmURL(), audience: "file-storage", expiresAt: time.Now().Add(time.Hour)}
+
+	tests := []struct {
+		name   string
+		method jwt.SigningMethod
+		kid    string
+		key    crypto.PrivateKey
+	}{
+		{"RS256", jwt.SigningMethodRS256, "rs", rsaKey},
+		{"PS256", jwt.SigningMethodPS256, "ps", rsaKey},
+		{"ES256", jwt.SigningMethodES256, "es", ecKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := verifier.VerifyToken(signToken(t, tt.method, tt.kid, tt.key, opts))
+			require.NoError(t, err)
+			claims := token.Claims.(*Claims)
+			assert.Equal(t, "user-1", claims.UserId)
+			assert.Equal(t, []string{"openid", "files:read"}, claims.Scopes())
+		})
+	}
+
+	_, err = verifier.VerifyToken(signToken(t, jwt.SigningMethodRS384, "rs", rsaKey, opts))
+	assert.Error(t, err, "RS384 is not accepted")
+
+	_, err = verifier.VerifyToken(signToken(t, jwt.SigningMethodPS256, "rs", rsaKey, opts))
+	assert.Error(t, err, "the algorithm must match the key's alg")
+}
+
+func TestJWTVerifier_IssuerAudienceAndClockSkew(t *testing.T) {
+	jwks := newJWKSStandIn(t)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	jwks.setKeys(rsaJWK("kid", "RS256", key))
+
+	verifier := NewJWTVerifier(KeycloakConfig{
+		RealmURL:  jwks.realmURL(),
+		ClientID:  "file-storage",
+		ClockSkew: 30 * time.Second,
+	})
+	valid := tokenOptions{issuer: jwks.realmURL(), audience: "file-storage", expiresAt: time.Now().Add(time.Hour)}
+
+	_, err = verifier.VerifyToken(signToken(t, jwt.SigningMethodRS256, "kid", key, valid))
+	require.NoError(t, err)
+
+	wrongIssuer := valid
+	wrongIssuer.issuer = "https://other.example.com/realms/test"
+	_, err = verifier.VerifyToken(signToken(t, jwt.SigningMethodRS256, "kid", key, wrongIssuer))
+	assert.ErrorIs(t, err, jwt.ErrTokenInvalidIssuer)
+
+	wrongAudience := valid
+	wrongAudience.audience = "other-client"
+	_, err = verifier.VerifyToken(signToken(t, jwt.SigningMethodRS256, "kid", key, wrongAudience))
+	assert.ErrorIs(t, err, jwt.ErrTokenInvalidAudience)
+
+	withinSkew := valid
+	withinSkew.expiresAt = time.Now().Add(-10 * time.Second)
+	_, err = verifier.VerifyToken(signToken(t, jwt.SigningMethodRS256, "kid", key, withinSkew))
+	assert.NoError(t, err)
+
+	beyondSkew := valid
+	beyondSkew.expiresAt = time.Now().Add(-time.Minute)
+	_, err = verifier.VerifyToken(signToken(t, jwt.SigningMethodRS256, "kid", key, beyondSkew))
+	assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+}
+
+func TestJWTVerifier_UnknownKidRefetchIsRateLimited(t *testing.T) {
+	jwks := newJWKSStandIn(t)
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	jwks.setKeys(rsaJWK("old", "RS256", oldKey))
+
+	verifier := NewJWTVerifier(KeycloakConfig{
+		RealmURL:           jwks.realmURL(),
+		ClientID:           "file-storage",
+		MinRefetchInterval: 100 * time.Millisecond,
+	})
+	opts := tokenOptions{issuer: jwks.realmURL(), audience: "file-storage", expiresAt: time.Now().Add(time.Hour)}
+
+	_, err = verifier.VerifyToken(signToken(t, jwt.SigningMethodRS256, "old", oldKey, opts))
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), jwks.fetches.Load())
+
+	for i := 0; i < 5; i++ {
+		_, err = verifier.VerifyToken(signToken(t, jwt.SigningMethodRS256, "unknown", oldKey, opts))
+		assert.Error(t, err)
+	}
+	assert.Equal(t, int32(1), jwks.fetches.Load(), "unknown kids must not refetch within the rate limit")
+
+	// After a rotation the new kid is picked up once the rate limit allows it,
+	// and the removed key is no longer accepted.
+	jwks.setKeys(rsaJWK("new", "RS256", newKey))
+	time.Sleep(150 * time.Millisecond)
+
+	_, err = verifier.VerifyToken(signToken(t, jwt.SigningMethodRS256, "new", newKey, opts))
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), jwks.fetches.Load())
+
+	_, err = verifier.VerifyToken(signToken(t, jwt.SigningMethodRS256, "old", oldKey, opts))
+	assert.Error(t, err)
+}
+
+func TestJWTVerifier_BackgroundRefreshUsesMaxAge(t *testing.T) {
+	jwks := newJWKSStandIn(t)
+	jwks.cacheControl = "public, max-age=1"
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	jwks.setKeys(rsaJWK("kid", "RS256", key))
+
+	verifier := NewJWTVerifier(KeycloakConfig{
+		RealmURL:           jwks.realmURL(),
+		ClientID:           "file-storage",
+		RefreshInterval:    time.Hour,
+		MinRefetchInterval: 10 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	verifier.Start(ctx)
+	assert.Equal(t, int32(1), jwks.fetches.Load())
+
+	assert.Eventually(t, func() bool {
+		return jwks.fetches.Load() >= 2
+	}, 3*time.Second, 50*time.Millisecond)
+}
+
+func TestJWTVerifier_Check(t *testing.T) {
+	jwks := newJWKSStandIn(t)
+	verifier := NewJWTVerifier(KeycloakConfig{RealmURL: jwks.realmURL(), ClientID: "file-storage"})
+
+	require.NoError(t, verifier.Check(context.Background()))
+	assert.Equal(t, int32(1), jwks.fetches.Load())
+
+	jwks.server.Close()
+	assert.Error(t, verifier.Check(context.Background()))
+}
+
+func TestParseMaxAge(t *testing.T) {
+	maxAge, ok := parseMaxAge("public, max-age=300, must-revalidate")
+	assert.True(t, ok)
+	assert.Equal(t, 5*time.Minute, maxAge)
+
+	_, ok = parseMaxAge("no-cache")
+	assert.False(t, ok)
+
+	_, ok = parseMaxAge("max-age=abc")
+	assert.False(t, ok)
+}
@@ -16,6 +16,7 @@ func NewMockJWTVerifier() *MockJWTVerifier {
 func (m *MockJWTVerifier) VerifyToken(tokenString string) (*jwt.Token, error) {
 	claims := &Claims{
 		UserId: "mock-user-id",
+		Scope:  "files:read files:write files:admin files:audit",
 		RegisteredClaims: jwt.RegisteredClaims{
 			Subject: "mock-user-id",
 		},
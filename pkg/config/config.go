@@ -2,8 +2,12 @@ package config
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
 
 	"file-storage-go/pkg/services/secrets"
 
@@ -24,18 +28,139 @@ type Config struct {
 	DBName               string `mapstructure:"DB_NAME"`
 	DBUser               string `mapstructure:"DB_USER"`
 	DBPassword           string `mapstructure:"DB_PASSWORD"`
+	DBSSLMode            string `mapstructure:"DB_SSL_MODE"`
+	DBSSLRootCert        string `mapstructure:"DB_SSL_ROOT_CERT"`
+	DBReplicaDSN         string `mapstructure:"DB_REPLICA_DSN"`
+	DBMaxConns           int    `mapstructure:"DB_MAX_CONNS"`
+	DBMinConns           int    `mapstructure:"DB_MIN_CONNS"`
+	DBConnMaxLifetime    string `mapstructure:"DB_CONN_MAX_LIFETIME"`
+	DBStatementTimeout   string `mapstructure:"DB_STATEMENT_TIMEOUT"`
 	KeycloakURL          string `mapstructure:"KEYCLOAK_URL"`
 	KeycloakClientID     string `mapstructure:"KEYCLOAK_CLIENT_ID"`
+	KeycloakIssuer       string `mapstructure:"KEYCLOAK_ISSUER"`
+	KeycloakAudience     string `mapstructure:"KEYCLOAK_AUDIENCE"`
+	JWTClockSkew         string `mapstructure:"JWT_CLOCK_SKEW"`
 	VirusCheckTimeout    string `mapstructure:"VIRUS_CHECK_TIMEOUT"`
 	UseMockVirusChecker  bool   `mapstructure:"USE_MOCK_VIRUS_CHECKER"`
 	VirusCheckerURL      string `mapstructure:"VIRUS_CHECKER_URL"`
 	UseInMemoryRepo      bool   `mapstructure:"USE_IN_MEMORY_REPO"`
 	UseMockAuthorization bool   `mapstructure:"USE_MOCK_AUTHORIZATION"`
+	ContentTypePolicy    string `mapstructure:"CONTENT_TYPE_MISMATCH_POLICY"`
+	EncryptionEnabled    bool   `mapstructure:"ENCRYPTION_ENABLED"`
+	AllowLegacyPlaintext bool   `mapstructure:"ENCRYPTION_ALLOW_PLAINTEXT_LEGACY"`
+	VaultTransitMount    string `mapstructure:"VAULT_TRANSIT_MOUNT"`
+	VaultTransitKey      string `mapstructure:"VAULT_TRANSIT_KEY"`
+	CompressionEnabled   bool   `mapstructure:"COMPRESSION_ENABLED"`
+	CompressionTypes     string `mapstructure:"COMPRESSION_CONTENT_TYPES"`
+	ThumbnailSizes       string `mapstructure:"THUMBNAIL_SIZES"`
+	SearchLanguage       string `mapstructure:"SEARCH_LANGUAGE"`
+	FileAuthorization    string `mapstructure:"FILE_AUTHORIZATION"`
+	AdminRole            string `mapstructure:"ADMIN_ROLE"`
+	AdminUserIDs         string `mapstructure:"ADMIN_USER_IDS"`
+	AuditorRole          string `mapstructure:"AUDITOR_ROLE"`
+	AuditorUserIDs       string `mapstructure:"AUDITOR_USER_IDS"`
+	TrustedProxies       string `mapstructure:"TRUSTED_PROXIES"`
+	PolicyFile           string `mapstructure:"POLICY_FILE"`
+	AuthzCalloutURL      string `mapstructure:"AUTHZ_CALLOUT_URL"`
+	AuthzCalloutTimeout  string `mapstructure:"AUTHZ_CALLOUT_TIMEOUT"`
+	AuthzCacheTTL        string `mapstructure:"AUTHZ_CACHE_TTL"`
+	ScopesRead           string `mapstructure:"SCOPES_READ"`
+	ScopesWrite          string `mapstructure:"SCOPES_WRITE"`
+	ScopesAdmin          string `mapstructure:"SCOPES_ADMIN"`
+	ScopesAudit          string `mapstructure:"SCOPES_AUDIT"`
+	WebhooksEnabled      bool   `mapstructure:"WEBHOOKS_ENABLED"`
+	WebhookSecret        string `mapstructure:"WEBHOOK_SECRET"`
+	WebhookAllowedHosts  string `mapstructure:"WEBHOOK_ALLOWED_HOSTS"`
+	WebhookMaxAttempts   int    `mapstructure:"WEBHOOK_MAX_ATTEMPTS"`
+	WebhookTimeout       string `mapstructure:"WEBHOOK_TIMEOUT"`
+	EventPublisher       string `mapstructure:"EVENT_PUBLISHER"`
+	EventPublisherURL    string `mapstructure:"EVENT_PUBLISHER_URL"`
+	EventTimeout         string `mapstructure:"EVENT_PUBLISHER_TIMEOUT"`
+	OutboxRetention      string `mapstructure:"OUTBOX_RETENTION"`
+	IdempotencyKeyTTL    string `mapstructure:"IDEMPOTENCY_KEY_TTL"`
+	HealthCheckTimeout   string `mapstructure:"HEALTH_CHECK_TIMEOUT"`
+	HealthCheckCacheTTL  string `mapstructure:"HEALTH_CHECK_CACHE_TTL"`
+	TracesExporter       string `mapstructure:"OTEL_TRACES_EXPORTER"`
+	ServiceName          string `mapstructure:"OTEL_SERVICE_NAME"`
+	TraceSampleRatio     string `mapstructure:"OTEL_TRACES_SAMPLER_ARG"`
+	RateLimitStore       string `mapstructure:"RATE_LIMIT_STORE"`
+	RateLimitRead        string `mapstructure:"RATE_LIMIT_READ"`
+	RateLimitWrite       string `mapstructure:"RATE_LIMIT_WRITE"`
+	RateLimitUpload      string `mapstructure:"RATE_LIMIT_UPLOAD"`
+	ConcurrentUploads    int    `mapstructure:"MAX_CONCURRENT_UPLOADS"`
+	ConcurrentDownloads  int    `mapstructure:"MAX_CONCURRENT_DOWNLOADS"`
 }
 
 func (c *Config) GetDBConnString() string {
-	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
-		c.DBUser, c.DBPassword, c.DBHost, c.DBPort, c.DBName)
+	query := url.Values{}
+	query.Set("sslmode", c.DBSSLMode)
+	if c.DBSSLRootCert != "" {
+		query.Set("sslrootcert", c.DBSSLRootCert)
+	}
+
+	connURL := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.DBUser, c.DBPassword),
+		Host:     net.JoinHostPort(c.DBHost, c.DBPort),
+		Path:     "/" + c.DBName,
+		RawQuery: query.Encode(),
+	}
+	return connURL.String()
+}
+
+func (c *Config) GetThumbnailSizes() ([]int, error) {
+	var sizes []int
+	for _, part := range strings.Split(c.ThumbnailSizes, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		size, err := strconv.Atoi(part)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid thumbnail size: %q", part)
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
+
+func (c *Config) GetAdminUserIDs() []string {
+	return splitList(c.AdminUserIDs)
+}
+
+func (c *Config) GetAuditorUserIDs() []string {
+	return splitList(c.AuditorUserIDs)
+}
+
+func (c *Config) GetTrustedProxies() []string {
+	return splitList(c.TrustedProxies)
+}
+
+func (c *Config) GetWebhookAllowedHosts() []string {
+	return splitList(c.WebhookAllowedHosts)
+}
+
+// splitList splits a comma or space separated list, dropping empty entries.
+func splitList(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
+
+func (c *Config) GetScopesRead() []string {
+	return splitList(c.ScopesRead)
+}
+
+func (c *Config) GetScopesWrite() []string {
+	return splitList(c.ScopesWrite)
+}
+
+func (c *Config) GetScopesAdmin() []string {
+	return splitList(c.ScopesAdmin)
+}
+
+func (c *Config) GetScopesAudit() []string {
+	return splitList(c.ScopesAudit)
 }
 
 func LoadConfig() (*Config, error) {
@@ -54,13 +179,67 @@ func LoadConfig() (*Config, error) {
 	viper.SetDefault("DB_NAME", "file_storage")
 	viper.SetDefault("DB_USER", "postgres")
 	viper.SetDefault("DB_PASSWORD", "postgres")
+	viper.SetDefault("DB_SSL_MODE", "require")
+	viper.SetDefault("DB_SSL_ROOT_CERT", "")
+	viper.SetDefault("DB_REPLICA_DSN", "")
+	viper.SetDefault("DB_MAX_CONNS", 20)
+	viper.SetDefault("DB_MIN_CONNS", 2)
+	viper.SetDefault("DB_CONN_MAX_LIFETIME", "1h")
+	viper.SetDefault("DB_STATEMENT_TIMEOUT", "30s")
 	viper.SetDefault("KEYCLOAK_URL", "http://localhost:8081/realms/file-storage")
 	viper.SetDefault("KEYCLOAK_CLIENT_ID", "file-storage")
+	viper.SetDefault("KEYCLOAK_ISSUER", "")
+	viper.SetDefault("KEYCLOAK_AUDIENCE", "")
+	viper.SetDefault("JWT_CLOCK_SKEW", "30s")
 	viper.SetDefault("VIRUS_CHECK_TIMEOUT", "5s")
 	viper.SetDefault("USE_MOCK_VIRUS_CHECKER", false)
 	viper.SetDefault("VIRUS_CHECKER_URL", "http://localhost:8082")
 	viper.SetDefault("USE_IN_MEMORY_REPO", false)
 	viper.SetDefault("USE_MOCK_JWT_VERIFIER", false)
+	viper.SetDefault("CONTENT_TYPE_MISMATCH_POLICY", "reject")
+	viper.SetDefault("ENCRYPTION_ENABLED", false)
+	viper.SetDefault("ENCRYPTION_ALLOW_PLAINTEXT_LEGACY", false)
+	viper.SetDefault("VAULT_TRANSIT_MOUNT", "transit")
+	viper.SetDefault("VAULT_TRANSIT_KEY", "file-storage")
+	viper.SetDefault("COMPRESSION_ENABLED", false)
+	viper.SetDefault("COMPRESSION_CONTENT_TYPES", "text/*,application/json,application/xml")
+	viper.SetDefault("THUMBNAIL_SIZES", "64,256")
+	viper.SetDefault("SEARCH_LANGUAGE", "simple")
+	viper.SetDefault("FILE_AUTHORIZATION", "acl")
+	viper.SetDefault("ADMIN_ROLE", "file-storage-admin")
+	viper.SetDefault("ADMIN_USER_IDS", "")
+	viper.SetDefault("AUDITOR_ROLE", "file-storage-auditor")
+	viper.SetDefault("AUDITOR_USER_IDS", "")
+	viper.SetDefault("TRUSTED_PROXIES", "")
+	viper.SetDefault("POLICY_FILE", "policy.yaml")
+	viper.SetDefault("AUTHZ_CALLOUT_URL", "")
+	viper.SetDefault("AUTHZ_CALLOUT_TIMEOUT", "2s")
+	viper.SetDefault("AUTHZ_CACHE_TTL", "30s")
+	viper.SetDefault("SCOPES_READ", "files:read")
+	viper.SetDefault("SCOPES_WRITE", "files:write")
+	viper.SetDefault("SCOPES_ADMIN", "files:admin")
+	viper.SetDefault("SCOPES_AUDIT", "files:audit")
+	viper.SetDefault("WEBHOOKS_ENABLED", false)
+	viper.SetDefault("WEBHOOK_SECRET", "")
+	viper.SetDefault("WEBHOOK_ALLOWED_HOSTS", "")
+	viper.SetDefault("WEBHOOK_MAX_ATTEMPTS", 8)
+	viper.SetDefault("WEBHOOK_TIMEOUT", "10s")
+	viper.SetDefault("EVENT_PUBLISHER", "log")
+	viper.SetDefault("EVENT_PUBLISHER_URL", "")
+	viper.SetDefault("EVENT_PUBLISHER_TIMEOUT", "5s")
+	viper.SetDefault("OUTBOX_RETENTION", "168h")
+	viper.SetDefault("IDEMPOTENCY_KEY_TTL", "24h")
+	viper.SetDefault("HEALTH_CHECK_TIMEOUT", "2s")
+	viper.SetDefault("HEALTH_CHECK_CACHE_TTL", "5s")
+	viper.SetDefault("OTEL_TRACES_EXPORTER", "none")
+	viper.SetDefault("OTEL_SERVICE_NAME", "file-storage")
+	viper.SetDefault("OTEL_TRACES_SAMPLER_ARG", "1.0")
+	viper.SetDefault("RATE_LIMIT_STORE", "memory")
+	viper.SetDefault("RATE_LIMIT_READ", "")
+	viper.SetDefault("RATE_LIMIT_WRITE", "")
+	viper.SetDefault("RATE_LIMIT_UPLOAD", "")
+	viper.SetDefault("MAX_CONCURRENT_UPLOADS", 0)
+	viper.SetDefault("MAX_CONCURRENT_DOWNLOADS", 0)
 
 	if err := viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
@@ -81,33 +260,88 @@ func LoadConfig() (*Config, error) {
 		DBName:               viper.GetString("DB_NAME"),
 		DBUser:               viper.GetString("DB_USER"),
 		DBPassword:           viper.GetString("DB_PASSWORD"),
+		DBSSLMode:            viper.GetString("DB_SSL_MODE"),
+		DBSSLRootCert:        viper.GetString("DB_SSL_ROOT_CERT"),
+		DBReplicaDSN:         viper.GetString("DB_REPLICA_DSN"),
+		DBMaxConns:           viper.GetInt("DB_MAX_CONNS"),
+		DBMinConns:           viper.GetInt("DB_MIN_CONNS"),
+		DBConnMaxLifetime:    viper.GetString("DB_CONN_MAX_LIFETIME"),
+		DBStatementTimeout:   viper.GetString("DB_STATEMENT_TIMEOUT"),
 		KeycloakURL:          viper.GetString("KEYCLOAK_URL"),
 		KeycloakClientID:     viper.GetString("KEYCLOAK_CLIENT_ID"),
+		KeycloakIssuer:       viper.GetString("KEYCLOAK_ISSUER"),
+		KeycloakAudience:     viper.GetString("KEYCLOAK_AUDIENCE"),
+		JWTClockSkew:         viper.GetString("JWT_CLOCK_SKEW"),
 		VirusCheckTimeout:    viper.GetString("VIRUS_CHECK_TIMEOUT"),
 		UseMockVirusChecker:  viper.GetBool("USE_MOCK_VIRUS_CHECKER"),
 		VirusCheckerURL:      viper.GetString("VIRUS_CHECKER_URL"),
 		UseInMemoryRepo:      viper.GetBool("USE_IN_MEMORY_REPO"),
 		UseMockAuthorization: viper.GetBool("USE_MOCK_AUTHORIZATION"),
+		ContentTypePolicy:    viper.GetString("CONTENT_TYPE_MISMATCH_POLICY"),
+		EncryptionEnabled:    viper.GetBool("ENCRYPTION_ENABLED"),
+		AllowLegacyPlaintext: viper.GetBool("ENCRYPTION_ALLOW_PLAINTEXT_LEGACY"),
+		VaultTransitMount:    viper.GetString("VAULT_TRANSIT_MOUNT"),
+		VaultTransitKey:      viper.GetString("VAULT_TRANSIT_KEY"),
+		CompressionEnabled:   viper.GetBool("COMPRESSION_ENABLED"),
+		CompressionTypes:     viper.GetString("COMPRESSION_CONTENT_TYPES"),
+		ThumbnailSizes:       viper.GetString("THUMBNAIL_SIZES"),
+		SearchLanguage:       viper.GetString("SEARCH_LANGUAGE"),
+		FileAuthorization:    viper.GetString("FILE_AUTHORIZATION"),
+		AdminRole:            viper.GetString("ADMIN_ROLE"),
+		AdminUserIDs:         viper.GetString("ADMIN_USER_IDS"),
+		AuditorRole:          viper.GetString("AUDITOR_ROLE"),
+		AuditorUserIDs:       viper.GetString("AUDITOR_USER_IDS"),
+		TrustedProxies:       viper.GetString("TRUSTED_PROXIES"),
+		PolicyFile:           viper.GetString("POLICY_FILE"),
+		AuthzCalloutURL:      viper.GetString("AUTHZ_CALLOUT_URL"),
+		AuthzCalloutTimeout:  viper.GetString("AUTHZ_CALLOUT_TIMEOUT"),
+		AuthzCacheTTL:        viper.GetString("AUTHZ_CACHE_TTL"),
+		ScopesRead:           viper.GetString("SCOPES_READ"),
+		ScopesWrite:          viper.GetString("SCOPES_WRITE"),
+		ScopesAdmin:          viper.GetString("SCOPES_ADMIN"),
+		ScopesAudit:          viper.GetString("SCOPES_AUDIT"),
+		WebhooksEnabled:      viper.GetBool("WEBHOOKS_ENABLED"),
+		WebhookSecret:        viper.GetString("WEBHOOK_SECRET"),
+		WebhookAllowedHosts:  viper.GetString("WEBHOOK_ALLOWED_HOSTS"),
+		WebhookMaxAttempts:   viper.GetInt("WEBHOOK_MAX_ATTEMPTS"),
+		WebhookTimeout:       viper.GetString("WEBHOOK_TIMEOUT"),
+		EventPublisher:       viper.GetString("EVENT_PUBLISHER"),
+		EventPublisherURL:    viper.GetString("EVENT_PUBLISHER_URL"),
+		EventTimeout:         viper.GetString("EVENT_PUBLISHER_TIMEOUT"),
+		OutboxRetention:      viper.GetString("OUTBOX_RETENTION"),
+		IdempotencyKeyTTL:    viper.GetString("IDEMPOTENCY_KEY_TTL"),
+		HealthCheckTimeout:   viper.GetString("HEALTH_CHECK_TIMEOUT"),
+		HealthCheckCacheTTL:  viper.GetString("HEALTH_CHECK_CACHE_TTL"),
+		TracesExporter:       viper.GetString("OTEL_TRACES_EXPORTER"),
+		ServiceName:          viper.GetString("OTEL_SERVICE_NAME"),
+		TraceSampleRatio:     viper.GetString("OTEL_TRACES_SAMPLER_ARG"),
+		RateLimitStore:       viper.GetString("RATE_LIMIT_STORE"),
+		RateLimitRead:        viper.GetString("RATE_LIMIT_READ"),
+		RateLimitWrite:       viper.GetString("RATE_LIMIT_WRITE"),
+		RateLimitUpload:      viper.GetString("RATE_LIMIT_UPLOAD"),
+		ConcurrentUploads:    viper.GetInt("MAX_CONCURRENT_UPLOADS"),
+		ConcurrentDownloads:  viper.GetInt("MAX_CONCURRENT_DOWNLOADS"),
 	}
 
-	if os.Getenv("SKIP_STORAGE_VALIDATION") == "true" {
+	// Mock storage needs no storage credentials.
+	if os.Getenv("SKIP_STORAGE_VALIDATION") == "true" || os.Getenv("USE_MOCK_STORAGE") == "true" {
 		return config, nil
 	}
 
 	storageKey := os.Getenv("STORAGE_KEY")
 	if storageKey == "" {
-		log.Printf("Creating vault service with address: %s", config.VaultAddress)
+		slog.Info("Creating vault service", "vault.address", config.VaultAddress)
 		vaultService, err := secrets.NewVaultService(config.VaultAddress, config.VaultRoleID, config.VaultSecretID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create vault service: %w", err)
 		}
 
-		log.Printf("Retrieving storage credentials from vault")
+		slog.Info("Retrieving storage credentials from vault")
 		creds, err := vaultService.GetStorageCredentials()
 		if err != nil {
 			return nil, fmt.Errorf("failed to get storage credentials from vault: %w", err)
 		}
-		log.Printf("Successfully retrieved storage credentials from vault")
+		slog.Info("Successfully retrieved storage credentials from vault")
 
 		config.StorageKey = creds.StorageKey
 		if config.BlobAccountName == "" {
@@ -123,7 +357,7 @@ func LoadConfig() (*Config, error) {
 		config.StorageKey = storageKey
 	}
 
-	if os.Getenv("USE_MOCK_STORAGE") != "true" && config.BlobStorageURL == "" {
+	if config.BlobStorageURL == "" {
 		return nil, fmt.Errorf("BLOB_STORAGE_URL is required when not using mock storage (USE_MOCK_STORAGE is not 'true')")
 	}
 
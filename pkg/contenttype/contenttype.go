@@ -0,0 +1,234 @@
+package contenttype
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+const sniffLen = 512
+
+type MismatchPolicy string
+
+const (
+	MismatchPolicyReject MismatchPolicy = "reject"
+	MismatchPolicyFlag   MismatchPolicy = "flag"
+)
+
+func ParseMismatchPolicy(value string) (MismatchPolicy, error) {
+	switch MismatchPolicy(strings.ToLower(strings.TrimSpace(value))) {
+	case "", MismatchPolicyReject:
+		return MismatchPolicyReject, nil
+	case MismatchPolicyFlag:
+		return MismatchPolicyFlag, nil
+	default:
+		return "", fmt.Errorf("unknown content type mismatch policy: %s", value)
+	}
+}
+
+var executableSignatures = []struct {
+	prefix   []byte
+	mimeType string
+}{
+	{[]byte("MZ"), "application/x-msdownload"},
+	{[]byte("\x7fELF"), "application/x-executable"},
+	{[]byte("\xfe\xed\xfa\xce"), "application/x-mach-binary"},
+	{[]byte("\xfe\xed\xfa\xcf"), "application/x-mach-binary"},
+	{[]byte("\xce\xfa\xed\xfe"), "application/x-mach-binary"},
+	{[]byte("\xcf\xfa\xed\xfe"), "application/x-mach-binary"},
+}
+
+// executableExtensions are the file extensions under which executables may be
+// stored. Executables without an extension are common on Unix and match too.
+var executableExtensions = map[string]bool{
+	"":       true,
+	".exe":   true,
+	".dll":   true,
+	".com":   true,
+	".scr":   true,
+	".msi":   true,
+	".sys":   true,
+	".bin":   true,
+	".so":    true,
+	".dylib": true,
+}
+
+// extensionTypes are the media types that files with these extensions claim
+// to be. They are built in rather than read from the system's mime.types,
+// so that the checks do not depend on the host.
+var extensionTypes = map[string]string{
+	".pdf":  "application/pdf",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".bmp":  "image/bmp",
+	".ico":  "image/x-icon",
+	".svg":  "image/svg+xml",
+	".txt":  "text/plain",
+	".csv":  "text/csv",
+	".md":   "text/markdown",
+	".html": "text/html",
+	".htm":  "text/html",
+	".xml":  "text/xml",
+	".json": "application/json",
+	".yaml": "application/yaml",
+	".yml":  "application/yaml",
+	".rtf":  "application/rtf",
+	".zip":  "application/zip",
+	".gz":   "application/gzip",
+	".doc":  "application/msword",
+	".xls":  "application/vnd.ms-excel",
+	".ppt":  "application/vnd.ms-powerpoint",
+	".msg":  "application/vnd.ms-outlook",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".odt":  "application/vnd.oasis.opendocument.text",
+	".ods":  "application/vnd.oasis.opendocument.spreadsheet",
+	".odp":  "application/vnd.oasis.opendocument.presentation",
+	".epub": "application/epub+zip",
+	".mp3":  "audio/mpeg",
+	".wav":  "audio/wave",
+	".mp4":  "video/mp4",
+	".webm": "video/webm",
+}
+
+var zipContainerPrefixes = []string{
+	"application/vnd.openxmlformats-officedocument.",
+	"application/vnd.oasis.opendocument.",
+	"application/vnd.ms-excel.sheet.macroenabled",
+	"application/vnd.ms-word.document.macroenabled",
+	"application/epub+zip",
+	"application/java-archive",
+	"application/x-zip-compressed",
+}
+
+var textualTypes = map[string]bool{
+	"application/json":     true,
+	"application/x-ndjson": true,
+	"application/xml":      true,
+	"application/yaml":     true,
+	"application/x-yaml":   true,
+	"application/csv":      true,
+	"application/sql":      true,
+	"application/rtf":      true,
+}
+
+var aliases = map[string]string{
+	"image/jpg":          "image/jpeg",
+	"image/pjpeg":        "image/jpeg",
+	"application/x-pdf":  "application/pdf",
+	"application/x-gzip": "application/gzip",
+	"audio/x-wav":        "audio/wave",
+	"audio/wav":          "audio/wave",
+}
+
+// Sniff detects the MIME type from the leading bytes of reader. The returned
+// reader yields the complete original stream, including the sniffed bytes.
+func Sniff(reader io.Reader) (string, io.Reader, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(reader, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", nil, fmt.Errorf("failed to read file header: %w", err)
+	}
+	head = head[:n]
+
+	return Detect(head), io.MultiReader(bytes.NewReader(head), reader), nil
+}
+
+func Detect(head []byte) string {
+	for _, sig := range executableSignatures {
+		if bytes.HasPrefix(head, sig.prefix) {
+			return sig.mimeType
+		}
+	}
+	return Normalize(http.DetectContentType(head))
+}
+
+// Normalize lowercases a media type, strips its parameters and resolves
+// common aliases. Values that are not media types yield an empty string.
+func Normalize(value string) string {
+	mediaType, _, err := mime.ParseMediaType(value)
+	if err != nil || !strings.Contains(mediaType, "/") {
+		return ""
+	}
+	if alias, ok := aliases[mediaType]; ok {
+		return alias
+	}
+	return mediaType
+}
+
+// Matches reports whether content detected as detected may legitimately be
+// served under the declared type. Declared values that are not media types
+// never match, so that they cannot be used to get around a reject policy.
+// Content that could not be identified, e.g. legacy Office files, makes no
+// claim and matches any declared type.
+func Matches(declared, detected string) bool {
+	declared = Normalize(declared)
+	detected = Normalize(detected)
+	if declared == "" {
+		return false
+	}
+	if declared == "application/octet-stream" || detected == "" || detected == "application/octet-stream" {
+		return true
+	}
+	if declared == detected {
+		return true
+	}
+
+	switch detected {
+	case "text/plain":
+		return isTextual(declared)
+	case "text/xml":
+		return declared == "application/xml" || strings.HasSuffix(declared, "+xml")
+	case "application/zip":
+		for _, prefix := range zipContainerPrefixes {
+			if strings.HasPrefix(declared, prefix) {
+				return true
+			}
+		}
+	case "application/gzip":
+		return declared == "application/x-tar+gzip" || declared == "application/x-compressed-tar"
+	}
+
+	return false
+}
+
+// MatchesFilename reports whether content detected as detected may
+// legitimately be stored under filename. Executables need an executable
+// extension or none; other content must match the type of a known extension,
+// and unknown extensions carry no content claim.
+func MatchesFilename(filename, detected string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if isExecutable(detected) {
+		return executableExtensions[ext]
+	}
+
+	extType, ok := extensionTypes[ext]
+	if !ok {
+		return true
+	}
+	return Matches(extType, detected)
+}
+
+func isExecutable(mediaType string) bool {
+	for _, sig := range executableSignatures {
+		if sig.mimeType == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+func isTextual(mediaType string) bool {
+	if strings.HasPrefix(mediaType, "text/") && mediaType != "text/html" {
+		return true
+	}
+	return textualTypes[mediaType] || strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml")
+}
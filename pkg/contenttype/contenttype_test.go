@@ -0,0 +1,128 @@
+package contenttype
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniff_PreservesStream(t *testing.T) {
+	content := append([]byte("%PDF-1.7\n"), bytes.Repeat([]byte("x"), 2048)...)
+
+	detected, reader, err := Sniff(bytes.NewReader(content))
+	require.NoError(t, err)
+	assert.Equal(t, "application/pdf", detected)
+
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+}
+
+func TestSniff_ShortInput(t *testing.T) {
+	detected, reader, err := Sniff(bytes.NewReader([]byte("hello")))
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain", detected)
+
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name     string
+		head     []byte
+		expected string
+	}{
+		{"windows executable", []byte("MZ\x90\x00\x03\x00"), "application/x-msdownload"},
+		{"elf binary", []byte("\x7fELF\x02\x01\x01"), "application/x-executable"},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00"), "image/png"},
+		{"jpeg", []byte("\xff\xd8\xff\xe0\x00\x10JFIF"), "image/jpeg"},
+		{"zip", []byte("PK\x03\x04\x14\x00"), "application/zip"},
+		{"plain text", []byte("id,name\n1,foo\n"), "text/plain"},
+		{"ole compound document", []byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1\x00\x00"), "application/octet-stream"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Detect(tt.head))
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		declared string
+		detected string
+		expected bool
+	}{
+		{"application/pdf", "application/pdf", true},
+		{"application/pdf", "application/x-msdownload", false},
+		{"image/jpg", "image/jpeg", true},
+		{"image/png", "image/jpeg", false},
+		{"text/csv", "text/plain", true},
+		{"application/json", "text/plain", true},
+		{"text/html", "text/plain", false},
+		{"application/vnd.openxmlformats-officedocument.wordprocessingml.document", "application/zip", true},
+		{"application/pdf", "application/zip", false},
+		{"application/octet-stream", "application/x-msdownload", true},
+		{"some_filetype", "application/x-msdownload", false},
+		{"", "image/png", false},
+		{"text/plain; charset=utf-8", "text/plain", true},
+		{"application/msword", "application/octet-stream", true},
+		{"some_filetype", "application/octet-stream", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.declared+" vs "+tt.detected, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Matches(tt.declared, tt.detected))
+		})
+	}
+}
+
+func TestMatchesFilename(t *testing.T) {
+	tests := []struct {
+		filename string
+		detected string
+		expected bool
+	}{
+		{"report.pdf", "application/pdf", true},
+		{"report.PDF", "application/pdf", true},
+		{"report.pdf", "application/x-msdownload", false},
+		{"photo.jpg", "image/jpeg", true},
+		{"photo.png", "image/jpeg", false},
+		{"data.json", "text/plain", true},
+		{"setup.exe", "application/x-msdownload", true},
+		{"tool", "application/x-executable", true},
+		{"notes.unknownext", "application/x-msdownload", false},
+		{"notes.unknownext", "application/pdf", true},
+		{"notes", "text/plain", true},
+		{"legacy.doc", "application/octet-stream", true},
+		{"mail.msg", "application/octet-stream", true},
+		{"legacy.doc", "application/pdf", false},
+		{"report.docx", "application/zip", true},
+		{"letter.rtf", "text/plain", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename+" vs "+tt.detected, func(t *testing.T) {
+			assert.Equal(t, tt.expected, MatchesFilename(tt.filename, tt.detected))
+		})
+	}
+}
+
+func TestParseMismatchPolicy(t *testing.T) {
+	policy, err := ParseMismatchPolicy("")
+	require.NoError(t, err)
+	assert.Equal(t, MismatchPolicyReject, policy)
+
+	policy, err = ParseMismatchPolicy("FLAG")
+	require.NoError(t, err)
+	assert.Equal(t, MismatchPolicyFlag, policy)
+
+	_, err = ParseMismatchPolicy("ignore")
+	assert.Error(t, err)
+}
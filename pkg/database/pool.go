@@ -0,0 +1,177 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"file-storage-go/pkg/ecsslog"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PoolConfig tunes the connection pools. Zero values keep the pgx defaults.
+type PoolConfig struct {
+	MaxConns         int32
+	MinConns         int32
+	MaxConnLifetime  time.Duration
+	StatementTimeout time.Duration
+}
+
+// NewPool creates a connection pool and checks that the database is
+// reachable.
+func NewPool(ctx context.Context, connStr string, config PoolConfig) (*pgxpool.Pool, error) {
+	pool, err := newPool(ctx, connStr, config)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+	return pool, nil
+}
+
+func newPool(ctx context.Context, connStr string, config PoolConfig) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(connStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse connection string: %w", err)
+	}
+
+	if config.MaxConns > 0 {
+		poolConfig.MaxConns = config.MaxConns
+	}
+	if config.MinConns > 0 {
+		poolConfig.MinConns = config.MinConns
+	}
+	if config.MaxConnLifetime > 0 {
+		poolConfig.MaxConnLifetime = config.MaxConnLifetime
+	}
+	if config.StatementTimeout > 0 {
+		poolConfig.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(config.StatementTimeout.Milliseconds(), 10)
+	}
+	poolConfig.ConnConfig.Tracer = queryTracer{}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create connection pool: %w", err)
+	}
+	return pool, nil
+}
+
+type replicaReadsKey struct{}
+
+// WithReplicaReads marks reads made with the returned context as tolerant of
+// replication lag, so they may be served by the read replica.
+func WithReplicaReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replicaReadsKey{}, true)
+}
+
+// DB holds the connection pools shared by the Postgres repositories: the
+// primary, and optionally a read replica.
+type DB struct {
+	primary   *pgxpool.Pool
+	replica   *pgxpool.Pool
+	replicaUp atomic.Bool
+	logger    *slog.Logger
+}
+
+// Open connects to the primary and, if replicaConnStr is set, to the read
+// replica. The primary must be reachable. An unreachable replica is not an
+// error; reads go to the primary until MonitorReplica finds it reachable.
+func Open(ctx context.Context, primaryConnStr, replicaConnStr string, config PoolConfig, logger *slog.Logger) (*DB, error) {
+	primary, err := NewPool(ctx, primaryConnStr, config)
+	if err != nil {
+		return nil, err
+	}
+
+	db := NewDB(primary, nil)
+	db.logger = logger
+	if replicaConnStr == "" {
+		return db, nil
+	}
+
+	db.replica, err = newPool(ctx, replicaConnStr, config)
+	if err != nil {
+		primary.Close()
+		return nil, fmt.Errorf("replica: %w", err)
+	}
+	if err := db.replica.Ping(ctx); err != nil {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Read replica is not reachable, reading from the primary",
+			ecsslog.Action("db.replica_check"), ecsslog.Err(err))
+	} else {
+		db.replicaUp.Store(true)
+	}
+	return db, nil
+}
+
+// NewDB wraps existing pools. replica may be nil.
+func NewDB(primary, replica *pgxpool.Pool) *DB {
+	db := &DB{
+		primary: primary,
+		replica: replica,
+		logger:  slog.Default(),
+	}
+	db.replicaUp.Store(replica != nil)
+	return db
+}
+
+func (db *DB) Primary() *pgxpool.Pool {
+	return db.primary
+}
+
+// Reader returns the replica for contexts marked with WithReplicaReads while
+// the replica is reachable, and the primary otherwise.
+func (db *DB) Reader(ctx context.Context) *pgxpool.Pool {
+	if db.replica == nil || !db.replicaUp.Load() {
+		return db.primary
+	}
+	if replicaReads, _ := ctx.Value(replicaReadsKey{}).(bool); !replicaReads {
+		return db.primary
+	}
+	return db.replica
+}
+
+// MonitorReplica pings the replica every interval until ctx is done, so that
+// reads fall back to the primary while the replica is unreachable.
+func (db *DB) MonitorReplica(ctx context.Context, interval time.Duration) {
+	if db.replica == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pingCtx, cancel := context.WithTimeout(ctx, interval)
+			err := db.replica.Ping(pingCtx)
+			cancel()
+
+			up := err == nil
+			if db.replicaUp.Swap(up) != up {
+				if up {
+					db.logger.LogAttrs(ctx, slog.LevelInfo, "Read replica is reachable again",
+						ecsslog.Action("db.replica_check"))
+				} else {
+					db.logger.LogAttrs(ctx, slog.LevelWarn, "Read replica is not reachable, reading from the primary",
+						ecsslog.Action("db.replica_check"), ecsslog.Err(err))
+				}
+			}
+		}
+	}
+}
+
+func (db *DB) Close() {
+	db.primary.Close()
+	if db.replica != nil {
+		db.replica.Close()
+	}
+}
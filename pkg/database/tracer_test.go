@@ -0,0 +1,13 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuerySpanName(t *testing.T) {
+	assert.Equal(t, "SELECT", querySpanName("\n\t\tSELECT id FROM upload_jobs WHERE id = $1"))
+	assert.Equal(t, "INSERT", querySpanName("insert into upload_jobs VALUES ($1)"))
+	assert.Equal(t, "query", querySpanName("  "))
+}
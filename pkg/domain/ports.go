@@ -2,10 +2,19 @@ package domain
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"io"
 	"time"
 )
 
+var (
+	ErrFileNotFound    = errors.New("file not found")
+	ErrGrantNotFound   = errors.New("grant not found")
+	ErrAPIKeyNotFound  = errors.New("api key not found")
+	ErrWebhookNotFound = errors.New("webhook not found")
+)
+
 type JobStatus string
 
 const (
@@ -19,13 +28,15 @@ const (
 )
 
 type FileInfo struct {
-	ID                 string    `json:"id"`
-	Filename           string    `json:"filename,omitempty"`
-	FileType           string    `json:"fileType,omitempty"`
-	LinkedResourceType string    `json:"linkedResourceType,omitempty"`
-	LinkedResourceID   string    `json:"linkedResourceID,omitempty"`
-	CreatedAt          time.Time `json:"createdAt"`
-	UpdatedAt          time.Time `json:"updatedAt"`
+	ID                  string    `json:"id"`
+	Filename            string    `json:"filename,omitempty"`
+	FileType            string    `json:"fileType,omitempty"`
+	LinkedResourceType  string    `json:"linkedResourceType,omitempty"`
+	LinkedResourceID    string    `json:"linkedResourceID,omitempty"`
+	DetectedMimeType    string    `json:"detectedMimeType,omitempty"`
+	ContentTypeMismatch bool      `json:"contentTypeMismatch,omitempty"`
+	CreatedAt           time.Time `json:"createdAt"`
+	UpdatedAt           time.Time `json:"updatedAt"`
 }
 
 type UploadJob struct {
@@ -36,6 +47,259 @@ type UploadJob struct {
 	CreatedAt       time.Time `json:"createdAt"`
 	UpdatedAt       time.Time `json:"updatedAt"`
 	Error           string    `json:"error,omitempty"`
+	// TraceParent is the W3C traceparent of the request that uploaded the
+	// file, so the scan can be linked to it.
+	TraceParent string `json:"-"`
+	// RequestID is the X-Request-ID of the request that uploaded the file,
+	// so the scan's logs can be correlated with it.
+	RequestID string `json:"-"`
+	// Rescan is set when the file of a completed job is scanned again.
+	// Completing it again does not announce the file a second time.
+	Rescan bool `json:"rescan,omitempty"`
+}
+
+// UploadJobFilter selects jobs for the admin API. Zero values match every job.
+type UploadJobFilter struct {
+	Status        JobStatus
+	UpdatedBefore time.Time
+	Limit         int
+}
+
+// ScannerState describes the virus scanner's workers and queue.
+type ScannerState struct {
+	Paused        bool       `json:"paused"`
+	Workers       int        `json:"workers"`
+	ActiveWorkers int        `json:"activeWorkers"`
+	QueueLength   int        `json:"queueLength"`
+	QueueCapacity int        `json:"queueCapacity"`
+	LastPollAt    *time.Time `json:"lastPollAt,omitempty"`
+}
+
+type SearchResult struct {
+	FileID    string  `json:"fileId"`
+	Rank      float64 `json:"rank"`
+	Highlight string  `json:"highlight"`
+}
+
+type Permission string
+
+const (
+	PermissionRead   Permission = "read"
+	PermissionDelete Permission = "delete"
+	PermissionUpload Permission = "upload"
+)
+
+type PrincipalType string
+
+const (
+	PrincipalUser  PrincipalType = "user"
+	PrincipalGroup PrincipalType = "group"
+)
+
+// GrantResourceFile is the resource type of grants on a single file. Any other
+// resource type names a linked resource type, e.g. "company".
+const GrantResourceFile = "file"
+
+// FileGrant gives a user or group a permission on a file or on all files of a
+// linked resource. FileType optionally restricts upload grants to one file type.
+type FileGrant struct {
+	ID            string        `json:"id"`
+	PrincipalType PrincipalType `json:"principalType"`
+	PrincipalID   string        `json:"principalId"`
+	ResourceType  string        `json:"resourceType"`
+	ResourceID    string        `json:"resourceId"`
+	FileType      string        `json:"fileType,omitempty"`
+	Permission    Permission    `json:"permission"`
+	CreatedBy     string        `json:"createdBy"`
+	CreatedAt     time.Time     `json:"createdAt"`
+}
+
+type FileGrantFilter struct {
+	PrincipalType PrincipalType
+	PrincipalID   string
+	ResourceType  string
+	ResourceID    string
+}
+
+// APIKey authenticates a service as OwnerID with the given OAuth scopes. Only
+// the SHA-256 hash of the key is stored; Prefix identifies it in listings.
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	OwnerID    string     `json:"ownerId"`
+	Scopes     []string   `json:"scopes"`
+	Prefix     string     `json:"prefix"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	CreatedBy  string     `json:"createdBy"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+type AuditAction string
+
+const (
+	AuditJobCreated           AuditAction = "job.created"
+	AuditFileUploaded         AuditAction = "file.uploaded"
+	AuditFileScanned          AuditAction = "file.scanned"
+	AuditFileMetadataRead     AuditAction = "file.metadata_read"
+	AuditFileDownloaded       AuditAction = "file.downloaded"
+	AuditFileDeleted          AuditAction = "file.deleted"
+	AuditAuthorizationChanged AuditAction = "authorization.changed"
+	// AuditAccessDenied records requests that were rejected before reaching
+	// an audited route, e.g. for a missing token, scope or role, or a rate
+	// limit.
+	AuditAccessDenied AuditAction = "access.denied"
+)
+
+type AuditOutcome string
+
+const (
+	AuditOutcomeSuccess AuditOutcome = "success"
+	AuditOutcomeDenied  AuditOutcome = "denied"
+	AuditOutcomeFailure AuditOutcome = "failure"
+)
+
+// AuditEvent records who did what to which file. Details holds action
+// specific values such as the scan verdict or the IDs of a changed grant.
+type AuditEvent struct {
+	ID                 string            `json:"id"`
+	OccurredAt         time.Time         `json:"occurredAt"`
+	Action             AuditAction       `json:"action"`
+	Outcome            AuditOutcome      `json:"outcome"`
+	UserID             string            `json:"userId,omitempty"`
+	ClientIP           string            `json:"clientIp,omitempty"`
+	RequestID          string            `json:"requestId,omitempty"`
+	FileID             string            `json:"fileId,omitempty"`
+	LinkedResourceType string            `json:"linkedResourceType,omitempty"`
+	LinkedResourceID   string            `json:"linkedResourceId,omitempty"`
+	Details            map[string]string `json:"details,omitempty"`
+}
+
+// AuditEventFilter selects audit events. Zero values match every event.
+type AuditEventFilter struct {
+	Action             AuditAction
+	Outcome            AuditOutcome
+	UserID             string
+	FileID             string
+	LinkedResourceType string
+	LinkedResourceID   string
+	From               time.Time
+	To                 time.Time
+	Limit              int
+	Offset             int
+}
+
+const (
+	WebhookEventJobCompleted = "job.completed"
+	WebhookEventJobFailed    = "job.failed"
+)
+
+// WebhookSubscription receives the job events of a single job (JobID) or of
+// every job whose file is linked to a resource of LinkedResourceType. Without
+// a Secret, deliveries are signed with the service wide webhook secret.
+type WebhookSubscription struct {
+	ID                 string    `json:"id"`
+	URL                string    `json:"url"`
+	Secret             string    `json:"-"`
+	JobID              string    `json:"jobId,omitempty"`
+	LinkedResourceType string    `json:"linkedResourceType,omitempty"`
+	CreatedBy          string    `json:"createdBy"`
+	CreatedAt          time.Time `json:"createdAt"`
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDelivery is one event sent to one subscription, including the state
+// of its attempts. Secret is filled in from the subscription when deliveries
+// are claimed for sending.
+type WebhookDelivery struct {
+	ID             string                `json:"id"`
+	SubscriptionID string                `json:"subscriptionId"`
+	JobID          string                `json:"jobId"`
+	URL            string                `json:"url"`
+	EventType      string                `json:"eventType"`
+	Payload        json.RawMessage       `json:"payload"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttemptAt  time.Time             `json:"nextAttemptAt"`
+	LastAttemptAt  *time.Time            `json:"lastAttemptAt,omitempty"`
+	LastStatusCode int                   `json:"lastStatusCode,omitempty"`
+	LastError      string                `json:"lastError,omitempty"`
+	CreatedAt      time.Time             `json:"createdAt"`
+	Secret         string                `json:"-"`
+}
+
+type WebhookDeliveryFilter struct {
+	Status WebhookDeliveryStatus
+	JobID  string
+	Limit  int
+}
+
+const (
+	EventFileCreated = "file.created"
+	EventFileDeleted = "file.deleted"
+	EventScanFailed  = "scan.failed"
+)
+
+// OutboxEvent is a file lifecycle event. It is written in the same
+// transaction as the change it describes and published afterwards, at least
+// once and in Seq order per file.
+type OutboxEvent struct {
+	ID         string          `json:"id"`
+	Seq        int64           `json:"seq"`
+	Type       string          `json:"type"`
+	FileID     string          `json:"fileId"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Payload    json.RawMessage `json:"payload"`
+	Attempts   int             `json:"-"`
+	LastError  string          `json:"-"`
+}
+
+// FileEventPayload is the payload of the file lifecycle events.
+type FileEventPayload struct {
+	JobID string    `json:"jobId,omitempty"`
+	File  *FileInfo `json:"file,omitempty"`
+	Error string    `json:"error,omitempty"`
+}
+
+// JobTransitionEvent returns the type of the event the change of a job from
+// the previous status publishes, or "" if it does not publish one. A rescan
+// that completes publishes nothing, since the file was created before.
+func JobTransitionEvent(previous JobStatus, job *UploadJob) string {
+	switch next := job.Status; {
+	case next == JobStatusCompleted && previous != JobStatusCompleted:
+		if job.Rescan {
+			return ""
+		}
+		return EventFileCreated
+	case next == JobStatusFailed && previous == JobStatusVirusChecking:
+		return EventScanFailed
+	default:
+		return ""
+	}
+}
+
+// IdempotencyRecord is the stored outcome of a request sent with an
+// Idempotency-Key. Until the request has completed, only the fingerprint is
+// known.
+type IdempotencyRecord struct {
+	UserID      string
+	Key         string
+	Fingerprint string
+	Completed   bool
+	StatusCode  int
+	ContentType string
+	Location    string
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
 }
 
 type FileStorage interface {
@@ -44,25 +308,125 @@ type FileStorage interface {
 	Delete(ctx context.Context, fileID string) error
 }
 
+// MetadataFileStorage is implemented by storages that keep metadata, e.g. the
+// content encoding, with a blob without changing its bytes.
+type MetadataFileStorage interface {
+	FileStorage
+	UploadWithMetadata(ctx context.Context, fileID string, reader io.Reader, metadata map[string]string) error
+	DownloadWithMetadata(ctx context.Context, fileID string) (io.ReadCloser, map[string]string, error)
+}
+
+const ContentEncodingIdentity = "identity"
+
+// EncodedFileStorage is implemented by storages that can hand out the stored
+// bytes in their content encoding, e.g. gzip, without decoding them.
+type EncodedFileStorage interface {
+	DownloadEncoded(ctx context.Context, fileID string) (io.ReadCloser, string, error)
+}
+
+type DataKeyProvider interface {
+	GenerateDataKey(ctx context.Context) (plaintext []byte, wrapped string, err error)
+	DecryptDataKey(ctx context.Context, wrapped string) ([]byte, error)
+	RewrapDataKey(ctx context.Context, wrapped string) (string, error)
+}
+
 type UploadJobRepository interface {
 	Create(ctx context.Context, job *UploadJob) error
 	Get(ctx context.Context, jobID string) (*UploadJob, error)
 	Update(ctx context.Context, job *UploadJob) error
+	// UpdateIfStatus stores the job only if its stored status is still
+	// expected and reports whether it did, so that concurrent status changes
+	// do not overwrite each other.
+	UpdateIfStatus(ctx context.Context, job *UploadJob, expected JobStatus) (bool, error)
 	GetByFileID(ctx context.Context, fileID string) (*UploadJob, error)
 	GetByStatus(ctx context.Context, status JobStatus) ([]*UploadJob, error)
+	// List returns the matching jobs, least recently updated first.
+	List(ctx context.Context, filter UploadJobFilter) ([]*UploadJob, error)
+	// Purge deletes the jobs in one of the statuses that were last updated
+	// before updatedBefore and returns the deleted jobs.
+	Purge(ctx context.Context, statuses []JobStatus, updatedBefore time.Time) ([]*UploadJob, error)
+	// CountByStatus returns the number of jobs in each status that has any.
+	CountByStatus(ctx context.Context) (map[JobStatus]int64, error)
+}
+
+// JobWatcher signals changes of upload jobs, including changes made by other
+// replicas.
+type JobWatcher interface {
+	// Watch returns a channel that receives a value after the job changed
+	// and a function that stops watching. Signals are coalesced, so
+	// receivers re-read the job.
+	Watch(jobID string) (<-chan struct{}, func())
 }
 
 type FileInfoRepository interface {
 	Create(ctx context.Context, fileInfo *FileInfo) error
 	Get(ctx context.Context, fileID string) (*FileInfo, error)
+	// GetMany returns the file info of the fileIDs that exist, in the order
+	// of fileIDs.
+	GetMany(ctx context.Context, fileIDs []string) ([]*FileInfo, error)
 	Update(ctx context.Context, fileInfo *FileInfo) error
 	Delete(ctx context.Context, fileID string) error
 }
 
+// Repositories are the repositories a unit of work runs against.
+type Repositories struct {
+	Jobs               UploadJobRepository
+	FileInfos          FileInfoRepository
+	FileAuthorizations FileAuthorizationRegistry
+	Webhooks           WebhookRepository
+}
+
+// UnitOfWork makes changes to upload jobs, file info, file authorizations
+// and webhook subscriptions all-or-nothing.
+type UnitOfWork interface {
+	// Do calls fn with repositories whose changes are stored only if fn
+	// returns nil. The repositories must not be used after Do returns.
+	Do(ctx context.Context, fn func(repos Repositories) error) error
+}
+
+// PostScanStage is run by the virus scanner for every file that passed the
+// scan, after its job has been marked COMPLETED.
+type PostScanStage interface {
+	Name() string
+	Process(ctx context.Context, fileInfo *FileInfo) error
+}
+
+type ThumbnailStore interface {
+	Sizes() []int
+	OpenThumbnail(ctx context.Context, fileID string, size int) (io.ReadCloser, error)
+	DeleteThumbnails(ctx context.Context, fileID string) error
+}
+
+type FileSearchIndex interface {
+	Index(ctx context.Context, fileID string, content string) error
+	Search(ctx context.Context, query string, limit, offset int) ([]*SearchResult, error)
+	Delete(ctx context.Context, fileID string) error
+}
+
+// ScannerControl lets operators pause the virus scanner and inspect it.
+type ScannerControl interface {
+	Pause()
+	Resume()
+	State() ScannerState
+	// IsStuck reports whether a job that is being scanned was not updated
+	// for longer than the stuck job timeout at now.
+	IsStuck(job *UploadJob, now time.Time) bool
+}
+
 type MetricsCollector interface {
 	RecordUploadDuration(status string, duration time.Duration)
 	RecordUploadSize(size int64)
+	RecordDownloadDuration(status string, duration time.Duration)
+	RecordDownloadSize(size int64)
+	RecordDeleteDuration(status string, duration time.Duration)
 	RecordVirusCheckDuration(status string, duration time.Duration)
+	// RecordHTTPRequest records a served request. route is the route
+	// pattern, e.g. /files/:fileId, so that IDs do not become label values.
+	RecordHTTPRequest(method, route string, status int, duration time.Duration, requestSize, responseSize int64)
+	RecordJWKSFetch(status string, duration time.Duration)
+	// RecordAuthorizationCheck records a file access decision; result is
+	// allowed, denied or error.
+	RecordAuthorizationCheck(action, result string, duration time.Duration)
 }
 
 type VirusChecker interface {
@@ -76,10 +440,110 @@ type AuthorizationService interface {
 }
 
 type FileAuthorization interface {
-	CanUploadFile(userID, fileType, linkedResourceType, linkedResourceID string) (bool, error)
-	CanReadFile(userID, fileID string) (bool, error)
-	CanDeleteFile(userID, fileID string) (bool, error)
+	CanUploadFile(ctx context.Context, userID, fileType, linkedResourceType, linkedResourceID string) (bool, error)
+	CanReadFile(ctx context.Context, userID, fileID string) (bool, error)
+	CanDeleteFile(ctx context.Context, userID, fileID string) (bool, error)
+	// FilterReadableFiles returns the files the user may read, in order, with
+	// as few lookups as the implementation allows.
+	FilterReadableFiles(ctx context.Context, userID string, files []*FileInfo) ([]*FileInfo, error)
+
+	FileAuthorizationRegistry
+}
+
+// FileAuthorizationRegistry registers files with a FileAuthorization, so that
+// it can authorize access to them.
+type FileAuthorizationRegistry interface {
+	CreateFileAuthorization(ctx context.Context, fileID, fileType, linkedResourceID, linkedResourceType string) error
+	RemoveFileAuthorization(ctx context.Context, fileID, fileType, linkedResourceID, linkedResourceType string) error
+}
+
+// FileGrantRepository manages the grants and group memberships evaluated by
+// ACL based FileAuthorization implementations.
+type FileGrantRepository interface {
+	CreateGrant(ctx context.Context, grant *FileGrant) error
+	DeleteGrant(ctx context.Context, grantID string) error
+	ListGrants(ctx context.Context, filter FileGrantFilter) ([]*FileGrant, error)
+
+	AddGroupMember(ctx context.Context, groupID, userID string) error
+	RemoveGroupMember(ctx context.Context, groupID, userID string) error
+	ListGroupMembers(ctx context.Context, groupID string) ([]string, error)
+}
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *APIKey, keyHash string) error
+	// GetByHash returns nil if no key has the hash.
+	GetByHash(ctx context.Context, keyHash string) (*APIKey, error)
+	List(ctx context.Context, ownerID string) ([]*APIKey, error)
+	Revoke(ctx context.Context, keyID string, revokedAt time.Time) error
+	UpdateLastUsed(ctx context.Context, keyID string, usedAt time.Time) error
+}
+
+// AuditLog is append-only: events can be recorded and read, never changed.
+type AuditLog interface {
+	Record(ctx context.Context, event *AuditEvent) error
+	// Query returns the matching events in the order they occurred.
+	Query(ctx context.Context, filter AuditEventFilter) ([]*AuditEvent, error)
+	// Export calls fn for every matching event in the order they occurred,
+	// ignoring Limit and Offset, and stops at the first error.
+	Export(ctx context.Context, filter AuditEventFilter, fn func(*AuditEvent) error) error
+}
+
+type WebhookRepository interface {
+	CreateSubscription(ctx context.Context, subscription *WebhookSubscription) error
+	DeleteSubscription(ctx context.Context, subscriptionID string) error
+	ListSubscriptions(ctx context.Context) ([]*WebhookSubscription, error)
+	// MatchingSubscriptions returns the subscriptions of the job and of the
+	// linked resource type.
+	MatchingSubscriptions(ctx context.Context, jobID, linkedResourceType string) ([]*WebhookSubscription, error)
+
+	CreateDelivery(ctx context.Context, delivery *WebhookDelivery) error
+	// GetDelivery returns nil if the delivery does not exist.
+	GetDelivery(ctx context.Context, deliveryID string) (*WebhookDelivery, error)
+	ListDeliveries(ctx context.Context, filter WebhookDeliveryFilter) ([]*WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery *WebhookDelivery) error
+	// ClaimDueDeliveries returns up to limit pending deliveries whose next
+	// attempt is due and moves their next attempt lease into the future, so
+	// that other replicas do not send them at the same time.
+	ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*WebhookDelivery, error)
+}
+
+type EventPublisher interface {
+	Publish(ctx context.Context, event *OutboxEvent) error
+}
+
+type Outbox interface {
+	// Relay passes the oldest unpublished event of each file whose next
+	// attempt is due to publish, up to limit events in Seq order, and marks
+	// them published or schedules a retry. The events are claimed by one
+	// relay at a time and published outside of a transaction, so a file's
+	// events are published in order. It returns how many events were
+	// published.
+	Relay(ctx context.Context, limit int, publish func(*OutboxEvent) error) (int, error)
+	// DeletePublished deletes the events published before the given time and
+	// returns how many were deleted.
+	DeletePublished(ctx context.Context, before time.Time) (int64, error)
+}
+
+type IdempotencyStore interface {
+	// Begin stores a new, not yet completed record unless the user has an
+	// unexpired record for the key. It returns the existing record in that
+	// case and nil otherwise.
+	Begin(ctx context.Context, record *IdempotencyRecord) (*IdempotencyRecord, error)
+	// Complete stores the response of the request that began the record.
+	Complete(ctx context.Context, record *IdempotencyRecord) error
+	// Release deletes a record that has not completed, so that the request
+	// can be retried.
+	Release(ctx context.Context, userID, key string) error
+	// DeleteExpired deletes the records that expired before now and returns
+	// how many were deleted.
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
 
-	CreateFileAuthorization(fileID, fileType, linkedResourceID, linkedResourceType string) error
-	RemoveFileAuthorization(fileID, fileType, linkedResourceID, linkedResourceType string) error
+// RateLimiter keeps a token bucket per key, e.g. per route group and client.
+type RateLimiter interface {
+	// Take takes a token from the bucket of key for a request at now.
+	Take(ctx context.Context, key string, limit RateLimit, now time.Time) (RateLimitResult, error)
+	// DeleteExpired deletes the buckets that are full at now and returns how
+	// many were deleted.
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
 }
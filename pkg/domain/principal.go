@@ -0,0 +1,43 @@
+package domain
+
+import "context"
+
+// Principal is the authenticated caller of a request, with the roles and the
+// raw token claims that authorization implementations may evaluate.
+type Principal struct {
+	UserID string
+	Roles  []string
+	Scopes []string
+	Claims map[string]any
+}
+
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type principalContextKey struct{}
+
+func ContextWithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal stored by the auth middleware, or
+// nil for background work such as the virus scanner.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalContextKey{}).(*Principal)
+	return principal
+}
@@ -0,0 +1,97 @@
+package domain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit allows Requests requests per Period from a token bucket that
+// holds Requests tokens, so a client that was idle can send them at once. The
+// zero value allows every request.
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+var rateLimitPeriods = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+}
+
+// ParseRateLimit parses limits like "100/m": a number of requests per
+// second (s), minute (m) or hour (h). An empty value disables the limit.
+func ParseRateLimit(value string) (RateLimit, error) {
+	if value == "" {
+		return RateLimit{}, nil
+	}
+
+	requests, unit, ok := strings.Cut(value, "/")
+	period, known := rateLimitPeriods[unit]
+	if !ok || !known {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q, expected <requests>/<s|m|h>", value)
+	}
+	n, err := strconv.Atoi(requests)
+	if err != nil || n < 1 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q, expected a positive number of requests", value)
+	}
+	return RateLimit{Requests: n, Period: period}, nil
+}
+
+func (l RateLimit) Enabled() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+// RateLimitBucket is the state of a token bucket between requests.
+type RateLimitBucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+	// FullAt is when the bucket is refilled. A bucket that is full can be
+	// deleted, because a missing bucket starts full.
+	FullAt time.Time
+}
+
+// RateLimitResult is the outcome of taking a token.
+type RateLimitResult struct {
+	Allowed bool
+	// Limit and Remaining are the requests of a full and of the current
+	// bucket.
+	Limit     int
+	Remaining int
+	// RetryAfter is the time until a rejected request can be retried.
+	RetryAfter time.Duration
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+}
+
+// Take refills the bucket for the time since it was last updated and takes a
+// token if there is one. A bucket that was never updated starts full.
+func (l RateLimit) Take(bucket *RateLimitBucket, now time.Time) RateLimitResult {
+	capacity := float64(l.Requests)
+	perSecond := capacity / l.Period.Seconds()
+
+	if bucket.UpdatedAt.IsZero() {
+		bucket.Tokens = capacity
+	} else if elapsed := now.Sub(bucket.UpdatedAt); elapsed > 0 {
+		bucket.Tokens = min(capacity, bucket.Tokens+elapsed.Seconds()*perSecond)
+	}
+	bucket.UpdatedAt = now
+
+	result := RateLimitResult{Limit: l.Requests}
+	if bucket.Tokens >= 1 {
+		bucket.Tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - bucket.Tokens) / perSecond)
+	}
+	result.Remaining = int(bucket.Tokens)
+	result.Reset = seconds((capacity - bucket.Tokens) / perSecond)
+	bucket.FullAt = now.Add(result.Reset)
+	return result
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
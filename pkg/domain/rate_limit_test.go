@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	limit, err := ParseRateLimit("100/m")
+	require.NoError(t, err)
+	assert.Equal(t, RateLimit{Requests: 100, Period: time.Minute}, limit)
+	assert.True(t, limit.Enabled())
+
+	limit, err = ParseRateLimit("")
+	require.NoError(t, err)
+	assert.False(t, limit.Enabled())
+
+	for _, value := range []string{"100", "100/d", "0/s", "-1/s", "x/s", "/m"} {
+		_, err := ParseRateLimit(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestRateLimit_Take(t *testing.T) {
+	limit := RateLimit{Requests: 2, Period: time.Second}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	bucket := &RateLimitBucket{}
+
+	result := limit.Take(bucket, now)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 2, result.Limit)
+	assert.Equal(t, 1, result.Remaining)
+	assert.Equal(t, 500*time.Millisecond, result.Reset)
+
+	result = limit.Take(bucket, now)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+
+	result = limit.Take(bucket, now.Add(250*time.Millisecond))
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+	assert.Equal(t, 250*time.Millisecond, result.RetryAfter)
+	assert.Equal(t, now.Add(time.Second), bucket.FullAt)
+
+	result = limit.Take(bucket, now.Add(500*time.Millisecond))
+	assert.True(t, result.Allowed)
+
+	// Refills stop at the limit.
+	result = limit.Take(bucket, now.Add(time.Hour))
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 1, result.Remaining)
+}
@@ -0,0 +1,35 @@
+package ecsslog
+
+import "log/slog"
+
+// ECS field names shared by the service's log lines, so that lines about the
+// same file, user or job can be searched for the same way.
+const (
+	EventActionKey  = "event.action"
+	ErrorMessageKey = "error.message"
+	FileIDKey       = "file.id"
+	UserIDKey       = "user.id"
+	JobIDKey        = "job.id"
+)
+
+// Action returns the event.action field, e.g. "file.scan".
+func Action(action string) slog.Attr {
+	return slog.String(EventActionKey, action)
+}
+
+// Err returns the error.message field.
+func Err(err error) slog.Attr {
+	return slog.String(ErrorMessageKey, err.Error())
+}
+
+func FileID(fileID string) slog.Attr {
+	return slog.String(FileIDKey, fileID)
+}
+
+func UserID(userID string) slog.Attr {
+	return slog.String(UserIDKey, userID)
+}
+
+func JobID(jobID string) slog.Attr {
+	return slog.String(JobIDKey, jobID)
+}
@@ -0,0 +1,160 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// CheckFunc returns an error if the component is not usable.
+type CheckFunc func(ctx context.Context) error
+
+type ComponentStatus struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	LatencyMs int64     `json:"latencyMs"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// Report is up if every component is up.
+type Report struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+type check struct {
+	fn CheckFunc
+
+	// mu lets a single check run at a time; callers arriving meanwhile get
+	// its result.
+	mu      sync.Mutex
+	result  ComponentStatus
+	expires time.Time
+}
+
+// Checker runs the registered checks concurrently, each with a timeout, and
+// caches their results for cacheTTL, so that frequent probes do not load the
+// dependencies.
+type Checker struct {
+	timeout  time.Duration
+	cacheTTL time.Duration
+
+	mu     sync.RWMutex
+	checks map[string]*check
+}
+
+func NewChecker(timeout, cacheTTL time.Duration) *Checker {
+	return &Checker{
+		timeout:  timeout,
+		cacheTTL: cacheTTL,
+		checks:   make(map[string]*check),
+	}
+}
+
+// Register adds a check for the named component, replacing an existing one.
+func (c *Checker) Register(name string, fn CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checks[name] = &check{fn: fn}
+}
+
+// Names returns the names of the registered components, sorted.
+func (c *Checker) Names() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	names := make([]string, 0, len(c.checks))
+	for name := range c.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c *Checker) Check(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := make(map[string]*check, len(c.checks))
+	for name, registered := range c.checks {
+		checks[name] = registered
+	}
+	c.mu.RUnlock()
+
+	report := Report{Status: StatusUp, Components: make(map[string]ComponentStatus, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, registered := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := c.run(ctx, registered)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Components[name] = result
+			if result.Status != StatusUp {
+				report.Status = StatusDown
+			}
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, registered *check) ComponentStatus {
+	registered.mu.Lock()
+	defer registered.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(registered.expires) {
+		return registered.result
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	err := runCheck(checkCtx, registered.fn)
+
+	result := ComponentStatus{
+		Status:    StatusUp,
+		LatencyMs: time.Since(now).Milliseconds(),
+		CheckedAt: now,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+
+	// A result cut short by the caller going away says nothing about the
+	// component, so it is not cached.
+	if ctx.Err() == nil {
+		registered.result = result
+		registered.expires = now.Add(c.cacheTTL)
+	}
+	return result
+}
+
+// runCheck returns when the check returns or ctx is done, whichever comes
+// first, so that a check ignoring its context cannot block the probe.
+func runCheck(ctx context.Context, fn CheckFunc) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return errors.New("check timed out")
+		}
+		return ctx.Err()
+	}
+}
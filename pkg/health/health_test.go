@@ -0,0 +1,86 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecker_ReportsEachComponent(t *testing.T) {
+	checker := NewChecker(time.Second, 0)
+	checker.Register("postgres", func(ctx context.Context) error { return nil })
+	checker.Register("blobStorage", func(ctx context.Context) error { return errors.New("connection refused") })
+
+	report := checker.Check(context.Background())
+	assert.Equal(t, StatusDown, report.Status)
+	require.Len(t, report.Components, 2)
+	assert.Equal(t, StatusUp, report.Components["postgres"].Status)
+	assert.Empty(t, report.Components["postgres"].Error)
+	assert.Equal(t, StatusDown, report.Components["blobStorage"].Status)
+	assert.Equal(t, "connection refused", report.Components["blobStorage"].Error)
+	assert.Equal(t, []string{"blobStorage", "postgres"}, checker.Names())
+}
+
+func TestChecker_NoComponentsIsUp(t *testing.T) {
+	report := NewChecker(time.Second, time.Second).Check(context.Background())
+	assert.Equal(t, StatusUp, report.Status)
+	assert.Empty(t, report.Components)
+}
+
+func TestChecker_TimesOutSlowChecks(t *testing.T) {
+	checker := NewChecker(50*time.Millisecond, 0)
+	release := make(chan struct{})
+	defer close(release)
+	checker.Register("keycloak", func(ctx context.Context) error {
+		// Ignores ctx, like a client without a timeout would.
+		<-release
+		return nil
+	})
+	checker.Register("postgres", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	start := time.Now()
+	report := checker.Check(context.Background())
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, StatusDown, report.Status)
+	assert.Equal(t, "check timed out", report.Components["keycloak"].Error)
+	assert.Equal(t, "check timed out", report.Components["postgres"].Error)
+}
+
+func TestChecker_CachesResults(t *testing.T) {
+	checker := NewChecker(time.Second, 100*time.Millisecond)
+	var calls atomic.Int32
+	checker.Register("virusChecker", func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	})
+
+	first := checker.Check(context.Background())
+	second := checker.Check(context.Background())
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, first.Components["virusChecker"].CheckedAt, second.Components["virusChecker"].CheckedAt)
+
+	time.Sleep(150 * time.Millisecond)
+	checker.Check(context.Background())
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestChecker_DoesNotCacheCanceledChecks(t *testing.T) {
+	checker := NewChecker(time.Second, time.Hour)
+	checker.Register("postgres", func(ctx context.Context) error {
+		return ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, StatusDown, checker.Check(ctx).Status)
+
+	assert.Equal(t, StatusUp, checker.Check(context.Background()).Status)
+}
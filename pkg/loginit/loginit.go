@@ -1,27 +1,42 @@
 package loginit
 
 import (
+	"fmt"
 	"log/slog"
 	"os"
 	"strings"
 
 	"file-storage-go/pkg/ecsslog"
+	"file-storage-go/pkg/requestid"
 )
 
-// InitLogger initializes the global slog logger based on the LOG_FORMAT environment variable.
+// InitLogger initializes the global slog logger based on the LOG_FORMAT and LOG_LEVEL environment variables.
 // If LOG_FORMAT=ecs, it uses the ECS handler; otherwise, it uses the default text handler.
-// Returns the configured logger.
-func InitLogger() *slog.Logger {
+// LOG_LEVEL is debug, info, warn or error and defaults to info.
+// Returns the configured logger and the level, which can be changed at runtime. An invalid LOG_LEVEL is
+// returned as an error together with a logger at the info level.
+func InitLogger() (*slog.Logger, *slog.LevelVar, error) {
 	format := strings.ToLower(os.Getenv("LOG_FORMAT"))
-	var handler slog.Handler
 
+	level := new(slog.LevelVar)
+	var err error
+	if name := os.Getenv("LOG_LEVEL"); name != "" {
+		var parsed slog.Level
+		if err = parsed.UnmarshalText([]byte(name)); err != nil {
+			err = fmt.Errorf("invalid LOG_LEVEL %q: %w", name, err)
+		} else {
+			level.Set(parsed)
+		}
+	}
+
+	var handler slog.Handler
 	if format == "ecs" {
-		handler = ecsslog.NewECSHandler(os.Stdout, ecsslog.Config{})
+		handler = ecsslog.NewECSHandler(os.Stdout, ecsslog.Config{HandlerOptions: slog.HandlerOptions{Level: level}})
 	} else {
-		handler = slog.NewTextHandler(os.Stdout, nil)
+		handler = slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: level})
 	}
 
-	logger := slog.New(handler)
+	logger := slog.New(requestid.NewLogHandler(handler))
 	slog.SetDefault(logger)
-	return logger
+	return logger, level, err
 }
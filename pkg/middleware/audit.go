@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"file-storage-go/pkg/domain"
+	"file-storage-go/pkg/ecsslog"
+	"file-storage-go/pkg/requestid"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	auditFileIDKey             = "auditFileId"
+	auditLinkedResourceTypeKey = "auditLinkedResourceType"
+	auditLinkedResourceIDKey   = "auditLinkedResourceId"
+	auditDetailsKey            = "auditDetails"
+	auditRecordedKey           = "auditRecorded"
+)
+
+// SetAuditResource names the file and linked resource of an audited request
+// that are not part of its path. Empty values are ignored.
+func SetAuditResource(c *gin.Context, fileID, linkedResourceType, linkedResourceID string) {
+	if fileID != "" {
+		c.Set(auditFileIDKey, fileID)
+	}
+	if linkedResourceType != "" {
+		c.Set(auditLinkedResourceTypeKey, linkedResourceType)
+	}
+	if linkedResourceID != "" {
+		c.Set(auditLinkedResourceIDKey, linkedResourceID)
+	}
+}
+
+// SetAuditDetail adds a value to the details of an audited request's event.
+func SetAuditDetail(c *gin.Context, key, value string) {
+	details, _ := c.Get(auditDetailsKey)
+	detailMap, ok := details.(map[string]string)
+	if !ok {
+		detailMap = make(map[string]string)
+		c.Set(auditDetailsKey, detailMap)
+	}
+	detailMap[key] = value
+}
+
+// Audit records an event for every request to the route once its handler has
+// run. 401, 403 and 429 responses are recorded as denied and other errors as
+// failures. The route's path parameters, except fileId, are added to the
+// event's details. Without an audit log nothing is recorded.
+func Audit(auditLog domain.AuditLog, action domain.AuditAction, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if auditLog == nil {
+			return
+		}
+		recordAuditEvent(c, auditLog, action, logger)
+	}
+}
+
+// AuditDenials records an access.denied event for every request that was
+// rejected with 401, 403 or 429 and not recorded by a route's Audit, e.g.
+// because authentication, a scope, role or rate limit check rejected it
+// first. It must run before those checks.
+func AuditDenials(auditLog domain.AuditLog, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if auditLog == nil || publicPaths[c.Request.URL.Path] || c.GetBool(auditRecordedKey) {
+			return
+		}
+		if auditOutcome(c.Writer.Status()) == domain.AuditOutcomeDenied {
+			recordAuditEvent(c, auditLog, domain.AuditAccessDenied, logger)
+		}
+	}
+}
+
+func recordAuditEvent(c *gin.Context, auditLog domain.AuditLog, action domain.AuditAction, logger *slog.Logger) {
+	c.Set(auditRecordedKey, true)
+
+	// Requests rejected before routing, e.g. for an unknown path, have no
+	// route pattern.
+	route := c.FullPath()
+	if route == "" {
+		route = c.Request.URL.Path
+	}
+	details := map[string]string{
+		"route":  c.Request.Method + " " + route,
+		"status": strconv.Itoa(c.Writer.Status()),
+	}
+	for _, param := range c.Params {
+		if param.Key != "fileId" {
+			details[param.Key] = param.Value
+		}
+	}
+	if extra, ok := c.Get(auditDetailsKey); ok {
+		for key, value := range extra.(map[string]string) {
+			details[key] = value
+		}
+	}
+
+	fileID := c.Param("fileId")
+	if fileID == "" {
+		fileID = c.GetString(auditFileIDKey)
+	}
+
+	event := &domain.AuditEvent{
+		ID:                 uuid.New().String(),
+		OccurredAt:         time.Now(),
+		Action:             action,
+		Outcome:            auditOutcome(c.Writer.Status()),
+		UserID:             c.GetString("userId"),
+		ClientIP:           c.ClientIP(),
+		RequestID:          requestid.FromContext(c.Request.Context()),
+		FileID:             fileID,
+		LinkedResourceType: c.GetString(auditLinkedResourceTypeKey),
+		LinkedResourceID:   c.GetString(auditLinkedResourceIDKey),
+		Details:            details,
+	}
+
+	// The event is recorded even if the client has gone away.
+	if err := auditLog.Record(context.WithoutCancel(c.Request.Context()), event); err != nil {
+		logger.LogAttrs(c.Request.Context(), slog.LevelError, "Failed to record audit event",
+			ecsslog.Action(string(action)), ecsslog.UserID(event.UserID), ecsslog.FileID(fileID), slog.String("http.route", event.Details["route"]), ecsslog.Err(err))
+	}
+}
+
+func auditOutcome(status int) domain.AuditOutcome {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden || status == http.StatusTooManyRequests:
+		return domain.AuditOutcomeDenied
+	case status >= 400:
+		return domain.AuditOutcomeFailure
+	default:
+		return domain.AuditOutcomeSuccess
+	}
+}
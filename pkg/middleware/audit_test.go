@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"file-storage-go/pkg/adapters/repository"
+	"file-storage-go/pkg/domain"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAudit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auditLog := repository.NewInMemoryAuditLog()
+
+	r := gin.New()
+	r.Use(RequestID())
+	r.Use(func(c *gin.Context) { c.Set("userId", "alice") })
+	r.GET("/files/:fileId/download", Audit(auditLog, domain.AuditFileDownloaded, testLogger), func(c *gin.Context) {
+		if c.Param("fileId") == "secret" {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		SetAuditResource(c, "", "company", "3")
+		c.Status(http.StatusOK)
+	})
+	r.POST("/upload-jobs", Audit(auditLog, domain.AuditJobCreated, testLogger), func(c *gin.Context) {
+		SetAuditResource(c, "file-2", "company", "3")
+		SetAuditDetail(c, "jobId", "job-2")
+		c.Status(http.StatusInternalServerError)
+	})
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/files/file-1/download", nil),
+		httptest.NewRequest(http.MethodGet, "/files/secret/download", nil),
+		httptest.NewRequest(http.MethodPost, "/upload-jobs", nil),
+	} {
+		req.Header.Set("X-Request-ID", "req-1")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	events, err := auditLog.Query(context.Background(), domain.AuditEventFilter{})
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+
+	assert.Equal(t, domain.AuditFileDownloaded, events[0].Action)
+	assert.Equal(t, domain.AuditOutcomeSuccess, events[0].Outcome)
+	assert.Equal(t, "alice", events[0].UserID)
+	assert.Equal(t, "req-1", events[0].RequestID)
+	assert.Equal(t, "file-1", events[0].FileID)
+	assert.Equal(t, "company", events[0].LinkedResourceType)
+	assert.Equal(t, "3", events[0].LinkedResourceID)
+	assert.Equal(t, "GET /files/:fileId/download", events[0].Details["route"])
+	assert.NotEmpty(t, events[0].ClientIP)
+
+	assert.Equal(t, domain.AuditOutcomeDenied, events[1].Outcome)
+	assert.Equal(t, "secret", events[1].FileID)
+
+	assert.Equal(t, domain.AuditJobCreated, events[2].Action)
+	assert.Equal(t, domain.AuditOutcomeFailure, events[2].Outcome)
+	assert.Equal(t, "file-2", events[2].FileID)
+	assert.Equal(t, "job-2", events[2].Details["jobId"])
+}
+
+func TestAuditDenials(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auditLog := repository.NewInMemoryAuditLog()
+
+	r := gin.New()
+	r.Use(AuditDenials(auditLog, testLogger))
+	r.Use(func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Set("userId", "alice")
+	})
+	limited := r.Group("", func(c *gin.Context) { c.AbortWithStatus(http.StatusTooManyRequests) })
+	limited.GET("/files/:fileId", Audit(auditLog, domain.AuditFileMetadataRead, testLogger), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	r.DELETE("/files/:fileId", Audit(auditLog, domain.AuditFileDeleted, testLogger), func(c *gin.Context) {
+		c.AbortWithStatus(http.StatusForbidden)
+	})
+	r.GET("/upload-jobs/:jobId", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/upload-jobs/job-1", nil),
+		httptest.NewRequest(http.MethodGet, "/files/file-1", nil),
+		httptest.NewRequest(http.MethodDelete, "/files/file-1", nil),
+		httptest.NewRequest(http.MethodGet, "/health", nil),
+	} {
+		req.Header.Set("Authorization", "Bearer token")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/upload-jobs/job-2", nil))
+
+	events, err := auditLog.Query(context.Background(), domain.AuditEventFilter{})
+	require.NoError(t, err)
+	require.Len(t, events, 3, "allowed requests to unaudited routes are not recorded")
+
+	assert.Equal(t, domain.AuditAccessDenied, events[0].Action, "rate limited requests are recorded")
+	assert.Equal(t, domain.AuditOutcomeDenied, events[0].Outcome)
+	assert.Equal(t, "alice", events[0].UserID)
+	assert.Equal(t, "file-1", events[0].FileID)
+	assert.Equal(t, "429", events[0].Details["status"])
+
+	assert.Equal(t, domain.AuditFileDeleted, events[1].Action, "denials recorded by the route are not recorded twice")
+	assert.Equal(t, domain.AuditOutcomeDenied, events[1].Outcome)
+
+	assert.Equal(t, domain.AuditAccessDenied, events[2].Action)
+	assert.Empty(t, events[2].UserID)
+	assert.Equal(t, "GET /upload-jobs/:jobId", events[2].Details["route"])
+	assert.Equal(t, "401", events[2].Details["status"])
+}
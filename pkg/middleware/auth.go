@@ -1,81 +1,113 @@
 package middleware
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
+	"strings"
 
 	"file-storage-go/pkg/auth"
+	"file-storage-go/pkg/domain"
+	"file-storage-go/pkg/ecsslog"
 
 	"github.com/gin-gonic/gin"
 )
 
 type AuthMiddlewareConfig struct {
 	JWTVerifier auth.JWTVerifierInterface
+	// APIKeyAuthenticator, if set, accepts an X-API-Key header instead of a
+	// bearer token.
+	APIKeyAuthenticator auth.APIKeyAuthenticator
+	// ClientID selects the client roles that are added to the principal.
+	ClientID string
+	Logger   *slog.Logger
+}
+
+// publicPaths are served without authentication and are not logged.
+var publicPaths = map[string]bool{
+	"/health":  true,
+	"/livez":   true,
+	"/readyz":  true,
+	"/metrics": true,
 }
 
 func NewAuthMiddleware(config AuthMiddlewareConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if c.Request.URL.Path == "/health" || c.Request.URL.Path == "/metrics" {
+		if publicPaths[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		if apiKey := c.GetHeader(auth.APIKeyHeader); apiKey != "" && config.APIKeyAuthenticator != nil {
+			principal, err := config.APIKeyAuthenticator.Authenticate(c.Request.Context(), apiKey)
+			if err != nil {
+				logAuthFailure(c, config.Logger, "Failed to authenticate api key", ecsslog.Err(err))
+				c.AbortWithStatus(http.StatusUnauthorized)
+				return
+			}
+
+			c.Set("claims", &auth.Claims{UserId: principal.UserID, Scope: strings.Join(principal.Scopes, " "), Raw: principal.Claims})
+			c.Request = c.Request.WithContext(domain.ContextWithPrincipal(c.Request.Context(), principal))
 			c.Next()
 			return
 		}
 
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			log.Println("Authorization header missing")
+			logAuthFailure(c, config.Logger, "Authorization header missing")
 			c.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
 
 		tokenString, err := config.JWTVerifier.ExtractTokenFromHeader(authHeader)
 		if err != nil {
-			log.Printf("Failed to extract token from header: %v\n", err)
+			logAuthFailure(c, config.Logger, "Failed to extract token from header", ecsslog.Err(err))
 			c.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
 
 		token, err := config.JWTVerifier.VerifyToken(tokenString)
 		if err != nil {
-			log.Printf("Failed to verify token: %v\n", err)
+			logAuthFailure(c, config.Logger, "Failed to verify token", ecsslog.Err(err))
 			c.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
 
 		claims, ok := token.Claims.(*auth.Claims)
 		if !ok {
-			log.Printf("Invalid token claims format")
+			logAuthFailure(c, config.Logger, "Invalid token claims format")
 			c.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
 
 		c.Set("claims", claims)
+		c.Request = c.Request.WithContext(domain.ContextWithPrincipal(c.Request.Context(), claims.Principal(config.ClientID)))
 		c.Next()
 	}
 }
 
-func RequireUserId() gin.HandlerFunc {
+func RequireUserId(logger *slog.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if c.Request.URL.Path == "/health" || c.Request.URL.Path == "/metrics" {
+		if publicPaths[c.Request.URL.Path] {
 			c.Next()
 			return
 		}
 
 		claimsInterface, exists := c.Get("claims")
 		if !exists {
-			log.Printf("No claims found in context")
+			logAuthFailure(c, logger, "No claims found in context")
 			c.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
 
 		claims, ok := claimsInterface.(*auth.Claims)
 		if !ok {
-			log.Printf("Invalid claims format in context")
+			logAuthFailure(c, logger, "Invalid claims format in context")
 			c.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
 
 		if claims.UserId == "" {
-			log.Printf("Missing userId in token claims")
+			logAuthFailure(c, logger, "Missing userId in token claims")
 			c.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
@@ -84,3 +116,43 @@ func RequireUserId() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// RequireAdmin only lets principals with the admin role through. If
+// adminUserIDs is not empty, the user must also be one of them. It must run
+// after RequireUserId.
+func RequireAdmin(role string, adminUserIDs []string, logger *slog.Logger) gin.HandlerFunc {
+	return requireRole(role, adminUserIDs, "Admin access required", logger)
+}
+
+// RequireAuditor only lets principals with the auditor role through. If
+// auditorUserIDs is not empty, the user must also be one of them. It must run
+// after RequireUserId.
+func RequireAuditor(role string, auditorUserIDs []string, logger *slog.Logger) gin.HandlerFunc {
+	return requireRole(role, auditorUserIDs, "Auditor access required", logger)
+}
+
+func requireRole(role string, userIDs []string, message string, logger *slog.Logger) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(userIDs))
+	for _, userID := range userIDs {
+		allowed[userID] = true
+	}
+
+	return func(c *gin.Context) {
+		userID := c.GetString("userId")
+		principal := domain.PrincipalFromContext(c.Request.Context())
+		hasRole := principal != nil && principal.UserID == userID && principal.HasRole(role)
+		if !hasRole || (len(allowed) > 0 && !allowed[userID]) {
+			logger.LogAttrs(c.Request.Context(), slog.LevelWarn, "User was denied",
+				ecsslog.Action("auth.authorize"), ecsslog.UserID(userID), slog.String("http.request.path", c.Request.URL.Path), slog.String("event.reason", message))
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": message})
+			return
+		}
+		c.Next()
+	}
+}
+
+// logAuthFailure logs why a request was rejected with 401.
+func logAuthFailure(c *gin.Context, logger *slog.Logger, message string, attrs ...slog.Attr) {
+	attrs = append(attrs, ecsslog.Action("auth.authenticate"), slog.String("http.request.path", c.Request.URL.Path))
+	logger.LogAttrs(c.Request.Context(), slog.LevelWarn, message, attrs...)
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"file-storage-go/pkg/auth"
+	"file-storage-go/pkg/domain"
+	"file-storage-go/pkg/requestid"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testLogger = slog.New(slog.DiscardHandler)
+
+func TestAuthMiddleware_LogsFailures(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var logs bytes.Buffer
+	logger := slog.New(requestid.NewLogHandler(slog.NewJSONHandler(&logs, nil)))
+
+	r := gin.New()
+	r.Use(RequestID())
+	r.Use(NewAuthMiddleware(AuthMiddlewareConfig{JWTVerifier: auth.NewMockJWTVerifier(), Logger: logger}))
+	r.Use(RequireUserId(logger))
+	r.GET("/files/:fileId", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/files/file-1", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(logs.Bytes(), &line))
+	assert.Equal(t, "WARN", line["level"])
+	assert.Equal(t, "Authorization header missing", line["msg"])
+	assert.Equal(t, "auth.authenticate", line["event.action"])
+	assert.Equal(t, "/files/file-1", line["http.request.path"])
+	assert.Equal(t, "req-1", line[requestid.LogKey])
+
+	logs.Reset()
+	req = httptest.NewRequest(http.MethodGet, "/files/file-1", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, logs.String())
+}
+
+// withPrincipal authenticates every request as a user with the roles.
+func withPrincipal(userID string, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("userId", userID)
+		c.Request = c.Request.WithContext(domain.ContextWithPrincipal(c.Request.Context(), &domain.Principal{UserID: userID, Roles: roles}))
+	}
+}
+
+func TestRequireAdmin_LogsDeniedUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var logs bytes.Buffer
+
+	r := gin.New()
+	r.Use(withPrincipal("mallory", "user"))
+	r.GET("/admin/jobs", RequireAdmin("file-storage-admin", nil, slog.New(slog.NewJSONHandler(&logs, nil))), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/jobs", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(logs.Bytes(), &line))
+	assert.Equal(t, "auth.authorize", line["event.action"])
+	assert.Equal(t, "mallory", line["user.id"])
+	assert.Equal(t, "Admin access required", line["event.reason"])
+}
+
+func TestRequireAdmin_RequiresRoleAndOptionalAllowlist(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name         string
+		userID       string
+		roles        []string
+		adminUserIDs []string
+		expected     int
+	}{
+		{"admin role", "alice", []string{"file-storage-admin"}, nil, http.StatusOK},
+		{"listed user without role", "alice", nil, []string{"alice"}, http.StatusForbidden},
+		{"admin role and listed", "alice", []string{"file-storage-admin"}, []string{"alice"}, http.StatusOK},
+		{"admin role but not listed", "bob", []string{"file-storage-admin"}, []string{"alice"}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(withPrincipal(tt.userID, tt.roles...))
+			r.GET("/admin/jobs", RequireAdmin("file-storage-admin", tt.adminUserIDs, testLogger), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/jobs", nil))
+			assert.Equal(t, tt.expected, w.Code)
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"log/slog"
 	"strings"
 
+	"file-storage-go/pkg/ecsslog"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -15,7 +17,7 @@ func GinLoggerMiddleware(logger *slog.Logger) gin.HandlerFunc {
 
 		// Skip logging for certain paths (like health checks)
 		path := c.Request.URL.Path
-		if path == "/health" || path == "/metrics" {
+		if publicPaths[path] {
 			return
 		}
 
@@ -34,8 +36,11 @@ func GinLoggerMiddleware(logger *slog.Logger) gin.HandlerFunc {
 			slog.Int("http.response.body.bytes", c.Writer.Size()),
 		}
 
+		if userID := c.GetString("userId"); userID != "" {
+			attrs = append(attrs, ecsslog.UserID(userID))
+		}
 		if len(errs) > 0 {
-			attrs = append(attrs, slog.String("error.message", strings.Join(errs, "; ")))
+			attrs = append(attrs, slog.String(ecsslog.ErrorMessageKey, strings.Join(errs, "; ")))
 		}
 
 		if c.Writer.Status() >= 400 {
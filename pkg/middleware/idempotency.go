@@ -0,0 +1,183 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"file-storage-go/pkg/domain"
+	"file-storage-go/pkg/ecsslog"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	ContentDigestHeader      = "Content-Digest"
+	maxIdempotencyKeyLength  = 255
+	maxIdempotentBody        = 1 << 20
+)
+
+// Idempotency makes a route safe to retry. The response to the first request
+// with an Idempotency-Key header is stored for ttl and replayed to later
+// requests of the same user with that key. A request with a key that is
+// still being processed gets 409 and one with a different method, path or
+// body gets 422. 5xx responses are not stored, so the request can be
+// retried. Requests without the header pass through.
+//
+// Bodies are read into memory to fingerprint them, up to maxIdempotentBody.
+// Multipart uploads are not read: they are fingerprinted by their
+// Content-Digest header, so two uploads with the same key are only told
+// apart if the client sends one.
+func Idempotency(store domain.IdempotencyStore, ttl time.Duration, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" || store == nil {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be at most 255 characters"})
+			return
+		}
+
+		fingerprint, err := requestFingerprint(c)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body is too large"})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			return
+		}
+
+		now := time.Now()
+		record := &domain.IdempotencyRecord{
+			UserID:      c.GetString("userId"),
+			Key:         key,
+			Fingerprint: fingerprint,
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(ttl),
+		}
+
+		ctx := c.Request.Context()
+		existing, err := store.Begin(ctx, record)
+		if err != nil {
+			logger.LogAttrs(ctx, slog.LevelError, "Failed to look up idempotency key",
+				ecsslog.Action("idempotency.begin"), ecsslog.UserID(record.UserID), ecsslog.Err(err))
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up idempotency key"})
+			return
+		}
+		if existing != nil {
+			switch {
+			case existing.Fingerprint != fingerprint:
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used for a different request"})
+			case !existing.Completed:
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still being processed"})
+			default:
+				c.Header(IdempotentReplayedHeader, "true")
+				if existing.Location != "" {
+					c.Header("Location", existing.Location)
+				}
+				c.Data(existing.StatusCode, existing.ContentType, existing.Body)
+				c.Abort()
+			}
+			return
+		}
+
+		// The outcome is stored even if the client has gone away.
+		storeCtx := context.WithoutCancel(ctx)
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			if err := store.Release(storeCtx, record.UserID, key); err != nil {
+				logger.LogAttrs(storeCtx, slog.LevelError, "Failed to release idempotency key",
+					ecsslog.Action("idempotency.release"), ecsslog.UserID(record.UserID), ecsslog.Err(err))
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			return
+		}
+		record.StatusCode = c.Writer.Status()
+		record.ContentType = c.Writer.Header().Get("Content-Type")
+		record.Location = c.Writer.Header().Get("Location")
+		record.Body = recorder.body.Bytes()
+		if err := store.Complete(storeCtx, record); err != nil {
+			logger.LogAttrs(storeCtx, slog.LevelError, "Failed to store idempotent response",
+				ecsslog.Action("idempotency.complete"), ecsslog.UserID(record.UserID), ecsslog.Err(err))
+			return
+		}
+		completed = true
+	}
+}
+
+// responseRecorder keeps a copy of the response body.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// requestFingerprint hashes the method, path and body of a request. A
+// multipart body is left for the handler to stream and its Content-Digest
+// header is hashed instead. Other bodies are read, up to maxIdempotentBody,
+// and put back on the request.
+func requestFingerprint(c *gin.Context) (string, error) {
+	r := c.Request
+	h := sha256.New()
+	writeField(h, r.Method)
+	writeField(h, r.URL.Path)
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err == nil && strings.HasPrefix(mediaType, "multipart/") {
+		writeField(h, mediaType)
+		writeField(h, r.Header.Get(ContentDigestHeader))
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	var body []byte
+	if r.Body != nil {
+		body, err = io.ReadAll(http.MaxBytesReader(c.Writer, r.Body, maxIdempotentBody))
+		if err != nil {
+			return "", err
+		}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeField writes a length-prefixed value, so that adjacent values cannot
+// run into each other.
+func writeField(h hash.Hash, value string) {
+	binary.Write(h, binary.BigEndian, uint32(len(value)))
+	h.Write([]byte(value))
+}
@@ -0,0 +1,183 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"file-storage-go/pkg/adapters/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newIdempotentRouter(handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("userId", c.GetHeader("X-Test-User")) })
+	r.POST("/upload-jobs", Idempotency(repository.NewInMemoryIdempotencyStore(), time.Hour, testLogger), handler)
+	r.POST("/upload-jobs/:jobId", Idempotency(repository.NewInMemoryIdempotencyStore(), time.Hour, testLogger), handler)
+	return r
+}
+
+func idempotentRequest(r *gin.Engine, path, user, key, contentType string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	req.Header.Set("X-Test-User", user)
+	req.Header.Set("Content-Type", contentType)
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotency_ReplaysResponse(t *testing.T) {
+	var calls atomic.Int32
+	r := newIdempotentRouter(func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		n := calls.Add(1)
+		c.JSON(http.StatusCreated, gin.H{"call": n, "body": string(body)})
+	})
+	body := []byte(`{"filename":"a.pdf"}`)
+
+	first := idempotentRequest(r, "/upload-jobs", "alice", "k1", "application/json", body)
+	require.Equal(t, http.StatusCreated, first.Code)
+	assert.Contains(t, first.Body.String(), `"body":"{\"filename\":\"a.pdf\"}"`, "the handler reads the whole body")
+
+	replay := idempotentRequest(r, "/upload-jobs", "alice", "k1", "application/json", body)
+	assert.Equal(t, http.StatusCreated, replay.Code)
+	assert.Equal(t, first.Body.String(), replay.Body.String())
+	assert.Equal(t, "true", replay.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, int32(1), calls.Load())
+
+	mismatch := idempotentRequest(r, "/upload-jobs", "alice", "k1", "application/json", []byte(`{"filename":"b.pdf"}`))
+	assert.Equal(t, http.StatusUnprocessableEntity, mismatch.Code)
+
+	other := idempotentRequest(r, "/upload-jobs", "bob", "k1", "application/json", body)
+	assert.Equal(t, http.StatusCreated, other.Code, "keys are per user")
+
+	idempotentRequest(r, "/upload-jobs", "alice", "", "application/json", body)
+	assert.Equal(t, int32(3), calls.Load(), "requests without a key are not deduplicated")
+}
+
+func TestIdempotency_DoesNotStoreServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	r := newIdempotentRouter(func(c *gin.Context) {
+		if calls.Add(1) == 1 {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database unavailable"})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"jobId": "job-1"})
+	})
+
+	assert.Equal(t, http.StatusInternalServerError, idempotentRequest(r, "/upload-jobs", "alice", "k1", "application/json", nil).Code)
+	assert.Equal(t, http.StatusCreated, idempotentRequest(r, "/upload-jobs", "alice", "k1", "application/json", nil).Code)
+	assert.Equal(t, http.StatusCreated, idempotentRequest(r, "/upload-jobs", "alice", "k1", "application/json", nil).Code)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestIdempotency_ConflictWhileInProgress(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	r := newIdempotentRouter(func(c *gin.Context) {
+		close(started)
+		<-release
+		c.Status(http.StatusCreated)
+	})
+
+	done := make(chan int)
+	go func() {
+		done <- idempotentRequest(r, "/upload-jobs", "alice", "k1", "application/json", nil).Code
+	}()
+	<-started
+	assert.Equal(t, http.StatusConflict, idempotentRequest(r, "/upload-jobs", "alice", "k1", "application/json", nil).Code)
+	close(release)
+	assert.Equal(t, http.StatusCreated, <-done)
+}
+
+func multipartBody(t *testing.T, boundary, content string) (string, []byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	require.NoError(t, writer.SetBoundary(boundary))
+	part, err := writer.CreateFormFile("file", "a.txt")
+	require.NoError(t, err)
+	_, err = part.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return writer.FormDataContentType(), buf.Bytes()
+}
+
+func multipartRequest(r *gin.Engine, key, digest, contentType string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/upload-jobs/job-1", bytes.NewReader(body))
+	req.Header.Set("X-Test-User", "alice")
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set(IdempotencyKeyHeader, key)
+	if digest != "" {
+		req.Header.Set(ContentDigestHeader, digest)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotency_MultipartUsesContentDigest(t *testing.T) {
+	var calls atomic.Int32
+	r := newIdempotentRouter(func(c *gin.Context) {
+		fileHeader, err := c.FormFile("file")
+		require.NoError(t, err)
+		calls.Add(1)
+		c.JSON(http.StatusOK, gin.H{"size": fileHeader.Size})
+	})
+	large := strings.Repeat("x", maxIdempotentBody+10)
+
+	contentType, body := multipartBody(t, "first-boundary", large)
+	first := multipartRequest(r, "k1", "sha-256=:first:", contentType, body)
+	require.Equal(t, http.StatusOK, first.Code, "uploads are not limited to maxIdempotentBody")
+
+	contentType, body = multipartBody(t, "second-boundary", large)
+	replay := multipartRequest(r, "k1", "sha-256=:first:", contentType, body)
+	assert.Equal(t, http.StatusOK, replay.Code)
+	assert.Equal(t, "true", replay.Header().Get(IdempotentReplayedHeader))
+
+	contentType, body = multipartBody(t, "second-boundary", "other content")
+	assert.Equal(t, http.StatusUnprocessableEntity, multipartRequest(r, "k1", "sha-256=:other:", contentType, body).Code)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestIdempotency_RejectsLargeBodies(t *testing.T) {
+	var calls atomic.Int32
+	r := newIdempotentRouter(func(c *gin.Context) {
+		calls.Add(1)
+		c.Status(http.StatusCreated)
+	})
+
+	body := []byte(strings.Repeat("x", maxIdempotentBody+1))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, idempotentRequest(r, "/upload-jobs", "alice", "k1", "application/json", body).Code)
+	assert.Equal(t, http.StatusCreated, idempotentRequest(r, "/upload-jobs", "alice", "", "application/json", body).Code,
+		"requests without a key are not limited")
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestIdempotency_ReplaysLocation(t *testing.T) {
+	r := newIdempotentRouter(func(c *gin.Context) {
+		c.Header("Location", "/files/file-1")
+		c.JSON(http.StatusCreated, gin.H{"fileId": "file-1"})
+	})
+
+	first := idempotentRequest(r, "/upload-jobs", "alice", "k1", "application/json", nil)
+	require.Equal(t, http.StatusCreated, first.Code)
+
+	replay := idempotentRequest(r, "/upload-jobs", "alice", "k1", "application/json", nil)
+	assert.Equal(t, "true", replay.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, "/files/file-1", replay.Header().Get("Location"))
+	assert.Equal(t, first.Header().Get("Content-Type"), replay.Header().Get("Content-Type"))
+}
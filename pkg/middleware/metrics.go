@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"time"
+
+	"file-storage-go/pkg/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics records the count, duration and sizes of requests per route and
+// status. Requests that match no route are recorded as route "unmatched", so
+// that scanners probing random paths do not create new label values.
+func Metrics(metrics domain.MetricsCollector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if publicPaths[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		requestSize := c.Request.ContentLength
+		if requestSize < 0 {
+			requestSize = 0
+		}
+		responseSize := int64(c.Writer.Size())
+		if responseSize < 0 {
+			responseSize = 0
+		}
+		metrics.RecordHTTPRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start), requestSize, responseSize)
+	}
+}
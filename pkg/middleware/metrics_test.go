@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"file-storage-go/pkg/domain"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordedRequest struct {
+	method       string
+	route        string
+	status       int
+	requestSize  int64
+	responseSize int64
+}
+
+type recordingMetrics struct {
+	domain.MetricsCollector
+	requests []recordedRequest
+}
+
+func (m *recordingMetrics) RecordHTTPRequest(method, route string, status int, duration time.Duration, requestSize, responseSize int64) {
+	m.requests = append(m.requests, recordedRequest{method, route, status, requestSize, responseSize})
+}
+
+func TestMetrics(t *testing.T) {
+	metrics := &recordingMetrics{}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Metrics(metrics))
+	r.POST("/upload-jobs/:jobId", func(c *gin.Context) { c.String(http.StatusCreated, "created") })
+	r.GET("/livez", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/upload-jobs/job-1", strings.NewReader("file contents")))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/wp-admin", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/livez", nil))
+
+	assert.Equal(t, []recordedRequest{
+		{http.MethodPost, "/upload-jobs/:jobId", http.StatusCreated, 13, 7},
+		{http.MethodGet, "unmatched", http.StatusNotFound, 0, 0},
+	}, metrics.requests)
+}
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"file-storage-go/pkg/domain"
+	"file-storage-go/pkg/ecsslog"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit limits the requests of each client to a route group with a token
+// bucket per group and client. Responses carry RateLimit-Limit,
+// RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy headers, and
+// rejected requests get 429 with Retry-After. Requests are let through if
+// the limiter fails. A disabled limit lets every request through.
+func RateLimit(limiter domain.RateLimiter, group string, limit domain.RateLimit, logger *slog.Logger) gin.HandlerFunc {
+	if !limit.Enabled() {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	policy := fmt.Sprintf("%d;w=%d", limit.Requests, int(limit.Period.Seconds()))
+	return func(c *gin.Context) {
+		client := rateLimitClient(c)
+		result, err := limiter.Take(c.Request.Context(), group+":"+client, limit, time.Now())
+		if err != nil {
+			logger.LogAttrs(c.Request.Context(), slog.LevelError, "Failed to check rate limit",
+				ecsslog.Action("rate_limit.check"), slog.String("rate_limit.group", group), ecsslog.Err(err))
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", ceilSeconds(result.Reset))
+		c.Header("RateLimit-Policy", policy)
+		if !result.Allowed {
+			logger.LogAttrs(c.Request.Context(), slog.LevelWarn, "Rate limit exceeded",
+				ecsslog.Action("rate_limit.check"), slog.String("rate_limit.group", group), slog.String("rate_limit.client", client))
+			c.Header("Retry-After", ceilSeconds(result.RetryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// ConcurrencyLimit lets each client run at most maxActive requests of a route
+// group at once; further requests get 429 with Retry-After. The requests are
+// counted per replica. A maxActive of 0 lets every request through.
+func ConcurrencyLimit(group string, maxActive int, logger *slog.Logger) gin.HandlerFunc {
+	if maxActive <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	var mu sync.Mutex
+	active := make(map[string]int)
+	return func(c *gin.Context) {
+		client := rateLimitClient(c)
+
+		mu.Lock()
+		if active[client] >= maxActive {
+			mu.Unlock()
+			logger.LogAttrs(c.Request.Context(), slog.LevelWarn, "Concurrency limit exceeded",
+				ecsslog.Action("rate_limit.acquire"), slog.String("rate_limit.group", group), slog.String("rate_limit.client", client))
+			c.Header("Retry-After", "1")
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many concurrent requests"})
+			return
+		}
+		active[client]++
+		mu.Unlock()
+
+		defer func() {
+			mu.Lock()
+			active[client]--
+			if active[client] == 0 {
+				delete(active, client)
+			}
+			mu.Unlock()
+		}()
+		c.Next()
+	}
+}
+
+// rateLimitClient identifies the client of a request by its API key, its
+// user or, for unauthenticated requests, its IP address.
+func rateLimitClient(c *gin.Context) string {
+	if principal := domain.PrincipalFromContext(c.Request.Context()); principal != nil {
+		if apiKeyID, ok := principal.Claims["api_key_id"].(string); ok && apiKeyID != "" {
+			return "api_key:" + apiKeyID
+		}
+		if principal.UserID != "" {
+			return "user:" + principal.UserID
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"file-storage-go/pkg/adapters/repository"
+	"file-storage-go/pkg/domain"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingRateLimiter struct{}
+
+func (failingRateLimiter) Take(ctx context.Context, key string, limit domain.RateLimit, now time.Time) (domain.RateLimitResult, error) {
+	return domain.RateLimitResult{}, errors.New("database is down")
+}
+
+func (failingRateLimiter) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	return 0, nil
+}
+
+func serveAs(r *gin.Engine, principal *domain.Principal) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/files/file-1", nil)
+	if principal != nil {
+		req = req.WithContext(domain.ContextWithPrincipal(req.Context(), principal))
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	limit := domain.RateLimit{Requests: 2, Period: time.Minute}
+	r.GET("/files/:fileId", RateLimit(repository.NewInMemoryRateLimiter(), "read", limit, testLogger), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	alice := &domain.Principal{UserID: "alice"}
+
+	w := serveAs(r, alice)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", w.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, "2;w=60", w.Header().Get("RateLimit-Policy"))
+
+	assert.Equal(t, http.StatusOK, serveAs(r, alice).Code)
+
+	w = serveAs(r, alice)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	retryAfter := w.Header().Get("Retry-After")
+	assert.Contains(t, []string{"29", "30"}, retryAfter)
+
+	// Other clients have buckets of their own.
+	assert.Equal(t, http.StatusOK, serveAs(r, &domain.Principal{UserID: "bob"}).Code)
+	assert.Equal(t, http.StatusOK, serveAs(r, &domain.Principal{UserID: "alice", Claims: map[string]any{"api_key_id": "key-1"}}).Code)
+	assert.Equal(t, http.StatusOK, serveAs(r, nil).Code)
+}
+
+func TestRateLimit_DisabledOrFailing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/files/:fileId",
+		RateLimit(failingRateLimiter{}, "read", domain.RateLimit{}, testLogger),
+		RateLimit(failingRateLimiter{}, "write", domain.RateLimit{Requests: 1, Period: time.Second}, testLogger),
+		func(c *gin.Context) { c.Status(http.StatusOK) },
+	)
+
+	w := serveAs(r, &domain.Principal{UserID: "alice"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	r.GET("/files/:fileId", ConcurrencyLimit("download", 1, testLogger), func(c *gin.Context) {
+		if c.Query("block") != "" {
+			close(started)
+			<-release
+		}
+		c.Status(http.StatusOK)
+	})
+	alice := &domain.Principal{UserID: "alice"}
+
+	done := make(chan int)
+	go func() {
+		req := httptest.NewRequest(http.MethodGet, "/files/file-1?block=1", nil)
+		req = req.WithContext(domain.ContextWithPrincipal(req.Context(), alice))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		done <- w.Code
+	}()
+	<-started
+
+	w := serveAs(r, alice)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, serveAs(r, &domain.Principal{UserID: "bob"}).Code)
+
+	close(release)
+	require.Equal(t, http.StatusOK, <-done)
+	assert.Equal(t, http.StatusOK, serveAs(r, alice).Code)
+}
@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"file-storage-go/pkg/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReplicaReads lets the read replica serve the reads of a route. Only use it
+// on routes that tolerate replication lag.
+func ReplicaReads() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(database.WithReplicaReads(c.Request.Context()))
+		c.Next()
+	}
+}
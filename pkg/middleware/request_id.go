@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"file-storage-go/pkg/requestid"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestID adopts the client's X-Request-ID, or generates one if it is
+// missing or unusable, returns it on the response and puts it in the request
+// context for logs, audit events and outbound calls.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+
+		c.Header(requestid.Header, id)
+		c.Request = c.Request.WithContext(requestid.NewContext(c.Request.Context(), id))
+		c.Next()
+	}
+}
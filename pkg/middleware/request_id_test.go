@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"file-storage-go/pkg/requestid"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestID())
+	var seen string
+	r.GET("/", func(c *gin.Context) {
+		seen = requestid.FromContext(c.Request.Context())
+		c.Status(http.StatusOK)
+	})
+
+	serve := func(header string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if header != "" {
+			req.Header.Set(requestid.Header, header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("client-request-1")
+	assert.Equal(t, "client-request-1", w.Header().Get(requestid.Header))
+	assert.Equal(t, "client-request-1", seen)
+
+	w = serve("")
+	assert.True(t, requestid.Valid(w.Header().Get(requestid.Header)))
+	assert.Equal(t, w.Header().Get(requestid.Header), seen)
+
+	w = serve("not a valid id")
+	assert.NotEqual(t, "not a valid id", w.Header().Get(requestid.Header))
+	assert.Equal(t, w.Header().Get(requestid.Header), seen)
+}
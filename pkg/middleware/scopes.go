@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"file-storage-go/pkg/domain"
+	"file-storage-go/pkg/ecsslog"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireScopes rejects requests whose token lacks any of the given OAuth
+// scopes with 403 and an RFC 6750 insufficient_scope challenge. Without scopes
+// every request is let through.
+func RequireScopes(logger *slog.Logger, scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(scopes) == 0 {
+			c.Next()
+			return
+		}
+
+		principal := domain.PrincipalFromContext(c.Request.Context())
+		if principal == nil {
+			logAuthFailure(c, logger, "No principal found in context")
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		var missing []string
+		for _, scope := range scopes {
+			if !principal.HasScope(scope) {
+				missing = append(missing, scope)
+			}
+		}
+		if len(missing) > 0 {
+			logger.LogAttrs(c.Request.Context(), slog.LevelWarn, "Token lacks required scopes",
+				ecsslog.Action("auth.authorize"), ecsslog.UserID(principal.UserID), slog.Any("oauth.missing_scopes", missing))
+			c.Header("WWW-Authenticate", fmt.Sprintf(
+				`Bearer error="insufficient_scope", error_description="The access token is missing required scopes", scope="%s"`,
+				strings.Join(scopes, " "),
+			))
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient scope", "requiredScopes": scopes})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"file-storage-go/pkg/domain"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func serveWithPrincipal(principal *domain.Principal, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if principal != nil {
+			c.Request = c.Request.WithContext(domain.ContextWithPrincipal(c.Request.Context(), principal))
+		}
+	})
+	r.GET("/", handler, func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	return w
+}
+
+func TestRequireScopes(t *testing.T) {
+	principal := &domain.Principal{UserID: "alice", Scopes: []string{"openid", "files:read"}}
+
+	w := serveWithPrincipal(principal, RequireScopes(testLogger, "files:read"))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = serveWithPrincipal(principal, RequireScopes(testLogger, "files:read", "files:write"))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t,
+		`Bearer error="insufficient_scope", error_description="The access token is missing required scopes", scope="files:read files:write"`,
+		w.Header().Get("WWW-Authenticate"))
+
+	w = serveWithPrincipal(&domain.Principal{UserID: "bob"}, RequireScopes(testLogger))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = serveWithPrincipal(nil, RequireScopes(testLogger, "files:read"))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,192 @@
+// Package policy evaluates declarative authorization rules against the roles
+// and claims of a principal.
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"file-storage-go/pkg/domain"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rule allows the listed actions to principals holding at least one of Roles.
+// Empty lists match anything, but a rule must name Roles or a
+// LinkedResourceIDClaim. When LinkedResourceIDClaim is set, the linked
+// resource ID must be one of the values of that claim; nested claims are
+// addressed with dots, e.g. "org.companies".
+type Rule struct {
+	Name                  string              `yaml:"name"`
+	Roles                 []string            `yaml:"roles"`
+	Actions               []domain.Permission `yaml:"actions"`
+	FileTypes             []string            `yaml:"fileTypes"`
+	LinkedResourceTypes   []string            `yaml:"linkedResourceTypes"`
+	LinkedResourceIDClaim string              `yaml:"linkedResourceIdClaim"`
+}
+
+type Policy struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+type Input struct {
+	Principal          *domain.Principal
+	Action             domain.Permission
+	FileType           string
+	LinkedResourceType string
+	LinkedResourceID   string
+}
+
+type Decision struct {
+	Allowed bool
+	// Rule is the name of the first rule that allowed the action.
+	Rule string
+}
+
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse rejects unknown keys, so that a misspelt matcher cannot silently widen
+// a rule, and rules that would match every principal.
+func Parse(data []byte) (*Policy, error) {
+	var policy Policy
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&policy); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+
+	for i := range policy.Rules {
+		rule := &policy.Rules[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		if len(rule.Actions) == 0 {
+			return nil, fmt.Errorf("rule %s: at least one action is required", rule.Name)
+		}
+		for _, action := range rule.Actions {
+			switch action {
+			case domain.PermissionRead, domain.PermissionDelete, domain.PermissionUpload:
+			default:
+				return nil, fmt.Errorf("rule %s: unknown action %q", rule.Name, action)
+			}
+		}
+		if len(rule.Roles) == 0 && rule.LinkedResourceIDClaim == "" {
+			return nil, fmt.Errorf("rule %s: roles or linkedResourceIdClaim is required", rule.Name)
+		}
+		for field, values := range map[string][]string{"roles": rule.Roles, "fileTypes": rule.FileTypes, "linkedResourceTypes": rule.LinkedResourceTypes} {
+			if slices.Contains(values, "") {
+				return nil, fmt.Errorf("rule %s: empty entry in %s", rule.Name, field)
+			}
+		}
+	}
+
+	return &policy, nil
+}
+
+// Evaluate returns the decision of the first matching rule. Without a matching
+// rule the action is denied.
+func (p *Policy) Evaluate(input Input) Decision {
+	if input.Principal == nil {
+		return Decision{}
+	}
+
+	for _, rule := range p.Rules {
+		if rule.matches(input) {
+			return Decision{Allowed: true, Rule: rule.Name}
+		}
+	}
+	return Decision{}
+}
+
+func (r *Rule) matches(input Input) bool {
+	if !containsOrEmpty(r.Actions, input.Action) ||
+		!containsOrEmpty(r.FileTypes, input.FileType) ||
+		!containsOrEmpty(r.LinkedResourceTypes, input.LinkedResourceType) {
+		return false
+	}
+
+	if len(r.Roles) > 0 {
+		hasRole := false
+		for _, role := range r.Roles {
+			if input.Principal.HasRole(role) {
+				hasRole = true
+				break
+			}
+		}
+		if !hasRole {
+			return false
+		}
+	}
+
+	if r.LinkedResourceIDClaim != "" {
+		return claimContains(lookupClaim(input.Principal.Claims, r.LinkedResourceIDClaim), input.LinkedResourceID)
+	}
+	return true
+}
+
+func containsOrEmpty[T comparable](values []T, value T) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func lookupClaim(claims map[string]any, path string) any {
+	var value any = claims
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
+
+func claimContains(claim any, value string) bool {
+	if value == "" {
+		return false
+	}
+
+	switch v := claim.(type) {
+	case []any:
+		for _, item := range v {
+			if claimContains(item, value) {
+				return true
+			}
+		}
+		return false
+	case []string:
+		for _, item := range v {
+			if item == value {
+				return true
+			}
+		}
+		return false
+	case string:
+		return v == value
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64) == value
+	case int:
+		return strconv.Itoa(v) == value
+	default:
+		return false
+	}
+}
@@ -0,0 +1,125 @@
+package policy
+
+import (
+	"testing"
+
+	"file-storage-go/pkg/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPolicy = `
+rules:
+  - name: accountants-upload-invoices
+    roles: [accountant]
+    actions: [upload, read]
+    fileTypes: [invoice]
+    linkedResourceTypes: [company]
+    linkedResourceIdClaim: companies
+  - name: auditors-read-nested
+    roles: [auditor]
+    actions: [read]
+    linkedResourceIdClaim: org.companies
+  - name: file-admins
+    roles: [file-admin]
+    actions: [read, delete]
+`
+
+func TestEvaluate(t *testing.T) {
+	policy, err := Parse([]byte(testPolicy))
+	require.NoError(t, err)
+
+	accountant := &domain.Principal{
+		UserID: "alice",
+		Roles:  []string{"accountant"},
+		Claims: map[string]any{"companies": []any{"3", float64(7)}},
+	}
+	auditor := &domain.Principal{
+		UserID: "bob",
+		Roles:  []string{"auditor"},
+		Claims: map[string]any{"org": map[string]any{"companies": "3"}},
+	}
+	admin := &domain.Principal{UserID: "carol", Roles: []string{"file-admin"}}
+
+	tests := []struct {
+		name     string
+		input    Input
+		expected Decision
+	}{
+		{
+			name:     "upload to a company from the claim",
+			input:    Input{Principal: accountant, Action: domain.PermissionUpload, FileType: "invoice", LinkedResourceType: "company", LinkedResourceID: "3"},
+			expected: Decision{Allowed: true, Rule: "accountants-upload-invoices"},
+		},
+		{
+			name:     "numeric claim values match",
+			input:    Input{Principal: accountant, Action: domain.PermissionUpload, FileType: "invoice", LinkedResourceType: "company", LinkedResourceID: "7"},
+			expected: Decision{Allowed: true, Rule: "accountants-upload-invoices"},
+		},
+		{
+			name:  "company not in claim",
+			input: Input{Principal: accountant, Action: domain.PermissionUpload, FileType: "invoice", LinkedResourceType: "company", LinkedResourceID: "9"},
+		},
+		{
+			name:  "file type not allowed",
+			input: Input{Principal: accountant, Action: domain.PermissionUpload, FileType: "contract", LinkedResourceType: "company", LinkedResourceID: "3"},
+		},
+		{
+			name:  "action not allowed",
+			input: Input{Principal: accountant, Action: domain.PermissionDelete, FileType: "invoice", LinkedResourceType: "company", LinkedResourceID: "3"},
+		},
+		{
+			name:     "nested claim",
+			input:    Input{Principal: auditor, Action: domain.PermissionRead, FileType: "contract", LinkedResourceType: "company", LinkedResourceID: "3"},
+			expected: Decision{Allowed: true, Rule: "auditors-read-nested"},
+		},
+		{
+			name:     "role without conditions",
+			input:    Input{Principal: admin, Action: domain.PermissionDelete, FileType: "contract", LinkedResourceType: "project", LinkedResourceID: "1"},
+			expected: Decision{Allowed: true, Rule: "file-admins"},
+		},
+		{
+			name:  "missing role",
+			input: Input{Principal: &domain.Principal{UserID: "dave"}, Action: domain.PermissionRead, FileType: "invoice", LinkedResourceType: "company", LinkedResourceID: "3"},
+		},
+		{
+			name:  "no principal",
+			input: Input{Action: domain.PermissionRead},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, policy.Evaluate(tt.input))
+		})
+	}
+}
+
+func TestParse_Validation(t *testing.T) {
+	_, err := Parse([]byte("rules:\n  - roles: [x]\n"))
+	assert.ErrorContains(t, err, "at least one action")
+
+	_, err = Parse([]byte("rules:\n  - actions: [write]\n"))
+	assert.ErrorContains(t, err, `unknown action "write"`)
+
+	_, err = Parse([]byte("rules:\n  - actions: [read]\n"))
+	assert.ErrorContains(t, err, "roles or linkedResourceIdClaim is required")
+
+	_, err = Parse([]byte("rules:\n  - actions: [read]\n    roles: [\"\"]\n"))
+	assert.ErrorContains(t, err, "empty entry in roles")
+
+	_, err = Parse([]byte("rules:\n  - actions: [read]\n    roles: [x]\n    condtions: [y]\n"))
+	assert.ErrorContains(t, err, "field condtions not found")
+
+	_, err = Parse([]byte("rules:\n  - action: [read]\n    roles: [x]\n"))
+	assert.ErrorContains(t, err, "field action not found")
+
+	policy, err := Parse([]byte("rules:\n  - actions: [read]\n    roles: [x]\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "rule-1", policy.Rules[0].Name)
+
+	policy, err = Parse(nil)
+	require.NoError(t, err)
+	assert.Empty(t, policy.Rules)
+}
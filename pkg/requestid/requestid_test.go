@@ -0,0 +1,53 @@
+package requestid
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValid(t *testing.T) {
+	assert.True(t, Valid(New()))
+	assert.True(t, Valid("req_01HX:web-1.2"))
+	assert.False(t, Valid(""))
+	assert.False(t, Valid(strings.Repeat("a", 129)))
+	assert.False(t, Valid("id with spaces"))
+	assert.False(t, Valid("id\nforged: log line"))
+}
+
+func TestSetHeader(t *testing.T) {
+	req, err := http.NewRequestWithContext(NewContext(context.Background(), "req-1"), http.MethodPost, "http://scanner", nil)
+	require.NoError(t, err)
+	SetHeader(req)
+	assert.Equal(t, "req-1", req.Header.Get(Header))
+
+	req, err = http.NewRequest(http.MethodPost, "http://scanner", nil)
+	require.NoError(t, err)
+	SetHeader(req)
+	assert.Empty(t, req.Header.Values(Header))
+}
+
+func TestLogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewLogHandler(slog.NewJSONHandler(&buf, nil))).With("service", "file-storage")
+
+	logger.InfoContext(NewContext(context.Background(), "req-1"), "scanned")
+	logger.Info("polled")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var scanned, polled map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &scanned))
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &polled))
+	assert.Equal(t, "req-1", scanned[LogKey])
+	assert.Equal(t, "file-storage", scanned["service"])
+	assert.NotContains(t, polled, LogKey)
+}
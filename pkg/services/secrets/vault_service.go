@@ -25,6 +25,17 @@ type VaultService struct {
 }
 
 func NewVaultService(address, roleID, secretID string) (*VaultService, error) {
+	client, err := NewAppRoleVaultClient(address, roleID, secretID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &VaultService{
+		client: client,
+	}, nil
+}
+
+func NewAppRoleVaultClient(address, roleID, secretID string) (*vault.VaultClient, error) {
 	token, err := getAppRoleToken(address, roleID, secretID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get app role token: %w", err)
@@ -35,9 +46,7 @@ func NewVaultService(address, roleID, secretID string) (*VaultService, error) {
 		return nil, fmt.Errorf("failed to create vault client: %w", err)
 	}
 
-	return &VaultService{
-		client: client,
-	}, nil
+	return client, nil
 }
 
 func getAppRoleToken(address, roleID, secretID string) (string, error) {
@@ -0,0 +1,62 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSetup(t *testing.T) {
+	for _, exporter := range []string{ExporterNone, ExporterStdout} {
+		shutdown, err := Setup(context.Background(), Config{Exporter: exporter, ServiceName: "file-storage", SampleRatio: 1})
+		require.NoError(t, err, exporter)
+		require.NoError(t, shutdown(context.Background()), exporter)
+	}
+
+	_, err := Setup(context.Background(), Config{Exporter: "jaeger"})
+	assert.Error(t, err)
+}
+
+func TestTraceParentLinksBack(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	assert.Empty(t, TraceParent(context.Background()))
+	_, ok := LinkFromTraceParent("")
+	assert.False(t, ok)
+	_, ok = LinkFromTraceParent("not a traceparent")
+	assert.False(t, ok)
+
+	ctx, upload := tracer.Start(context.Background(), "upload")
+	traceParent := TraceParent(ctx)
+	upload.End()
+	require.NotEmpty(t, traceParent)
+
+	link, ok := LinkFromTraceParent(traceParent)
+	require.True(t, ok)
+	assert.Equal(t, upload.SpanContext().TraceID(), link.SpanContext.TraceID())
+	assert.Equal(t, upload.SpanContext().SpanID(), link.SpanContext.SpanID())
+}
+
+func TestEnd(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	_, ok := tracer.Start(context.Background(), "ok")
+	End(ok, nil)
+	_, failed := tracer.Start(context.Background(), "failed")
+	End(failed, errors.New("blob not found"))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+	assert.Equal(t, "blob not found", spans[1].Status().Description)
+	require.Len(t, spans[1].Events(), 1)
+}
@@ -22,6 +22,14 @@ curl -s -X POST http://keycloak:8080/admin/realms \
         "enabled": true
     }'
 
+for SCOPE in files:read files:write files:admin files:audit; do
+    echo "Creating client scope $SCOPE..."
+    curl -s -X POST http://keycloak:8080/admin/realms/file-storage/client-scopes \
+        -H "Authorization: Bearer $ADMIN_TOKEN" \
+        -H "Content-Type: application/json" \
+        -d "{\"name\": \"$SCOPE\", \"protocol\": \"openid-connect\", \"attributes\": {\"include.in.token.scope\": \"true\"}}"
+done
+
 echo "Creating client..."
 curl -s -X POST http://keycloak:8080/admin/realms/file-storage/clients \
     -H "Authorization: Bearer $ADMIN_TOKEN" \
@@ -34,7 +42,15 @@ curl -s -X POST http://keycloak:8080/admin/realms/file-storage/clients \
         "publicClient": false,
         "standardFlowEnabled": false,
         "directAccessGrantsEnabled": false,
-        "serviceAccountsEnabled": true
+        "serviceAccountsEnabled": true,
+        "defaultClientScopes": ["profile", "email", "roles", "web-origins", "files:read", "files:write"],
+        "optionalClientScopes": ["files:admin", "files:audit"],
+        "protocolMappers": [{
+            "name": "file-storage-audience",
+            "protocol": "openid-connect",
+            "protocolMapper": "oidc-audience-mapper",
+            "config": {"included.client.audience": "file-storage", "access.token.claim": "true"}
+        }]
     }'
 
 echo "Keycloak initialization completed" 
\ No newline at end of file
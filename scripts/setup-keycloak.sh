@@ -76,6 +76,24 @@ if [ "$HTTP_CODE" != "201" ]; then
     exit 1
 fi
 
+# Create the client scopes the API requires per route
+for SCOPE in files:read files:write files:admin files:audit; do
+    echo "Creating client scope $SCOPE..."
+    SCOPE_RESPONSE=$(curl -s -w "\n%{http_code}" -X POST http://${KEYCLOAK_HOST}:${KEYCLOAK_PORT}/admin/realms/file-storage/client-scopes \
+        -H "Authorization: Bearer $ADMIN_TOKEN" \
+        -H "Content-Type: application/json" \
+        -d "{
+            \"name\": \"$SCOPE\",
+            \"protocol\": \"openid-connect\",
+            \"attributes\": {\"include.in.token.scope\": \"true\"}
+        }")
+    HTTP_CODE=$(echo "$SCOPE_RESPONSE" | tail -n1)
+    if [ "$HTTP_CODE" != "201" ]; then
+        echo "Failed to create client scope $SCOPE: $SCOPE_RESPONSE"
+        exit 1
+    fi
+done
+
 # Create client
 echo "Creating client..."
 CLIENT_RESPONSE=$(curl -s -w "\n%{http_code}" -X POST http://${KEYCLOAK_HOST}:${KEYCLOAK_PORT}/admin/realms/file-storage/clients \
@@ -89,7 +107,15 @@ CLIENT_RESPONSE=$(curl -s -w "\n%{http_code}" -X POST http://${KEYCLOAK_HOST}:${
         "publicClient": false,
         "standardFlowEnabled": true,
         "directAccessGrantsEnabled": true,
-        "serviceAccountsEnabled": true
+        "serviceAccountsEnabled": true,
+        "defaultClientScopes": ["profile", "email", "roles", "web-origins", "files:read", "files:write"],
+        "optionalClientScopes": ["files:admin", "files:audit"],
+        "protocolMappers": [{
+            "name": "file-storage-audience",
+            "protocol": "openid-connect",
+            "protocolMapper": "oidc-audience-mapper",
+            "config": {"included.client.audience": "file-storage", "access.token.claim": "true"}
+        }]
     }')
 HTTP_CODE=$(echo "$CLIENT_RESPONSE" | tail -n1)
 if [ "$HTTP_CODE" != "201" ]; then